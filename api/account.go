@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"errors"
 	"net/http"
+	"time"
 
 	db "github.com/amrizal94/simplebank/db/sqlc"
 	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 )
@@ -68,7 +70,7 @@ func (server *Server) getAccount(ctx *gin.Context) {
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if account.Owner != authPayload.Username {
+	if !canReadAccount(ctx, authPayload, account) {
 		err := errors.New("account doesn't belong to the authenticated user")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
@@ -78,8 +80,8 @@ func (server *Server) getAccount(ctx *gin.Context) {
 }
 
 type listAccountRequest struct {
-	PageID   int32 `form:"page_id" binding:"required,min=1"`
-	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+	pageRequest
+	Owner string `form:"owner" binding:"omitempty,alphanum"`
 }
 
 func (server *Server) listAccount(ctx *gin.Context) {
@@ -90,17 +92,79 @@ func (server *Server) listAccount(ctx *gin.Context) {
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	arg := db.ListAccountsParams{
-		Owner:  authPayload.Username,
-		Limit:  req.PageSize,
-		Offset: (req.PageID - 1) * req.PageSize,
+	owner := authPayload.Username
+	if len(req.Owner) > 0 && req.Owner != owner {
+		if !hasRole(ctx, util.BankerRole, util.AdminRole) {
+			err := errors.New("only bankers can list accounts of other users")
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		owner = req.Owner
+	}
+
+	list := "accounts:" + owner
+	page, err := server.paginator.page(list, req.pageRequest)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
 	}
 
-	accounts, err := server.store.ListAccounts(ctx, arg)
+	var accounts []db.Account
+	if page.reverse() {
+		accounts, err = server.store.ListAccountsReverse(ctx, db.ListAccountsReverseParams{
+			Owner:           owner,
+			CursorCreatedAt: page.cursor.CreatedAt,
+			CursorID:        page.cursor.ID,
+			Limit:           page.limit(),
+		})
+	} else {
+		accounts, err = server.store.ListAccounts(ctx, db.ListAccountsParams{
+			Owner:           owner,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			Limit:           page.limit(),
+		})
+	}
 	if err != nil {
 		ctx.JSON(500, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(200, gin.H{"accounts": accounts})
+	accounts, links := finishPage(server.paginator, list, page, accounts, func(account db.Account) (time.Time, int64) {
+		return account.CreatedAt, account.ID
+	})
+	ctx.JSON(200, pageResponse("accounts", accounts, links))
+}
+
+// canReadAccount reports whether the authenticated user may read the account.
+// Depositors can only read their own accounts, bankers and admins can read any account.
+func canReadAccount(ctx *gin.Context, payload *token.Payload, account db.Account) bool {
+	return account.Owner == payload.Username || hasRole(ctx, util.BankerRole, util.AdminRole)
+}
+
+// setAccountFrozen returns a handler that freezes or unfreezes an account.
+// A frozen account can neither send nor receive transfers.
+func (server *Server) setAccountFrozen(frozen bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req getAccountRequest
+		if err := ctx.ShouldBindUri(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		account, err := server.store.UpdateAccountFrozen(ctx, db.UpdateAccountFrozenParams{
+			ID:       req.ID,
+			IsFrozen: frozen,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"account": account})
+	}
 }
@@ -40,7 +40,7 @@ func TestCreateAccount(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -85,7 +85,7 @@ func TestCreateAccount(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -112,7 +112,7 @@ func TestCreateAccount(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -132,7 +132,7 @@ func TestCreateAccount(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -159,6 +159,7 @@ func TestCreateAccount(t *testing.T) {
 
 			store := mockdb.NewMockStore(ctrl)
 			tC.buildStubs(store)
+			buildAuthStubs(store)
 
 			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
@@ -195,7 +196,7 @@ func TestGetAccountAPI(t *testing.T) {
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -214,7 +215,7 @@ func TestGetAccountAPI(t *testing.T) {
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, "Unauthorized", time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, "Unauthorized", util.DepositorRole, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -227,6 +228,25 @@ func TestGetAccountAPI(t *testing.T) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
 		},
+		{
+			desc:      "BankerReadsAnyAccount",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
 		{
 			desc:      "NoAuthorization",
 			accountID: account.ID,
@@ -246,7 +266,7 @@ func TestGetAccountAPI(t *testing.T) {
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -264,7 +284,7 @@ func TestGetAccountAPI(t *testing.T) {
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -281,7 +301,7 @@ func TestGetAccountAPI(t *testing.T) {
 			accountID: 0,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -302,6 +322,7 @@ func TestGetAccountAPI(t *testing.T) {
 			store := mockdb.NewMockStore(ctlr)
 			// build stub
 			tC.buildStubs(store)
+			buildAuthStubs(store)
 
 			// start test server and send request
 			server := newTestServer(t, store)
@@ -329,10 +350,13 @@ func TestListAccount(t *testing.T) {
 	accounts := make([]db.Account, n)
 	for i := 0; i < n; i++ {
 		accounts[i] = randomAccount(user.Username)
+		accounts[i].ID = int64(i + 1)
+		accounts[i].CreatedAt = time.Date(2023, 1, 1, i, 0, 0, 0, time.UTC)
 	}
 
 	type Query struct {
-		pageID   int
+		owner    string
+		cursor   func(p *paginator) string
 		pageSize int
 	}
 
@@ -346,18 +370,16 @@ func TestListAccount(t *testing.T) {
 		{
 			desc: "OK",
 			query: Query{
-				pageID:   1,
 				pageSize: n},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.ListAccountsParams{
-					Owner:  user.Username,
-					Limit:  int32(n),
-					Offset: 0,
+					Owner: user.Username,
+					Limit: int32(n + 1),
 				}
 
 				store.EXPECT().
@@ -368,23 +390,171 @@ func TestListAccount(t *testing.T) {
 			checkResponse: func(recoder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recoder.Code)
 				requiredBodyMatchAccounts(t, recoder.Body, accounts)
+
+				links := requireBodyPageLinks(t, recoder.Body)
+				require.Empty(t, links.NextCursor)
+				require.Empty(t, links.PrevCursor)
+			},
+		},
+		{
+			desc:  "DefaultPageSize",
+			query: Query{},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsParams{
+					Owner: user.Username,
+					Limit: 21,
+				}
+
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(accounts, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				requiredBodyMatchAccounts(t, recoder.Body, accounts)
+			},
+		},
+		{
+			desc: "NextPage",
+			query: Query{
+				pageSize: n - 2},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsParams{
+					Owner: user.Username,
+					Limit: int32(n - 1),
+				}
+
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(accounts[:n-1], nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				requiredBodyMatchAccounts(t, recoder.Body, accounts[:n-2])
+
+				links := requireBodyPageLinks(t, recoder.Body)
+				require.NotEmpty(t, links.NextCursor)
+				require.Empty(t, links.PrevCursor)
+			},
+		},
+		{
+			desc: "WithCursor",
+			query: Query{
+				cursor: func(p *paginator) string {
+					return p.cursor("accounts:"+user.Username, pageCursor{CreatedAt: accounts[1].CreatedAt, ID: accounts[1].ID})
+				},
+				pageSize: n},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ListAccountsParams) ([]db.Account, error) {
+						require.Equal(t, user.Username, arg.Owner)
+						require.True(t, arg.CursorCreatedAt.Time.Equal(accounts[1].CreatedAt))
+						require.Equal(t, sql.NullInt64{Int64: accounts[1].ID, Valid: true}, arg.CursorID)
+						require.Equal(t, int32(n+1), arg.Limit)
+						return accounts[2:], nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				requiredBodyMatchAccounts(t, recoder.Body, accounts[2:])
+
+				links := requireBodyPageLinks(t, recoder.Body)
+				require.Empty(t, links.NextCursor)
+				require.NotEmpty(t, links.PrevCursor)
+			},
+		},
+		{
+			desc: "WithReverseCursor",
+			query: Query{
+				cursor: func(p *paginator) string {
+					return p.cursor("accounts:"+user.Username, pageCursor{CreatedAt: accounts[3].CreatedAt, ID: accounts[3].ID, Reverse: true})
+				},
+				pageSize: 2},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsReverseParams{
+					Owner:           user.Username,
+					CursorCreatedAt: accounts[3].CreatedAt,
+					CursorID:        accounts[3].ID,
+					Limit:           3,
+				}
+
+				store.EXPECT().
+					ListAccountsReverse(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, got db.ListAccountsReverseParams) ([]db.Account, error) {
+						require.True(t, got.CursorCreatedAt.Equal(arg.CursorCreatedAt))
+						got.CursorCreatedAt = arg.CursorCreatedAt
+						require.Equal(t, arg, got)
+						return []db.Account{accounts[2], accounts[1], accounts[0]}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				requiredBodyMatchAccounts(t, recoder.Body, accounts[1:3])
+
+				links := requireBodyPageLinks(t, recoder.Body)
+				require.NotEmpty(t, links.NextCursor)
+				require.NotEmpty(t, links.PrevCursor)
+			},
+		},
+		{
+			desc: "CursorOfOtherOwner",
+			query: Query{
+				cursor: func(p *paginator) string {
+					return p.cursor("accounts:other", pageCursor{CreatedAt: accounts[1].CreatedAt, ID: accounts[1].ID})
+				},
+				pageSize: n},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
 			},
 		},
 		{
 			desc: "InternalError",
 			query: Query{
-				pageID:   1,
 				pageSize: n},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.ListAccountsParams{
-					Owner:  user.Username,
-					Limit:  int32(n),
-					Offset: 0,
+					Owner: user.Username,
+					Limit: int32(n + 1),
 				}
 
 				store.EXPECT().
@@ -397,13 +567,76 @@ func TestListAccount(t *testing.T) {
 			},
 		},
 		{
-			desc: "InvalidParameters",
+			desc: "BankerListsOtherOwner",
 			query: Query{
-				pageID:   0,
+				owner:    user.Username,
 				pageSize: n},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsParams{
+					Owner: user.Username,
+					Limit: int32(n + 1),
+				}
+
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(accounts, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				requiredBodyMatchAccounts(t, recoder.Body, accounts)
+			},
+		},
+		{
+			desc: "DepositorListsOtherOwner",
+			query: Query{
+				owner:    user.Username,
+				pageSize: n},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "other", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc: "PageSizeTooLarge",
+			query: Query{
+				pageSize: 101},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "InvalidPageSize",
+			query: Query{
+				pageSize: -1},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -425,6 +658,7 @@ func TestListAccount(t *testing.T) {
 
 			store := mockdb.NewMockStore(ctrl)
 			tC.buildStubs(store)
+			buildAuthStubs(store)
 
 			// start test server and send request
 			server := newTestServer(t, store)
@@ -436,8 +670,15 @@ func TestListAccount(t *testing.T) {
 
 			// Add Query string parameters to request
 			q := request.URL.Query()
-			q.Add("page_id", strconv.Itoa(tC.query.pageID))
-			q.Add("page_size", strconv.Itoa(tC.query.pageSize))
+			if len(tC.query.owner) > 0 {
+				q.Add("owner", tC.query.owner)
+			}
+			if tC.query.cursor != nil {
+				q.Add("cursor", tC.query.cursor(server.paginator))
+			}
+			if tC.query.pageSize != 0 {
+				q.Add("page_size", strconv.Itoa(tC.query.pageSize))
+			}
 			request.URL.RawQuery = q.Encode()
 
 			tC.setupAuth(t, request, server.tokenMaker)
@@ -448,6 +689,125 @@ func TestListAccount(t *testing.T) {
 	}
 }
 
+func TestSetAccountFrozenAPI(t *testing.T) {
+	admin, _ := randomUser()
+	admin.Role = util.AdminRole
+
+	user, _ := randomUser()
+	account := randomAccount(user.Username)
+
+	testCases := []struct {
+		desc          string
+		action        string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc:   "Freeze",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				frozenAccount := account
+				frozenAccount.IsFrozen = true
+
+				store.EXPECT().
+					UpdateAccountFrozen(gomock.Any(), gomock.Eq(db.UpdateAccountFrozenParams{
+						ID:       account.ID,
+						IsFrozen: true,
+					})).
+					Times(1).
+					Return(frozenAccount, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc:   "Unfreeze",
+			action: "unfreeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateAccountFrozen(gomock.Any(), gomock.Eq(db.UpdateAccountFrozenParams{
+						ID:       account.ID,
+						IsFrozen: false,
+					})).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				requireBodyMatchAccount(t, recoder.Body, account)
+			},
+		},
+		{
+			desc:   "BankerForbidden",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateAccountFrozen(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc:   "NotFound",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateAccountFrozen(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Account{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/admin/accounts/%d/%s", account.ID, tC.action)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
 func randomAccount(owner string) db.Account {
 	return db.Account{
 		ID:       util.RandomInt(1, 1000),
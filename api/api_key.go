@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// apiKeyLastUsedResolution limits how often last_used_at is written for a busy key
+const apiKeyLastUsedResolution = time.Minute
+
+var (
+	errInvalidAPIKey = errors.New("api key is invalid")
+	errExpiredAPIKey = errors.New("api key is expired")
+	errRevokedAPIKey = errors.New("api key has been revoked")
+)
+
+type apiKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(apiKey db.ApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         apiKey.ID,
+		Prefix:     apiKey.Prefix,
+		Owner:      apiKey.Owner,
+		Scopes:     apiKey.Scopes,
+		LastUsedAt: nullTimePtr(apiKey.LastUsedAt),
+		ExpiresAt:  nullTimePtr(apiKey.ExpiresAt),
+		RevokedAt:  nullTimePtr(apiKey.RevokedAt),
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+type createAPIKeyRequest struct {
+	Scopes    []string   `json:"scopes" binding:"omitempty,dive,scope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type createAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey apiKeyResponse `json:"api_key"`
+}
+
+// createAPIKey issues a new API key for the authenticated user.
+// The key itself is only returned once, the database keeps its hash.
+func (server *Server) createAPIKey(ctx *gin.Context) {
+	var req createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		err := errors.New("expires_at must be in the future")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// a key can't be granted more than the token creating it
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = authPayload.Scopes
+	}
+	for _, scope := range scopes {
+		if !authPayload.HasScope(scope) {
+			err := fmt.Errorf("token is missing the %q scope", scope)
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+	}
+
+	prefix, key, err := util.GenerateAPIKey()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	arg := db.CreateAPIKeyParams{
+		ID:        uuid.New(),
+		Prefix:    prefix,
+		HashedKey: util.HashAPIKey(key),
+		Owner:     authPayload.Username,
+		Scopes:    scopes,
+	}
+	if req.ExpiresAt != nil {
+		arg.ExpiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	apiKey, err := server.store.CreateAPIKey(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := createAPIKeyResponse{
+		Key:    key,
+		APIKey: newAPIKeyResponse(apiKey),
+	}
+	ctx.JSON(http.StatusCreated, rsp)
+}
+
+func (server *Server) listAPIKeys(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	apiKeys, err := server.store.ListAPIKeys(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]apiKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		rsp = append(rsp, newAPIKeyResponse(apiKey))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"api_keys": rsp})
+}
+
+type revokeAPIKeyRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+func (server *Server) revokeAPIKey(ctx *gin.Context) {
+	var req revokeAPIKeyRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	apiKey, err := server.store.RevokeAPIKey(ctx, db.RevokeAPIKeyParams{
+		ID:    uuid.MustParse(req.ID),
+		Owner: authPayload.Username,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"api_key": newAPIKeyResponse(apiKey)})
+}
+
+// verifyAPIKey checks an API key and builds the payload its owner would get from a token.
+// ExpiredAt is left zero for keys that never expire.
+func (server *Server) verifyAPIKey(ctx context.Context, key string) (*token.Payload, error) {
+	prefix, ok := util.APIKeyPrefix(key)
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+
+	apiKey, err := server.store.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if !util.CheckAPIKey(key, apiKey.HashedKey) {
+		return nil, errInvalidAPIKey
+	}
+
+	if apiKey.RevokedAt.Valid {
+		return nil, errRevokedAPIKey
+	}
+
+	now := time.Now()
+	if apiKey.ExpiresAt.Valid && now.After(apiKey.ExpiresAt.Time) {
+		return nil, errExpiredAPIKey
+	}
+
+	if apiKey.IsFrozen {
+		return nil, errFrozenUser
+	}
+
+	if !apiKey.LastUsedAt.Valid || now.Sub(apiKey.LastUsedAt.Time) > apiKeyLastUsedResolution {
+		// best effort, a failed bookkeeping write shouldn't fail the request
+		_ = server.store.UpdateAPIKeyLastUsed(ctx, apiKey.ID)
+	}
+
+	payload := &token.Payload{
+		ID:       apiKey.ID,
+		Type:     token.AccessToken,
+		Username: apiKey.Owner,
+		Role:     apiKey.Role,
+		Scopes:   apiKey.Scopes,
+		IssuedAt: apiKey.CreatedAt,
+	}
+	if apiKey.ExpiresAt.Valid {
+		payload.ExpiredAt = apiKey.ExpiresAt.Time
+	}
+
+	return payload, nil
+}
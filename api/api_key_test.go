@@ -0,0 +1,567 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	user, _ := randomUser()
+	apiKey, key := randomAPIKey(t, user.Username)
+
+	keyRow := func(apiKey db.ApiKey) db.GetAPIKeyByPrefixRow {
+		return db.GetAPIKeyByPrefixRow{
+			ID:         apiKey.ID,
+			Prefix:     apiKey.Prefix,
+			HashedKey:  apiKey.HashedKey,
+			Owner:      apiKey.Owner,
+			Scopes:     apiKey.Scopes,
+			LastUsedAt: apiKey.LastUsedAt,
+			ExpiresAt:  apiKey.ExpiresAt,
+			RevokedAt:  apiKey.RevokedAt,
+			CreatedAt:  apiKey.CreatedAt,
+			Role:       user.Role,
+		}
+	}
+
+	testCases := []struct {
+		desc          string
+		key           string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(keyRow(apiKey), nil)
+				store.EXPECT().
+					UpdateAPIKeyLastUsed(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var payload token.Payload
+				err := json.Unmarshal(recorder.Body.Bytes(), &payload)
+				require.NoError(t, err)
+				require.Equal(t, apiKey.ID, payload.ID)
+				require.Equal(t, user.Username, payload.Username)
+				require.Equal(t, user.Role, payload.Role)
+				require.Equal(t, apiKey.Scopes, payload.Scopes)
+			},
+		},
+		{
+			desc: "RecentlyUsed",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				recentlyUsed := apiKey
+				recentlyUsed.LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(keyRow(recentlyUsed), nil)
+				store.EXPECT().
+					UpdateAPIKeyLastUsed(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			desc: "MalformedKey",
+			key:  "malformed",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "UnknownKey",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(db.GetAPIKeyByPrefixRow{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "WrongSecret",
+			key:  apiKey.Prefix + ".wrongsecret",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(keyRow(apiKey), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "RevokedKey",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				revoked := apiKey
+				revoked.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(keyRow(revoked), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "ExpiredKey",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				expired := apiKey
+				expired.ExpiresAt = sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}
+
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(keyRow(expired), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "FrozenOwner",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				row := keyRow(apiKey)
+				row.IsFrozen = true
+
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(row, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "InternalError",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(db.GetAPIKeyByPrefixRow{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+
+			server := newTestServer(t, store)
+
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				server.authMiddleware(),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, ctx.MustGet(authorizationPayloadKey))
+				})
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			request.Header.Set(authorizationHeaderKey, fmt.Sprintf("ApiKey %s", tC.key))
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser()
+
+	testCases := []struct {
+		desc          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			body: gin.H{
+				"scopes": []string{util.AccountsReadScope},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+						require.Equal(t, user.Username, arg.Owner)
+						require.Equal(t, []string{util.AccountsReadScope}, arg.Scopes)
+						require.NotEmpty(t, arg.Prefix)
+						require.NotEmpty(t, arg.HashedKey)
+						require.False(t, arg.ExpiresAt.Valid)
+						return db.ApiKey{
+							ID:        arg.ID,
+							Prefix:    arg.Prefix,
+							HashedKey: arg.HashedKey,
+							Owner:     arg.Owner,
+							Scopes:    arg.Scopes,
+							CreatedAt: time.Now(),
+						}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recoder.Code)
+
+				var rsp createAPIKeyResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+
+				prefix, ok := util.APIKeyPrefix(rsp.Key)
+				require.True(t, ok)
+				require.Equal(t, rsp.APIKey.Prefix, prefix)
+				require.NotContains(t, recoder.Body.String(), "hashed_key")
+			},
+		},
+		{
+			desc: "DefaultsToTokenScopes",
+			body: gin.H{},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addScopedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role,
+					[]string{util.AccountsReadScope, util.TransfersReadScope, util.UsersWriteScope}, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+						require.Equal(t, []string{util.AccountsReadScope, util.TransfersReadScope, util.UsersWriteScope}, arg.Scopes)
+						return db.ApiKey{ID: arg.ID, Prefix: arg.Prefix, Owner: arg.Owner, Scopes: arg.Scopes}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recoder.Code)
+			},
+		},
+		{
+			desc: "ScopeNotGranted",
+			body: gin.H{
+				"scopes": []string{util.AccountsReadScope, util.TransfersWriteScope},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addScopedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role,
+					[]string{util.AccountsReadScope, util.TransfersReadScope, util.UsersWriteScope}, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc: "MissingUsersWriteScope",
+			body: gin.H{
+				"scopes": []string{util.AccountsReadScope},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addScopedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role,
+					[]string{util.AccountsReadScope}, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc: "InvalidScope",
+			body: gin.H{
+				"scopes": []string{"everything"},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "ExpiresInThePast",
+			body: gin.H{
+				"expires_at": time.Now().Add(-time.Hour),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "NoAuthorization",
+			body: gin.H{},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "InternalError",
+			body: gin.H{},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tC.body)
+			require.NoError(t, err)
+
+			url := "/api_keys"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListAPIKeysAPI(t *testing.T) {
+	user, _ := randomUser()
+	apiKey, _ := randomAPIKey(t, user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListAPIKeys(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return([]db.ApiKey{apiKey}, nil)
+	buildAuthStubs(store)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/api_keys", nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp struct {
+		APIKeys []apiKeyResponse `json:"api_keys"`
+	}
+	err = json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	require.Len(t, rsp.APIKeys, 1)
+	require.Equal(t, apiKey.ID, rsp.APIKeys[0].ID)
+	require.Equal(t, apiKey.Prefix, rsp.APIKeys[0].Prefix)
+	require.Nil(t, rsp.APIKeys[0].RevokedAt)
+}
+
+func TestRevokeAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser()
+	apiKey, key := randomAPIKey(t, user.Username)
+
+	testCases := []struct {
+		desc          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				revoked := apiKey
+				revoked.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Eq(db.RevokeAPIKeyParams{
+						ID:    apiKey.ID,
+						Owner: user.Username,
+					})).
+					Times(1).
+					Return(revoked, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc: "NotFound",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			desc: "AuthenticatedWithAPIKey",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("ApiKey %s", key))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(db.GetAPIKeyByPrefixRow{
+						ID:         apiKey.ID,
+						Prefix:     apiKey.Prefix,
+						HashedKey:  apiKey.HashedKey,
+						Owner:      apiKey.Owner,
+						Scopes:     apiKey.Scopes,
+						LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+						Role:       user.Role,
+					}, nil)
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/api_keys/%s", apiKey.ID)
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func randomAPIKey(t *testing.T, owner string) (db.ApiKey, string) {
+	prefix, key, err := util.GenerateAPIKey()
+	require.NoError(t, err)
+
+	apiKey := db.ApiKey{
+		ID:        uuid.New(),
+		Prefix:    prefix,
+		HashedKey: util.HashAPIKey(key),
+		Owner:     owner,
+		Scopes:    []string{util.AccountsReadScope},
+		CreatedAt: time.Now(),
+	}
+	return apiKey, key
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/google/uuid"
+)
+
+const denylistPruneInterval = time.Minute
+
+// tokenDenylist keeps track of revoked token IDs.
+// Revocations are persisted in the revoked_tokens table, and every revocation
+// seen by this process is cached in memory until the token would have expired.
+type tokenDenylist struct {
+	store db.Store
+
+	mu         sync.RWMutex
+	revoked    map[uuid.UUID]time.Time
+	lastPruned time.Time
+}
+
+func newTokenDenylist(store db.Store) *tokenDenylist {
+	return &tokenDenylist{
+		store:      store,
+		revoked:    make(map[uuid.UUID]time.Time),
+		lastPruned: time.Now(),
+	}
+}
+
+// revoke adds a token ID to the denylist until expiresAt
+func (denylist *tokenDenylist) revoke(ctx context.Context, tokenID uuid.UUID, expiresAt time.Time) (db.RevokedToken, error) {
+	revokedToken, err := denylist.store.CreateRevokedToken(ctx, db.CreateRevokedTokenParams{
+		ID:        tokenID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return revokedToken, err
+	}
+
+	denylist.add(revokedToken.ID, revokedToken.ExpiresAt)
+	denylist.prune(ctx)
+	return revokedToken, nil
+}
+
+// isRevoked reports whether the token ID has been revoked.
+// The cache is checked first; a miss falls back to the database,
+// so revocations made by other server instances are honoured too.
+func (denylist *tokenDenylist) isRevoked(ctx context.Context, tokenID uuid.UUID) (bool, error) {
+	denylist.mu.RLock()
+	expiresAt, ok := denylist.revoked[tokenID]
+	denylist.mu.RUnlock()
+
+	if ok && time.Now().Before(expiresAt) {
+		return true, nil
+	}
+
+	revokedToken, err := denylist.store.GetRevokedToken(ctx, tokenID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	denylist.add(revokedToken.ID, revokedToken.ExpiresAt)
+	return true, nil
+}
+
+func (denylist *tokenDenylist) add(tokenID uuid.UUID, expiresAt time.Time) {
+	denylist.mu.Lock()
+	defer denylist.mu.Unlock()
+
+	denylist.revoked[tokenID] = expiresAt
+}
+
+// prune drops expired entries from the cache and the database.
+// Expired tokens are already rejected by the token maker, so they no longer need tracking.
+func (denylist *tokenDenylist) prune(ctx context.Context) {
+	now := time.Now()
+
+	denylist.mu.Lock()
+	if now.Sub(denylist.lastPruned) < denylistPruneInterval {
+		denylist.mu.Unlock()
+		return
+	}
+
+	for tokenID, expiresAt := range denylist.revoked {
+		if now.After(expiresAt) {
+			delete(denylist.revoked, tokenID)
+		}
+	}
+	denylist.lastPruned = now
+	denylist.mu.Unlock()
+
+	// best effort, the rows are harmless if this fails
+	_ = denylist.store.DeleteExpiredRevokedTokens(ctx)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenDenylist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	denylist := newTokenDenylist(store)
+
+	revokedID := uuid.New()
+	expiresAt := time.Now().Add(time.Minute)
+
+	store.EXPECT().
+		CreateRevokedToken(gomock.Any(), gomock.Eq(db.CreateRevokedTokenParams{
+			ID:        revokedID,
+			ExpiresAt: expiresAt,
+		})).
+		Times(1).
+		Return(db.RevokedToken{ID: revokedID, ExpiresAt: expiresAt}, nil)
+
+	_, err := denylist.revoke(context.Background(), revokedID, expiresAt)
+	require.NoError(t, err)
+
+	// served from the cache, without hitting the store
+	revoked, err := denylist.isRevoked(context.Background(), revokedID)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// unknown IDs are looked up in the store
+	otherID := uuid.New()
+	store.EXPECT().
+		GetRevokedToken(gomock.Any(), gomock.Eq(otherID)).
+		Times(1).
+		Return(db.RevokedToken{}, sql.ErrNoRows)
+
+	revoked, err = denylist.isRevoked(context.Background(), otherID)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestTokenDenylistPrune(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	denylist := newTokenDenylist(store)
+
+	expiredID := uuid.New()
+	liveID := uuid.New()
+	denylist.add(expiredID, time.Now().Add(-time.Minute))
+	denylist.add(liveID, time.Now().Add(time.Minute))
+
+	// nothing happens until the prune interval has passed
+	denylist.prune(context.Background())
+	require.Len(t, denylist.revoked, 2)
+
+	store.EXPECT().
+		DeleteExpiredRevokedTokens(gomock.Any()).
+		Times(1)
+
+	denylist.lastPruned = time.Now().Add(-denylistPruneInterval)
+	denylist.prune(context.Background())
+	require.Len(t, denylist.revoked, 1)
+	require.Contains(t, denylist.revoked, liveID)
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/fx"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type fxQuoteResponse struct {
+	ID           uuid.UUID `json:"id"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	Rate         string    `json:"rate"`
+	IsUsed       bool      `json:"is_used"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiredAt    time.Time `json:"expired_at"`
+	// Amount and ToAmount preview a conversion at the rate when an amount is given
+	Amount   int64 `json:"amount,omitempty"`
+	ToAmount int64 `json:"to_amount,omitempty"`
+}
+
+func newFXQuoteResponse(quote db.FxQuote) fxQuoteResponse {
+	return fxQuoteResponse{
+		ID:           quote.ID,
+		FromCurrency: quote.FromCurrency,
+		ToCurrency:   quote.ToCurrency,
+		Rate:         quote.Rate,
+		IsUsed:       quote.IsUsed,
+		CreatedAt:    quote.CreatedAt,
+		ExpiredAt:    quote.ExpiredAt,
+	}
+}
+
+type createFXQuoteRequest struct {
+	FromCurrency string `json:"from_currency" binding:"required,currency"`
+	ToCurrency   string `json:"to_currency" binding:"required,currency,nefield=FromCurrency"`
+	Amount       int64  `json:"amount" binding:"omitempty,gt=0"`
+}
+
+// createFXQuote locks the current rate between two currencies for the authenticated user.
+// A transfer made with the quote before it expires is converted at that rate.
+func (server *Server) createFXQuote(ctx *gin.Context) {
+	var req createFXQuoteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rate, err := server.rateProvider.Rate(ctx, req.FromCurrency, req.ToCurrency)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// a rate turned around to quote the other way may be out of the bounds of stored rates
+	if err := fx.CheckRate(rate.Value); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		return
+	}
+
+	var toAmount int64
+	if req.Amount > 0 {
+		toAmount, err = fx.Convert(req.Amount, rate.Value)
+		if err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	quote, err := server.store.CreateFXQuote(ctx, db.CreateFXQuoteParams{
+		ID:           uuid.New(),
+		Username:     authPayload.Username,
+		FromCurrency: req.FromCurrency,
+		ToCurrency:   req.ToCurrency,
+		Rate:         fx.FormatRate(rate.Value),
+		ExpiredAt:    time.Now().Add(server.config.FXQuoteDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := newFXQuoteResponse(quote)
+	rsp.Amount = req.Amount
+	rsp.ToAmount = toAmount
+	ctx.JSON(http.StatusCreated, gin.H{"quote": rsp})
+}
+
+type getFXQuoteRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+func (server *Server) getFXQuote(ctx *gin.Context) {
+	var req getFXQuoteRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	quote, err := server.store.GetFXQuote(ctx, uuid.MustParse(req.ID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "quote not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if quote.Username != authPayload.Username {
+		err := errors.New("quote doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"quote": newFXQuoteResponse(quote)})
+}
+
+type createFXRateRequest struct {
+	FromCurrency string `json:"from_currency" binding:"required,currency"`
+	ToCurrency   string `json:"to_currency" binding:"required,currency,nefield=FromCurrency"`
+	Rate         string `json:"rate" binding:"required"`
+}
+
+// createFXRate stores a new rate between two currencies, used by the database rate provider
+func (server *Server) createFXRate(ctx *gin.Context) {
+	var req createFXRateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rate, err := fx.ParseRate(req.Rate)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	fxRate, err := server.store.CreateFXRate(ctx, db.CreateFXRateParams{
+		FromCurrency: req.FromCurrency,
+		ToCurrency:   req.ToCurrency,
+		Rate:         fx.FormatRate(rate),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"rate": fxRate})
+}
+
+// convertAtQuote converts the amount of a cross-currency transfer at the rate of its quote
+func convertAtQuote(quote db.FxQuote, amount int64) (int64, error) {
+	rate, err := fx.ParseRate(quote.Rate)
+	if err != nil {
+		return 0, err
+	}
+	return fx.Convert(amount, rate)
+}
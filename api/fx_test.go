@@ -0,0 +1,486 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/fx"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateFXQuoteAPI(t *testing.T) {
+	user, _ := randomUser()
+	rates := fx.NewStaticRateProvider(
+		fx.Rate{From: util.USD, To: util.IDR, Value: big.NewRat(15000, 1)},
+		fx.Rate{From: util.CAD, To: util.USD, Value: big.NewRat(1, 1_000_000_000_000)},
+	)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		scopes        []string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"from_currency": util.USD,
+				"to_currency":   util.IDR,
+				"amount":        250,
+			},
+			scopes: util.AllScopes(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXQuote(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateFXQuoteParams) (db.FxQuote, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, util.USD, arg.FromCurrency)
+						require.Equal(t, util.IDR, arg.ToCurrency)
+						require.Equal(t, "15000.000000000000", arg.Rate)
+						require.WithinDuration(t, time.Now().Add(30*time.Second), arg.ExpiredAt, time.Second)
+						return db.FxQuote{
+							ID:           arg.ID,
+							Username:     arg.Username,
+							FromCurrency: arg.FromCurrency,
+							ToCurrency:   arg.ToCurrency,
+							Rate:         arg.Rate,
+							CreatedAt:    time.Now(),
+							ExpiredAt:    arg.ExpiredAt,
+						}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				quote := requireBodyFXQuote(t, recorder.Body)
+				require.NotEqual(t, uuid.Nil, quote.ID)
+				require.Equal(t, "15000.000000000000", quote.Rate)
+				require.Equal(t, int64(250), quote.Amount)
+				require.Equal(t, int64(3750000), quote.ToAmount)
+			},
+		},
+		{
+			name: "InverseRate",
+			body: gin.H{
+				"from_currency": util.IDR,
+				"to_currency":   util.USD,
+			},
+			scopes: util.AllScopes(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXQuote(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateFXQuoteParams) (db.FxQuote, error) {
+						// rounded down
+						require.Equal(t, "0.000066666666", arg.Rate)
+						return db.FxQuote{ID: arg.ID, Rate: arg.Rate}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				quote := requireBodyFXQuote(t, recorder.Body)
+				require.Zero(t, quote.ToAmount)
+			},
+		},
+		{
+			name: "RateNotFound",
+			body: gin.H{
+				"from_currency": util.EUR,
+				"to_currency":   util.CAD,
+			},
+			scopes: util.AllScopes(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXQuote(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InverseRateTooLarge",
+			body: gin.H{
+				"from_currency": util.USD,
+				"to_currency":   util.CAD,
+			},
+			scopes: util.AllScopes(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXQuote(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name: "AmountTooSmall",
+			body: gin.H{
+				"from_currency": util.IDR,
+				"to_currency":   util.USD,
+				"amount":        100,
+			},
+			scopes: util.AllScopes(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXQuote(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name: "SameCurrency",
+			body: gin.H{
+				"from_currency": util.USD,
+				"to_currency":   util.USD,
+			},
+			scopes: util.AllScopes(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXQuote(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "MissingTransfersWriteScope",
+			body: gin.H{
+				"from_currency": util.USD,
+				"to_currency":   util.IDR,
+			},
+			scopes: []string{util.TransfersReadScope},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXQuote(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{
+				"from_currency": util.USD,
+				"to_currency":   util.IDR,
+			},
+			scopes: util.AllScopes(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXQuote(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.FxQuote{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			testCase.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			server.rateProvider = rates
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(testCase.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/fx/quotes", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addScopedAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, testCase.scopes, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			testCase.checkResponse(recorder)
+		})
+	}
+}
+
+func TestGetFXQuoteAPI(t *testing.T) {
+	user, _ := randomUser()
+	otherUser, _ := randomUser()
+	quote := randomFXQuote(user.Username, util.USD, util.EUR)
+
+	testCases := []struct {
+		name          string
+		quoteID       string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			quoteID:  quote.ID.String(),
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(quote, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				got := requireBodyFXQuote(t, recorder.Body)
+				require.Equal(t, quote.ID, got.ID)
+				require.Equal(t, quote.Rate, got.Rate)
+				require.WithinDuration(t, quote.ExpiredAt, got.ExpiredAt, time.Second)
+			},
+		},
+		{
+			name:     "QuoteOfAnotherUser",
+			quoteID:  quote.ID.String(),
+			username: otherUser.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(quote, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			quoteID:  quote.ID.String(),
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(db.FxQuote{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidID",
+			quoteID:  "invalid",
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			testCase.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/fx/quotes/%s", testCase.quoteID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, testCase.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			testCase.checkResponse(recorder)
+		})
+	}
+}
+
+func TestCreateFXRateAPI(t *testing.T) {
+	testCases := []struct {
+		name          string
+		body          gin.H
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"from_currency": util.EUR,
+				"to_currency":   util.USD,
+				"rate":          "1.0875",
+			},
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.CreateFXRateParams{
+					FromCurrency: util.EUR,
+					ToCurrency:   util.USD,
+					Rate:         "1.087500000000",
+				}
+				store.EXPECT().
+					CreateFXRate(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.FxRate{ID: 1, FromCurrency: arg.FromCurrency, ToCurrency: arg.ToCurrency, Rate: arg.Rate}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "NegativeRate",
+			body: gin.H{
+				"from_currency": util.EUR,
+				"to_currency":   util.USD,
+				"rate":          "-1",
+			},
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXRate(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidRate",
+			body: gin.H{
+				"from_currency": util.EUR,
+				"to_currency":   util.USD,
+				"rate":          "abc",
+			},
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXRate(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "RateTooLarge",
+			body: gin.H{
+				"from_currency": util.EUR,
+				"to_currency":   util.USD,
+				"rate":          "1000000000000",
+			},
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXRate(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "RateTooSmall",
+			body: gin.H{
+				"from_currency": util.EUR,
+				"to_currency":   util.USD,
+				"rate":          "0.0000000000001",
+			},
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXRate(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			body: gin.H{
+				"from_currency": util.EUR,
+				"to_currency":   util.USD,
+				"rate":          "1.0875",
+			},
+			role: util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateFXRate(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			testCase.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(testCase.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/admin/fx/rates", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, util.RandomOwner(), testCase.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			testCase.checkResponse(recorder)
+		})
+	}
+}
+
+func randomFXQuote(username string, from string, to string) db.FxQuote {
+	return db.FxQuote{
+		ID:           uuid.New(),
+		Username:     username,
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         "1.100000000000",
+		CreatedAt:    time.Now().Truncate(time.Second),
+		ExpiredAt:    time.Now().Add(time.Minute).Truncate(time.Second),
+	}
+}
+
+func requireBodyFXQuote(t *testing.T, body *bytes.Buffer) fxQuoteResponse {
+	var rsp struct {
+		Quote fxQuoteResponse `json:"quote"`
+	}
+	err := json.Unmarshal(body.Bytes(), &rsp)
+	require.NoError(t, err)
+	return rsp.Quote
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader tells the client the response is the one of an earlier request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+var errIdempotencyKeyReused = errors.New("idempotency key was already used for another request")
+
+// idempotencyKey returns the Idempotency-Key header of the request, checking it's usable
+func idempotencyKey(ctx *gin.Context) (string, error) {
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLength {
+		return "", fmt.Errorf("%s must have at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+	return key, nil
+}
+
+// hashRequest identifies a bound request by its content, however its JSON was laid out
+func hashRequest(req interface{}) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replayIdempotencyKey sends the stored response of an earlier request with the key, if any.
+// It returns whether a response was sent.
+func (server *Server) replayIdempotencyKey(ctx *gin.Context, username, key, requestHash string) bool {
+	record, err := server.store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Username: username,
+		Key:      key,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return true
+	}
+
+	writeIdempotentReplay(ctx, record, requestHash)
+	return true
+}
+
+// writeIdempotentReplay sends the response stored for the key,
+// unless the key was used for another request
+func writeIdempotentReplay(ctx *gin.Context, record db.IdempotencyKey, requestHash string) {
+	if record.RequestHash != requestHash {
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(errIdempotencyKeyReused))
+		return
+	}
+
+	ctx.Header(idempotentReplayedHeader, "true")
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", record.Response)
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+)
+
+const loginThrottlePruneInterval = time.Minute
+
+var errTooManyLoginFailures = errors.New("too many failed login attempts, try again later")
+
+// loginThrottle slows down password guessing against /users/login.
+// Failed attempts are counted per username and per client IP in the login_failures table.
+// Every failure delays the next attempt exponentially, and the key is locked
+// for the configured duration once it reaches its threshold.
+// Unknown usernames are tracked like existing ones, so a lock reveals nothing about them.
+type loginThrottle struct {
+	store  db.Store
+	config util.Config
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+func newLoginThrottle(store db.Store, config util.Config) *loginThrottle {
+	return &loginThrottle{
+		store:      store,
+		config:     config,
+		lastPruned: time.Now(),
+	}
+}
+
+func loginUsernameKey(username string) string {
+	return "username:" + strings.ToLower(username)
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// lockedFor returns how long the username or the IP is still locked,
+// or zero if both may attempt to log in
+func (throttle *loginThrottle) lockedFor(ctx context.Context, username string, ip string) (time.Duration, error) {
+	locks, err := throttle.store.ListLoginLocks(ctx, []string{loginUsernameKey(username), loginIPKey(ip)})
+	if err != nil {
+		return 0, err
+	}
+
+	var lockedFor time.Duration
+	for _, lock := range locks {
+		if d := time.Until(lock.LockedUntil.Time); d > lockedFor {
+			lockedFor = d
+		}
+	}
+	return lockedFor, nil
+}
+
+// recordFailure counts a failed attempt against both the username and the IP
+func (throttle *loginThrottle) recordFailure(ctx context.Context, username string, ip string) error {
+	err := throttle.record(ctx, loginUsernameKey(username), throttle.config.LoginMaxUsernameFailures)
+	if err != nil {
+		return err
+	}
+
+	err = throttle.record(ctx, loginIPKey(ip), throttle.config.LoginMaxIPFailures)
+	if err != nil {
+		return err
+	}
+
+	throttle.prune(ctx)
+	return nil
+}
+
+func (throttle *loginThrottle) record(ctx context.Context, key string, maxFailures int) error {
+	failure, err := throttle.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         key,
+		ResetBefore: time.Now().Add(-throttle.config.LoginFailureWindow),
+	})
+	if err != nil {
+		return err
+	}
+
+	lockDuration := throttle.lockDuration(int(failure.FailedCount), maxFailures)
+	if lockDuration <= 0 {
+		return nil
+	}
+
+	return throttle.store.LockLoginKey(ctx, db.LockLoginKeyParams{
+		Key: key,
+		LockedUntil: sql.NullTime{
+			Time:  time.Now().Add(lockDuration),
+			Valid: true,
+		},
+	})
+}
+
+// lockDuration doubles the backoff with every failure,
+// up to the full lock once maxFailures is reached
+func (throttle *loginThrottle) lockDuration(failedCount int, maxFailures int) time.Duration {
+	if failedCount >= maxFailures {
+		return throttle.config.LoginLockDuration
+	}
+
+	backoff := throttle.config.LoginBackoffBase
+	for i := 1; i < failedCount && backoff < throttle.config.LoginLockDuration; i++ {
+		backoff *= 2
+	}
+	if backoff > throttle.config.LoginLockDuration {
+		backoff = throttle.config.LoginLockDuration
+	}
+	return backoff
+}
+
+// reset forgets the failures of a username after a successful login.
+// The IP keeps its count, so one valid account doesn't help a spray from that IP.
+func (throttle *loginThrottle) reset(ctx context.Context, username string) error {
+	return throttle.store.DeleteLoginFailures(ctx, loginUsernameKey(username))
+}
+
+// prune drops failures older than the window whose lock has expired
+func (throttle *loginThrottle) prune(ctx context.Context) {
+	now := time.Now()
+
+	throttle.mu.Lock()
+	if now.Sub(throttle.lastPruned) < loginThrottlePruneInterval {
+		throttle.mu.Unlock()
+		return
+	}
+	throttle.lastPruned = now
+	throttle.mu.Unlock()
+
+	// best effort, stale rows are reset by the next failure anyway
+	_ = throttle.store.DeleteStaleLoginFailures(ctx, now.Add(-throttle.config.LoginFailureWindow))
+}
+
+// checkLoginThrottle aborts with 429 and returns false while the username
+// or the client IP is locked out
+func (server *Server) checkLoginThrottle(ctx *gin.Context, username string) bool {
+	lockedFor, err := server.loginThrottle.lockedFor(ctx, username, ctx.ClientIP())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+
+	if lockedFor > 0 {
+		retryAfter := int(math.Ceil(lockedFor.Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+		ctx.JSON(http.StatusTooManyRequests, errorResponse(errTooManyLoginFailures))
+		return false
+	}
+
+	return true
+}
+
+// rejectLogin records a failed login attempt and responds with 401
+func (server *Server) rejectLogin(ctx *gin.Context, username string, reason error) {
+	err := server.loginThrottle.recordFailure(ctx, username, ctx.ClientIP())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusUnauthorized, errorResponse(reason))
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyHashedPassword returns a hash no password matches,
+// checked in place of the hash of unknown users
+func (server *Server) dummyHashedPassword() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = server.hashPassword(util.RandomString(32))
+	})
+	return dummyHash
+}
+
+type unlockUserRequest struct {
+	Username string `uri:"username" binding:"required,alphanum"`
+}
+
+// unlockUser lifts the login lockout of a user.
+// Lockouts of client IPs are left to expire.
+func (server *Server) unlockUser(ctx *gin.Context) {
+	var req unlockUserRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	err := server.loginThrottle.reset(ctx, req.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
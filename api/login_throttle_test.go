@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+// buildLoginThrottleStubs lets every login attempt through the throttle
+func buildLoginThrottleStubs(store *mockdb.MockStore) {
+	store.EXPECT().
+		ListLoginLocks(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return([]db.LoginFailure{}, nil)
+}
+
+// buildLoginFailureStubs expects a failed attempt to be recorded
+// for both the username and the IP
+func buildLoginFailureStubs(store *mockdb.MockStore, failedCount int32) {
+	store.EXPECT().
+		RecordLoginFailure(gomock.Any(), gomock.Any()).
+		Times(2).
+		DoAndReturn(func(_ interface{}, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+			return db.LoginFailure{Key: arg.Key, FailedCount: failedCount}, nil
+		})
+	store.EXPECT().
+		LockLoginKey(gomock.Any(), gomock.Any()).
+		Times(2)
+}
+
+func TestLoginThrottleLockDuration(t *testing.T) {
+	throttle := newLoginThrottle(nil, util.Config{
+		LoginBackoffBase:  time.Second,
+		LoginLockDuration: time.Minute,
+	})
+
+	testCases := []struct {
+		failedCount int
+		duration    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{9, time.Minute},
+		{10, time.Minute},
+	}
+
+	for _, tC := range testCases {
+		t.Run(fmt.Sprint(tC.failedCount), func(t *testing.T) {
+			require.Equal(t, tC.duration, throttle.lockDuration(tC.failedCount, 10))
+		})
+	}
+}
+
+func TestUnlockUserAPI(t *testing.T) {
+	user, _ := randomUser()
+
+	testCases := []struct {
+		desc          string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			role: util.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recoder.Code)
+			},
+		},
+		{
+			desc: "DepositorForbidden",
+			role: util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/admin/users/%s/unlock", user.Username)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tC.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func requireBodyMatchError(t *testing.T, body *bytes.Buffer, err error) {
+	var rsp struct {
+		Error string `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(body.Bytes(), &rsp))
+	require.Equal(t, err.Error(), rsp.Error)
+}
@@ -1,23 +1,75 @@
 package api
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/mail"
 	"github.com/amrizal94/simplebank/util"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 )
 
-func newTestServer(t *testing.T, store db.Store) *Server {
-	config := util.Config{
-		TokenSymmetricKey:   util.RandomString(32),
-		AccessTokenDuration: time.Minute,
+// testBreachedPassword passes every rule of the password policy but the breached password check
+const testBreachedPassword = "Xq7#mVw2!kPz"
+
+// writeBreachedPasswords stores the passwords as a k-anonymity range list in a temporary directory
+func writeBreachedPasswords(t *testing.T, passwords ...string) string {
+	dir := t.TempDir()
+
+	for _, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+		file, err := os.OpenFile(filepath.Join(dir, hash[:5]+".txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = file.WriteString(hash[5:] + ":42\n")
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+	}
+
+	return dir
+}
+
+func newTestConfig(t *testing.T) util.Config {
+	return util.Config{
+		TokenSymmetricKey:         util.RandomString(32),
+		AccessTokenDuration:       time.Minute,
+		RefreshTokenDuration:      time.Hour,
+		TOTPIssuer:                "SimpleBank",
+		TOTPChallengeDuration:     time.Minute,
+		LoginMaxUsernameFailures:  5,
+		LoginMaxIPFailures:        20,
+		LoginBackoffBase:          time.Second,
+		LoginLockDuration:         time.Minute,
+		LoginFailureWindow:        time.Hour,
+		EmailSenderType:           mail.TypeMemory,
+		VerifyEmailURL:            "http://localhost:8080/verify_email",
+		VerifyEmailDuration:       15 * time.Minute,
+		PasswordResetURL:          "http://localhost:3000/reset_password",
+		PasswordResetDuration:     30 * time.Minute,
+		PasswordResetRateWindow:   time.Hour,
+		PasswordResetMaxPerEmail:  3,
+		PasswordResetMaxPerIP:     20,
+		PasswordMinLength:         8,
+		PasswordMinScore:          2,
+		PasswordBreachedDir:       writeBreachedPasswords(t, testBreachedPassword),
+		IdempotencyKeyDuration:    24 * time.Hour,
+		PaginationCursorKey:       util.RandomString(32),
+		PaginationDefaultPageSize: 20,
+		PaginationMaxPageSize:     100,
+		FXQuoteDuration:           30 * time.Second,
 	}
+}
 
-	server, err := NewServer(config, store)
+func newTestServer(t *testing.T, store db.Store) *Server {
+	server, err := NewServer(newTestConfig(t), store)
 	require.NoError(t, err)
 
 	return server
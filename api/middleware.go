@@ -1,10 +1,12 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/amrizal94/simplebank/token"
 	"github.com/gin-gonic/gin"
@@ -13,10 +15,25 @@ import (
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
+	authorizationTypeAPIKey = "apikey"
+	authorizationTypeKey    = "authorization_type"
 	authorizationPayloadKey = "authorization_payload"
+	// authorizationRoleKey holds the current role of the authenticated user,
+	// which may have changed since the token was issued
+	authorizationRoleKey = "authorization_role"
 )
 
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+var (
+	errRevokedToken      = errors.New("token has been revoked")
+	errNotAccessToken    = errors.New("only access tokens can be used for authorization")
+	errUnknownUser       = errors.New("token belongs to an unknown user")
+	errPasswordChanged   = errors.New("token was issued before the last password change")
+	errSessionTerminated = errors.New("session has been terminated")
+)
+
+// authMiddleware authenticates the request with either a bearer access token
+// or an API key, and stores the resulting payload for the handlers
+func (server *Server) authMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
 
@@ -33,21 +50,158 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 			return
 		}
 
+		var payload *token.Payload
+		var role string
+		var err error
+
 		authorizationType := strings.ToLower(fields[0])
-		if authorizationType != authorizationTypeBearer {
-			err := fmt.Errorf("unsupported authorization type %s", authorizationTypeBearer)
+		switch authorizationType {
+		case authorizationTypeBearer:
+			payload, err = server.tokenMaker.VerifyToken(fields[1])
+			if err != nil {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+				return
+			}
+
+			// refresh and challenge tokens point to a live session too, but only for their own use
+			if payload.Type != token.AccessToken {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errNotAccessToken))
+				return
+			}
+
+			revoked, err := server.denylist.isRevoked(ctx, payload.ID)
+			if err != nil {
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			if revoked {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errRevokedToken))
+				return
+			}
+
+			user, err := server.store.GetUserAuth(ctx, payload.Username)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errUnknownUser))
+					return
+				}
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+
+			if user.IsFrozen {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errFrozenUser))
+				return
+			}
+
+			if issuedBeforePasswordChange(payload, user.PasswordChangedAt) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errPasswordChanged))
+				return
+			}
+			role = user.Role
+
+			session, err := server.store.GetSession(ctx, payload.Session())
+			if err != nil {
+				if err == sql.ErrNoRows {
+					ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errSessionTerminated))
+					return
+				}
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+
+			if session.IsBlocked || time.Now().After(session.ExpiresAt) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errSessionTerminated))
+				return
+			}
+
+			server.touchSession(ctx, session)
+		case authorizationTypeAPIKey:
+			payload, err = server.verifyAPIKey(ctx, fields[1])
+			switch err {
+			case nil:
+			case errInvalidAPIKey, errExpiredAPIKey, errRevokedAPIKey, errFrozenUser:
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+				return
+			default:
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			// the role of an API key is loaded with the key
+			role = payload.Role
+		default:
+			err := fmt.Errorf("unsupported authorization type %s", authorizationType)
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
 			return
 		}
 
-		accessToken := fields[1]
-		payload, err := tokenMaker.VerifyToken(accessToken)
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		ctx.Set(authorizationTypeKey, authorizationType)
+		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Set(authorizationRoleKey, role)
+		ctx.Next()
+	}
+}
+
+// issuedBeforePasswordChange reports whether the token predates the last password change
+// of its user. Such tokens are rejected, so changing the password logs out every device.
+func issuedBeforePasswordChange(payload *token.Payload, passwordChangedAt time.Time) bool {
+	return payload.IssuedAt.Before(passwordChangedAt)
+}
+
+// requireBearerToken rejects requests authenticated with an API key,
+// for actions that need an interactive login such as managing API keys.
+// It must run after authMiddleware.
+func requireBearerToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetString(authorizationTypeKey) != authorizationTypeBearer {
+			err := errors.New("this action requires a bearer access token")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
 			return
 		}
 
-		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Next()
+	}
+}
+
+// requireRole only lets through users who currently have one of the given roles,
+// whatever role their token was issued with. It must run after authMiddleware.
+func requireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !hasRole(ctx, roles...) {
+			err := fmt.Errorf("role %q is not allowed to access this resource", ctx.GetString(authorizationRoleKey))
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// hasRole checks the current role of the authenticated user, set by authMiddleware
+func hasRole(ctx *gin.Context, roles ...string) bool {
+	current := ctx.GetString(authorizationRoleKey)
+	for _, role := range roles {
+		if current == role {
+			return true
+		}
+	}
+	return false
+}
+
+// requireScopes only lets through tokens granted every one of the given scopes.
+// It must run after authMiddleware.
+func requireScopes(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+		for _, scope := range scopes {
+			if !authPayload.HasScope(scope) {
+				err := fmt.Errorf("token is missing the %q scope", scope)
+				ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+				return
+			}
+		}
+
 		ctx.Next()
 	}
 }
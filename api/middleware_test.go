@@ -1,14 +1,24 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
 	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
@@ -18,37 +28,376 @@ func addAuthorization(
 	tokenMaker token.Maker,
 	authorizationType string,
 	username string,
+	role string,
 	duration time.Duration,
 ) {
-	token, payload, err := tokenMaker.CreateToken(username, duration)
+	addScopedAuthorization(t, request, tokenMaker, authorizationType, username, role, util.AllScopes(), duration)
+}
+
+func addScopedAuthorization(
+	t *testing.T,
+	request *http.Request,
+	tokenMaker token.Maker,
+	authorizationType string,
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) {
+	addTypedAuthorization(t, request, tokenMaker, authorizationType, token.AccessToken, username, role, scopes, duration)
+}
+
+func addTypedAuthorization(
+	t *testing.T,
+	request *http.Request,
+	tokenMaker token.Maker,
+	authorizationType string,
+	tokenType string,
+	username string,
+	role string,
+	scopes []string,
+	duration time.Duration,
+) {
+	authToken, payload, err := tokenMaker.CreateToken(tokenType, username, role, scopes, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, payload)
+	testUserRoles.Store(username, role)
 
-	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, token)
+	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, authToken)
 	request.Header.Set(authorizationHeaderKey, authorizationHeader)
 }
 
+// testUserRoles holds the role of the users the tests authorized,
+// so their users still have the role their token was issued with
+var testUserRoles sync.Map
+
+// buildAuthStubs lets authMiddleware accept any valid token
+func buildAuthStubs(store *mockdb.MockStore) {
+	store.EXPECT().
+		GetRevokedToken(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.RevokedToken{}, sql.ErrNoRows)
+	store.EXPECT().
+		GetUserAuth(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, username string) (db.GetUserAuthRow, error) {
+			role, _ := testUserRoles.Load(username)
+			userRole, _ := role.(string)
+			return db.GetUserAuthRow{Role: userRole}, nil
+		})
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, id uuid.UUID) (db.Session, error) {
+			return db.Session{ID: id, ExpiresAt: time.Now().Add(time.Hour), LastSeenAt: time.Now()}, nil
+		})
+	store.EXPECT().
+		TouchSession(gomock.Any(), gomock.Any()).
+		AnyTimes()
+}
+
 func TestAuthMiddlewar(t *testing.T) {
 	testCases := []struct {
 		desc          string
 		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			desc: "OK",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: buildAuthStubs,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			desc: "RevokedToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{ExpiresAt: time.Now().Add(time.Minute)}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "RevokedTokenCheckError",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			desc: "PasswordChanged",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{PasswordChangedAt: time.Now().Add(time.Second)}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errPasswordChanged)
+			},
+		},
+		{
+			desc: "FrozenUser",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{IsFrozen: true}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errFrozenUser)
+			},
+		},
+		{
+			desc: "UnknownUser",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "UserAuthCheckError",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetUserAuthRow{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			desc: "SessionTouched",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
 				)
 			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{}, nil)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{ExpiresAt: time.Now().Add(time.Hour), LastSeenAt: time.Now().Add(-time.Hour)}, nil)
+				store.EXPECT().
+					TouchSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
 			},
 		},
+		{
+			desc: "SessionBlocked",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{}, nil)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{IsBlocked: true, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errSessionTerminated)
+			},
+		},
+		{
+			desc: "SessionExpired",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{}, nil)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{ExpiresAt: time.Now().Add(-time.Minute)}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errSessionTerminated)
+			},
+		},
+		{
+			desc: "SessionNotFound",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{}, nil)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errSessionTerminated)
+			},
+		},
+		{
+			desc: "SessionCheckError",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{}, nil)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			desc: "ChallengeToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addTypedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, token.ChallengeToken, "user", util.DepositorRole,
+					[]string{util.TOTPChallengeScope}, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			// a refresh token is its own live session, only its type keeps it out
+			desc: "RefreshToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addTypedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, token.RefreshToken, "user", util.DepositorRole,
+					util.AllScopes(), time.Hour,
+				)
+			},
+			buildStubs: buildAuthStubs,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			desc: "NoAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 			},
+			buildStubs: func(store *mockdb.MockStore) {},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
@@ -57,9 +406,10 @@ func TestAuthMiddlewar(t *testing.T) {
 			desc: "UnsupportedAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, "unsupported", "user", time.Minute,
+					t, request, tokenMaker, "unsupported", "user", util.DepositorRole, time.Minute,
 				)
 			},
+			buildStubs: func(store *mockdb.MockStore) {},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
@@ -68,9 +418,10 @@ func TestAuthMiddlewar(t *testing.T) {
 			desc: "InvalidAuthorizationFormat",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, "", "user", time.Minute,
+					t, request, tokenMaker, "", "user", util.DepositorRole, time.Minute,
 				)
 			},
+			buildStubs: func(store *mockdb.MockStore) {},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
@@ -79,9 +430,10 @@ func TestAuthMiddlewar(t *testing.T) {
 			desc: "ExpiredAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, "user", -time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, -time.Minute,
 				)
 			},
+			buildStubs: func(store *mockdb.MockStore) {},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
@@ -89,12 +441,18 @@ func TestAuthMiddlewar(t *testing.T) {
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
-			server := newTestServer(t, nil)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+
+			server := newTestServer(t, store)
 
 			authPath := "/auth"
 			server.router.GET(
 				authPath,
-				authMiddleware(server.tokenMaker),
+				server.authMiddleware(),
 				func(ctx *gin.Context) {
 					ctx.JSON(http.StatusOK, gin.H{})
 				})
@@ -108,3 +466,225 @@ func TestAuthMiddlewar(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireRole(t *testing.T) {
+	testCases := []struct {
+		desc string
+		role string
+		// currentRole is the role of the user in the database, if it changed since the login
+		currentRole   string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "Allowed",
+			role: util.BankerRole,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			desc: "AlsoAllowed",
+			role: util.AdminRole,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			desc: "Forbidden",
+			role: util.DepositorRole,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			desc:        "Demoted",
+			role:        util.AdminRole,
+			currentRole: util.DepositorRole,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			desc:        "Promoted",
+			role:        util.DepositorRole,
+			currentRole: util.BankerRole,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			if len(tC.currentRole) > 0 {
+				store.EXPECT().
+					GetUserAuth(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.GetUserAuthRow{Role: tC.currentRole}, nil)
+			}
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				server.authMiddleware(),
+				requireRole(util.BankerRole, util.AdminRole),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				})
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", tC.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		scopes        []string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc:   "OK",
+			scopes: []string{util.AccountsReadScope, util.TransfersWriteScope},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			desc:   "MissingScope",
+			scopes: []string{util.AccountsReadScope},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			desc:   "NoScopes",
+			scopes: nil,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				server.authMiddleware(),
+				requireScopes(util.AccountsReadScope, util.TransfersWriteScope),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				})
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			addScopedAuthorization(
+				t, request, server.tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, tC.scopes, time.Minute,
+			)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAdminRoutesRequireAdminBearerToken(t *testing.T) {
+	admin, _ := randomUser()
+	admin.Role = util.AdminRole
+
+	apiKey, key := randomAPIKey(t, admin.Username)
+	apiKey.Scopes = util.AllScopes()
+
+	testCases := []struct {
+		desc       string
+		setupAuth  func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs func(store *mockdb.MockStore)
+	}{
+		{
+			desc: "ReadScopedToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				scopes := []string{util.AccountsReadScope, util.TransfersReadScope, util.UsersReadScope}
+				addScopedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, scopes, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {},
+		},
+		{
+			desc: "APIKey",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeAPIKey, key))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					AnyTimes().
+					Return(db.GetAPIKeyByPrefixRow{
+						ID:        apiKey.ID,
+						Prefix:    apiKey.Prefix,
+						HashedKey: apiKey.HashedKey,
+						Owner:     apiKey.Owner,
+						Scopes:    apiKey.Scopes,
+						CreatedAt: apiKey.CreatedAt,
+						Role:      admin.Role,
+					}, nil)
+				store.EXPECT().
+					UpdateAPIKeyLastUsed(gomock.Any(), gomock.Any()).
+					AnyTimes()
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// the handlers must not be reached, any other store call fails the test
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+
+			adminRoutes := 0
+			for _, route := range server.router.Routes() {
+				if !strings.HasPrefix(route.Path, "/admin/") {
+					continue
+				}
+				adminRoutes++
+
+				url := routeParam.ReplaceAllString(route.Path, "1")
+				recorder := httptest.NewRecorder()
+				request, err := http.NewRequest(route.Method, url, nil)
+				require.NoError(t, err)
+
+				tC.setupAuth(t, request, server.tokenMaker)
+				server.router.ServeHTTP(recorder, request)
+				require.Equal(t, http.StatusForbidden, recorder.Code, "%s %s", route.Method, route.Path)
+			}
+			require.NotZero(t, adminRoutes)
+		})
+	}
+}
+
+// routeParam matches the parameters of a route path
+var routeParam = regexp.MustCompile(`:[a-z_]+`)
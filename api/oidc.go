@@ -0,0 +1,286 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/oidc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	// oidcStateCookie ties the callback to the browser that started the login
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/users/login/oidc"
+	// maxOIDCUsernameLength keeps usernames derived from identities readable
+	maxOIDCUsernameLength = 32
+	// oidcUsernameAttempts is how many usernames are tried for a new user before giving up
+	oidcUsernameAttempts = 3
+)
+
+var (
+	errInvalidOIDCState  = errors.New("invalid or expired sign-in state")
+	errOIDCEmailRequired = errors.New("the identity provider didn't share an email address")
+)
+
+// startOIDCLogin sends the user to the identity provider to sign in.
+// It comes back to finishOIDCLogin.
+func (server *Server) startOIDCLogin(ctx *gin.Context) {
+	state, err := util.GenerateSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	nonce, err := util.GenerateSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	codeVerifier, err := util.GenerateSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authURL, err := server.oidcProvider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, errorResponse(err))
+		return
+	}
+
+	// requests that were never finished are cleaned up as new ones come in
+	if err := server.store.DeleteExpiredOIDCAuthRequests(ctx); err != nil {
+		ctx.Error(err)
+	}
+
+	_, err = server.store.CreateOIDCAuthRequest(ctx, db.CreateOIDCAuthRequestParams{
+		HashedState:  util.HashSecretCode(state),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiredAt:    time.Now().Add(server.config.OIDCAuthRequestDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.setOIDCStateCookie(ctx, state, int(server.config.OIDCAuthRequestDuration.Seconds()))
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// setOIDCStateCookie sets the state cookie, or deletes it with a negative maxAge.
+// Lax is needed for the cookie to come back with the redirect of the provider.
+func (server *Server) setOIDCStateCookie(ctx *gin.Context, state string, maxAge int) {
+	secure := strings.HasPrefix(server.config.OIDCRedirectURL, "https://")
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, state, maxAge, oidcCookiePath, "", secure, true)
+}
+
+type oidcCallbackRequest struct {
+	State            string `form:"state" binding:"required"`
+	Code             string `form:"code"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+// finishOIDCLogin handles the redirect of the identity provider.
+// It redeems the authorization code for an ID token and logs in the user linked to it,
+// like loginUser would.
+func (server *Server) finishOIDCLogin(ctx *gin.Context) {
+	var req oidcCallbackRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	cookieState, err := ctx.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookieState), []byte(req.State)) != 1 {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errInvalidOIDCState))
+		return
+	}
+	server.setOIDCStateCookie(ctx, "", -1)
+
+	// the state is used up whatever happens next
+	authRequest, err := server.store.UseOIDCAuthRequest(ctx, util.HashSecretCode(req.State))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(errInvalidOIDCState))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if req.Error != "" {
+		err := fmt.Errorf("identity provider refused the login: %s %s", req.Error, req.ErrorDescription)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	if req.Code == "" {
+		err := errors.New("missing authorization code")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rawIDToken, err := server.oidcProvider.Exchange(ctx, req.Code, authRequest.CodeVerifier)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	idToken, err := server.oidcProvider.VerifyIDToken(ctx, rawIDToken, authRequest.Nonce)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	user, err := server.oidcUser(ctx, idToken)
+	if err != nil {
+		if err == errOIDCEmailRequired {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			ctx.JSON(http.StatusConflict, errorResponse(errEmailTaken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if user.IsFrozen {
+		ctx.JSON(http.StatusForbidden, errorResponse(errFrozenUser))
+		return
+	}
+
+	scopes := util.AllScopes()
+
+	if user.IsTotpEnabled {
+		rsp, err := server.createLoginChallenge(user, scopes)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusOK, rsp)
+		return
+	}
+
+	rsp, err := server.createLoginSession(ctx, user, scopes)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// oidcUser returns the user linked to the identity. An identity seen for the first time
+// is linked to the user with the same email, or to a new user if there is none.
+func (server *Server) oidcUser(ctx *gin.Context, idToken *oidc.IDToken) (db.User, error) {
+	identity, err := server.store.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+	})
+	if err == nil {
+		return server.store.GetUser(ctx, identity.Username)
+	}
+	if err != sql.ErrNoRows {
+		return db.User{}, err
+	}
+
+	if idToken.Email == "" {
+		return db.User{}, errOIDCEmailRequired
+	}
+
+	// an existing user is only linked through an email both sides verified,
+	// or whoever registered the address first could take over the account of the other
+	if idToken.EmailVerified {
+		user, err := server.store.GetUserByEmail(ctx, idToken.Email)
+		if err != nil && err != sql.ErrNoRows {
+			return db.User{}, err
+		}
+		if err == nil && user.IsEmailVerified {
+			_, err = server.store.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+				Issuer:   idToken.Issuer,
+				Subject:  idToken.Subject,
+				Username: user.Username,
+			})
+			return user, err
+		}
+	}
+
+	return server.createOIDCUser(ctx, idToken)
+}
+
+// createOIDCUser signs up the owner of a new identity. They have no usable password,
+// they can still set one with a password reset.
+func (server *Server) createOIDCUser(ctx *gin.Context, idToken *oidc.IDToken) (db.User, error) {
+	password, err := util.GenerateSecretCode()
+	if err != nil {
+		return db.User{}, err
+	}
+	hashedPassword, err := server.hashPassword(password)
+	if err != nil {
+		return db.User{}, err
+	}
+
+	baseUsername := oidcUsername(idToken)
+	fullName := idToken.Name
+	if fullName == "" {
+		fullName = baseUsername
+	}
+
+	arg := db.CreateOIDCUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			HashedPassword: hashedPassword,
+			FullName:       fullName,
+			Email:          idToken.Email,
+		},
+		Issuer:          idToken.Issuer,
+		Subject:         idToken.Subject,
+		IsEmailVerified: idToken.EmailVerified,
+	}
+
+	for attempt := 1; ; attempt++ {
+		arg.Username = baseUsername
+		if attempt > 1 {
+			arg.Username = fmt.Sprintf("%s%d", baseUsername, util.RandomInt(1000, 9999))
+		}
+
+		result, err := server.store.CreateOIDCUserTx(ctx, arg)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == "users_pkey" && attempt < oidcUsernameAttempts {
+			continue
+		}
+		return result.User, err
+	}
+}
+
+// oidcUsername derives a username from the identity, keeping the characters usernames allow
+func oidcUsername(idToken *oidc.IDToken) string {
+	candidate := idToken.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(idToken.Email, "@")
+	}
+
+	var sb strings.Builder
+	for _, c := range strings.ToLower(candidate) {
+		if ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			sb.WriteRune(c)
+		}
+		if sb.Len() == maxOIDCUsernameLength {
+			break
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "user"
+	}
+	return sb.String()
+}
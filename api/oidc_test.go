@@ -0,0 +1,571 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/oidc"
+	mockoidc "github.com/amrizal94/simplebank/oidc/mock"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testOIDCClientID     = "simplebank"
+	testOIDCClientSecret = "secret"
+)
+
+// newTestOIDCServer returns a server signing users in at a mock identity provider
+func newTestOIDCServer(t *testing.T, store db.Store) (*Server, *mockoidc.IdP) {
+	idp, err := mockoidc.NewIdP(testOIDCClientID, testOIDCClientSecret)
+	require.NoError(t, err)
+	t.Cleanup(idp.Close)
+
+	config := newTestConfig(t)
+	config.OIDCIssuerURL = idp.Issuer()
+	config.OIDCClientID = testOIDCClientID
+	config.OIDCClientSecret = testOIDCClientSecret
+	config.OIDCRedirectURL = "http://localhost:8080/users/login/oidc/callback"
+	config.OIDCAuthRequestDuration = 10 * time.Minute
+
+	server, err := NewServer(config, store)
+	require.NoError(t, err)
+	return server, idp
+}
+
+// buildStartOIDCLoginStubs lets the login start, keeping the auth request it stores
+func buildStartOIDCLoginStubs(store *mockdb.MockStore, authRequest *db.OidcAuthRequest) {
+	store.EXPECT().
+		DeleteExpiredOIDCAuthRequests(gomock.Any()).
+		AnyTimes()
+	store.EXPECT().
+		CreateOIDCAuthRequest(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, arg db.CreateOIDCAuthRequestParams) (db.OidcAuthRequest, error) {
+			*authRequest = db.OidcAuthRequest{
+				HashedState:  arg.HashedState,
+				Nonce:        arg.Nonce,
+				CodeVerifier: arg.CodeVerifier,
+				ExpiredAt:    arg.ExpiredAt,
+			}
+			return *authRequest, nil
+		})
+}
+
+// startTestOIDCLogin starts a login and returns the authorization URL and state cookie it gets
+func startTestOIDCLogin(t *testing.T, server *Server) (string, *http.Cookie) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/users/login/oidc", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return recorder.Header().Get("Location"), cookies[0]
+}
+
+func TestStartOIDCLoginAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	var authRequest db.OidcAuthRequest
+	buildStartOIDCLoginStubs(store, &authRequest)
+
+	server, idp := newTestOIDCServer(t, store)
+	location, cookie := startTestOIDCLogin(t, server)
+
+	authURL, err := url.Parse(location)
+	require.NoError(t, err)
+	query := authURL.Query()
+	require.Equal(t, idp.Issuer()+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	require.Equal(t, testOIDCClientID, query.Get("client_id"))
+	require.Equal(t, authRequest.Nonce, query.Get("nonce"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.NotEqual(t, authRequest.CodeVerifier, query.Get("code_challenge"))
+
+	// only the hash of the state is stored, the browser keeps the state itself
+	require.Equal(t, util.HashSecretCode(query.Get("state")), authRequest.HashedState)
+	require.Equal(t, oidcStateCookie, cookie.Name)
+	require.Equal(t, query.Get("state"), cookie.Value)
+	require.True(t, cookie.HttpOnly)
+	require.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	require.WithinDuration(t, time.Now().Add(10*time.Minute), authRequest.ExpiredAt, time.Second)
+}
+
+func TestStartOIDCLoginAPIErrors(t *testing.T) {
+	t.Run("InternalError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().
+			DeleteExpiredOIDCAuthRequests(gomock.Any()).
+			AnyTimes()
+		store.EXPECT().
+			CreateOIDCAuthRequest(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(db.OidcAuthRequest{}, sql.ErrConnDone)
+
+		server, _ := newTestOIDCServer(t, store)
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/users/login/oidc", nil)
+		require.NoError(t, err)
+
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusInternalServerError, recorder.Code)
+	})
+
+	t.Run("ProviderUnreachable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().
+			CreateOIDCAuthRequest(gomock.Any(), gomock.Any()).
+			Times(0)
+
+		server, idp := newTestOIDCServer(t, store)
+		idp.Close()
+
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/users/login/oidc", nil)
+		require.NoError(t, err)
+
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusBadGateway, recorder.Code)
+	})
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		server := newTestServer(t, mockdb.NewMockStore(ctrl))
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/users/login/oidc", nil)
+		require.NoError(t, err)
+
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestFinishOIDCLoginAPI(t *testing.T) {
+	user, _ := randomUser()
+	user.IsEmailVerified = true
+
+	identity := mockoidc.Identity{
+		Subject:       util.RandomString(16),
+		Email:         user.Email,
+		EmailVerified: true,
+		Name:          user.FullName,
+	}
+	unverifiedIdentity := identity
+	unverifiedIdentity.EmailVerified = false
+	noEmailIdentity := identity
+	noEmailIdentity.Email = ""
+
+	frozenUser := user
+	frozenUser.IsFrozen = true
+	totpUser := user
+	totpUser.IsTotpEnabled = true
+
+	// the issuer is only known once the IdP is started, it isn't needed to match identities
+	getIdentity := func(store *mockdb.MockStore, username string) {
+		store.EXPECT().
+			GetUserIdentity(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(db.UserIdentity{Subject: identity.Subject, Username: username}, nil)
+	}
+	noIdentity := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			GetUserIdentity(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(db.UserIdentity{}, sql.ErrNoRows)
+	}
+
+	testCases := []struct {
+		desc     string
+		identity mockoidc.Identity
+		// setupCallback changes the callback of the IdP before it's sent
+		setupCallback func(query url.Values, request *http.Request, authRequest *db.OidcAuthRequest)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc:     "LinkedIdentity",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				getIdentity(store, user.Username)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchUser(t, recorder.Body, user)
+			},
+		},
+		{
+			desc:     "LinkVerifiedEmail",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				noIdentity(store)
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateUserIdentity(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateUserIdentityParams) (db.UserIdentity, error) {
+						require.Equal(t, identity.Subject, arg.Subject)
+						require.Equal(t, user.Username, arg.Username)
+						return db.UserIdentity{Issuer: arg.Issuer, Subject: arg.Subject, Username: arg.Username}, nil
+					})
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchUser(t, recorder.Body, user)
+			},
+		},
+		{
+			desc:     "NewUser",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				noIdentity(store)
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateOIDCUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateOIDCUserTxParams) (db.CreateOIDCUserTxResult, error) {
+						require.Equal(t, identity.Subject, arg.Subject)
+						require.Equal(t, identity.Email, arg.Email)
+						require.Equal(t, identity.Name, arg.FullName)
+						require.True(t, arg.IsEmailVerified)
+						require.Regexp(t, "^[a-z0-9]+$", arg.Username)
+
+						// the password is random, nobody knows it
+						require.NotEmpty(t, arg.HashedPassword)
+						return db.CreateOIDCUserTxResult{User: user}, nil
+					})
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchUser(t, recorder.Body, user)
+			},
+		},
+		{
+			desc:     "NewUserUsernameTaken",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				noIdentity(store)
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				gomock.InOrder(
+					store.EXPECT().
+						CreateOIDCUserTx(gomock.Any(), gomock.Any()).
+						Times(1).
+						Return(db.CreateOIDCUserTxResult{}, &pq.Error{Code: "23505", Constraint: "users_pkey"}),
+					store.EXPECT().
+						CreateOIDCUserTx(gomock.Any(), gomock.Any()).
+						Times(1).
+						Return(db.CreateOIDCUserTxResult{User: user}, nil),
+				)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			desc:     "UnverifiedEmailNotLinked",
+			identity: unverifiedIdentity,
+			buildStubs: func(store *mockdb.MockStore) {
+				noIdentity(store)
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateOIDCUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateOIDCUserTxResult{}, &pq.Error{Code: "23505", Constraint: "users_email_key"})
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errEmailTaken)
+			},
+		},
+		{
+			desc:     "LocalEmailNotVerified",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				unverifiedUser := user
+				unverifiedUser.IsEmailVerified = false
+
+				noIdentity(store)
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(unverifiedUser, nil)
+				store.EXPECT().
+					CreateUserIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateOIDCUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.CreateOIDCUserTxResult{}, &pq.Error{Code: "23505", Constraint: "users_email_key"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			desc:     "NoEmail",
+			identity: noEmailIdentity,
+			buildStubs: func(store *mockdb.MockStore) {
+				noIdentity(store)
+				store.EXPECT().
+					CreateOIDCUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errOIDCEmailRequired)
+			},
+		},
+		{
+			desc:     "FrozenUser",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				getIdentity(store, user.Username)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(frozenUser, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errFrozenUser)
+			},
+		},
+		{
+			desc:     "TOTPChallenge",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				getIdentity(store, user.Username)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(totpUser, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"totp_required":true`)
+			},
+		},
+		{
+			desc:     "NoStateCookie",
+			identity: identity,
+			setupCallback: func(query url.Values, request *http.Request, authRequest *db.OidcAuthRequest) {
+				request.Header.Del("Cookie")
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UseOIDCAuthRequest(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errInvalidOIDCState)
+			},
+		},
+		{
+			desc:     "StateOfAnotherBrowser",
+			identity: identity,
+			setupCallback: func(query url.Values, request *http.Request, authRequest *db.OidcAuthRequest) {
+				request.Header.Del("Cookie")
+				request.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: util.RandomString(43)})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UseOIDCAuthRequest(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errInvalidOIDCState)
+			},
+		},
+		{
+			desc:     "StateUsedOrExpired",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UseOIDCAuthRequest(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OidcAuthRequest{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireBodyMatchError(t, recorder.Body, errInvalidOIDCState)
+			},
+		},
+		{
+			desc:     "WrongNonce",
+			identity: identity,
+			setupCallback: func(query url.Values, request *http.Request, authRequest *db.OidcAuthRequest) {
+				authRequest.Nonce = util.RandomString(43)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc:     "WrongCodeVerifier",
+			identity: identity,
+			setupCallback: func(query url.Values, request *http.Request, authRequest *db.OidcAuthRequest) {
+				authRequest.CodeVerifier = util.RandomString(43)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc:     "ProviderRefused",
+			identity: identity,
+			setupCallback: func(query url.Values, request *http.Request, authRequest *db.OidcAuthRequest) {
+				query.Del("code")
+				query.Set("error", "access_denied")
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc:     "InternalError",
+			identity: identity,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserIdentity(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserIdentity{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			var authRequest db.OidcAuthRequest
+			tC.buildStubs(store)
+			buildStartOIDCLoginStubs(store, &authRequest)
+			store.EXPECT().
+				UseOIDCAuthRequest(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				DoAndReturn(func(_ context.Context, hashedState string) (db.OidcAuthRequest, error) {
+					require.Equal(t, authRequest.HashedState, hashedState)
+					return authRequest, nil
+				})
+
+			server, idp := newTestOIDCServer(t, store)
+			authURL, cookie := startTestOIDCLogin(t, server)
+
+			code, state, err := idp.Authorize(authURL, tC.identity)
+			require.NoError(t, err)
+
+			query := url.Values{}
+			query.Set("code", code)
+			query.Set("state", state)
+
+			request, err := http.NewRequest(http.MethodGet, "", nil)
+			require.NoError(t, err)
+			request.AddCookie(cookie)
+			if tC.setupCallback != nil {
+				tC.setupCallback(query, request, &authRequest)
+			}
+			request.URL, err = url.Parse("/users/login/oidc/callback?" + query.Encode())
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestOIDCUsername(t *testing.T) {
+	testCases := []struct {
+		preferredUsername string
+		email             string
+		username          string
+	}{
+		{"Jane.Doe", "jane@example.com", "janedoe"},
+		{"", "john.smith+bank@example.com", "johnsmithbank"},
+		{"", "", "user"},
+		{"ÉLODIE", "", "lodie"},
+		{"a-very-long-preferred-username-from-the-directory", "", "averylongpreferredusernamefromthe"[:maxOIDCUsernameLength]},
+	}
+
+	for _, tC := range testCases {
+		idToken := &oidc.IDToken{PreferredUsername: tC.preferredUsername, Email: tC.email}
+		require.Equal(t, tC.username, oidcUsername(idToken))
+	}
+}
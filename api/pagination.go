@@ -0,0 +1,189 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+)
+
+// minCursorKeySize is the smallest key cursors are signed with
+const minCursorKeySize = 32
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageRequest holds the pagination parameters of list requests
+type pageRequest struct {
+	Cursor   string `form:"cursor"`
+	PageSize int32  `form:"page_size" binding:"omitempty,min=1"`
+}
+
+// pageCursor points at the row a page starts after, in the order of the list.
+// Rows are ordered by (created_at, id), which doesn't change as rows are added.
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+	// Reverse cursors point at the page before the row instead
+	Reverse bool `json:"r,omitempty"`
+}
+
+// page is a validated pageRequest
+type page struct {
+	size int32
+	// cursor is nil on the first page
+	cursor *pageCursor
+}
+
+// pageLinks holds the cursors of the pages around a page, if there are any
+type pageLinks struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// paginator reads and writes the opaque cursors of lists.
+// Cursors are signed so clients can't seek to rows the list wouldn't get to.
+type paginator struct {
+	key             []byte
+	defaultPageSize int32
+	maxPageSize     int32
+}
+
+func newPaginator(config util.Config) (*paginator, error) {
+	if len(config.PaginationCursorKey) < minCursorKeySize {
+		return nil, fmt.Errorf("invalid cursor key size: must be at least %d characters", minCursorKeySize)
+	}
+	if config.PaginationDefaultPageSize < 1 || config.PaginationMaxPageSize < config.PaginationDefaultPageSize {
+		return nil, fmt.Errorf("invalid page sizes: default %d, max %d", config.PaginationDefaultPageSize, config.PaginationMaxPageSize)
+	}
+
+	return &paginator{
+		key:             []byte(config.PaginationCursorKey),
+		defaultPageSize: int32(config.PaginationDefaultPageSize),
+		maxPageSize:     int32(config.PaginationMaxPageSize),
+	}, nil
+}
+
+// page validates the request of a page of list. A cursor is only valid for the list it was made for.
+func (p *paginator) page(list string, req pageRequest) (page, error) {
+	pg := page{size: p.defaultPageSize}
+	if req.PageSize > 0 {
+		if req.PageSize > p.maxPageSize {
+			return pg, fmt.Errorf("page_size must be at most %d", p.maxPageSize)
+		}
+		pg.size = req.PageSize
+	}
+
+	if req.Cursor == "" {
+		return pg, nil
+	}
+
+	payload, signature, ok := strings.Cut(req.Cursor, ".")
+	if !ok {
+		return pg, errInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return pg, errInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, p.sign(list, data)) {
+		return pg, errInvalidCursor
+	}
+
+	pg.cursor = &pageCursor{}
+	if err := json.Unmarshal(data, pg.cursor); err != nil {
+		return pg, errInvalidCursor
+	}
+	return pg, nil
+}
+
+func (p *paginator) cursor(list string, cursor pageCursor) string {
+	// a cursor is made of values that always marshal
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data) + "." +
+		base64.RawURLEncoding.EncodeToString(p.sign(list, data))
+}
+
+func (p *paginator) sign(list string, data []byte) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(list))
+	mac.Write([]byte{0})
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// limit is the number of rows to read for the page,
+// one more than its size to know whether there are more
+func (pg page) limit() int32 {
+	return pg.size + 1
+}
+
+func (pg page) reverse() bool {
+	return pg.cursor != nil && pg.cursor.Reverse
+}
+
+func (pg page) cursorCreatedAt() sql.NullTime {
+	if pg.cursor == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: pg.cursor.CreatedAt, Valid: true}
+}
+
+func (pg page) cursorID() sql.NullInt64 {
+	if pg.cursor == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: pg.cursor.ID, Valid: true}
+}
+
+// finishPage trims the rows read for the page to its size, puts them in the order of the list
+// and links the pages around it. Reverse pages are read in the opposite order.
+func finishPage[T any](p *paginator, list string, pg page, rows []T, key func(T) (time.Time, int64)) ([]T, pageLinks) {
+	more := len(rows) > int(pg.size)
+	if more {
+		rows = rows[:pg.size]
+	}
+
+	reverse := pg.reverse()
+	if reverse {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	var links pageLinks
+	if len(rows) == 0 {
+		return rows, links
+	}
+
+	// there is a next page when more rows were read going forward, or when coming back from it
+	if more || reverse {
+		createdAt, id := key(rows[len(rows)-1])
+		links.NextCursor = p.cursor(list, pageCursor{CreatedAt: createdAt, ID: id})
+	}
+	if (reverse && more) || (!reverse && pg.cursor != nil) {
+		createdAt, id := key(rows[0])
+		links.PrevCursor = p.cursor(list, pageCursor{CreatedAt: createdAt, ID: id, Reverse: true})
+	}
+	return rows, links
+}
+
+// pageResponse is the response of a page of a list, with the items under name
+func pageResponse(name string, items interface{}, links pageLinks) gin.H {
+	rsp := gin.H{name: items}
+	if links.NextCursor != "" {
+		rsp["next_cursor"] = links.NextCursor
+	}
+	if links.PrevCursor != "" {
+		rsp["prev_cursor"] = links.PrevCursor
+	}
+	return rsp
+}
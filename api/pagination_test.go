@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPaginator(t *testing.T) *paginator {
+	p, err := newPaginator(newTestConfig(t))
+	require.NoError(t, err)
+	return p
+}
+
+func TestNewPaginator(t *testing.T) {
+	config := newTestConfig(t)
+	config.PaginationCursorKey = util.RandomString(minCursorKeySize - 1)
+	_, err := newPaginator(config)
+	require.Error(t, err)
+
+	config = newTestConfig(t)
+	config.PaginationMaxPageSize = config.PaginationDefaultPageSize - 1
+	_, err = newPaginator(config)
+	require.Error(t, err)
+
+	config = newTestConfig(t)
+	config.PaginationDefaultPageSize = 0
+	_, err = newPaginator(config)
+	require.Error(t, err)
+}
+
+func TestPaginatorPage(t *testing.T) {
+	p := newTestPaginator(t)
+
+	pg, err := p.page("list", pageRequest{})
+	require.NoError(t, err)
+	require.Equal(t, p.defaultPageSize, pg.size)
+	require.Nil(t, pg.cursor)
+	require.False(t, pg.cursorCreatedAt().Valid)
+	require.False(t, pg.cursorID().Valid)
+
+	_, err = p.page("list", pageRequest{PageSize: p.maxPageSize + 1})
+	require.Error(t, err)
+
+	cursor := pageCursor{
+		CreatedAt: time.Now().Round(time.Microsecond),
+		ID:        util.RandomInt(1, 1000),
+		Reverse:   true,
+	}
+	encoded := p.cursor("list", cursor)
+
+	pg, err = p.page("list", pageRequest{Cursor: encoded, PageSize: 7})
+	require.NoError(t, err)
+	require.Equal(t, int32(7), pg.size)
+	require.Equal(t, int32(8), pg.limit())
+	require.True(t, pg.reverse())
+	require.True(t, pg.cursorCreatedAt().Time.Equal(cursor.CreatedAt))
+	require.Equal(t, cursor.ID, pg.cursorID().Int64)
+
+	invalidCursors := map[string]string{
+		"OtherList": p.cursor("other", cursor),
+		"Tampered":  "x" + encoded,
+		"NoMAC":     encoded[:len(encoded)-44],
+		"Garbage":   "not a cursor",
+	}
+	for name, invalid := range invalidCursors {
+		t.Run(name, func(t *testing.T) {
+			_, err := p.page("list", pageRequest{Cursor: invalid})
+			require.ErrorIs(t, err, errInvalidCursor)
+		})
+	}
+
+	other := newTestPaginator(t)
+	_, err = other.page("list", pageRequest{Cursor: encoded})
+	require.ErrorIs(t, err, errInvalidCursor)
+}
+
+type testRow struct {
+	createdAt time.Time
+	id        int64
+}
+
+func testRowKey(row testRow) (time.Time, int64) {
+	return row.createdAt, row.id
+}
+
+func TestFinishPage(t *testing.T) {
+	p := newTestPaginator(t)
+
+	rows := make([]testRow, 5)
+	for i := range rows {
+		rows[i] = testRow{createdAt: time.Date(2023, 1, 1, i, 0, 0, 0, time.UTC), id: int64(i + 1)}
+	}
+
+	cursorPage := func(row testRow, reverse bool) page {
+		return page{size: 2, cursor: &pageCursor{CreatedAt: row.createdAt, ID: row.id, Reverse: reverse}}
+	}
+	requireCursor := func(encoded string, row testRow, reverse bool) {
+		pg, err := p.page("list", pageRequest{Cursor: encoded})
+		require.NoError(t, err)
+		require.True(t, pg.cursor.CreatedAt.Equal(row.createdAt))
+		require.Equal(t, row.id, pg.cursor.ID)
+		require.Equal(t, reverse, pg.cursor.Reverse)
+	}
+
+	// first page with more after it
+	got, links := finishPage(p, "list", page{size: 2}, append([]testRow{}, rows[:3]...), testRowKey)
+	require.Equal(t, rows[:2], got)
+	requireCursor(links.NextCursor, rows[1], false)
+	require.Empty(t, links.PrevCursor)
+
+	// last page
+	got, links = finishPage(p, "list", cursorPage(rows[2], false), append([]testRow{}, rows[3:]...), testRowKey)
+	require.Equal(t, rows[3:], got)
+	require.Empty(t, links.NextCursor)
+	requireCursor(links.PrevCursor, rows[3], true)
+
+	// going back with more before
+	got, links = finishPage(p, "list", cursorPage(rows[3], true), []testRow{rows[2], rows[1], rows[0]}, testRowKey)
+	require.Equal(t, rows[1:3], got)
+	requireCursor(links.NextCursor, rows[2], false)
+	requireCursor(links.PrevCursor, rows[1], true)
+
+	// going back to the first page
+	got, links = finishPage(p, "list", cursorPage(rows[2], true), []testRow{rows[1], rows[0]}, testRowKey)
+	require.Equal(t, rows[:2], got)
+	requireCursor(links.NextCursor, rows[1], false)
+	require.Empty(t, links.PrevCursor)
+
+	got, links = finishPage(p, "list", page{size: 2}, []testRow{}, testRowKey)
+	require.Empty(t, got)
+	require.Empty(t, links)
+}
+
+func requireBodyPageLinks(t *testing.T, body *bytes.Buffer) pageLinks {
+	var links pageLinks
+	err := json.Unmarshal(body.Bytes(), &links)
+	require.NoError(t, err)
+	return links
+}
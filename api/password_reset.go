@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+)
+
+// passwordResetEmailTimeout bounds sending a password reset, which outlives its request
+const passwordResetEmailTimeout = time.Minute
+
+var (
+	errInvalidPasswordReset  = errors.New("invalid or expired password reset token")
+	errTooManyPasswordResets = errors.New("too many password reset requests, try again later")
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// forgotPasswordResponse is the response of forgotPassword, whether or not the email belongs to a user
+var forgotPasswordResponse = gin.H{
+	"message": "if the email belongs to an account, a password reset link has been sent to it",
+}
+
+// forgotPassword emails a one-time password reset link to the owner of the email.
+// The link is created and sent after the response, so a registered email
+// takes no longer to answer than an unknown one.
+func (server *Server) forgotPassword(ctx *gin.Context) {
+	var req forgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !server.checkPasswordResetLimit(ctx, req.Email) {
+		return
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusAccepted, forgotPasswordResponse)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// failures from here on are only logged,
+	// a different response would reveal that the email exists
+	server.background.Add(1)
+	go func() {
+		defer server.background.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetEmailTimeout)
+		defer cancel()
+
+		if err := server.sendPasswordReset(ctx, user); err != nil {
+			log.Printf("cannot send password reset to %s: %v", user.Username, err)
+		}
+	}()
+
+	ctx.JSON(http.StatusAccepted, forgotPasswordResponse)
+}
+
+// checkPasswordResetLimit counts the request against the email and the client IP,
+// and aborts with 429 and returns false once either is over its limit.
+// Unknown emails are counted like registered ones, so the limit reveals nothing about them.
+func (server *Server) checkPasswordResetLimit(ctx *gin.Context, email string) bool {
+	limits := []struct {
+		key string
+		max int
+	}{
+		{"password_reset:email:" + strings.ToLower(email), server.config.PasswordResetMaxPerEmail},
+		{"password_reset:ip:" + ctx.ClientIP(), server.config.PasswordResetMaxPerIP},
+	}
+
+	for _, limit := range limits {
+		allowed, err := server.passwordResetLimiter.allow(ctx, limit.key, limit.max)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return false
+		}
+		if !allowed {
+			ctx.Header("Retry-After", strconv.Itoa(int(server.config.PasswordResetRateWindow.Seconds())))
+			ctx.JSON(http.StatusTooManyRequests, errorResponse(errTooManyPasswordResets))
+			return false
+		}
+	}
+
+	return true
+}
+
+// sendPasswordReset creates a password reset token for the user and emails them the link to use it
+func (server *Server) sendPasswordReset(ctx context.Context, user db.User) error {
+	resetToken, err := util.GenerateSecretCode()
+	if err != nil {
+		return err
+	}
+
+	_, err = server.store.CreatePasswordReset(ctx, db.CreatePasswordResetParams{
+		Username:    user.Username,
+		HashedToken: util.HashSecretCode(resetToken),
+		ExpiredAt:   time.Now().Add(server.config.PasswordResetDuration),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create password reset: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("token", resetToken)
+	resetURL := server.config.PasswordResetURL + "?" + query.Encode()
+
+	subject := "Reset your Simple Bank password"
+	content := fmt.Sprintf(`Hello %s,<br/>
+We received a request to reset your password.<br/>
+Please <a href="%s">click here</a> to choose a new one. The link expires in %s.<br/>
+If you didn't ask for it, you can ignore this email.<br/>
+`, html.EscapeString(user.FullName), html.EscapeString(resetURL), server.config.PasswordResetDuration)
+
+	return server.mailer.SendEmail(subject, content, []string{user.Email})
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,password"`
+}
+
+// resetPassword sets a new password with a token sent by forgotPassword
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server.bindingErrorResponse(err))
+		return
+	}
+
+	hashedPassword, err := server.hashPassword(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	result, err := server.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		HashedToken:    util.HashSecretCode(req.Token),
+		HashedPassword: hashedPassword,
+		CheckUser: func(user db.User) error {
+			return server.passwordPolicy.CheckUserInputs(req.NewPassword, user.Username, user.Email)
+		},
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errInvalidPasswordReset))
+			return
+		}
+		if errors.Is(err, util.ErrPasswordPersonal) {
+			ctx.JSON(http.StatusBadRequest, fieldErrorResponse("new_password", err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// proving ownership of the email lifts a lockout caused by the forgotten password
+	err = server.loginThrottle.reset(ctx, result.User.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
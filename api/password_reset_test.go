@@ -0,0 +1,368 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/mail"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForgotPasswordAPI(t *testing.T) {
+	user, _ := randomUser()
+
+	var hashedToken string
+
+	testCases := []struct {
+		desc          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender)
+	}{
+		{
+			desc: "OK",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
+						require.Equal(t, user.Username, arg.Username)
+						hashedToken = arg.HashedToken
+						return db.PasswordReset{Username: arg.Username, HashedToken: arg.HashedToken}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusAccepted, recoder.Code)
+				requireBodyMatchForgotPassword(t, recoder.Body)
+
+				emails := mailer.Emails()
+				require.Len(t, emails, 1)
+				require.Equal(t, []string{user.Email}, emails[0].To)
+
+				link := regexp.MustCompile(`href="([^"]+)"`).FindStringSubmatch(emails[0].Content)
+				require.Len(t, link, 2)
+
+				resetURL, err := url.Parse(html.UnescapeString(link[1]))
+				require.NoError(t, err)
+				require.Equal(t, hashedToken, util.HashSecretCode(resetURL.Query().Get("token")))
+			},
+		},
+		{
+			desc: "UnknownEmail",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusAccepted, recoder.Code)
+				requireBodyMatchForgotPassword(t, recoder.Body)
+				require.Empty(t, mailer.Emails())
+			},
+		},
+		{
+			desc: "CreatePasswordResetError",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.PasswordReset{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusAccepted, recoder.Code)
+				requireBodyMatchForgotPassword(t, recoder.Body)
+				require.Empty(t, mailer.Emails())
+			},
+		},
+		{
+			desc: "InternalError",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			desc: "TooManyForEmail",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					HitRateLimit(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.HitRateLimitParams) (db.RateLimit, error) {
+						require.Equal(t, "password_reset:email:"+strings.ToLower(user.Email), arg.Key)
+						return db.RateLimit{Key: arg.Key, Hits: 4}, nil
+					})
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusTooManyRequests, recoder.Code)
+				require.NotEmpty(t, recoder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			desc: "TooManyForIP",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					HitRateLimit(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ interface{}, arg db.HitRateLimitParams) (db.RateLimit, error) {
+						if strings.HasPrefix(arg.Key, "password_reset:ip:") {
+							return db.RateLimit{Key: arg.Key, Hits: 21}, nil
+						}
+						return db.RateLimit{Key: arg.Key, Hits: 1}, nil
+					})
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusTooManyRequests, recoder.Code)
+			},
+		},
+		{
+			desc: "RateLimitError",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					HitRateLimit(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RateLimit{}, sql.ErrConnDone)
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			desc: "InvalidEmail",
+			body: gin.H{"email": "not-an-email"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildRateLimitStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tC.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/password/forgot", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			server.background.Wait()
+			tC.checkResponse(recorder, server.mailer.(*mail.MemorySender))
+		})
+	}
+}
+
+// buildRateLimitStubs lets every request through the rate limits
+func buildRateLimitStubs(store *mockdb.MockStore) {
+	store.EXPECT().
+		HitRateLimit(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ interface{}, arg db.HitRateLimitParams) (db.RateLimit, error) {
+			return db.RateLimit{Key: arg.Key, Hits: 1}, nil
+		})
+}
+
+func TestResetPasswordAPI(t *testing.T) {
+	user, _ := randomUser()
+	resetToken, err := util.GenerateSecretCode()
+	require.NoError(t, err)
+
+	newPassword := util.RandomPassword()
+
+	testCases := []struct {
+		desc          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+						require.Equal(t, util.HashSecretCode(resetToken), arg.HashedToken)
+						require.NoError(t, util.CheckPassword(newPassword, arg.HashedPassword))
+						require.NoError(t, arg.CheckUser(user))
+						return db.ResetPasswordTxResult{User: user}, nil
+					})
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recoder.Code)
+			},
+		},
+		{
+			desc: "InvalidOrExpiredToken",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPasswordTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, errInvalidPasswordReset)
+			},
+		},
+		{
+			desc: "InternalError",
+			body: gin.H{"token": resetToken, "new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPasswordTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			desc: "PasswordContainsUsername",
+			body: gin.H{"token": resetToken, "new_password": user.Username + newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+						return db.ResetPasswordTxResult{}, arg.CheckUser(user)
+					})
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+				requireBodyMatchFieldError(t, recoder.Body, "new_password", util.ErrPasswordPersonal)
+			},
+		},
+		{
+			desc: "WeakPassword",
+			body: gin.H{"token": resetToken, "new_password": "password1"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+				requireBodyMatchFieldError(t, recoder.Body, "new_password", util.ErrPasswordTooWeak)
+			},
+		},
+		{
+			desc: "PasswordTooShort",
+			body: gin.H{"token": resetToken, "new_password": "abc"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "MissingToken",
+			body: gin.H{"new_password": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tC.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/password/reset", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func requireBodyMatchForgotPassword(t *testing.T, body *bytes.Buffer) {
+	expected, err := json.Marshal(forgotPasswordResponse)
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), body.String())
+}
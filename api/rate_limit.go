@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+)
+
+const rateLimitPruneInterval = time.Minute
+
+// rateLimiter counts requests per key in the rate_limits table over a fixed window,
+// so the limit holds across server instances
+type rateLimiter struct {
+	store  db.Store
+	window time.Duration
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+func newRateLimiter(store db.Store, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		store:      store,
+		window:     window,
+		lastPruned: time.Now(),
+	}
+}
+
+// allow counts a request against the key and reports whether it's within max requests per window
+func (limiter *rateLimiter) allow(ctx context.Context, key string, max int) (bool, error) {
+	rateLimit, err := limiter.store.HitRateLimit(ctx, db.HitRateLimitParams{
+		Key:         key,
+		WindowStart: time.Now().Add(-limiter.window),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	limiter.prune(ctx)
+	return int(rateLimit.Hits) <= max, nil
+}
+
+// prune drops the counts of windows that are over
+func (limiter *rateLimiter) prune(ctx context.Context) {
+	now := time.Now()
+
+	limiter.mu.Lock()
+	if now.Sub(limiter.lastPruned) < rateLimitPruneInterval {
+		limiter.mu.Unlock()
+		return
+	}
+	limiter.lastPruned = now
+	limiter.mu.Unlock()
+
+	// best effort, a stale count is reset by the next request anyway
+	_ = limiter.store.DeleteStaleRateLimits(ctx, now.Add(-limiter.window))
+}
@@ -0,0 +1,420 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/schedule"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/gin-gonic/gin"
+)
+
+type scheduledTransferResponse struct {
+	ID            int64      `json:"id"`
+	Owner         string     `json:"owner"`
+	FromAccountID int64      `json:"from_account_id"`
+	ToAccountID   int64      `json:"to_account_id"`
+	Amount        int64      `json:"amount"`
+	Schedule      string     `json:"schedule,omitempty"`
+	StartAt       time.Time  `json:"start_at"`
+	EndAt         *time.Time `json:"end_at,omitempty"`
+	NextRunAt     time.Time  `json:"next_run_at"`
+	// FailedAttempts counts the failed runs due at NextRunAt
+	FailedAttempts int32     `json:"failed_attempts"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func newScheduledTransferResponse(scheduledTransfer db.ScheduledTransfer) scheduledTransferResponse {
+	rsp := scheduledTransferResponse{
+		ID:             scheduledTransfer.ID,
+		Owner:          scheduledTransfer.Owner,
+		FromAccountID:  scheduledTransfer.FromAccountID,
+		ToAccountID:    scheduledTransfer.ToAccountID,
+		Amount:         scheduledTransfer.Amount,
+		Schedule:       scheduledTransfer.Schedule,
+		StartAt:        scheduledTransfer.StartAt,
+		NextRunAt:      scheduledTransfer.NextRunAt,
+		FailedAttempts: scheduledTransfer.FailedAttempts,
+		Status:         scheduledTransfer.Status,
+		CreatedAt:      scheduledTransfer.CreatedAt,
+		UpdatedAt:      scheduledTransfer.UpdatedAt,
+	}
+	if scheduledTransfer.EndAt.Valid {
+		rsp.EndAt = &scheduledTransfer.EndAt.Time
+	}
+	return rsp
+}
+
+type scheduledTransferRunResponse struct {
+	ID         int64     `json:"id"`
+	DueAt      time.Time `json:"due_at"`
+	Attempt    int32     `json:"attempt"`
+	Status     string    `json:"status"`
+	TransferID *int64    `json:"transfer_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newScheduledTransferRunResponse(run db.ScheduledTransferRun) scheduledTransferRunResponse {
+	rsp := scheduledTransferRunResponse{
+		ID:        run.ID,
+		DueAt:     run.DueAt,
+		Attempt:   run.Attempt,
+		Status:    run.Status,
+		Error:     run.Error,
+		CreatedAt: run.CreatedAt,
+	}
+	if run.TransferID.Valid {
+		rsp.TransferID = &run.TransferID.Int64
+	}
+	return rsp
+}
+
+type createScheduledTransferRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	// Schedule is a cron expression or an RRULE, a one-off transfer has none
+	Schedule string     `json:"schedule"`
+	StartAt  time.Time  `json:"start_at" binding:"required"`
+	EndAt    *time.Time `json:"end_at"`
+}
+
+// createScheduledTransfer schedules a transfer from an account of the authenticated user,
+// once at start_at or on every run of a schedule from start_at until end_at
+func (server *Server) createScheduledTransfer(ctx *gin.Context) {
+	var req createScheduledTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !req.StartAt.After(time.Now()) {
+		err := errors.New("start_at must be in the future")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	nextRunAt := req.StartAt
+	if req.Schedule != "" {
+		recurrence, err := schedule.Parse(req.Schedule, req.StartAt)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		nextRunAt = schedule.First(recurrence, req.StartAt)
+		if nextRunAt.IsZero() {
+			err := errors.New("schedule never runs")
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	} else if req.EndAt != nil {
+		err := errors.New("end_at requires a schedule")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.EndAt != nil && req.EndAt.Before(nextRunAt) {
+		err := fmt.Errorf("end_at must not be before the first run at %s", nextRunAt.Format(time.RFC3339))
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !user.IsEmailVerified {
+		ctx.JSON(http.StatusForbidden, errorResponse(errEmailNotVerified))
+		return
+	}
+
+	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	if fromAccount.Owner != authPayload.Username {
+		err := errors.New("from account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	arg := db.CreateScheduledTransferParams{
+		Owner:         authPayload.Username,
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Amount:        req.Amount,
+		Schedule:      req.Schedule,
+		StartAt:       req.StartAt,
+		NextRunAt:     nextRunAt,
+	}
+	if req.EndAt != nil {
+		arg.EndAt = sql.NullTime{Time: *req.EndAt, Valid: true}
+	}
+
+	scheduledTransfer, err := server.store.CreateScheduledTransfer(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"scheduled_transfer": newScheduledTransferResponse(scheduledTransfer)})
+}
+
+type getScheduledTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// ownScheduledTransfer returns the scheduled transfer of the uri if it belongs to the authenticated user
+func (server *Server) ownScheduledTransfer(ctx *gin.Context) (db.ScheduledTransfer, bool) {
+	var req getScheduledTransferRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.ScheduledTransfer{}, false
+	}
+
+	scheduledTransfer, err := server.store.GetScheduledTransfer(ctx, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "scheduled transfer not found"})
+			return scheduledTransfer, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return scheduledTransfer, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if scheduledTransfer.Owner != authPayload.Username {
+		err := errors.New("scheduled transfer doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return scheduledTransfer, false
+	}
+
+	return scheduledTransfer, true
+}
+
+func (server *Server) getScheduledTransfer(ctx *gin.Context) {
+	scheduledTransfer, ok := server.ownScheduledTransfer(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"scheduled_transfer": newScheduledTransferResponse(scheduledTransfer)})
+}
+
+func (server *Server) listScheduledTransfers(ctx *gin.Context) {
+	var req pageRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	list := "scheduled_transfers:" + authPayload.Username
+	page, err := server.paginator.page(list, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var scheduledTransfers []db.ScheduledTransfer
+	if page.reverse() {
+		scheduledTransfers, err = server.store.ListScheduledTransfersReverse(ctx, db.ListScheduledTransfersReverseParams{
+			Owner:           authPayload.Username,
+			CursorCreatedAt: page.cursor.CreatedAt,
+			CursorID:        page.cursor.ID,
+			Limit:           page.limit(),
+		})
+	} else {
+		scheduledTransfers, err = server.store.ListScheduledTransfers(ctx, db.ListScheduledTransfersParams{
+			Owner:           authPayload.Username,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			Limit:           page.limit(),
+		})
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	scheduledTransfers, links := finishPage(server.paginator, list, page, scheduledTransfers, func(scheduledTransfer db.ScheduledTransfer) (time.Time, int64) {
+		return scheduledTransfer.CreatedAt, scheduledTransfer.ID
+	})
+
+	rsp := make([]scheduledTransferResponse, len(scheduledTransfers))
+	for i, scheduledTransfer := range scheduledTransfers {
+		rsp[i] = newScheduledTransferResponse(scheduledTransfer)
+	}
+	ctx.JSON(http.StatusOK, pageResponse("scheduled_transfers", rsp, links))
+}
+
+type updateScheduledTransferRequest struct {
+	Amount *int64     `json:"amount" binding:"omitempty,gt=0"`
+	EndAt  *time.Time `json:"end_at"`
+	Status *string    `json:"status" binding:"omitempty,oneof=active paused"`
+}
+
+// updateScheduledTransfer changes the amount or end of a scheduled transfer, or pauses and resumes it.
+// A resumed schedule skips the runs it missed while it was paused.
+func (server *Server) updateScheduledTransfer(ctx *gin.Context) {
+	scheduledTransfer, ok := server.ownScheduledTransfer(ctx)
+	if !ok {
+		return
+	}
+
+	var req updateScheduledTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !isPendingScheduledTransfer(ctx, scheduledTransfer) {
+		return
+	}
+
+	arg := db.UpdateScheduledTransferParams{
+		ID: scheduledTransfer.ID,
+	}
+	if req.Amount != nil {
+		arg.Amount = sql.NullInt64{Int64: *req.Amount, Valid: true}
+	}
+
+	nextRunAt := scheduledTransfer.NextRunAt
+	if req.Status != nil && *req.Status != scheduledTransfer.Status {
+		arg.Status = sql.NullString{String: *req.Status, Valid: true}
+
+		now := time.Now()
+		if *req.Status == db.ScheduledTransferActive && scheduledTransfer.Schedule != "" && nextRunAt.Before(now) {
+			recurrence, err := schedule.Parse(scheduledTransfer.Schedule, scheduledTransfer.StartAt)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			nextRunAt = recurrence.Next(now)
+			arg.NextRunAt = sql.NullTime{Time: nextRunAt, Valid: true}
+		}
+	}
+
+	if req.EndAt != nil {
+		if scheduledTransfer.Schedule == "" {
+			err := errors.New("end_at requires a schedule")
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		if req.EndAt.Before(nextRunAt) {
+			err := fmt.Errorf("end_at must not be before the next run at %s", nextRunAt.Format(time.RFC3339))
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		arg.EndAt = sql.NullTime{Time: *req.EndAt, Valid: true}
+	}
+
+	scheduledTransfer, err := server.store.UpdateScheduledTransfer(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"scheduled_transfer": newScheduledTransferResponse(scheduledTransfer)})
+}
+
+// deleteScheduledTransfer cancels a scheduled transfer. It is kept with its runs.
+func (server *Server) deleteScheduledTransfer(ctx *gin.Context) {
+	scheduledTransfer, ok := server.ownScheduledTransfer(ctx)
+	if !ok {
+		return
+	}
+
+	if !isPendingScheduledTransfer(ctx, scheduledTransfer) {
+		return
+	}
+
+	scheduledTransfer, err := server.store.UpdateScheduledTransfer(ctx, db.UpdateScheduledTransferParams{
+		ID:     scheduledTransfer.ID,
+		Status: sql.NullString{String: db.ScheduledTransferCancelled, Valid: true},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"scheduled_transfer": newScheduledTransferResponse(scheduledTransfer)})
+}
+
+// isPendingScheduledTransfer checks a scheduled transfer can still run, once it's over it can't be changed
+func isPendingScheduledTransfer(ctx *gin.Context, scheduledTransfer db.ScheduledTransfer) bool {
+	if scheduledTransfer.Status != db.ScheduledTransferActive && scheduledTransfer.Status != db.ScheduledTransferPaused {
+		err := fmt.Errorf("scheduled transfer is %s", scheduledTransfer.Status)
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		return false
+	}
+	return true
+}
+
+// listScheduledTransferRuns returns the runs of a scheduled transfer, newest first
+func (server *Server) listScheduledTransferRuns(ctx *gin.Context) {
+	scheduledTransfer, ok := server.ownScheduledTransfer(ctx)
+	if !ok {
+		return
+	}
+
+	var req pageRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	list := fmt.Sprintf("scheduled_transfer_runs:%d", scheduledTransfer.ID)
+	page, err := server.paginator.page(list, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var runs []db.ScheduledTransferRun
+	if page.reverse() {
+		runs, err = server.store.ListScheduledTransferRunsReverse(ctx, db.ListScheduledTransferRunsReverseParams{
+			ScheduledTransferID: scheduledTransfer.ID,
+			CursorCreatedAt:     page.cursor.CreatedAt,
+			CursorID:            page.cursor.ID,
+			Limit:               page.limit(),
+		})
+	} else {
+		runs, err = server.store.ListScheduledTransferRuns(ctx, db.ListScheduledTransferRunsParams{
+			ScheduledTransferID: scheduledTransfer.ID,
+			CursorCreatedAt:     page.cursorCreatedAt(),
+			CursorID:            page.cursorID(),
+			Limit:               page.limit(),
+		})
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	runs, links := finishPage(server.paginator, list, page, runs, func(run db.ScheduledTransferRun) (time.Time, int64) {
+		return run.CreatedAt, run.ID
+	})
+
+	rsp := make([]scheduledTransferRunResponse, len(runs))
+	for i, run := range runs {
+		rsp[i] = newScheduledTransferRunResponse(run)
+	}
+	ctx.JSON(http.StatusOK, pageResponse("runs", rsp, links))
+}
@@ -0,0 +1,653 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateScheduledTransferAPI(t *testing.T) {
+	user1, _ := randomUser()
+	user2, _ := randomUser()
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	startAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	endAt := startAt.AddDate(1, 0, 0)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OneOff",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"start_at":        startAt,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+
+				arg := db.CreateScheduledTransferParams{
+					Owner:         user1.Username,
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        100,
+					StartAt:       startAt,
+					NextRunAt:     startAt,
+				}
+				store.EXPECT().
+					CreateScheduledTransfer(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.ScheduledTransfer{ID: 1, Owner: arg.Owner, NextRunAt: arg.NextRunAt, Status: db.ScheduledTransferActive}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				scheduledTransfer := requireBodyScheduledTransfer(t, recorder.Body)
+				require.Equal(t, int64(1), scheduledTransfer.ID)
+				require.True(t, scheduledTransfer.NextRunAt.Equal(startAt))
+			},
+		},
+		{
+			name: "Recurring",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"schedule":        "0 9 1 * *",
+				"start_at":        startAt,
+				"end_at":          endAt,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					CreateScheduledTransfer(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateScheduledTransferParams) (db.ScheduledTransfer, error) {
+						require.Equal(t, "0 9 1 * *", arg.Schedule)
+						require.Equal(t, sql.NullTime{Time: endAt, Valid: true}, arg.EndAt)
+						// the first run is on the first of a month at 9
+						require.Equal(t, 1, arg.NextRunAt.Day())
+						require.Equal(t, 9, arg.NextRunAt.Hour())
+						require.False(t, arg.NextRunAt.Before(startAt))
+						return db.ScheduledTransfer{ID: 1}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidSchedule",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"schedule":        "every day",
+				"start_at":        startAt,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "StartInThePast",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"start_at":        time.Now().Add(-time.Minute),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "EndBeforeFirstRun",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"schedule":        "RRULE:FREQ=DAILY",
+				"start_at":        startAt,
+				"end_at":          startAt.Add(-time.Minute),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "OneOffWithEnd",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"start_at":        startAt,
+				"end_at":          endAt,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "SameAccount",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account1.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"start_at":        startAt,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnauthorizedAccountUser",
+			body: gin.H{
+				"from_account_id": account2.ID,
+				"to_account_id":   account1.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"start_at":        startAt,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().CreateScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ToAccountCurrencyMismatch",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"start_at":        startAt,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				eurAccount := account2
+				eurAccount.Currency = util.EUR
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(eurAccount, nil)
+				store.EXPECT().CreateScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          100,
+				"currency":        util.USD,
+				"start_at":        startAt,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().
+					CreateScheduledTransfer(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ScheduledTransfer{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			testCase.buildStubs(store)
+			buildAuthStubs(store)
+			buildVerifiedUserStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(testCase.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/scheduled_transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			testCase.checkResponse(recorder)
+		})
+	}
+}
+
+func TestGetScheduledTransferAPI(t *testing.T) {
+	user, _ := randomUser()
+	otherUser, _ := randomUser()
+	scheduledTransfer := randomScheduledTransfer(user.Username)
+
+	testCases := []struct {
+		name          string
+		id            int64
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			id:       scheduledTransfer.ID,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetScheduledTransfer(gomock.Any(), gomock.Eq(scheduledTransfer.ID)).
+					Times(1).
+					Return(scheduledTransfer, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				got := requireBodyScheduledTransfer(t, recorder.Body)
+				require.Equal(t, newScheduledTransferResponse(scheduledTransfer), got)
+			},
+		},
+		{
+			name:     "OtherUser",
+			id:       scheduledTransfer.ID,
+			username: otherUser.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetScheduledTransfer(gomock.Any(), gomock.Eq(scheduledTransfer.ID)).
+					Times(1).
+					Return(scheduledTransfer, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			id:       scheduledTransfer.ID,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetScheduledTransfer(gomock.Any(), gomock.Eq(scheduledTransfer.ID)).
+					Times(1).
+					Return(db.ScheduledTransfer{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidID",
+			id:       0,
+			username: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetScheduledTransfer(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			testCase.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/scheduled_transfers/%d", testCase.id)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, testCase.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			testCase.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUpdateScheduledTransferAPI(t *testing.T) {
+	user, _ := randomUser()
+	scheduledTransfer := randomScheduledTransfer(user.Username)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		current       func() db.ScheduledTransfer
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Amount",
+			body: gin.H{"amount": 500},
+			current: func() db.ScheduledTransfer {
+				return scheduledTransfer
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UpdateScheduledTransferParams{
+					ID:     scheduledTransfer.ID,
+					Amount: sql.NullInt64{Int64: 500, Valid: true},
+				}
+				store.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(scheduledTransfer, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "Pause",
+			body: gin.H{"status": db.ScheduledTransferPaused},
+			current: func() db.ScheduledTransfer {
+				return scheduledTransfer
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UpdateScheduledTransferParams{
+					ID:     scheduledTransfer.ID,
+					Status: sql.NullString{String: db.ScheduledTransferPaused, Valid: true},
+				}
+				store.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(scheduledTransfer, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ResumeSkipsMissedRuns",
+			body: gin.H{"status": db.ScheduledTransferActive},
+			current: func() db.ScheduledTransfer {
+				paused := scheduledTransfer
+				paused.Status = db.ScheduledTransferPaused
+				paused.StartAt = time.Now().AddDate(0, 0, -10)
+				paused.NextRunAt = paused.StartAt
+				return paused
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.UpdateScheduledTransferParams) (db.ScheduledTransfer, error) {
+						require.Equal(t, db.ScheduledTransferActive, arg.Status.String)
+						require.True(t, arg.NextRunAt.Valid)
+						require.True(t, arg.NextRunAt.Time.After(time.Now()))
+						require.WithinDuration(t, time.Now().Add(24*time.Hour), arg.NextRunAt.Time, 24*time.Hour)
+						return scheduledTransfer, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "EndBeforeNextRun",
+			body: gin.H{"end_at": scheduledTransfer.NextRunAt.Add(-time.Minute)},
+			current: func() db.ScheduledTransfer {
+				return scheduledTransfer
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidStatus",
+			body: gin.H{"status": db.ScheduledTransferCompleted},
+			current: func() db.ScheduledTransfer {
+				return scheduledTransfer
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Cancelled",
+			body: gin.H{"amount": 500},
+			current: func() db.ScheduledTransfer {
+				cancelled := scheduledTransfer
+				cancelled.Status = db.ScheduledTransferCancelled
+				return cancelled
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				GetScheduledTransfer(gomock.Any(), gomock.Eq(scheduledTransfer.ID)).
+				Times(1).
+				Return(testCase.current(), nil)
+			testCase.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(testCase.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/scheduled_transfers/%d", scheduledTransfer.ID)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			testCase.checkResponse(recorder)
+		})
+	}
+}
+
+func TestDeleteScheduledTransferAPI(t *testing.T) {
+	user, _ := randomUser()
+	scheduledTransfer := randomScheduledTransfer(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetScheduledTransfer(gomock.Any(), gomock.Eq(scheduledTransfer.ID)).
+		Times(1).
+		Return(scheduledTransfer, nil)
+
+	cancelled := scheduledTransfer
+	cancelled.Status = db.ScheduledTransferCancelled
+	arg := db.UpdateScheduledTransferParams{
+		ID:     scheduledTransfer.ID,
+		Status: sql.NullString{String: db.ScheduledTransferCancelled, Valid: true},
+	}
+	store.EXPECT().
+		UpdateScheduledTransfer(gomock.Any(), gomock.Eq(arg)).
+		Times(1).
+		Return(cancelled, nil)
+	buildAuthStubs(store)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/scheduled_transfers/%d", scheduledTransfer.ID)
+	request, err := http.NewRequest(http.MethodDelete, url, nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, db.ScheduledTransferCancelled, requireBodyScheduledTransfer(t, recorder.Body).Status)
+}
+
+func TestListScheduledTransferRunsAPI(t *testing.T) {
+	user, _ := randomUser()
+	scheduledTransfer := randomScheduledTransfer(user.Username)
+
+	runs := []db.ScheduledTransferRun{
+		{
+			ID:                  2,
+			ScheduledTransferID: scheduledTransfer.ID,
+			DueAt:               scheduledTransfer.NextRunAt,
+			Attempt:             2,
+			Status:              db.ScheduledTransferRunSucceeded,
+			TransferID:          sql.NullInt64{Int64: 7, Valid: true},
+			CreatedAt:           time.Now().Truncate(time.Second),
+		},
+		{
+			ID:                  1,
+			ScheduledTransferID: scheduledTransfer.ID,
+			DueAt:               scheduledTransfer.NextRunAt,
+			Attempt:             1,
+			Status:              db.ScheduledTransferRunFailed,
+			Error:               db.ErrInsufficientFunds.Error(),
+			CreatedAt:           time.Now().Add(-time.Minute).Truncate(time.Second),
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetScheduledTransfer(gomock.Any(), gomock.Eq(scheduledTransfer.ID)).
+		Times(1).
+		Return(scheduledTransfer, nil)
+	arg := db.ListScheduledTransferRunsParams{
+		ScheduledTransferID: scheduledTransfer.ID,
+		Limit:               21,
+	}
+	store.EXPECT().
+		ListScheduledTransferRuns(gomock.Any(), gomock.Eq(arg)).
+		Times(1).
+		Return(runs, nil)
+	buildAuthStubs(store)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/scheduled_transfers/%d/runs", scheduledTransfer.ID)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp struct {
+		Runs []scheduledTransferRunResponse `json:"runs"`
+	}
+	err = json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	require.Len(t, rsp.Runs, 2)
+	require.Equal(t, int64(7), *rsp.Runs[0].TransferID)
+	require.Nil(t, rsp.Runs[1].TransferID)
+	require.Equal(t, db.ErrInsufficientFunds.Error(), rsp.Runs[1].Error)
+	require.Empty(t, requireBodyPageLinks(t, recorder.Body))
+}
+
+func randomScheduledTransfer(owner string) db.ScheduledTransfer {
+	startAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	return db.ScheduledTransfer{
+		ID:            util.RandomInt(1, 1000),
+		Owner:         owner,
+		FromAccountID: util.RandomInt(1, 1000),
+		ToAccountID:   util.RandomInt(1001, 2000),
+		Amount:        util.RandomMoney(),
+		Schedule:      "RRULE:FREQ=DAILY",
+		StartAt:       startAt,
+		NextRunAt:     startAt,
+		Status:        db.ScheduledTransferActive,
+		CreatedAt:     time.Now().Truncate(time.Second).UTC(),
+		UpdatedAt:     time.Now().Truncate(time.Second).UTC(),
+	}
+}
+
+func requireBodyScheduledTransfer(t *testing.T, body *bytes.Buffer) scheduledTransferResponse {
+	var rsp struct {
+		ScheduledTransfer scheduledTransferResponse `json:"scheduled_transfer"`
+	}
+	err := json.Unmarshal(body.Bytes(), &rsp)
+	require.NoError(t, err)
+	return rsp.ScheduledTransfer
+}
@@ -1,9 +1,16 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 
 	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/fx"
+	"github.com/amrizal94/simplebank/mail"
+	"github.com/amrizal94/simplebank/oidc"
 	"github.com/amrizal94/simplebank/token"
 	"github.com/amrizal94/simplebank/util"
 	"github.com/gin-gonic/gin"
@@ -13,56 +20,253 @@ import (
 
 // Server serves HTTP requests for our banking service
 type Server struct {
-	config     util.Config
-	store      db.Store
-	router     *gin.Engine
-	tokenMaker token.Maker
+	config         util.Config
+	store          db.Store
+	router         *gin.Engine
+	httpServer     *http.Server
+	tokenMaker     token.Maker
+	denylist       *tokenDenylist
+	loginThrottle  *loginThrottle
+	mailer         mail.Sender
+	passwordParams util.PasswordParams
+	passwordPolicy util.PasswordPolicy
+	// oidcProvider is nil unless single sign-on is configured
+	oidcProvider *oidc.Provider
+	paginator    *paginator
+	rateProvider fx.RateProvider
+	// passwordResetLimiter limits the password reset emails per email and per client IP
+	passwordResetLimiter *rateLimiter
+	// background tracks the work a request hands off to run after its response, like sending emails.
+	// Shutdown waits for it.
+	background sync.WaitGroup
 }
 
 // NewServer creates a new http server and setup routing
 func NewServer(config util.Config, store db.Store) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token  maker: %v", err)
 	}
 
+	mailer, err := newEmailSender(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create email sender: %v", err)
+	}
+
+	passwordPolicy, err := util.NewPasswordPolicy(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create password policy: %v", err)
+	}
+
+	oidcProvider, err := newOIDCProvider(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create OIDC provider: %v", err)
+	}
+
+	paginator, err := newPaginator(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create paginator: %v", err)
+	}
+
+	rateProvider, err := newRateProvider(config, store)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create rate provider: %v", err)
+	}
+
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:         config,
+		store:          store,
+		httpServer:     &http.Server{},
+		tokenMaker:     tokenMaker,
+		denylist:       newTokenDenylist(store),
+		loginThrottle:  newLoginThrottle(store, config),
+		mailer:         mailer,
+		passwordParams: util.NewPasswordParams(config),
+		passwordPolicy: passwordPolicy,
+		oidcProvider:   oidcProvider,
+		paginator:      paginator,
+		rateProvider:   rateProvider,
+
+		passwordResetLimiter: newRateLimiter(store, config.PasswordResetRateWindow),
 	}
 
+	validatedPasswordPolicy.Store(&server.passwordPolicy)
+
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", validCurrency)
+		v.RegisterValidation("tier", validTier)
+		v.RegisterValidation("scope", validScope)
+		v.RegisterValidation("password", validPassword)
+		v.RegisterTagNameFunc(jsonFieldName)
 	}
 
 	server.setupRouter()
 	return server, nil
 }
 
+// newTokenMaker builds the token maker selected by config.TokenType
+func newTokenMaker(config util.Config) (token.Maker, error) {
+	switch config.TokenType {
+	case "", token.TypePaseto:
+		return token.NewPasetoMaker(config.TokenSymmetricKey)
+	case token.TypePasetoPublic:
+		return token.NewPasetoPublicMaker(config.TokenKeyID, config.TokenPrivateKey, config.TokenPublicKeys)
+	case token.TypeJWT:
+		return token.NewJWTMaker(config.TokenSymmetricKey)
+	default:
+		return nil, fmt.Errorf("unsupported token type %q", config.TokenType)
+	}
+}
+
+// newOIDCProvider builds the identity provider of config.OIDCIssuerURL, if set
+func newOIDCProvider(config util.Config) (*oidc.Provider, error) {
+	if config.OIDCIssuerURL == "" {
+		return nil, nil
+	}
+	return oidc.NewProvider(
+		config.OIDCIssuerURL,
+		config.OIDCClientID,
+		config.OIDCClientSecret,
+		config.OIDCRedirectURL,
+		config.OIDCScopes,
+	)
+}
+
+// newEmailSender builds the email sender selected by config.EmailSenderType
+func newEmailSender(config util.Config) (mail.Sender, error) {
+	switch config.EmailSenderType {
+	case mail.TypeSMTP:
+		return mail.NewSMTPSender(config.EmailSenderName, config.EmailSenderAddress, config.EmailSenderPassword, config.SMTPAddress)
+	case mail.TypeFile:
+		return mail.NewFileSender(config.EmailFileDir)
+	case mail.TypeMemory:
+		return mail.NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unsupported email sender type %q", config.EmailSenderType)
+	}
+}
+
+// newRateProvider builds the exchange rate provider selected by config.FXRateProvider
+func newRateProvider(config util.Config, store db.Store) (fx.RateProvider, error) {
+	switch config.FXRateProvider {
+	case "", fx.TypeDB:
+		return fx.NewDBRateProvider(store), nil
+	case fx.TypeFile:
+		return fx.NewFileRateProvider(config.FXRatesFile)
+	default:
+		return nil, fmt.Errorf("unsupported rate provider type %q", config.FXRateProvider)
+	}
+}
+
 func (server *Server) setupRouter() {
 	router := gin.Default()
 	router.SetTrustedProxies(nil)
 
 	router.POST("/users", server.createUser)
 	router.POST("/users/login", server.loginUser)
+	router.POST("/users/login/totp", server.verifyLoginTOTP)
+	router.POST("/users/password/forgot", server.forgotPassword)
+	router.POST("/users/password/reset", server.resetPassword)
+	router.POST("/tokens/renew_access", server.renewAccessToken)
+	router.GET("/verify_email", server.verifyEmail)
+
+	if server.oidcProvider != nil {
+		router.GET("/users/login/oidc", server.startOIDCLogin)
+		router.GET("/users/login/oidc/callback", server.finishOIDCLogin)
+	}
+
+	if _, ok := server.tokenMaker.(token.PublicKeySet); ok {
+		router.GET("/.well-known/jwks.json", server.listPublicKeys)
+	}
 
 	authRoutes := router.Group("/").
-		Use(authMiddleware(server.tokenMaker))
+		Use(server.authMiddleware())
+
+	authRoutes.POST("/users/logout", requireBearerToken(), server.logoutUser)
+	authRoutes.PUT("/users/password", requireBearerToken(), requireScopes(util.UsersWriteScope), server.changePassword)
+	authRoutes.PATCH("/users/:username", requireBearerToken(), requireScopes(util.UsersWriteScope), server.updateUser)
+	authRoutes.GET("/users/me/sessions", requireBearerToken(), requireScopes(util.UsersReadScope), server.listSessions)
+	authRoutes.DELETE("/users/me/sessions", requireBearerToken(), requireScopes(util.UsersWriteScope), server.deleteOtherSessions)
+	authRoutes.DELETE("/users/me/sessions/:id", requireBearerToken(), requireScopes(util.UsersWriteScope), server.deleteSession)
+	authRoutes.POST("/users/totp", requireBearerToken(), requireScopes(util.UsersWriteScope), server.enrollTOTP)
+	authRoutes.POST("/users/totp/confirm", requireBearerToken(), requireScopes(util.UsersWriteScope), server.confirmTOTP)
+
+	authRoutes.POST("/api_keys", requireBearerToken(), requireScopes(util.UsersWriteScope), server.createAPIKey)
+	authRoutes.GET("/api_keys", requireBearerToken(), requireScopes(util.UsersReadScope), server.listAPIKeys)
+	authRoutes.DELETE("/api_keys/:id", requireBearerToken(), requireScopes(util.UsersWriteScope), server.revokeAPIKey)
+
+	authRoutes.POST("/accounts", requireScopes(util.AccountsWriteScope), server.createAccount)
+	authRoutes.GET("/accounts/:id", requireScopes(util.AccountsReadScope), server.getAccount)
+	authRoutes.GET("/accounts", requireScopes(util.AccountsReadScope), server.listAccount)
+	authRoutes.GET("/accounts/:id/transfers", requireScopes(util.TransfersReadScope), server.listAccountTransfers)
+	authRoutes.GET("/accounts/:id/entries", requireScopes(util.AccountsReadScope), server.getAccountStatement)
+	authRoutes.GET("/accounts/:id/limits", requireScopes(util.AccountsReadScope), server.getAccountTransferLimits)
 
-	authRoutes.POST("/accounts", server.createAccount)
-	authRoutes.GET("/accounts/:id", server.getAccount)
-	authRoutes.GET("/accounts", server.listAccount)
+	authRoutes.POST("/transfers", requireScopes(util.TransfersWriteScope), server.createTransfer)
+	authRoutes.GET("/transfers/:id", requireScopes(util.TransfersReadScope), server.getTransfer)
 
-	authRoutes.POST("/transfers", server.createTransfer)
+	authRoutes.POST("/scheduled_transfers", requireScopes(util.TransfersWriteScope), server.createScheduledTransfer)
+	authRoutes.GET("/scheduled_transfers", requireScopes(util.TransfersReadScope), server.listScheduledTransfers)
+	authRoutes.GET("/scheduled_transfers/:id", requireScopes(util.TransfersReadScope), server.getScheduledTransfer)
+	authRoutes.PATCH("/scheduled_transfers/:id", requireScopes(util.TransfersWriteScope), server.updateScheduledTransfer)
+	authRoutes.DELETE("/scheduled_transfers/:id", requireScopes(util.TransfersWriteScope), server.deleteScheduledTransfer)
+	authRoutes.GET("/scheduled_transfers/:id/runs", requireScopes(util.TransfersReadScope), server.listScheduledTransferRuns)
+
+	authRoutes.POST("/fx/quotes", requireScopes(util.TransfersWriteScope), server.createFXQuote)
+	authRoutes.GET("/fx/quotes/:id", requireScopes(util.TransfersReadScope), server.getFXQuote)
+
+	adminRoutes := router.Group("/admin").
+		Use(server.authMiddleware()).
+		Use(requireBearerToken()).
+		Use(requireScopes(util.AdminScope)).
+		Use(requireRole(util.AdminRole))
+
+	adminRoutes.POST("/tokens/revoke", server.revokeToken)
+	adminRoutes.POST("/users/:username/freeze", server.setUserFrozen(true))
+	adminRoutes.POST("/users/:username/unfreeze", server.setUserFrozen(false))
+	adminRoutes.POST("/users/:username/unlock", server.unlockUser)
+	adminRoutes.POST("/accounts/:id/freeze", server.setAccountFrozen(true))
+	adminRoutes.POST("/accounts/:id/unfreeze", server.setAccountFrozen(false))
+	adminRoutes.POST("/fx/rates", server.createFXRate)
+	adminRoutes.PUT("/users/:username/tier", server.updateUserTier)
+	adminRoutes.PUT("/accounts/:id/limits", server.setAccountTransferLimits)
+	adminRoutes.PUT("/tiers/:tier/limits/:currency", server.setTierTransferLimits)
 
 	server.router = router
 
 }
 
-// Start runs the server on a specific address
+// Start runs the server on a specific address until it is shut down
 func (server *Server) Start(address string) error {
-	return server.router.Run(address)
+	server.httpServer.Addr = address
+	server.httpServer.Handler = server.router
+
+	err := server.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting requests and waits for the ones in flight to finish,
+// then for the work they handed off to run in the background, until ctx is done
+func (server *Server) Shutdown(ctx context.Context) error {
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		server.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func errorResponse(err error) gin.H {
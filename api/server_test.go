@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/mail"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServerTokenType(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		tokenType  string
+		checkMaker func(t *testing.T, maker token.Maker, err error)
+	}{
+		{
+			desc:      "Default",
+			tokenType: "",
+			checkMaker: func(t *testing.T, maker token.Maker, err error) {
+				require.NoError(t, err)
+				require.IsType(t, &token.PasetoMaker{}, maker)
+			},
+		},
+		{
+			desc:      "Paseto",
+			tokenType: token.TypePaseto,
+			checkMaker: func(t *testing.T, maker token.Maker, err error) {
+				require.NoError(t, err)
+				require.IsType(t, &token.PasetoMaker{}, maker)
+			},
+		},
+		{
+			desc:      "JWT",
+			tokenType: token.TypeJWT,
+			checkMaker: func(t *testing.T, maker token.Maker, err error) {
+				require.NoError(t, err)
+				require.IsType(t, &token.JWTMaker{}, maker)
+			},
+		},
+		{
+			desc:      "PasetoPublic",
+			tokenType: token.TypePasetoPublic,
+			checkMaker: func(t *testing.T, maker token.Maker, err error) {
+				require.NoError(t, err)
+				require.IsType(t, &token.PasetoPublicMaker{}, maker)
+			},
+		},
+		{
+			desc:      "Unsupported",
+			tokenType: "unsupported",
+			checkMaker: func(t *testing.T, maker token.Maker, err error) {
+				require.Error(t, err)
+				require.Nil(t, maker)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			config := util.Config{
+				TokenType:           tC.tokenType,
+				TokenSymmetricKey:   util.RandomString(32),
+				TokenKeyID:          "key-1",
+				TokenPrivateKey:     hex.EncodeToString(make([]byte, ed25519.SeedSize)),
+				AccessTokenDuration: time.Minute,
+			}
+
+			maker, err := newTokenMaker(config)
+			tC.checkMaker(t, maker, err)
+		})
+	}
+}
+
+func TestNewServerEmailSenderType(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		senderType  string
+		checkSender func(t *testing.T, sender mail.Sender, err error)
+	}{
+		{
+			desc:       "SMTP",
+			senderType: mail.TypeSMTP,
+			checkSender: func(t *testing.T, sender mail.Sender, err error) {
+				require.NoError(t, err)
+				require.IsType(t, &mail.SMTPSender{}, sender)
+			},
+		},
+		{
+			desc:       "File",
+			senderType: mail.TypeFile,
+			checkSender: func(t *testing.T, sender mail.Sender, err error) {
+				require.NoError(t, err)
+				require.IsType(t, &mail.FileSender{}, sender)
+			},
+		},
+		{
+			desc:       "Memory",
+			senderType: mail.TypeMemory,
+			checkSender: func(t *testing.T, sender mail.Sender, err error) {
+				require.NoError(t, err)
+				require.IsType(t, &mail.MemorySender{}, sender)
+			},
+		},
+		{
+			desc:       "Unset",
+			senderType: "",
+			checkSender: func(t *testing.T, sender mail.Sender, err error) {
+				require.Error(t, err)
+				require.Nil(t, sender)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			config := util.Config{
+				EmailSenderType:    tC.senderType,
+				EmailSenderName:    "Simple Bank",
+				EmailSenderAddress: "simplebank@example.com",
+				SMTPAddress:        "smtp.example.com:587",
+				EmailFileDir:       t.TempDir(),
+			}
+
+			sender, err := newEmailSender(config)
+			tC.checkSender(t, sender, err)
+		})
+	}
+}
+
+func TestServerShutdownWaitsForBackground(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	server.background.Add(1)
+	released := make(chan struct{})
+	go func() {
+		defer server.background.Done()
+		<-released
+	}()
+
+	// the background work outlives the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, server.Shutdown(ctx), context.DeadlineExceeded)
+
+	close(released)
+	require.NoError(t, server.Shutdown(context.Background()))
+}
+
+func TestServerStartAfterShutdown(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	// a closed server doesn't start, without it being an error
+	require.NoError(t, server.Start("127.0.0.1:0"))
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval limits how often a request updates the last-seen time of its session
+const sessionTouchInterval = time.Minute
+
+// touchSession records that the session was just used, and from where.
+// Failing to do so doesn't fail the request.
+func (server *Server) touchSession(ctx *gin.Context, session db.Session) {
+	clientIP := ctx.ClientIP()
+	if time.Since(session.LastSeenAt) < sessionTouchInterval && session.ClientIp == clientIP {
+		return
+	}
+
+	err := server.store.TouchSession(ctx, db.TouchSessionParams{
+		ID:       session.ID,
+		ClientIp: clientIP,
+	})
+	if err != nil {
+		ctx.Error(err)
+	}
+}
+
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	ClientIP   string    `json:"client_ip"`
+	IsCurrent  bool      `json:"is_current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func newSessionResponse(session db.Session, currentID uuid.UUID) sessionResponse {
+	return sessionResponse{
+		ID:         session.ID,
+		UserAgent:  session.UserAgent,
+		ClientIP:   session.ClientIp,
+		IsCurrent:  session.ID == currentID,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
+
+// listSessions lists the active sessions of the authenticated user, most recently used first
+func (server *Server) listSessions(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	sessions, err := server.store.ListSessions(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		rsp[i] = newSessionResponse(session, authPayload.Session())
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type deleteSessionRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+// deleteSession signs the authenticated user out of one of their sessions.
+// Its tokens stop working right away.
+func (server *Server) deleteSession(ctx *gin.Context) {
+	var req deleteSessionRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	// sessions of other users are reported as missing
+	_, err := server.store.BlockSession(ctx, db.BlockSessionParams{
+		ID:       uuid.MustParse(req.ID),
+		Username: authPayload.Username,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// deleteOtherSessions signs the authenticated user out everywhere but the current session
+func (server *Server) deleteOtherSessions(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	err := server.store.BlockUserSessions(ctx, db.BlockUserSessionsParams{
+		Username: authPayload.Username,
+		ExceptID: authPayload.Session(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// addSessionAuthorization adds an access token of the session to the request
+func addSessionAuthorization(
+	t *testing.T,
+	request *http.Request,
+	tokenMaker token.Maker,
+	username string,
+	sessionID uuid.UUID,
+) {
+	token, payload, err := tokenMaker.CreateSessionToken(username, util.DepositorRole, util.AllScopes(), sessionID, time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+	testUserRoles.Store(username, util.DepositorRole)
+
+	authorizationHeader := fmt.Sprintf("%s %s", authorizationTypeBearer, token)
+	request.Header.Set(authorizationHeaderKey, authorizationHeader)
+}
+
+func TestListSessionsAPI(t *testing.T) {
+	user, _ := randomUser()
+	currentID := uuid.New()
+
+	sessions := []db.Session{
+		randomActiveSession(user.Username, currentID),
+		randomActiveSession(user.Username, uuid.New()),
+	}
+
+	testCases := []struct {
+		desc          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addSessionAuthorization(t, request, tokenMaker, user.Username, currentID)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(sessions, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchSessions(t, recorder.Body, sessions, currentID)
+			},
+		},
+		{
+			desc: "MissingUsersReadScope",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addScopedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole,
+					[]string{util.AccountsReadScope}, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			desc: "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			desc: "InternalError",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addSessionAuthorization(t, request, tokenMaker, user.Username, currentID)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListSessions(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/users/me/sessions", nil)
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteSessionAPI(t *testing.T) {
+	user, _ := randomUser()
+	session := randomActiveSession(user.Username, uuid.New())
+
+	testCases := []struct {
+		desc          string
+		sessionID     string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc:      "OK",
+			sessionID: session.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.BlockSessionParams{
+					ID:       session.ID,
+					Username: user.Username,
+				}
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			desc:      "NotFound",
+			sessionID: session.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			desc:      "InvalidID",
+			sessionID: "not-a-uuid",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			desc:      "InternalError",
+			sessionID: session.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/me/sessions/%s", tC.sessionID)
+			request, err := http.NewRequest(http.MethodDelete, url, nil)
+			require.NoError(t, err)
+
+			addSessionAuthorization(t, request, server.tokenMaker, user.Username, uuid.New())
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteOtherSessionsAPI(t *testing.T) {
+	user, _ := randomUser()
+	currentID := uuid.New()
+
+	testCases := []struct {
+		desc          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.BlockUserSessionsParams{
+					Username: user.Username,
+					ExceptID: currentID,
+				}
+				store.EXPECT().
+					BlockUserSessions(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			desc: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockUserSessions(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodDelete, "/users/me/sessions", nil)
+			require.NoError(t, err)
+
+			addSessionAuthorization(t, request, server.tokenMaker, user.Username, currentID)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(t, recorder)
+		})
+	}
+}
+
+func randomActiveSession(username string, id uuid.UUID) db.Session {
+	return db.Session{
+		ID:         id,
+		Username:   username,
+		UserAgent:  util.RandomString(10),
+		ClientIp:   "127.0.0.1",
+		ExpiresAt:  time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+		LastSeenAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func requireBodyMatchSessions(t *testing.T, body *bytes.Buffer, sessions []db.Session, currentID uuid.UUID) {
+	var gotSessions []sessionResponse
+	err := json.Unmarshal(body.Bytes(), &gotSessions)
+	require.NoError(t, err)
+
+	require.Len(t, gotSessions, len(sessions))
+	for i, session := range sessions {
+		require.Equal(t, newSessionResponse(session, currentID), gotSessions[i])
+	}
+	require.True(t, gotSessions[0].IsCurrent)
+	require.False(t, gotSessions[1].IsCurrent)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/gin-gonic/gin"
+)
+
+// maxStatementPeriod bounds how much of the ledger a single statement reads
+const maxStatementPeriod = 366 * 24 * time.Hour
+
+type accountStatementRequest struct {
+	pageRequest
+	From time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To   time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type statementEntryResponse struct {
+	ID     int64 `json:"id"`
+	Amount int64 `json:"amount"`
+	// Balance is the balance of the account right after the entry
+	Balance               int64     `json:"balance"`
+	CreatedAt             time.Time `json:"created_at"`
+	TransferID            *int64    `json:"transfer_id,omitempty"`
+	CounterpartyAccountID int64     `json:"counterparty_account_id,omitempty"`
+}
+
+type accountStatementResponse struct {
+	AccountID      int64                    `json:"account_id"`
+	Currency       string                   `json:"currency"`
+	From           time.Time                `json:"from"`
+	To             time.Time                `json:"to"`
+	OpeningBalance int64                    `json:"opening_balance"`
+	ClosingBalance int64                    `json:"closing_balance"`
+	Entries        []statementEntryResponse `json:"entries"`
+	pageLinks
+}
+
+func newStatementEntryResponse(entry db.StatementEntry) statementEntryResponse {
+	rsp := statementEntryResponse{
+		ID:                    entry.ID,
+		Amount:                entry.Amount,
+		Balance:               entry.Balance,
+		CreatedAt:             entry.CreatedAt,
+		CounterpartyAccountID: entry.CounterpartyAccountID,
+	}
+	if entry.TransferID.Valid {
+		rsp.TransferID = &entry.TransferID.Int64
+	}
+	return rsp
+}
+
+// getAccountStatement returns a page of the entries of an account made from the start of the period
+// until before its end, each with the balance it left, between the balances before and after the period.
+func (server *Server) getAccountStatement(ctx *gin.Context) {
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req accountStatementRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.From.IsZero() || req.To.IsZero() {
+		err := errors.New("from and to are required")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !req.To.After(req.From) {
+		err := errors.New("to must be after from")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.To.Sub(req.From) > maxStatementPeriod {
+		err := fmt.Errorf("a statement can cover at most %d days", int(maxStatementPeriod.Hours()/24))
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// a cursor only moves within the period it was made for
+	list := fmt.Sprintf("entries:%d:%d:%d", uri.ID, req.From.UnixNano(), req.To.UnixNano())
+	page, err := server.paginator.page(list, req.pageRequest)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !canReadAccount(ctx, authPayload, account) {
+		err := errors.New("account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	statement, err := server.store.AccountStatementTx(ctx, db.AccountStatementTxParams{
+		AccountID:       account.ID,
+		FromTime:        req.From,
+		ToTime:          req.To,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Reverse:         page.reverse(),
+		Limit:           page.limit(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	entries, links := finishPage(server.paginator, list, page, statement.Entries, func(entry db.StatementEntry) (time.Time, int64) {
+		return entry.CreatedAt, entry.ID
+	})
+
+	rsp := accountStatementResponse{
+		AccountID:      account.ID,
+		Currency:       account.Currency,
+		From:           req.From,
+		To:             req.To,
+		OpeningBalance: statement.OpeningBalance,
+		ClosingBalance: statement.ClosingBalance,
+		Entries:        make([]statementEntryResponse, len(entries)),
+		pageLinks:      links,
+	}
+	for i, entry := range entries {
+		rsp.Entries[i] = newStatementEntryResponse(entry)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,380 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAccountStatementAPI(t *testing.T) {
+	user, _ := randomUser()
+	account := randomAccount(user.Username)
+
+	from := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	statement := db.AccountStatementTxResult{
+		OpeningBalance: 100,
+		ClosingBalance: 70,
+		Entries: []db.StatementEntry{
+			{
+				ListStatementEntriesRow: db.ListStatementEntriesRow{
+					ID:                    1,
+					AccountsID:            account.ID,
+					Amount:                -50,
+					CreatedAt:             from.Add(time.Hour),
+					TransferID:            sql.NullInt64{Int64: 11, Valid: true},
+					CounterpartyAccountID: account.ID + 1,
+				},
+				Balance: 50,
+			},
+			{
+				ListStatementEntriesRow: db.ListStatementEntriesRow{
+					ID:                    2,
+					AccountsID:            account.ID,
+					Amount:                20,
+					CreatedAt:             from.Add(2 * time.Hour),
+					TransferID:            sql.NullInt64{Int64: 12, Valid: true},
+					CounterpartyAccountID: account.ID + 2,
+				},
+				Balance: 70,
+			},
+		},
+	}
+
+	validQuery := map[string]string{
+		"from": from.Format(time.RFC3339),
+		"to":   to.Format(time.RFC3339),
+	}
+
+	testCases := []struct {
+		name          string
+		accountID     int64
+		query         map[string]string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			accountID: account.ID,
+			query:     validQuery,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					AccountStatementTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ interface{}, arg db.AccountStatementTxParams) (db.AccountStatementTxResult, error) {
+						require.Equal(t, account.ID, arg.AccountID)
+						require.True(t, arg.FromTime.Equal(from))
+						require.True(t, arg.ToTime.Equal(to))
+						require.False(t, arg.CursorCreatedAt.Valid)
+						require.False(t, arg.Reverse)
+						require.Equal(t, int32(21), arg.Limit)
+						return statement, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				rsp := requireBodyStatement(t, recoder.Body)
+				require.Equal(t, account.ID, rsp.AccountID)
+				require.Equal(t, account.Currency, rsp.Currency)
+				require.Equal(t, statement.OpeningBalance, rsp.OpeningBalance)
+				require.Equal(t, statement.ClosingBalance, rsp.ClosingBalance)
+				require.Len(t, rsp.Entries, len(statement.Entries))
+				for i, entry := range statement.Entries {
+					require.Equal(t, newStatementEntryResponse(entry), rsp.Entries[i])
+					require.Equal(t, entry.TransferID.Int64, *rsp.Entries[i].TransferID)
+				}
+				require.Empty(t, rsp.NextCursor)
+				require.Empty(t, rsp.PrevCursor)
+			},
+		},
+		{
+			name:      "NextPage",
+			accountID: account.ID,
+			query: map[string]string{
+				"from":      from.Format(time.RFC3339),
+				"to":        to.Format(time.RFC3339),
+				"page_size": "1",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					AccountStatementTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ interface{}, arg db.AccountStatementTxParams) (db.AccountStatementTxResult, error) {
+						require.Equal(t, int32(2), arg.Limit)
+						return statement, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				rsp := requireBodyStatement(t, recoder.Body)
+				require.Len(t, rsp.Entries, 1)
+				require.Equal(t, statement.Entries[0].ID, rsp.Entries[0].ID)
+				require.NotEmpty(t, rsp.NextCursor)
+				require.Empty(t, rsp.PrevCursor)
+			},
+		},
+		{
+			name:      "CursorOfOtherPeriod",
+			accountID: account.ID,
+			query: map[string]string{
+				"from":   from.Format(time.RFC3339),
+				"to":     to.Format(time.RFC3339),
+				"cursor": "",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "Banker",
+			accountID: account.ID,
+			query:     validQuery,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					AccountStatementTx(gomock.Any(), gomock.Any()).Times(1).
+					Return(statement, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			accountID: account.ID,
+			query:     validQuery,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					AccountStatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			accountID: account.ID,
+			query:     validQuery,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			name:      "AccountNotFound",
+			accountID: account.ID,
+			query:     validQuery,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().
+					AccountStatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			name:      "InternalError",
+			accountID: account.ID,
+			query:     validQuery,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					AccountStatementTx(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.AccountStatementTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			name:      "MissingPeriod",
+			accountID: account.ID,
+			query:     map[string]string{"from": from.Format(time.RFC3339)},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "InvalidTime",
+			accountID: account.ID,
+			query:     map[string]string{"from": "yesterday", "to": to.Format(time.RFC3339)},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "ReversedPeriod",
+			accountID: account.ID,
+			query: map[string]string{
+				"from": to.Format(time.RFC3339),
+				"to":   from.Format(time.RFC3339),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "PeriodTooLong",
+			accountID: account.ID,
+			query: map[string]string{
+				"from": from.Format(time.RFC3339),
+				"to":   from.AddDate(2, 0, 0).Format(time.RFC3339),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/entries", tc.accountID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			q := request.URL.Query()
+			for key, value := range tc.query {
+				q.Add(key, value)
+			}
+			if _, ok := tc.query["cursor"]; ok {
+				// made for the period of the request but a day later
+				list := fmt.Sprintf("entries:%d:%d:%d", tc.accountID, from.UnixNano(), to.AddDate(0, 0, 1).UnixNano())
+				q.Set("cursor", server.paginator.cursor(list, pageCursor{CreatedAt: from, ID: 1}))
+			}
+			request.URL.RawQuery = q.Encode()
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func requireBodyStatement(t *testing.T, body *bytes.Buffer) accountStatementResponse {
+	var rsp accountStatementResponse
+	err := json.Unmarshal(body.Bytes(), &rsp)
+	require.NoError(t, err)
+	return rsp
+}
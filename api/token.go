@@ -0,0 +1,209 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/amrizal94/simplebank/token"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var errNotRefreshToken = errors.New("not a refresh token")
+
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type renewAccessTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+}
+
+func (server *Server) renewAccessToken(ctx *gin.Context) {
+	var req renewAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if refreshPayload.Type != token.RefreshToken {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errNotRefreshToken))
+		return
+	}
+
+	session, err := server.store.GetSession(ctx, refreshPayload.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	revoked, err := server.denylist.isRevoked(ctx, refreshPayload.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if session.IsBlocked || revoked {
+		err := errors.New("blocked session")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if session.Username != refreshPayload.Username {
+		err := errors.New("incorrect session user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if session.RefreshToken != req.RefreshToken {
+		err := errors.New("mismatched session token")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		err := errors.New("expired session")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	// load the user again so a role change or freeze applies on the next renewal
+	user, err := server.store.GetUser(ctx, session.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if user.IsFrozen {
+		ctx.JSON(http.StatusForbidden, errorResponse(errFrozenUser))
+		return
+	}
+
+	if issuedBeforePasswordChange(refreshPayload, user.PasswordChangedAt) {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errPasswordChanged))
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateSessionToken(
+		user.Username,
+		user.Role,
+		refreshPayload.Scopes,
+		session.ID,
+		server.config.AccessTokenDuration,
+	)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := renewAccessTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessPayload.ExpiredAt,
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	X         string `json:"x"`
+}
+
+type listPublicKeysResponse struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// listPublicKeys publishes the token verification keys as a JWK set,
+// so other services can verify access tokens offline
+func (server *Server) listPublicKeys(ctx *gin.Context) {
+	keySet := server.tokenMaker.(token.PublicKeySet)
+
+	rsp := listPublicKeysResponse{Keys: []jsonWebKey{}}
+	for _, key := range keySet.PublicKeys() {
+		rsp.Keys = append(rsp.Keys, jsonWebKey{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+			X:         base64.RawURLEncoding.EncodeToString(key.Key),
+		})
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type revokeTokenRequest struct {
+	ID string `json:"id" binding:"required,uuid"`
+}
+
+// revokeToken lets an admin revoke any token by its payload ID.
+// The ID of an API key revokes the key, which the denylist doesn't cover.
+func (server *Server) revokeToken(ctx *gin.Context) {
+	var req revokeTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	tokenID, err := uuid.Parse(req.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	apiKey, err := server.store.RevokeAPIKeyByID(ctx, tokenID)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == nil {
+		ctx.JSON(http.StatusOK, gin.H{"api_key": newAPIKeyResponse(apiKey)})
+		return
+	}
+
+	// the expiry of an arbitrary token is unknown, so keep it denied for as long as
+	// any token could live, unless it is a refresh token with a known session
+	expiresAt := time.Now().Add(server.maxTokenDuration())
+
+	session, err := server.store.GetSession(ctx, tokenID)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == nil {
+		expiresAt = session.ExpiresAt
+	}
+
+	revokedToken, err := server.denylist.revoke(ctx, tokenID, expiresAt)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"revoked_token": revokedToken})
+}
+
+func (server *Server) maxTokenDuration() time.Duration {
+	if server.config.RefreshTokenDuration > server.config.AccessTokenDuration {
+		return server.config.RefreshTokenDuration
+	}
+	return server.config.AccessTokenDuration
+}
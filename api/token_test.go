@@ -0,0 +1,560 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/mail"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewAccessToken(t *testing.T) {
+	user, _ := randomUser()
+
+	testCases := []struct {
+		desc          string
+		tokenType     string
+		buildSession  func(refreshToken string, payload *token.Payload) db.Session
+		duration      time.Duration
+		buildStubs    func(store *mockdb.MockStore, session db.Session)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc:     "OK",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp renewAccessTokenResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.NotEmpty(t, rsp.AccessToken)
+				require.NotZero(t, rsp.AccessTokenExpiresAt)
+			},
+		},
+		{
+			desc:      "AccessToken",
+			tokenType: token.AccessToken,
+			duration:  time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc:     "FrozenUser",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				frozenUser := user
+				frozenUser.IsFrozen = true
+
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(frozenUser, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc:     "PasswordChanged",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				changedUser := user
+				changedUser.PasswordChangedAt = time.Now().Add(time.Second)
+
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(changedUser, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, errPasswordChanged)
+			},
+		},
+		{
+			desc:     "ExpiredRefreshToken",
+			duration: -time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc:     "SessionNotFound",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			desc:     "InternalError",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			desc:     "BlockedSession",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				session := randomSession(user.Username, refreshToken, payload)
+				session.IsBlocked = true
+				return session
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc:     "RevokedRefreshToken",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(db.RevokedToken{ID: session.ID, ExpiresAt: session.ExpiresAt}, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc:     "IncorrectSessionUser",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession("other", refreshToken, payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc:     "MismatchedSessionToken",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(user.Username, "other", payload)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc:     "ExpiredSession",
+			duration: time.Minute,
+			buildSession: func(refreshToken string, payload *token.Payload) db.Session {
+				session := randomSession(user.Username, refreshToken, payload)
+				session.ExpiresAt = time.Now().Add(-time.Minute)
+				return session
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+
+			tokenType := tC.tokenType
+			if tokenType == "" {
+				tokenType = token.RefreshToken
+			}
+
+			refreshToken, payload, err := server.tokenMaker.CreateToken(tokenType, user.Username, user.Role, util.AllScopes(), tC.duration)
+			require.NoError(t, err)
+
+			session := tC.buildSession(refreshToken, payload)
+			tC.buildStubs(store, session)
+			buildAuthStubs(store)
+
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"refresh_token": refreshToken})
+			require.NoError(t, err)
+
+			url := "/tokens/renew_access"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListPublicKeys(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	config := util.Config{
+		TokenType:                 token.TypePasetoPublic,
+		TokenKeyID:                "key-1",
+		TokenPrivateKey:           hex.EncodeToString(private.Seed()),
+		AccessTokenDuration:       time.Minute,
+		EmailSenderType:           mail.TypeMemory,
+		PaginationCursorKey:       util.RandomString(32),
+		PaginationDefaultPageSize: 20,
+		PaginationMaxPageSize:     100,
+	}
+	server, err := NewServer(config, nil)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp listPublicKeysResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	require.Len(t, rsp.Keys, 1)
+	require.Equal(t, "key-1", rsp.Keys[0].KeyID)
+	require.Equal(t, "Ed25519", rsp.Keys[0].Curve)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(public), rsp.Keys[0].X)
+
+	// symmetric makers have nothing to publish
+	server = newTestServer(t, nil)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestRevokeTokenAPI(t *testing.T) {
+	admin, _ := randomUser()
+	admin.Role = util.AdminRole
+	user, _ := randomUser()
+	tokenID := uuid.New()
+	apiKey, _ := randomAPIKey(t, user.Username)
+
+	testCases := []struct {
+		desc          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			body: gin.H{"id": tokenID.String()},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKeyByID(gomock.Any(), gomock.Eq(tokenID)).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(tokenID)).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateRevokedTokenParams) (db.RevokedToken, error) {
+						require.Equal(t, tokenID, arg.ID)
+						require.WithinDuration(t, time.Now().Add(time.Hour), arg.ExpiresAt, time.Second)
+						return db.RevokedToken{ID: arg.ID, ExpiresAt: arg.ExpiresAt}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc: "SessionExpiry",
+			body: gin.H{"id": tokenID.String()},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				expiresAt := time.Now().Add(10 * time.Minute)
+				store.EXPECT().
+					RevokeAPIKeyByID(gomock.Any(), gomock.Eq(tokenID)).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(tokenID)).
+					Times(1).
+					Return(db.Session{ID: tokenID, ExpiresAt: expiresAt}, nil)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Eq(db.CreateRevokedTokenParams{
+						ID:        tokenID,
+						ExpiresAt: expiresAt,
+					})).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc: "APIKey",
+			body: gin.H{"id": apiKey.ID.String()},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				revoked := apiKey
+				revoked.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+				store.EXPECT().
+					RevokeAPIKeyByID(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(revoked, nil)
+				// the key is revoked for good, not denied for as long as a token lives
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp struct {
+					APIKey apiKeyResponse `json:"api_key"`
+				}
+				require.NoError(t, json.Unmarshal(recoder.Body.Bytes(), &rsp))
+				require.Equal(t, apiKey.ID, rsp.APIKey.ID)
+				require.NotNil(t, rsp.APIKey.RevokedAt)
+			},
+		},
+		{
+			desc: "RevokeAPIKeyError",
+			body: gin.H{"id": tokenID.String()},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKeyByID(gomock.Any(), gomock.Eq(tokenID)).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrConnDone)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			desc: "NotAdmin",
+			body: gin.H{"id": tokenID.String()},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc: "NoAuthorization",
+			body: gin.H{"id": tokenID.String()},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "InvalidID",
+			body: gin.H{"id": "invalid"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "InternalError",
+			body: gin.H{"id": tokenID.String()},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKeyByID(gomock.Any(), gomock.Eq(tokenID)).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(tokenID)).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tC.body)
+			require.NoError(t, err)
+
+			url := "/admin/tokens/revoke"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func randomSession(username string, refreshToken string, payload *token.Payload) db.Session {
+	return db.Session{
+		ID:           payload.ID,
+		Username:     username,
+		RefreshToken: refreshToken,
+		UserAgent:    "test-agent",
+		ClientIp:     "127.0.0.1",
+		IsBlocked:    false,
+		ExpiresAt:    payload.ExpiredAt,
+		CreatedAt:    payload.IssuedAt,
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	errTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	errTOTPNotEnrolled    = errors.New("two-factor authentication enrollment has not been started")
+	errInvalidTOTPCode    = errors.New("invalid two-factor authentication code")
+	errInvalidChallenge   = errors.New("invalid challenge token")
+)
+
+type enrollTOTPResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+// enrollTOTP starts the two-factor enrollment of the authenticated user
+// with a new secret. It's only enabled once confirmed with a first code.
+func (server *Server) enrollTOTP(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.UpdateUserTOTPSecret(ctx, db.UpdateUserTOTPSecretParams{
+		Username:   authPayload.Username,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusConflict, errorResponse(errTOTPAlreadyEnabled))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := enrollTOTPResponse{
+		Secret:     secret,
+		OtpauthURI: util.TOTPURI(server.config.TOTPIssuer, user.Username, secret),
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,numeric,len=6"`
+}
+
+type confirmTOTPResponse struct {
+	RecoveryCodes []string     `json:"recovery_codes"`
+	User          userResponse `json:"user"`
+}
+
+// confirmTOTP enables two-factor authentication once the user proves
+// their authenticator works, and hands out the recovery codes.
+// The recovery codes are only ever shown here.
+func (server *Server) confirmTOTP(ctx *gin.Context) {
+	var req confirmTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if user.IsTotpEnabled {
+		ctx.JSON(http.StatusConflict, errorResponse(errTOTPAlreadyEnabled))
+		return
+	}
+
+	if !user.TotpSecret.Valid {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errTOTPNotEnrolled))
+		return
+	}
+
+	step, ok := util.ValidateTOTP(user.TotpSecret.String, req.Code, time.Now())
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errInvalidTOTPCode))
+		return
+	}
+
+	recoveryCodes, err := util.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	hashedRecoveryCodes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashedRecoveryCodes[i] = util.HashRecoveryCode(code)
+	}
+
+	user, err = server.store.EnableUserTOTP(ctx, db.EnableUserTOTPParams{
+		Username:            user.Username,
+		TotpLastStep:        step,
+		HashedRecoveryCodes: hashedRecoveryCodes,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := confirmTOTPResponse{
+		RecoveryCodes: recoveryCodes,
+		User:          newUserResponse(user),
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type verifyLoginTOTPRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode   string `json:"recovery_code" binding:"required_without=Code"`
+}
+
+// verifyLoginTOTP finishes the login of a user with two-factor authentication,
+// exchanging the challenge token and a TOTP or recovery code for the session tokens.
+func (server *Server) verifyLoginTOTP(ctx *gin.Context) {
+	var req verifyLoginTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	challengePayload, err := server.tokenMaker.VerifyToken(req.ChallengeToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if challengePayload.Type != token.ChallengeToken || !challengePayload.HasScope(util.TOTPChallengeScope) {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errInvalidChallenge))
+		return
+	}
+
+	revoked, err := server.denylist.isRevoked(ctx, challengePayload.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if revoked {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errRevokedToken))
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, challengePayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if user.IsFrozen {
+		ctx.JSON(http.StatusForbidden, errorResponse(errFrozenUser))
+		return
+	}
+
+	if issuedBeforePasswordChange(challengePayload, user.PasswordChangedAt) {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errPasswordChanged))
+		return
+	}
+
+	if !user.IsTotpEnabled {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errInvalidChallenge))
+		return
+	}
+
+	// guessing codes counts against the same lockout as guessing passwords
+	if !server.checkLoginThrottle(ctx, user.Username) {
+		return
+	}
+
+	// the conditional updates make sure each code is only accepted once,
+	// even when the same code is raced on several requests
+	if len(req.Code) > 0 {
+		step, ok := util.ValidateTOTP(user.TotpSecret.String, req.Code, time.Now())
+		if !ok {
+			server.rejectLogin(ctx, user.Username, errInvalidTOTPCode)
+			return
+		}
+
+		user, err = server.store.UseUserTOTPStep(ctx, db.UseUserTOTPStepParams{
+			Username: user.Username,
+			Step:     step,
+		})
+	} else {
+		user, err = server.store.UseUserRecoveryCode(ctx, db.UseUserRecoveryCodeParams{
+			Username:   user.Username,
+			HashedCode: util.HashRecoveryCode(req.RecoveryCode),
+		})
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			server.rejectLogin(ctx, user.Username, errInvalidTOTPCode)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.loginThrottle.reset(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// a challenge token is good for a single login
+	_, err = server.denylist.revoke(ctx, challengePayload.ID, challengePayload.ExpiredAt)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	scopes := make([]string, 0, len(challengePayload.Scopes))
+	for _, scope := range challengePayload.Scopes {
+		if scope != util.TOTPChallengeScope {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	rsp, err := server.createLoginSession(ctx, user, scopes)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,615 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrollTOTPAPI(t *testing.T) {
+	user, _ := randomUser()
+
+	testCases := []struct {
+		desc          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.UpdateUserTOTPSecretParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.True(t, arg.TotpSecret.Valid)
+
+						enrolledUser := user
+						enrolledUser.TotpSecret = arg.TotpSecret
+						return enrolledUser, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp enrollTOTPResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.NotEmpty(t, rsp.Secret)
+				require.Equal(t, util.TOTPURI("SimpleBank", user.Username, rsp.Secret), rsp.OtpauthURI)
+			},
+		},
+		{
+			desc: "AlreadyEnabled",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recoder.Code)
+			},
+		},
+		{
+			desc: "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp", nil)
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func TestConfirmTOTPAPI(t *testing.T) {
+	user, _ := randomUser()
+	secret, err := util.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	user.TotpSecret = sql.NullString{String: secret, Valid: true}
+
+	code, err := util.TOTPCode(secret, util.TOTPStep(time.Now()))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					EnableUserTOTP(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.EnableUserTOTPParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Len(t, arg.HashedRecoveryCodes, recoveryCodeCount)
+
+						enabledUser := user
+						enabledUser.IsTotpEnabled = true
+						enabledUser.TotpLastStep = arg.TotpLastStep
+						enabledUser.HashedRecoveryCodes = arg.HashedRecoveryCodes
+						return enabledUser, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp confirmTOTPResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Len(t, rsp.RecoveryCodes, recoveryCodeCount)
+				require.True(t, rsp.User.IsTOTPEnabled)
+				require.NotContains(t, recoder.Body.String(), secret)
+			},
+		},
+		{
+			desc: "WrongCode",
+			body: gin.H{"code": wrongTOTPCode(code)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					EnableUserTOTP(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "NotEnrolled",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{Username: user.Username}, nil)
+				store.EXPECT().
+					EnableUserTOTP(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "AlreadyEnabled",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				enabledUser := user
+				enabledUser.IsTotpEnabled = true
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(enabledUser, nil)
+				store.EXPECT().
+					EnableUserTOTP(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recoder.Code)
+			},
+		},
+		{
+			desc: "InvalidCode",
+			body: gin.H{"code": "abcdef"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tC.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp/confirm", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func TestVerifyLoginTOTPAPI(t *testing.T) {
+	user, _ := randomUser()
+	secret, err := util.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	recoveryCode := "abcde-fghij"
+	user.TotpSecret = sql.NullString{String: secret, Valid: true}
+	user.IsTotpEnabled = true
+	user.HashedRecoveryCodes = []string{util.HashRecoveryCode(recoveryCode)}
+
+	step := util.TOTPStep(time.Now())
+	code, err := util.TOTPCode(secret, step)
+	require.NoError(t, err)
+
+	challengeScopes := []string{util.TOTPChallengeScope, util.AccountsReadScope}
+
+	testCases := []struct {
+		desc          string
+		body          func(challengeToken string) gin.H
+		scopes        []string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker)
+	}{
+		{
+			desc: "OK",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": code}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), gomock.Eq(db.UseUserTOTPStepParams{
+						Username: user.Username,
+						Step:     step,
+					})).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp loginUserResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.NotEmpty(t, rsp.AccessToken)
+				require.NotEmpty(t, rsp.RefreshToken)
+			},
+		},
+		{
+			desc: "ChallengeScopeDropped",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": code}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CreateSessionParams) (db.Session, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.Session{ID: arg.ID}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp loginUserResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+
+				payload, err := tokenMaker.VerifyToken(rsp.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, []string{util.AccountsReadScope}, payload.Scopes)
+			},
+		},
+		{
+			desc: "RecoveryCode",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "recovery_code": "ABCDE-FGHIJ"}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UseUserRecoveryCode(gomock.Any(), gomock.Eq(db.UseUserRecoveryCodeParams{
+						Username:   user.Username,
+						HashedCode: util.HashRecoveryCode(recoveryCode),
+					})).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc: "UsedRecoveryCode",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "recovery_code": recoveryCode}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UseUserRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+				buildLoginFailureStubs(store, 1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "ReplayedCode",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": code}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+				buildLoginFailureStubs(store, 1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "WrongCode",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": wrongTOTPCode(code)}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), gomock.Any()).
+					Times(0)
+				buildLoginFailureStubs(store, 1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "Locked",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": code}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListLoginLocks(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.LoginFailure{
+						{
+							Key:         loginUsernameKey(user.Username),
+							FailedCount: 5,
+							LockedUntil: sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+						},
+					}, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusTooManyRequests, recoder.Code)
+			},
+		},
+		{
+			desc: "AccessTokenAsChallenge",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": code}
+			},
+			scopes: util.AllScopes(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "UsedChallenge",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": code}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{ExpiresAt: time.Now().Add(time.Minute)}, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "FrozenUser",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": code}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				frozenUser := user
+				frozenUser.IsFrozen = true
+
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(frozenUser, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc: "PasswordChanged",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken, "code": code}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				changedUser := user
+				changedUser.PasswordChangedAt = time.Now().Add(time.Second)
+
+				buildAuthStubs(store)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(changedUser, nil)
+				store.EXPECT().
+					UseUserTOTPStep(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, errPasswordChanged)
+			},
+		},
+		{
+			desc: "MissingCode",
+			body: func(challengeToken string) gin.H {
+				return gin.H{"challenge_token": challengeToken}
+			},
+			scopes: challengeScopes,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildLoginThrottleStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			challengeToken, _, err := server.tokenMaker.CreateToken(token.ChallengeToken, user.Username, user.Role, tC.scopes, time.Minute)
+			require.NoError(t, err)
+
+			data, err := json.Marshal(tC.body(challengeToken))
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login/totp", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder, server.tokenMaker)
+		})
+	}
+}
+
+// wrongTOTPCode returns a well-formed code that differs from the given one
+func wrongTOTPCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/fx"
 	"github.com/amrizal94/simplebank/token"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type transferRequest struct {
@@ -16,6 +19,9 @@ type transferRequest struct {
 	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1"`
 	Amount        int64  `json:"amount" binding:"required,gt=0"`
 	Currency      string `json:"currency" binding:"required,currency"`
+	// QuoteID makes it a cross-currency transfer at the rate of the quote,
+	// the to account must then be in the currency the quote converts to
+	QuoteID string `json:"quote_id" binding:"omitempty,uuid"`
 }
 
 func (server *Server) createTransfer(ctx *gin.Context) {
@@ -25,25 +31,65 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		return
 	}
 
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	// a retry with the key of a transfer that was made gets its response again,
+	// before any check the new state of the accounts could fail
+	key, err := idempotencyKey(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var requestHash string
+	if key != "" {
+		requestHash, err = hashRequest(req)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if server.replayIdempotencyKey(ctx, authPayload.Username, key, requestHash) {
+			return
+		}
+	}
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !user.IsEmailVerified {
+		ctx.JSON(http.StatusForbidden, errorResponse(errEmailNotVerified))
+		return
+	}
+
+	toCurrency := req.Currency
+	var quote *db.FXQuoteParams
+	if req.QuoteID != "" {
+		fxQuote, valid := server.validFXQuote(ctx, req.QuoteID, authPayload.Username, req.Currency)
+		if !valid {
+			return
+		}
+		toCurrency = fxQuote.ToCurrency
+		quote = &db.FXQuoteParams{
+			Username: authPayload.Username,
+			QuoteID:  fxQuote.ID,
+			Convert:  convertAtQuote,
+		}
+	}
+
 	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
 	if !valid {
 		return
 	}
 
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 	if fromAccount.Owner != authPayload.Username {
 		err := errors.New("from account dosn't belong to the authenticated user")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
 
-	if fromAccount.Balance < req.Amount {
-		err := errors.New("from account not enough money")
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
-
-	_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
+	_, valid = server.validAccount(ctx, req.ToAccountID, toCurrency)
 	if !valid {
 		return
 	}
@@ -54,12 +100,87 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		Amount:        req.Amount,
 	}
 
-	result, err := server.store.TransferTx(ctx, arg)
+	if key == "" {
+		var result db.TransferTxResult
+		if quote != nil {
+			result, err = server.store.CrossCurrencyTransferTx(ctx, db.CrossCurrencyTransferTxParams{
+				TranferTxParams: arg,
+				FXQuoteParams:   *quote,
+			})
+		} else {
+			result, err = server.store.TransferTx(ctx, arg)
+		}
+		if err != nil {
+			if isRejectedTransfer(err) {
+				ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+				return
+			}
+			ctx.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(200, result)
+		return
+	}
+
+	result, err := server.store.IdempotentTransferTx(ctx, db.IdempotentTransferTxParams{
+		TranferTxParams: arg,
+		Username:        authPayload.Username,
+		IdempotencyKey:  key,
+		Quote:           quote,
+		RequestHash:     requestHash,
+		ExpiredAt:       time.Now().Add(server.config.IdempotencyKeyDuration),
+	})
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err.Error()})
+		if isRejectedTransfer(err) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	ctx.JSON(200, result)
+
+	// a concurrent request with the key made the transfer first
+	if result.Replayed {
+		writeIdempotentReplay(ctx, result.IdempotencyKey, requestHash)
+		return
+	}
+	ctx.JSON(http.StatusOK, result.TransferTxResult)
+}
+
+// isRejectedTransfer reports whether a transfer failed because the state of the accounts
+// or of its quote doesn't allow it, rather than because of the server
+func isRejectedTransfer(err error) bool {
+	return errors.Is(err, db.ErrInsufficientFunds) ||
+		errors.Is(err, db.ErrTransferLimitExceeded) ||
+		errors.Is(err, db.ErrInvalidQuote) ||
+		errors.Is(err, db.ErrAccountFrozen) ||
+		errors.Is(err, fx.ErrAmountTooSmall)
+}
+
+// validFXQuote checks a quote of the user can still be used to convert from currency
+func (server *Server) validFXQuote(ctx *gin.Context, quoteID string, username string, currency string) (db.FxQuote, bool) {
+	quote, err := server.store.GetFXQuote(ctx, uuid.MustParse(quoteID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(db.ErrInvalidQuote))
+			return quote, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return quote, false
+	}
+
+	if quote.Username != username || quote.IsUsed || !quote.ExpiredAt.After(time.Now()) {
+		ctx.JSON(http.StatusUnprocessableEntity, errorResponse(db.ErrInvalidQuote))
+		return quote, false
+	}
+
+	if quote.FromCurrency != currency {
+		err := fmt.Errorf("quote currency mismatch: %s vs %s", quote.FromCurrency, currency)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return quote, false
+	}
+
+	return quote, true
 }
 
 func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) (db.Account, bool) {
@@ -67,12 +188,19 @@ func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency s
 	if err != nil {
 		if err == sql.ErrNoRows {
 			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return account, false
 		}
 
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return account, false
 	}
 
+	if account.IsFrozen {
+		err := fmt.Errorf("account [%d] is frozen", account.ID)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return account, false
+	}
+
 	if account.Currency != currency {
 		err := fmt.Errorf("account [%d] currency mismatch: %s vs %s", account.ID, account.Currency, currency)
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
@@ -81,3 +209,203 @@ func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency s
 
 	return account, true
 }
+
+const (
+	transferDirectionIncoming = "incoming"
+	transferDirectionOutgoing = "outgoing"
+)
+
+// transferResponse is a transfer as seen from one of its two accounts
+type transferResponse struct {
+	ID            int64 `json:"id"`
+	FromAccountID int64 `json:"from_account_id"`
+	ToAccountID   int64 `json:"to_account_id"`
+	Amount        int64 `json:"amount"`
+	// ToAmount and FXRate are set on cross-currency transfers,
+	// the to account was credited ToAmount in its own currency
+	ToAmount              int64     `json:"to_amount,omitempty"`
+	FXRate                string    `json:"fx_rate,omitempty"`
+	CreatedAt             time.Time `json:"created_at"`
+	Direction             string    `json:"direction"`
+	CounterpartyAccountID int64     `json:"counterparty_account_id"`
+}
+
+func newTransferResponse(transfer db.Transfer, accountID int64) transferResponse {
+	rsp := transferResponse{
+		ID:                    transfer.ID,
+		FromAccountID:         transfer.FromAccountID,
+		ToAccountID:           transfer.ToAccountID,
+		Amount:                transfer.Amount,
+		ToAmount:              transfer.ToAmount.Int64,
+		FXRate:                transfer.FxRate.String,
+		CreatedAt:             transfer.CreatedAt,
+		Direction:             transferDirectionOutgoing,
+		CounterpartyAccountID: transfer.ToAccountID,
+	}
+	if transfer.FromAccountID != accountID {
+		rsp.Direction = transferDirectionIncoming
+		rsp.CounterpartyAccountID = transfer.FromAccountID
+	}
+	return rsp
+}
+
+type getTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getTransfer returns a transfer to whoever can read one of its accounts.
+// It is seen from the account of the user, or from the sending account for bankers.
+func (server *Server) getTransfer(ctx *gin.Context) {
+	var req getTransferRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	transfer, err := server.store.GetTransfer(ctx, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	for _, accountID := range []int64{transfer.FromAccountID, transfer.ToAccountID} {
+		account, err := server.store.GetAccount(ctx, accountID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if canReadAccount(ctx, authPayload, account) {
+			ctx.JSON(http.StatusOK, gin.H{"transfer": newTransferResponse(transfer, account.ID)})
+			return
+		}
+	}
+
+	err = errors.New("transfer doesn't belong to the authenticated user")
+	ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+}
+
+type listAccountTransfersRequest struct {
+	pageRequest
+	Direction      string    `form:"direction" binding:"omitempty,oneof=incoming outgoing"`
+	CounterpartyID int64     `form:"counterparty_account_id" binding:"omitempty,min=1"`
+	MinAmount      int64     `form:"min_amount" binding:"omitempty,gt=0"`
+	MaxAmount      int64     `form:"max_amount" binding:"omitempty,gt=0"`
+	CreatedAfter   time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore  time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// list names the list of transfers of the account with the filters of the request,
+// so a cursor only works with the filters it was made with
+func (req listAccountTransfersRequest) list(accountID int64) string {
+	return fmt.Sprintf("transfers:%d:%s:%d:%d:%d:%s:%s", accountID, req.Direction, req.CounterpartyID,
+		req.MinAmount, req.MaxAmount, filterTime(req.CreatedAfter), filterTime(req.CreatedBefore))
+}
+
+// filterTime normalizes a time filter, the same instant in any time zone is the same filter
+func filterTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// listAccountTransfers returns the transfers of an account, newest first.
+// The amount range is inclusive and in the currency of the account: the amount sent,
+// or the to_amount credited for incoming cross-currency transfers. created_before is exclusive.
+func (server *Server) listAccountTransfers(ctx *gin.Context) {
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req listAccountTransfersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.MaxAmount > 0 && req.MaxAmount < req.MinAmount {
+		err := errors.New("max_amount must not be less than min_amount")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !req.CreatedAfter.IsZero() && !req.CreatedBefore.IsZero() && !req.CreatedBefore.After(req.CreatedAfter) {
+		err := errors.New("created_before must be after created_after")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	list := req.list(uri.ID)
+	page, err := server.paginator.page(list, req.pageRequest)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !canReadAccount(ctx, authPayload, account) {
+		err := errors.New("account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	arg := db.ListAccountTransfersParams{
+		AccountID:       account.ID,
+		Direction:       sql.NullString{String: req.Direction, Valid: req.Direction != ""},
+		CounterpartyID:  sql.NullInt64{Int64: req.CounterpartyID, Valid: req.CounterpartyID > 0},
+		MinAmount:       sql.NullInt64{Int64: req.MinAmount, Valid: req.MinAmount > 0},
+		MaxAmount:       sql.NullInt64{Int64: req.MaxAmount, Valid: req.MaxAmount > 0},
+		CreatedAfter:    sql.NullTime{Time: req.CreatedAfter, Valid: !req.CreatedAfter.IsZero()},
+		CreatedBefore:   sql.NullTime{Time: req.CreatedBefore, Valid: !req.CreatedBefore.IsZero()},
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.limit(),
+	}
+
+	var transfers []db.Transfer
+	if page.reverse() {
+		transfers, err = server.store.ListAccountTransfersReverse(ctx, db.ListAccountTransfersReverseParams{
+			AccountID:       arg.AccountID,
+			Direction:       arg.Direction,
+			CounterpartyID:  arg.CounterpartyID,
+			MinAmount:       arg.MinAmount,
+			MaxAmount:       arg.MaxAmount,
+			CreatedAfter:    arg.CreatedAfter,
+			CreatedBefore:   arg.CreatedBefore,
+			CursorCreatedAt: page.cursor.CreatedAt,
+			CursorID:        page.cursor.ID,
+			Limit:           arg.Limit,
+		})
+	} else {
+		transfers, err = server.store.ListAccountTransfers(ctx, arg)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	transfers, links := finishPage(server.paginator, list, page, transfers, func(transfer db.Transfer) (time.Time, int64) {
+		return transfer.CreatedAt, transfer.ID
+	})
+
+	rsp := make([]transferResponse, len(transfers))
+	for i, transfer := range transfers {
+		rsp[i] = newTransferResponse(transfer, account.ID)
+	}
+	ctx.JSON(http.StatusOK, pageResponse("transfers", rsp, links))
+}
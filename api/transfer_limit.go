@@ -0,0 +1,252 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/gin-gonic/gin"
+)
+
+// transferLimitsResponse holds the limits in effect for the outgoing transfers of an account
+// and what was sent today, in UTC. A nil limit is unlimited.
+type transferLimitsResponse struct {
+	AccountID         int64  `json:"account_id"`
+	Tier              string `json:"tier"`
+	Currency          string `json:"currency"`
+	MaxPerTransaction *int64 `json:"max_per_transaction"`
+	MaxDailyAmount    *int64 `json:"max_daily_amount"`
+	MaxDailyCount     *int32 `json:"max_daily_count"`
+	DailyAmount       int64  `json:"daily_amount"`
+	DailyCount        int32  `json:"daily_count"`
+}
+
+func newTransferLimitsResponse(limits db.GetTransferLimitsRow) transferLimitsResponse {
+	rsp := transferLimitsResponse{
+		AccountID:   limits.AccountID,
+		Tier:        limits.Tier,
+		Currency:    limits.Currency,
+		DailyAmount: limits.DailyAmount,
+		DailyCount:  limits.DailyCount,
+	}
+	if limits.MaxPerTransaction.Valid {
+		rsp.MaxPerTransaction = &limits.MaxPerTransaction.Int64
+	}
+	if limits.MaxDailyAmount.Valid {
+		rsp.MaxDailyAmount = &limits.MaxDailyAmount.Int64
+	}
+	if limits.MaxDailyCount.Valid {
+		rsp.MaxDailyCount = &limits.MaxDailyCount.Int32
+	}
+	return rsp
+}
+
+// getAccountTransferLimits returns the transfer limits of an account
+// to whoever can read the account
+func (server *Server) getAccountTransferLimits(ctx *gin.Context) {
+	var req getAccountRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, req.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !canReadAccount(ctx, authPayload, account) {
+		err := errors.New("account doesn't belong to the authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	limits, err := server.store.GetTransferLimits(ctx, account.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"limits": newTransferLimitsResponse(limits)})
+}
+
+// transferLimitsRequest sets every limit at once, a missing limit is removed
+type transferLimitsRequest struct {
+	MaxPerTransaction *int64 `json:"max_per_transaction" binding:"omitempty,gt=0"`
+	MaxDailyAmount    *int64 `json:"max_daily_amount" binding:"omitempty,gt=0"`
+	MaxDailyCount     *int32 `json:"max_daily_count" binding:"omitempty,gt=0"`
+}
+
+func (req transferLimitsRequest) maxPerTransaction() sql.NullInt64 {
+	if req.MaxPerTransaction == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *req.MaxPerTransaction, Valid: true}
+}
+
+func (req transferLimitsRequest) maxDailyAmount() sql.NullInt64 {
+	if req.MaxDailyAmount == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *req.MaxDailyAmount, Valid: true}
+}
+
+func (req transferLimitsRequest) maxDailyCount() sql.NullInt32 {
+	if req.MaxDailyCount == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *req.MaxDailyCount, Valid: true}
+}
+
+// setAccountTransferLimits replaces the limits set on an account.
+// The limits it doesn't set are the ones of the tier of its owner.
+func (server *Server) setAccountTransferLimits(ctx *gin.Context) {
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req transferLimitsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	_, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = server.store.SetAccountTransferLimits(ctx, db.SetAccountTransferLimitsParams{
+		AccountID:         uri.ID,
+		MaxPerTransaction: req.maxPerTransaction(),
+		MaxDailyAmount:    req.maxDailyAmount(),
+		MaxDailyCount:     req.maxDailyCount(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	limits, err := server.store.GetTransferLimits(ctx, uri.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"limits": newTransferLimitsResponse(limits)})
+}
+
+type tierTransferLimitsURI struct {
+	Tier     string `uri:"tier" binding:"required,tier"`
+	Currency string `uri:"currency" binding:"required,currency"`
+}
+
+// setTierTransferLimits replaces the limits of the accounts in a currency of the users of a tier
+func (server *Server) setTierTransferLimits(ctx *gin.Context) {
+	var uri tierTransferLimitsURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req transferLimitsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	limits, err := server.store.SetTierTransferLimits(ctx, db.SetTierTransferLimitsParams{
+		Tier:              uri.Tier,
+		Currency:          uri.Currency,
+		MaxPerTransaction: req.maxPerTransaction(),
+		MaxDailyAmount:    req.maxDailyAmount(),
+		MaxDailyCount:     req.maxDailyCount(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"limits": newTierTransferLimitsResponse(limits)})
+}
+
+type tierTransferLimitsResponse struct {
+	Tier              string    `json:"tier"`
+	Currency          string    `json:"currency"`
+	MaxPerTransaction *int64    `json:"max_per_transaction"`
+	MaxDailyAmount    *int64    `json:"max_daily_amount"`
+	MaxDailyCount     *int32    `json:"max_daily_count"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func newTierTransferLimitsResponse(limits db.TierTransferLimit) tierTransferLimitsResponse {
+	rsp := tierTransferLimitsResponse{
+		Tier:      limits.Tier,
+		Currency:  limits.Currency,
+		UpdatedAt: limits.UpdatedAt,
+	}
+	if limits.MaxPerTransaction.Valid {
+		rsp.MaxPerTransaction = &limits.MaxPerTransaction.Int64
+	}
+	if limits.MaxDailyAmount.Valid {
+		rsp.MaxDailyAmount = &limits.MaxDailyAmount.Int64
+	}
+	if limits.MaxDailyCount.Valid {
+		rsp.MaxDailyCount = &limits.MaxDailyCount.Int32
+	}
+	return rsp
+}
+
+type updateUserTierURI struct {
+	Username string `uri:"username" binding:"required,alphanum"`
+}
+
+type updateUserTierRequest struct {
+	Tier string `json:"tier" binding:"required,tier"`
+}
+
+// updateUserTier moves a user to another tier, with the transfer limits of the tier
+func (server *Server) updateUserTier(ctx *gin.Context) {
+	var uri updateUserTierURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req updateUserTierRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.UpdateUserTier(ctx, db.UpdateUserTierParams{
+		Username: uri.Username,
+		Tier:     req.Tier,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"username": user.Username, "tier": user.Tier})
+}
@@ -0,0 +1,559 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAccountTransferLimitsAPI(t *testing.T) {
+	user, _ := randomUser()
+	account := randomAccount(user.Username)
+	limits := randomTransferLimits(account)
+
+	testCases := []struct {
+		name          string
+		accountID     int64
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					GetTransferLimits(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(limits, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				rsp := requireBodyTransferLimits(t, recoder.Body)
+				require.Equal(t, account.ID, rsp.AccountID)
+				require.Equal(t, limits.Tier, rsp.Tier)
+				require.Equal(t, account.Currency, rsp.Currency)
+				require.NotNil(t, rsp.MaxPerTransaction)
+				require.Equal(t, limits.MaxPerTransaction.Int64, *rsp.MaxPerTransaction)
+				require.NotNil(t, rsp.MaxDailyAmount)
+				require.Equal(t, limits.MaxDailyAmount.Int64, *rsp.MaxDailyAmount)
+				require.Nil(t, rsp.MaxDailyCount)
+				require.Equal(t, limits.DailyAmount, rsp.DailyAmount)
+				require.Equal(t, limits.DailyCount, rsp.DailyCount)
+			},
+		},
+		{
+			name:      "BankerOK",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					GetTransferLimits(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(limits, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					GetTransferLimits(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			name:      "AccountNotFound",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetTransferLimits(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			name:      "InternalError",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					GetTransferLimits(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.GetTransferLimitsRow{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			name:      "InvalidID",
+			accountID: 0,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/limits", tc.accountID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestSetAccountTransferLimitsAPI(t *testing.T) {
+	admin, _ := randomUser()
+	admin.Role = util.AdminRole
+
+	user, _ := randomUser()
+	account := randomAccount(user.Username)
+	limits := randomTransferLimits(account)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"max_per_transaction": limits.MaxPerTransaction.Int64,
+				"max_daily_amount":    limits.MaxDailyAmount.Int64,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					SetAccountTransferLimits(gomock.Any(), gomock.Eq(db.SetAccountTransferLimitsParams{
+						AccountID:         account.ID,
+						MaxPerTransaction: limits.MaxPerTransaction,
+						MaxDailyAmount:    limits.MaxDailyAmount,
+					})).
+					Times(1).
+					Return(db.AccountTransferLimit{AccountID: account.ID}, nil)
+				store.EXPECT().
+					GetTransferLimits(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(limits, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				rsp := requireBodyTransferLimits(t, recoder.Body)
+				require.Equal(t, account.ID, rsp.AccountID)
+				require.Equal(t, limits.MaxPerTransaction.Int64, *rsp.MaxPerTransaction)
+			},
+		},
+		{
+			name: "BankerForbidden",
+			body: gin.H{
+				"max_per_transaction": limits.MaxPerTransaction.Int64,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SetAccountTransferLimits(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			name: "AccountNotFound",
+			body: gin.H{
+				"max_per_transaction": limits.MaxPerTransaction.Int64,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().
+					SetAccountTransferLimits(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			name: "NegativeLimit",
+			body: gin.H{
+				"max_daily_count": -1,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SetAccountTransferLimits(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/accounts/%d/limits", account.ID)
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestSetTierTransferLimitsAPI(t *testing.T) {
+	admin, _ := randomUser()
+	admin.Role = util.AdminRole
+
+	testCases := []struct {
+		name          string
+		tier          string
+		currency      string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			tier:     util.PremiumTier,
+			currency: util.USD,
+			body: gin.H{
+				"max_daily_count": 20,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SetTierTransferLimits(gomock.Any(), gomock.Eq(db.SetTierTransferLimitsParams{
+						Tier:          util.PremiumTier,
+						Currency:      util.USD,
+						MaxDailyCount: sql.NullInt32{Int32: 20, Valid: true},
+					})).
+					Times(1).
+					Return(db.TierTransferLimit{
+						Tier:          util.PremiumTier,
+						Currency:      util.USD,
+						MaxDailyCount: sql.NullInt32{Int32: 20, Valid: true},
+					}, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp struct {
+					Limits tierTransferLimitsResponse `json:"limits"`
+				}
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Equal(t, util.PremiumTier, rsp.Limits.Tier)
+				require.Equal(t, util.USD, rsp.Limits.Currency)
+				require.Nil(t, rsp.Limits.MaxPerTransaction)
+				require.Nil(t, rsp.Limits.MaxDailyAmount)
+				require.Equal(t, int32(20), *rsp.Limits.MaxDailyCount)
+			},
+		},
+		{
+			name:     "InvalidTier",
+			tier:     "gold",
+			currency: util.USD,
+			body:     gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SetTierTransferLimits(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:     "InvalidCurrency",
+			tier:     util.StandardTier,
+			currency: "XYZ",
+			body:     gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SetTierTransferLimits(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			tier:     util.StandardTier,
+			currency: util.EUR,
+			body:     gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					SetTierTransferLimits(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TierTransferLimit{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/tiers/%s/limits/%s", tc.tier, tc.currency)
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUpdateUserTierAPI(t *testing.T) {
+	admin, _ := randomUser()
+	admin.Role = util.AdminRole
+
+	user, _ := randomUser()
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"tier": util.BusinessTier,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				updatedUser := user
+				updatedUser.Tier = util.BusinessTier
+
+				store.EXPECT().
+					UpdateUserTier(gomock.Any(), gomock.Eq(db.UpdateUserTierParams{
+						Username: user.Username,
+						Tier:     util.BusinessTier,
+					})).
+					Times(1).
+					Return(updatedUser, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp gin.H
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Equal(t, user.Username, rsp["username"])
+				require.Equal(t, util.BusinessTier, rsp["tier"])
+			},
+		},
+		{
+			name: "UserNotFound",
+			body: gin.H{
+				"tier": util.PremiumTier,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTier(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			name: "InvalidTier",
+			body: gin.H{
+				"tier": "gold",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTier(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/users/%s/tier", user.Username)
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func randomTransferLimits(account db.Account) db.GetTransferLimitsRow {
+	return db.GetTransferLimitsRow{
+		AccountID:         account.ID,
+		Tier:              util.StandardTier,
+		Currency:          account.Currency,
+		MaxPerTransaction: sql.NullInt64{Int64: util.RandomInt(100, 1000), Valid: true},
+		MaxDailyAmount:    sql.NullInt64{Int64: util.RandomInt(1000, 10000), Valid: true},
+		DailyAmount:       util.RandomInt(0, 1000),
+		DailyCount:        int32(util.RandomInt(0, 10)),
+	}
+}
+
+func requireBodyTransferLimits(t *testing.T, body *bytes.Buffer) transferLimitsResponse {
+	var rsp struct {
+		Limits transferLimitsResponse `json:"limits"`
+	}
+	err := json.Unmarshal(body.Bytes(), &rsp)
+	require.NoError(t, err)
+	return rsp.Limits
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -52,7 +53,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user1.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -75,6 +76,119 @@ func TestTransferAPI(t *testing.T) {
 				require.Equal(t, http.StatusOK, recoder.Code)
 			},
 		},
+		{
+			name:   "UnverifiedEmail",
+			amount: amount,
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user1.Username)).
+					Times(1).
+					Return(user1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			name:   "GetUserError",
+			amount: amount,
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user1.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			name:   "FrozenToAccount",
+			amount: amount,
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				frozenAccount := account2
+				frozenAccount.IsFrozen = true
+
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).
+					Return(frozenAccount, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			name:   "MissingTransfersWriteScope",
+			amount: amount,
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addScopedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role,
+					[]string{util.AccountsReadScope, util.TransfersReadScope}, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
 		{
 			name:   "UnauthorizedAccountUser",
 			amount: amount,
@@ -86,7 +200,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user3.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user3.Username, user3.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -148,7 +262,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user1.Username, -time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, -time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -180,7 +294,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user1.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -205,6 +319,112 @@ func TestTransferAPI(t *testing.T) {
 				require.Equal(t, http.StatusInternalServerError, recoder.Code)
 			},
 		},
+		{
+			name:   "InsufficientFunds",
+			amount: amount,
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).
+					Return(account2, nil)
+
+				arg := db.TranferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        amount,
+				}
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+			},
+		},
+		{
+			name:   "AccountFrozenAfterCheck",
+			amount: amount,
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).
+					Return(account2, nil)
+
+				// frozen between the check of the handler and the lock of the transaction
+				arg := db.TranferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        amount,
+				}
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: [%d]", db.ErrAccountFrozen, account2.ID))
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+			},
+		},
+		{
+			name:   "TransferLimitExceeded",
+			amount: amount,
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).
+					Return(account2, nil)
+
+				arg := db.TranferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        amount,
+				}
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(db.TransferTxResult{}, fmt.Errorf("%w: at most 100 per transfer", db.ErrTransferLimitExceeded))
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+			},
+		},
 		{
 			name:   "FromAccountNotFound",
 			amount: amount,
@@ -216,7 +436,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user1.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -239,6 +459,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			checkResponse: func(recoder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, sql.ErrNoRows)
 			},
 		},
 		{
@@ -252,7 +473,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user1.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -276,6 +497,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			checkResponse: func(recoder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, sql.ErrNoRows)
 			},
 		},
 		{
@@ -289,7 +511,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user1.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -324,7 +546,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user1.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -359,7 +581,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user3.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user3.Username, user3.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -392,7 +614,7 @@ func TestTransferAPI(t *testing.T) {
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
 				addAuthorization(
-					t, request, tokenMaker, authorizationTypeBearer, user1.Username, time.Minute,
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
 				)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
@@ -424,6 +646,8 @@ func TestTransferAPI(t *testing.T) {
 
 			store := mockdb.NewMockStore(ctrl)
 			testCase.buildStubs(store)
+			buildAuthStubs(store)
+			buildVerifiedUserStubs(store)
 
 			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
@@ -445,3 +669,1213 @@ func TestTransferAPI(t *testing.T) {
 	}
 
 }
+
+// buildVerifiedUserStubs makes every user look up as having a verified email
+func buildVerifiedUserStubs(store *mockdb.MockStore) {
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ interface{}, username string) (db.User, error) {
+			return db.User{Username: username, Role: util.DepositorRole, IsEmailVerified: true}, nil
+		})
+}
+
+func TestTransferAPIIdempotencyKey(t *testing.T) {
+	amount := int64(10)
+
+	user1, _ := randomUser()
+	user2, _ := randomUser()
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+
+	key := util.RandomString(32)
+	req := transferRequest{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+		Currency:      util.USD,
+	}
+	requestHash, err := hashRequest(req)
+	require.NoError(t, err)
+
+	result := db.TransferTxResult{
+		Transfer: db.Transfer{
+			ID:            util.RandomInt(1, 1000),
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		},
+	}
+	response, err := json.Marshal(result)
+	require.NoError(t, err)
+
+	record := db.IdempotencyKey{
+		Username:    user1.Username,
+		Key:         key,
+		RequestHash: requestHash,
+		Response:    response,
+	}
+
+	buildAccountStubs := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			GetAccount(gomock.Any(), gomock.Eq(account1.ID)).
+			Times(1).
+			Return(account1, nil)
+		store.EXPECT().
+			GetAccount(gomock.Any(), gomock.Eq(account2.ID)).
+			Times(1).
+			Return(account2, nil)
+	}
+
+	testCases := []struct {
+		name          string
+		key           string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "FirstRequest",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Eq(db.GetIdempotencyKeyParams{Username: user1.Username, Key: key})).
+					Times(1).
+					Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				buildAccountStubs(store)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.IdempotentTransferTxParams) (db.IdempotentTransferTxResult, error) {
+						require.Equal(t, account1.ID, arg.FromAccountID)
+						require.Equal(t, account2.ID, arg.ToAccountID)
+						require.Equal(t, amount, arg.Amount)
+						require.Equal(t, user1.Username, arg.Username)
+						require.Equal(t, key, arg.IdempotencyKey)
+						require.Equal(t, requestHash, arg.RequestHash)
+						require.WithinDuration(t, time.Now().Add(24*time.Hour), arg.ExpiredAt, time.Second)
+						return db.IdempotentTransferTxResult{TransferTxResult: result, IdempotencyKey: record}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				require.Empty(t, recoder.Header().Get(idempotentReplayedHeader))
+				require.JSONEq(t, string(response), recoder.Body.String())
+			},
+		},
+		{
+			name: "Replay",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(record, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				require.Equal(t, "true", recoder.Header().Get(idempotentReplayedHeader))
+				require.JSONEq(t, string(response), recoder.Body.String())
+			},
+		},
+		{
+			name: "KeyReusedForAnotherRequest",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				otherRecord := record
+				otherRecord.RequestHash = util.RandomString(64)
+
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(otherRecord, nil)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, errIdempotencyKeyReused)
+			},
+		},
+		{
+			name: "ConcurrentRequestFirst",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				buildAccountStubs(store)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotentTransferTxResult{IdempotencyKey: record, Replayed: true}, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				require.Equal(t, "true", recoder.Header().Get(idempotentReplayedHeader))
+				require.JSONEq(t, string(response), recoder.Body.String())
+			},
+		},
+		{
+			name: "KeyTooLong",
+			key:  util.RandomString(maxIdempotencyKeyLength + 1),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name: "GetIdempotencyKeyError",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, sql.ErrConnDone)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				buildAccountStubs(store)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotentTransferTxResult{}, sql.ErrTxDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			name: "InsufficientFunds",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				buildAccountStubs(store)
+				store.EXPECT().
+					IdempotentTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.IdempotentTransferTxResult{}, db.ErrInsufficientFunds)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			testCase.buildStubs(store)
+			buildAuthStubs(store)
+			buildVerifiedUserStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			// the key is only tied to the content of the request, not to its layout
+			data := []byte(fmt.Sprintf(
+				`{"currency": %q, "amount": %d, "to_account_id": %d, "from_account_id": %d}`,
+				req.Currency, req.Amount, req.ToAccountID, req.FromAccountID,
+			))
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Set(idempotencyKeyHeader, testCase.key)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			testCase.checkResponse(recorder)
+		})
+	}
+}
+
+func TestGetTransferAPI(t *testing.T) {
+	user1, _ := randomUser()
+	user2, _ := randomUser()
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account2.ID = account1.ID + 1
+
+	transfer := randomTransfer(account1.ID, account2.ID)
+
+	testCases := []struct {
+		name          string
+		transferID    int64
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "Sender",
+			transferID: transfer.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).
+					Return(transfer, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				got := newTransferResponse(transfer, account1.ID)
+				require.Equal(t, transferDirectionOutgoing, got.Direction)
+				require.Equal(t, account2.ID, got.CounterpartyAccountID)
+				requireBodyMatchTransfer(t, recoder.Body, got)
+			},
+		},
+		{
+			name:       "Recipient",
+			transferID: transfer.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user2.Username, user2.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).
+					Return(transfer, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).
+					Return(account2, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				got := newTransferResponse(transfer, account2.ID)
+				require.Equal(t, transferDirectionIncoming, got.Direction)
+				require.Equal(t, account1.ID, got.CounterpartyAccountID)
+				requireBodyMatchTransfer(t, recoder.Body, got)
+			},
+		},
+		{
+			name:       "Banker",
+			transferID: transfer.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).
+					Return(transfer, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).
+					Return(account1, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				requireBodyMatchTransfer(t, recoder.Body, newTransferResponse(transfer, account1.ID))
+			},
+		},
+		{
+			name:       "UnauthorizedUser",
+			transferID: transfer.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).
+					Return(transfer, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).
+					Return(account2, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			name:       "NoAuthorization",
+			transferID: transfer.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			name:       "NotFound",
+			transferID: transfer.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).
+					Return(db.Transfer{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			name:       "InternalError",
+			transferID: transfer.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfer(gomock.Any(), gomock.Eq(transfer.ID)).Times(1).
+					Return(db.Transfer{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			name:       "InvalidID",
+			transferID: 0,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/transfers/%d", tc.transferID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListAccountTransfersAPI(t *testing.T) {
+	user, _ := randomUser()
+	account := randomAccount(user.Username)
+	counterparty := randomAccount("counterparty")
+	counterparty.ID = account.ID + 1
+
+	n := 5
+	transfers := make([]db.Transfer, n)
+	for i := range transfers {
+		if i%2 == 0 {
+			transfers[i] = randomTransfer(account.ID, counterparty.ID)
+		} else {
+			transfers[i] = randomTransfer(counterparty.ID, account.ID)
+		}
+	}
+
+	createdAfter := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	createdBefore := createdAfter.AddDate(0, 1, 0)
+
+	testCases := []struct {
+		name          string
+		accountID     int64
+		query         map[string]string
+		cursor        func(p *paginator) string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			accountID: account.ID,
+			query:     map[string]string{"page_size": "5"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+
+				arg := db.ListAccountTransfersParams{
+					AccountID: account.ID,
+					Limit:     6,
+				}
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Eq(arg)).Times(1).
+					Return(transfers, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				got := requireBodyTransfers(t, recoder.Body)
+				require.Len(t, got, n)
+				for i, transfer := range got {
+					require.Equal(t, newTransferResponse(transfers[i], account.ID), transfer)
+					require.Equal(t, counterparty.ID, transfer.CounterpartyAccountID)
+				}
+				require.Equal(t, transferDirectionOutgoing, got[0].Direction)
+				require.Equal(t, transferDirectionIncoming, got[1].Direction)
+			},
+		},
+		{
+			name:      "Filters",
+			accountID: account.ID,
+			query: map[string]string{
+				"direction":               transferDirectionIncoming,
+				"counterparty_account_id": fmt.Sprint(counterparty.ID),
+				"min_amount":              "10",
+				"max_amount":              "100",
+				"created_after":           createdAfter.Format(time.RFC3339),
+				"created_before":          createdBefore.Format(time.RFC3339),
+				"page_size":               "10",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ListAccountTransfersParams) ([]db.Transfer, error) {
+						require.Equal(t, account.ID, arg.AccountID)
+						require.Equal(t, sql.NullString{String: transferDirectionIncoming, Valid: true}, arg.Direction)
+						require.Equal(t, sql.NullInt64{Int64: counterparty.ID, Valid: true}, arg.CounterpartyID)
+						require.Equal(t, sql.NullInt64{Int64: 10, Valid: true}, arg.MinAmount)
+						require.Equal(t, sql.NullInt64{Int64: 100, Valid: true}, arg.MaxAmount)
+						require.True(t, arg.CreatedAfter.Valid)
+						require.True(t, arg.CreatedAfter.Time.Equal(createdAfter))
+						require.True(t, arg.CreatedBefore.Valid)
+						require.True(t, arg.CreatedBefore.Time.Equal(createdBefore))
+						require.Equal(t, int32(11), arg.Limit)
+						require.False(t, arg.CursorCreatedAt.Valid)
+						return []db.Transfer{}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+				require.Empty(t, requireBodyTransfers(t, recoder.Body))
+			},
+		},
+		{
+			name:      "AmountFilterOfCrossCurrencyTransfer",
+			accountID: account.ID,
+			query: map[string]string{
+				"min_amount": "150000",
+				"max_amount": "150000",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+
+				// the range applies to the 150000 credited to the account, not the 10 sent
+				fxTransfer := randomTransfer(counterparty.ID, account.ID)
+				fxTransfer.Amount = 10
+				fxTransfer.ToAmount = sql.NullInt64{Int64: 150000, Valid: true}
+				fxTransfer.FxRate = sql.NullString{String: "15000.000000000000", Valid: true}
+
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ListAccountTransfersParams) ([]db.Transfer, error) {
+						require.Equal(t, sql.NullInt64{Int64: 150000, Valid: true}, arg.MinAmount)
+						require.Equal(t, sql.NullInt64{Int64: 150000, Valid: true}, arg.MaxAmount)
+						return []db.Transfer{fxTransfer}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				got := requireBodyTransfers(t, recoder.Body)
+				require.Len(t, got, 1)
+				require.Equal(t, transferDirectionIncoming, got[0].Direction)
+				require.Equal(t, int64(10), got[0].Amount)
+				require.Equal(t, int64(150000), got[0].ToAmount)
+			},
+		},
+		{
+			name:      "WithReverseCursor",
+			accountID: account.ID,
+			query:     map[string]string{"direction": transferDirectionOutgoing, "page_size": "5"},
+			cursor: func(p *paginator) string {
+				list := listAccountTransfersRequest{Direction: transferDirectionOutgoing}.list(account.ID)
+				return p.cursor(list, pageCursor{
+					CreatedAt: transfers[3].CreatedAt,
+					ID:        transfers[3].ID,
+					Reverse:   true,
+				})
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+
+				store.EXPECT().
+					ListAccountTransfersReverse(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ interface{}, arg db.ListAccountTransfersReverseParams) ([]db.Transfer, error) {
+						require.Equal(t, account.ID, arg.AccountID)
+						require.Equal(t, sql.NullString{String: transferDirectionOutgoing, Valid: true}, arg.Direction)
+						require.True(t, arg.CursorCreatedAt.Equal(transfers[3].CreatedAt))
+						require.Equal(t, transfers[3].ID, arg.CursorID)
+						require.Equal(t, int32(6), arg.Limit)
+						return []db.Transfer{transfers[2], transfers[1], transfers[0]}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				got := requireBodyTransfers(t, recoder.Body)
+				require.Len(t, got, 3)
+				require.Equal(t, transfers[0].ID, got[0].ID)
+				require.Equal(t, transfers[2].ID, got[2].ID)
+
+				links := requireBodyPageLinks(t, recoder.Body)
+				require.NotEmpty(t, links.NextCursor)
+				require.Empty(t, links.PrevCursor)
+			},
+		},
+		{
+			name:      "CursorOfOtherAccount",
+			accountID: account.ID,
+			query:     map[string]string{"page_size": "5"},
+			cursor: func(p *paginator) string {
+				return p.cursor(listAccountTransfersRequest{}.list(counterparty.ID), pageCursor{
+					CreatedAt: transfers[3].CreatedAt,
+					ID:        transfers[3].ID,
+				})
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "CursorOfOtherFilters",
+			accountID: account.ID,
+			query:     map[string]string{"direction": transferDirectionIncoming, "page_size": "5"},
+			cursor: func(p *paginator) string {
+				list := listAccountTransfersRequest{Direction: transferDirectionOutgoing}.list(account.ID)
+				return p.cursor(list, pageCursor{
+					CreatedAt: transfers[3].CreatedAt,
+					ID:        transfers[3].ID,
+				})
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "Banker",
+			accountID: account.ID,
+			query:     map[string]string{"page_size": "5"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Any()).Times(1).
+					Return(transfers, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			accountID: account.ID,
+			query:     map[string]string{"page_size": "5"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			name:      "AccountNotFound",
+			accountID: account.ID,
+			query:     map[string]string{"page_size": "5"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			name:      "InternalError",
+			accountID: account.ID,
+			query:     map[string]string{"page_size": "5"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Any()).Times(1).
+					Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			name:      "InvalidDirection",
+			accountID: account.ID,
+			query:     map[string]string{"direction": "sideways", "page_size": "5"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "InvalidAmountRange",
+			accountID: account.ID,
+			query:     map[string]string{"min_amount": "100", "max_amount": "10", "page_size": "5"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "InvalidCreatedRange",
+			accountID: account.ID,
+			query: map[string]string{
+				"created_after":  createdBefore.Format(time.RFC3339),
+				"created_before": createdAfter.Format(time.RFC3339),
+				"page_size":      "5",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name:      "InvalidPageSize",
+			accountID: account.ID,
+			query:     map[string]string{"page_size": "101"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/transfers", tc.accountID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			q := request.URL.Query()
+			for key, value := range tc.query {
+				q.Add(key, value)
+			}
+			if tc.cursor != nil {
+				q.Add("cursor", tc.cursor(server.paginator))
+			}
+			request.URL.RawQuery = q.Encode()
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func randomTransfer(fromAccountID, toAccountID int64) db.Transfer {
+	return db.Transfer{
+		ID:            util.RandomInt(1, 1000),
+		FromAccountID: fromAccountID,
+		ToAccountID:   toAccountID,
+		Amount:        util.RandomMoney(),
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func requireBodyMatchTransfer(t *testing.T, body *bytes.Buffer, transfer transferResponse) {
+	var data map[string]json.RawMessage
+	err := json.Unmarshal(body.Bytes(), &data)
+	require.NoError(t, err)
+
+	var gotTransfer transferResponse
+	err = json.Unmarshal(data["transfer"], &gotTransfer)
+	require.NoError(t, err)
+	require.Equal(t, transfer, gotTransfer)
+}
+
+func requireBodyTransfers(t *testing.T, body *bytes.Buffer) []transferResponse {
+	var data map[string]json.RawMessage
+	err := json.Unmarshal(body.Bytes(), &data)
+	require.NoError(t, err)
+
+	var gotTransfers []transferResponse
+	err = json.Unmarshal(data["transfers"], &gotTransfers)
+	require.NoError(t, err)
+	return gotTransfers
+}
+
+func TestCrossCurrencyTransferAPI(t *testing.T) {
+	amount := int64(100)
+
+	user1, _ := randomUser()
+	user2, _ := randomUser()
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.IDR
+
+	quote := randomFXQuote(user1.Username, util.USD, util.IDR)
+	quote.Rate = "15000.500000000000"
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+				"quote_id":        quote.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(quote, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).
+					Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).
+					Times(1).
+					Return(account2, nil)
+				store.EXPECT().
+					CrossCurrencyTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.CrossCurrencyTransferTxParams) (db.TransferTxResult, error) {
+						require.Equal(t, db.TranferTxParams{
+							FromAccountID: account1.ID,
+							ToAccountID:   account2.ID,
+							Amount:        amount,
+						}, arg.TranferTxParams)
+						require.Equal(t, user1.Username, arg.Username)
+						require.Equal(t, quote.ID, arg.QuoteID)
+
+						toAmount, err := arg.Convert(quote, arg.Amount)
+						require.NoError(t, err)
+						require.Equal(t, int64(1500050), toAmount)
+						return db.TransferTxResult{}, nil
+					})
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			name: "QuoteNotFound",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+				"quote_id":        quote.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(db.FxQuote{}, sql.ErrNoRows)
+				store.EXPECT().
+					CrossCurrencyTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+			},
+		},
+		{
+			name: "QuoteOfAnotherUser",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+				"quote_id":        quote.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				otherQuote := quote
+				otherQuote.Username = user2.Username
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(otherQuote, nil)
+				store.EXPECT().
+					CrossCurrencyTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+			},
+		},
+		{
+			name: "ExpiredQuote",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+				"quote_id":        quote.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				expiredQuote := quote
+				expiredQuote.ExpiredAt = time.Now().Add(-time.Second)
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(expiredQuote, nil)
+				store.EXPECT().
+					CrossCurrencyTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+			},
+		},
+		{
+			name: "QuoteCurrencyMismatch",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.EUR,
+				"quote_id":        quote.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(quote, nil)
+				store.EXPECT().
+					CrossCurrencyTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name: "ToAccountNotInQuoteCurrency",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+				"quote_id":        quote.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				eurQuote := quote
+				eurQuote.ToCurrency = util.EUR
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(eurQuote, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).
+					Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).
+					Times(1).
+					Return(account2, nil)
+				store.EXPECT().
+					CrossCurrencyTransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			name: "QuoteUsedConcurrently",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+				"quote_id":        quote.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Eq(quote.ID)).
+					Times(1).
+					Return(quote, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).
+					Times(1).
+					Return(account1, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).
+					Times(1).
+					Return(account2, nil)
+				store.EXPECT().
+					CrossCurrencyTransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, db.ErrInvalidQuote)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recoder.Code)
+			},
+		},
+		{
+			name: "InvalidQuoteID",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+				"quote_id":        "invalid",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetFXQuote(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			testCase.buildStubs(store)
+			buildAuthStubs(store)
+			buildVerifiedUserStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(testCase.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user1.Username, user1.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			testCase.checkResponse(recorder)
+		})
+	}
+}
+
+func TestListAccountTransfersRequestList(t *testing.T) {
+	createdAfter := time.Now().UTC().Truncate(time.Second)
+	req := listAccountTransfersRequest{CreatedAfter: createdAfter}
+
+	// the same instant in another time zone is the same list
+	other := listAccountTransfersRequest{CreatedAfter: createdAfter.In(time.FixedZone("UTC+7", 7*60*60))}
+	require.Equal(t, req.list(1), other.list(1))
+
+	require.NotEqual(t, req.list(1), req.list(2))
+	require.NotEqual(t, req.list(1), listAccountTransfersRequest{}.list(1))
+	require.NotEqual(t, req.list(1), listAccountTransfersRequest{CreatedAfter: createdAfter, MinAmount: 10}.list(1))
+}
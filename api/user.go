@@ -2,26 +2,32 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/token"
 	"github.com/amrizal94/simplebank/util"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
 type createUserRequest struct {
 	Username string `json:"username" binding:"required,alphanum"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required,password"`
 	FullName string `json:"full_name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 }
 
 type userResponse struct {
 	Username          string    `json:"username"`
+	Role              string    `json:"role"`
 	FullName          string    `json:"full_name"`
 	Email             string    `json:"email"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
+	IsTOTPEnabled     bool      `json:"is_totp_enabled"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
 }
@@ -29,8 +35,11 @@ type userResponse struct {
 func newUserResponse(user db.User) userResponse {
 	return userResponse{
 		Username:          user.Username,
+		Role:              user.Role,
 		FullName:          user.FullName,
 		Email:             user.Email,
+		IsEmailVerified:   user.IsEmailVerified,
+		IsTOTPEnabled:     user.IsTotpEnabled,
 		PasswordChangedAt: user.PasswordChangedAt,
 		CreatedAt:         user.CreatedAt,
 	}
@@ -39,22 +48,35 @@ func newUserResponse(user db.User) userResponse {
 func (server *Server) createUser(ctx *gin.Context) {
 	var req createUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
+		ctx.JSON(400, server.bindingErrorResponse(err))
 		return
 	}
-	hashPassword, err := util.HashPassword(req.Password)
+	hashPassword, err := server.hashPassword(req.Password)
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	arg := db.CreateUserParams{
-		Username:       req.Username,
-		HashedPassword: hashPassword,
-		FullName:       req.FullName,
-		Email:          req.Email,
+	secretCode, err := util.GenerateSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
 	}
 
-	user, err := server.store.CreateUser(ctx, arg)
+	arg := db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username:       req.Username,
+			HashedPassword: hashPassword,
+			FullName:       req.FullName,
+			Email:          req.Email,
+		},
+		HashedSecretCode:     util.HashSecretCode(secretCode),
+		VerifyEmailExpiredAt: time.Now().Add(server.config.VerifyEmailDuration),
+		AfterCreate: func(user db.User, verifyEmail db.VerifyEmail) error {
+			return server.sendVerifyEmail(user, verifyEmail, secretCode)
+		},
+	}
+
+	result, err := server.store.CreateUserTx(ctx, arg)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
 			switch pqErr.Code.Name() {
@@ -66,19 +88,39 @@ func (server *Server) createUser(ctx *gin.Context) {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	rsp := newUserResponse(user)
+	rsp := newUserResponse(result.User)
 
 	ctx.JSON(201, gin.H{"user": rsp})
 }
 
+var (
+	errFrozenUser         = errors.New("user is frozen")
+	errInvalidCredentials = errors.New("incorrect username or password")
+	errIncorrectPassword  = errors.New("incorrect current password")
+	errEmailTaken         = errors.New("email address is already in use")
+)
+
 type loginUserRequest struct {
-	Username string `json:"username" binding:"required,alphanum"`
-	Password string `json:"password" binding:"required,min=6"`
+	Username string   `json:"username" binding:"required,alphanum"`
+	Password string   `json:"password" binding:"required,min=6"`
+	Scopes   []string `json:"scopes" binding:"omitempty,dive,scope"`
 }
 
 type loginUserResponse struct {
-	AccessToken string       `json:"access_token"`
-	User        userResponse `json:"user"`
+	SessionID             uuid.UUID    `json:"session_id"`
+	AccessToken           string       `json:"access_token"`
+	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  userResponse `json:"user"`
+}
+
+// loginChallengeResponse is returned instead of the tokens to users with two-factor authentication.
+// The challenge token is exchanged for them at /users/login/totp.
+type loginChallengeResponse struct {
+	TOTPRequired            bool      `json:"totp_required"`
+	ChallengeToken          string    `json:"challenge_token"`
+	ChallengeTokenExpiresAt time.Time `json:"challenge_token_expires_at"`
 }
 
 func (server *Server) loginUser(ctx *gin.Context) {
@@ -88,33 +130,403 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		return
 	}
 
+	if !server.checkLoginThrottle(ctx, req.Username) {
+		return
+	}
+
 	user, err := server.store.GetUser(ctx, req.Username)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	userExists := err == nil
+
+	// unknown usernames still pay for a password check,
+	// so neither the response nor its timing tells them apart
+	hashedPassword := user.HashedPassword
+	if !userExists {
+		hashedPassword = server.dummyHashedPassword()
+	}
+
+	err = util.CheckPassword(req.Password, hashedPassword)
+	if err != nil || !userExists {
+		server.rejectLogin(ctx, req.Username, errInvalidCredentials)
+		return
+	}
+
+	if user.IsFrozen {
+		ctx.JSON(http.StatusForbidden, errorResponse(errFrozenUser))
+		return
+	}
+
+	server.rehashPassword(ctx, user, req.Password)
+
+	// clients asking for nothing in particular get full access
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = util.AllScopes()
+	}
+
+	if user.IsTotpEnabled {
+		rsp, err := server.createLoginChallenge(user, scopes)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 			return
 		}
+		ctx.JSON(http.StatusOK, rsp)
+		return
+	}
+
+	// users with two-factor authentication keep their failures until the second factor passes
+	err = server.loginThrottle.reset(ctx, user.Username)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	err = util.CheckPassword(req.Password, user.HashedPassword)
+	rsp, err := server.createLoginSession(ctx, user, scopes)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	accessToken, _, err := server.tokenMaker.CreateToken(
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// hashPassword hashes a password with the argon2id parameters of the config
+func (server *Server) hashPassword(password string) (string, error) {
+	return util.HashPasswordWithParams(password, server.passwordParams)
+}
+
+// rehashPassword upgrades the stored hash of a user who just proved their password
+// if it was made with an older algorithm or weaker parameters.
+// Failing to do so doesn't fail the login, it's retried on the next one.
+func (server *Server) rehashPassword(ctx *gin.Context, user db.User, password string) {
+	if !util.PasswordNeedsRehash(user.HashedPassword, server.passwordParams) {
+		return
+	}
+
+	hashedPassword, err := server.hashPassword(password)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	// only replace the hash that was checked, in case the password changed in the meantime
+	err = server.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+		Username:          user.Username,
+		OldHashedPassword: user.HashedPassword,
+		NewHashedPassword: hashedPassword,
+	})
+	if err != nil {
+		ctx.Error(err)
+	}
+}
+
+// createLoginChallenge issues the challenge token a user with two-factor authentication
+// exchanges for the scopes once the second factor passes
+func (server *Server) createLoginChallenge(user db.User, scopes []string) (loginChallengeResponse, error) {
+	challengeToken, challengePayload, err := server.tokenMaker.CreateToken(
+		token.ChallengeToken,
 		user.Username,
+		user.Role,
+		append([]string{util.TOTPChallengeScope}, scopes...),
+		server.config.TOTPChallengeDuration,
+	)
+	if err != nil {
+		return loginChallengeResponse{}, err
+	}
+
+	rsp := loginChallengeResponse{
+		TOTPRequired:            true,
+		ChallengeToken:          challengeToken,
+		ChallengeTokenExpiresAt: challengePayload.ExpiredAt,
+	}
+	return rsp, nil
+}
+
+// createLoginSession issues the access and refresh tokens of a user who passed every login check
+func (server *Server) createLoginSession(ctx *gin.Context, user db.User, scopes []string) (loginUserResponse, error) {
+	// the refresh token identifies the session, access tokens point to it
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
+		token.RefreshToken,
+		user.Username,
+		user.Role,
+		scopes,
+		server.config.RefreshTokenDuration,
+	)
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateSessionToken(
+		user.Username,
+		user.Role,
+		scopes,
+		refreshPayload.ID,
 		server.config.AccessTokenDuration,
 	)
 	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	rsp := loginUserResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		User:                  newUserResponse(user),
+	}
+	return rsp, nil
+}
+
+type logoutUserRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// logoutUser terminates the session of the request, so neither its refresh token
+// nor any of its access tokens work anymore. The access token of the request and,
+// if given, the refresh token are revoked as well.
+func (server *Server) logoutUser(ctx *gin.Context) {
+	var req logoutUserRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if len(req.RefreshToken) > 0 {
+		refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken)
+		if err != nil && err != token.ErrExpiredToken {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+
+		// an expired refresh token is already useless, nothing to revoke
+		if err == nil {
+			if refreshPayload.Type != token.RefreshToken {
+				ctx.JSON(http.StatusUnauthorized, errorResponse(errNotRefreshToken))
+				return
+			}
+
+			if refreshPayload.Username != authPayload.Username {
+				err := errors.New("refresh token doesn't belong to the authenticated user")
+				ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+				return
+			}
+
+			_, err = server.denylist.revoke(ctx, refreshPayload.ID, refreshPayload.ExpiredAt)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+		}
+	}
+
+	// a session already gone has nothing left to terminate
+	_, err := server.store.BlockSession(ctx, db.BlockSessionParams{
+		ID:       authPayload.Session(),
+		Username: authPayload.Username,
+	})
+	if err != nil && err != sql.ErrNoRows {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	rsp := loginUserResponse{
-		AccessToken: accessToken,
-		User:        newUserResponse(user),
+	_, err = server.denylist.revoke(ctx, authPayload.ID, authPayload.ExpiredAt)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,password"`
+}
+
+// changePassword replaces the password of the authenticated user.
+// Tokens issued before the change stop working, so every other device is logged out;
+// the caller gets a fresh session to stay logged in.
+func (server *Server) changePassword(ctx *gin.Context) {
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server.bindingErrorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	// a stolen access token mustn't become a way to guess the password
+	if !server.checkLoginThrottle(ctx, authPayload.Username) {
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = util.CheckPassword(req.CurrentPassword, user.HashedPassword)
+	if err != nil {
+		server.rejectLogin(ctx, user.Username, errIncorrectPassword)
+		return
+	}
+
+	err = server.passwordPolicy.CheckUserInputs(req.NewPassword, user.Username, user.Email)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, fieldErrorResponse("new_password", err))
+		return
+	}
+
+	hashedPassword, err := server.hashPassword(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user, err = server.store.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+		Username:          user.Username,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.loginThrottle.reset(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp, err := server.createLoginSession(ctx, user, authPayload.Scopes)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
 	}
 	ctx.JSON(http.StatusOK, rsp)
 }
+
+type updateUserURI struct {
+	Username string `uri:"username" binding:"required,alphanum"`
+}
+
+// updateUserRequest holds the fields to change, omitted fields are left as they are
+type updateUserRequest struct {
+	FullName *string `json:"full_name" binding:"omitempty,min=1"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+}
+
+// updateUser changes the profile of a user. Only the user or an admin may do so.
+// A new email address has to be verified again.
+func (server *Server) updateUser(ctx *gin.Context) {
+	var uri updateUserURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req updateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Username != uri.Username && !hasRole(ctx, util.AdminRole) {
+		err := errors.New("cannot update other user's info")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	secretCode, err := util.GenerateSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	arg := db.UpdateUserTxParams{
+		UpdateUserParams: db.UpdateUserParams{
+			Username: uri.Username,
+		},
+		HashedSecretCode:     util.HashSecretCode(secretCode),
+		VerifyEmailExpiredAt: time.Now().Add(server.config.VerifyEmailDuration),
+		AfterEmailChange: func(user db.User, verifyEmail db.VerifyEmail) error {
+			return server.sendVerifyChangedEmail(user, verifyEmail, secretCode)
+		},
+	}
+	if req.FullName != nil {
+		arg.FullName = sql.NullString{String: *req.FullName, Valid: true}
+	}
+	if req.Email != nil {
+		arg.Email = sql.NullString{String: *req.Email, Valid: true}
+	}
+
+	result, err := server.store.UpdateUserTx(ctx, arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			ctx.JSON(http.StatusConflict, errorResponse(errEmailTaken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"user": newUserResponse(result.User)})
+}
+
+type userFrozenRequest struct {
+	Username string `uri:"username" binding:"required,alphanum"`
+}
+
+// setUserFrozen returns a handler that freezes or unfreezes a user.
+// A frozen user can't log in, renew access tokens or use the ones already issued.
+func (server *Server) setUserFrozen(frozen bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req userFrozenRequest
+		if err := ctx.ShouldBindUri(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		user, err := server.store.UpdateUserFrozen(ctx, db.UpdateUserFrozenParams{
+			Username: req.Username,
+			IsFrozen: frozen,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.JSON(http.StatusNotFound, errorResponse(err))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"user": newUserResponse(user)})
+	}
+}
@@ -5,18 +5,27 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"regexp"
 	"testing"
+	"time"
 
 	mockdb "github.com/amrizal94/simplebank/db/mock"
 	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/mail"
+	"github.com/amrizal94/simplebank/token"
 	"github.com/amrizal94/simplebank/util"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type eqCreatedUserParamsMatcher struct {
@@ -25,11 +34,17 @@ type eqCreatedUserParamsMatcher struct {
 }
 
 func (e eqCreatedUserParamsMatcher) Matches(x interface{}) bool {
-	arg, ok := x.(db.CreateUserParams)
+	txArg, ok := x.(db.CreateUserTxParams)
 	if !ok {
 		return false
 	}
 
+	if len(txArg.HashedSecretCode) == 0 || txArg.AfterCreate == nil {
+		return false
+	}
+
+	arg := txArg.CreateUserParams
+
 	err := util.CheckPassword(e.password, arg.HashedPassword)
 	if err != nil {
 		return false
@@ -66,11 +81,14 @@ func TestCreateUserAPI(t *testing.T) {
 	hashedPassword, err := util.HashPassword(password)
 	require.NoError(t, err)
 
+	var hashedSecretCode string
+	var verifyEmailID int64
+
 	testCases := []struct {
 		desc          string
 		body          gin.H
 		buildStubs    func(store *mockdb.MockStore)
-		checkResponse func(recoder *httptest.ResponseRecorder)
+		checkResponse func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender)
 	}{
 		{
 			desc: "OK",
@@ -88,13 +106,38 @@ func TestCreateUserAPI(t *testing.T) {
 					HashedPassword: hashedPassword,
 				}
 				store.EXPECT().
-					CreateUser(gomock.Any(), EqCreateUserParams(arg, password)).
+					CreateUserTx(gomock.Any(), EqCreateUserParams(arg, password)).
 					Times(1).
-					Return(user, nil)
+					DoAndReturn(func(_ interface{}, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+						hashedSecretCode = arg.HashedSecretCode
+						verifyEmail := db.VerifyEmail{
+							ID:               util.RandomInt(1, 1000),
+							Username:         user.Username,
+							Email:            user.Email,
+							HashedSecretCode: arg.HashedSecretCode,
+							ExpiredAt:        arg.VerifyEmailExpiredAt,
+						}
+						verifyEmailID = verifyEmail.ID
+
+						err := arg.AfterCreate(user, verifyEmail)
+						return db.CreateUserTxResult{User: user, VerifyEmail: verifyEmail}, err
+					})
 			},
-			checkResponse: func(recoder *httptest.ResponseRecorder) {
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
 				require.Equal(t, http.StatusCreated, recoder.Code)
 				requireBodyMatchUser(t, recoder.Body, user)
+
+				emails := mailer.Emails()
+				require.Len(t, emails, 1)
+				require.Equal(t, []string{user.Email}, emails[0].To)
+
+				link := regexp.MustCompile(`href="([^"]+)"`).FindStringSubmatch(emails[0].Content)
+				require.Len(t, link, 2)
+
+				verifyURL, err := url.Parse(html.UnescapeString(link[1]))
+				require.NoError(t, err)
+				require.Equal(t, fmt.Sprint(verifyEmailID), verifyURL.Query().Get("id"))
+				require.Equal(t, hashedSecretCode, util.HashSecretCode(verifyURL.Query().Get("code")))
 			},
 		},
 		{
@@ -107,11 +150,11 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, &pq.Error{Code: "23505"})
+					Return(db.CreateUserTxResult{}, &pq.Error{Code: "23505"})
 			},
-			checkResponse: func(recoder *httptest.ResponseRecorder) {
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
 				require.Equal(t, http.StatusForbidden, recoder.Code)
 			},
 		},
@@ -125,11 +168,11 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, &pq.Error{Code: "23505"})
+					Return(db.CreateUserTxResult{}, &pq.Error{Code: "23505"})
 			},
-			checkResponse: func(recoder *httptest.ResponseRecorder) {
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
 				require.Equal(t, http.StatusForbidden, recoder.Code)
 			},
 		},
@@ -143,11 +186,11 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, sql.ErrConnDone)
+					Return(db.CreateUserTxResult{}, sql.ErrConnDone)
 			},
-			checkResponse: func(recoder *httptest.ResponseRecorder) {
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
 				require.Equal(t, http.StatusInternalServerError, recoder.Code)
 			},
 		},
@@ -161,10 +204,10 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
-			checkResponse: func(recoder *httptest.ResponseRecorder) {
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
 				require.Equal(t, http.StatusBadRequest, recoder.Code)
 			},
 		},
@@ -178,10 +221,10 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
-			checkResponse: func(recoder *httptest.ResponseRecorder) {
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
 				require.Equal(t, http.StatusBadRequest, recoder.Code)
 			},
 		},
@@ -195,10 +238,10 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
-			checkResponse: func(recoder *httptest.ResponseRecorder) {
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
 				require.Equal(t, http.StatusBadRequest, recoder.Code)
 			},
 		},
@@ -223,7 +266,7 @@ func TestCreateUserAPI(t *testing.T) {
 			require.NoError(t, err)
 
 			server.router.ServeHTTP(recoder, request)
-			tC.checkResponse(recoder)
+			tC.checkResponse(recoder, server.mailer.(*mail.MemorySender))
 		})
 	}
 }
@@ -235,12 +278,75 @@ func TestLoginUser(t *testing.T) {
 
 	user.HashedPassword = hashedPassword
 
+	bcryptHashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	bcryptUser := user
+	bcryptUser.HashedPassword = string(bcryptHashedPassword)
+
 	testCases := []struct {
 		desc          string
 		body          gin.H
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(recoder *httptest.ResponseRecorder)
 	}{
+		{
+			desc: "RehashBcryptPassword",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(bcryptUser, nil)
+				store.EXPECT().
+					RehashUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.RehashUserPasswordParams) error {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, bcryptUser.HashedPassword, arg.OldHashedPassword)
+						require.False(t, util.PasswordNeedsRehash(arg.NewHashedPassword, util.DefaultPasswordParams))
+						require.NoError(t, util.CheckPassword(password, arg.NewHashedPassword))
+						return nil
+					})
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc: "RehashError",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(bcryptUser, nil)
+				store.EXPECT().
+					RehashUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
 		{
 			desc: "OK",
 			body: gin.H{
@@ -252,6 +358,12 @@ func TestLoginUser(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
 			},
 			checkResponse: func(recoder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recoder.Code)
@@ -268,9 +380,11 @@ func TestLoginUser(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(db.User{}, sql.ErrNoRows)
+				buildLoginFailureStubs(store, 1)
 			},
 			checkResponse: func(recoder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recoder.Code)
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, errInvalidCredentials)
 			},
 		},
 		{
@@ -289,6 +403,198 @@ func TestLoginUser(t *testing.T) {
 				require.Equal(t, http.StatusInternalServerError, recoder.Code)
 			},
 		},
+		{
+			desc: "ReadOnlyScopes",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+				"scopes":   []string{util.AccountsReadScope, util.TransfersReadScope},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc: "InvalidScope",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+				"scopes":   []string{"accounts:delete"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "FrozenUser",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				frozenUser := user
+				frozenUser.IsFrozen = true
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(frozenUser, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc: "TOTPRequired",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				totpUser := user
+				totpUser.IsTotpEnabled = true
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(totpUser, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp loginChallengeResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.True(t, rsp.TOTPRequired)
+				require.NotEmpty(t, rsp.ChallengeToken)
+				require.NotContains(t, recoder.Body.String(), "access_token")
+			},
+		},
+		{
+			desc: "CreateSessionError",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			desc: "LockThresholdReached",
+			body: gin.H{
+				"username": user.Username,
+				"password": "wrong password",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ interface{}, arg db.RecordLoginFailureParams) (db.LoginFailure, error) {
+						return db.LoginFailure{Key: arg.Key, FailedCount: 5}, nil
+					})
+				store.EXPECT().
+					LockLoginKey(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ interface{}, arg db.LockLoginKeyParams) error {
+						// the username reached its threshold, the IP only backs off
+						lockDuration := time.Second * 16
+						if arg.Key == loginUsernameKey(user.Username) {
+							lockDuration = time.Minute
+						}
+						require.WithinDuration(t, time.Now().Add(lockDuration), arg.LockedUntil.Time, time.Second)
+						return nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "Locked",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListLoginLocks(gomock.Any(), gomock.Eq([]string{loginUsernameKey(user.Username), loginIPKey("")})).
+					Times(1).
+					Return([]db.LoginFailure{
+						{
+							Key:         loginUsernameKey(user.Username),
+							FailedCount: 5,
+							LockedUntil: sql.NullTime{Time: time.Now().Add(30 * time.Second), Valid: true},
+						},
+					}, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recoder.Code)
+				require.Equal(t, "30", recoder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			desc: "LockCheckError",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListLoginLocks(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil, sql.ErrConnDone)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
 		{
 			desc: "IncorrectPassword",
 			body: gin.H{
@@ -300,9 +606,14 @@ func TestLoginUser(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+				buildLoginFailureStubs(store, 1)
 			},
 			checkResponse: func(recoder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, errInvalidCredentials)
 			},
 		},
 		{
@@ -328,6 +639,7 @@ func TestLoginUser(t *testing.T) {
 
 			store := mockdb.NewMockStore(ctrl)
 			tC.buildStubs(store)
+			buildLoginThrottleStubs(store)
 
 			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
@@ -346,11 +658,876 @@ func TestLoginUser(t *testing.T) {
 	}
 }
 
+func TestLogoutUserAPI(t *testing.T) {
+	user, _ := randomUser()
+	otherUser, _ := randomUser()
+
+	testCases := []struct {
+		desc          string
+		buildBody     func(t *testing.T, tokenMaker token.Maker) gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			buildBody: func(t *testing.T, tokenMaker token.Maker) gin.H {
+				return nil
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recoder.Code)
+			},
+		},
+		{
+			desc: "WithRefreshToken",
+			buildBody: func(t *testing.T, tokenMaker token.Maker) gin.H {
+				refreshToken, _, err := tokenMaker.CreateToken(token.RefreshToken, user.Username, user.Role, util.AllScopes(), time.Hour)
+				require.NoError(t, err)
+				return gin.H{"refresh_token": refreshToken}
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(2)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recoder.Code)
+			},
+		},
+		{
+			desc: "RefreshTokenOfOtherUser",
+			buildBody: func(t *testing.T, tokenMaker token.Maker) gin.H {
+				refreshToken, _, err := tokenMaker.CreateToken(token.RefreshToken, otherUser.Username, otherUser.Role, util.AllScopes(), time.Hour)
+				require.NoError(t, err)
+				return gin.H{"refresh_token": refreshToken}
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "BlockSessionError",
+			buildBody: func(t *testing.T, tokenMaker token.Maker) gin.H {
+				return nil
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			desc: "NoAuthorization",
+			buildBody: func(t *testing.T, tokenMaker token.Maker) gin.H {
+				return nil
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "InternalError",
+			buildBody: func(t *testing.T, tokenMaker token.Maker) gin.H {
+				return nil
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateRevokedToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RevokedToken{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			var body io.Reader
+			if data := tC.buildBody(t, server.tokenMaker); data != nil {
+				raw, err := json.Marshal(data)
+				require.NoError(t, err)
+				body = bytes.NewReader(raw)
+			}
+
+			url := "/users/logout"
+			request, err := http.NewRequest(http.MethodPost, url, body)
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+// TestLogoutUserTerminatesSession logs out without the refresh token in the body
+// and checks neither it nor another access token of the session work anymore
+func TestLogoutUserTerminatesSession(t *testing.T) {
+	user, _ := randomUser()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
+		token.RefreshToken, user.Username, user.Role, util.AllScopes(), time.Hour,
+	)
+	require.NoError(t, err)
+	session := randomSession(user.Username, refreshToken, refreshPayload)
+
+	accessToken, _, err := server.tokenMaker.CreateSessionToken(user.Username, user.Role, util.AllScopes(), session.ID, time.Minute)
+	require.NoError(t, err)
+	otherAccessToken, _, err := server.tokenMaker.CreateSessionToken(user.Username, user.Role, util.AllScopes(), session.ID, time.Minute)
+	require.NoError(t, err)
+
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Eq(session.ID)).
+		AnyTimes().
+		DoAndReturn(func(_ interface{}, _ uuid.UUID) (db.Session, error) {
+			return session, nil
+		})
+	store.EXPECT().
+		BlockSession(gomock.Any(), gomock.Eq(db.BlockSessionParams{ID: session.ID, Username: user.Username})).
+		Times(1).
+		DoAndReturn(func(_ interface{}, _ db.BlockSessionParams) (db.Session, error) {
+			session.IsBlocked = true
+			return session, nil
+		})
+	store.EXPECT().
+		CreateRevokedToken(gomock.Any(), gomock.Any()).
+		Times(1)
+	buildAuthStubs(store)
+
+	request, err := http.NewRequest(http.MethodPost, "/users/logout", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+
+	data, err := json.Marshal(gin.H{"refresh_token": refreshToken})
+	require.NoError(t, err)
+	request, err = http.NewRequest(http.MethodPost, "/tokens/renew_access", bytes.NewReader(data))
+	require.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	request, err = http.NewRequest(http.MethodGet, "/users/me/sessions", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, otherAccessToken))
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestChangePasswordAPI(t *testing.T) {
+	user, password := randomUser()
+	hashedPassword, err := util.HashPassword(password)
+	require.NoError(t, err)
+	user.HashedPassword = hashedPassword
+
+	newPassword := util.RandomPassword()
+
+	testCases := []struct {
+		desc          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc: "OK",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.UpdateUserPasswordParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.NoError(t, util.CheckPassword(newPassword, arg.HashedPassword))
+						require.WithinDuration(t, time.Now(), arg.PasswordChangedAt, time.Second)
+
+						updated := user
+						updated.HashedPassword = arg.HashedPassword
+						updated.PasswordChangedAt = arg.PasswordChangedAt
+						return updated, nil
+					})
+				store.EXPECT().
+					DeleteLoginFailures(gomock.Any(), gomock.Eq(loginUsernameKey(user.Username))).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp loginUserResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.NotEmpty(t, rsp.AccessToken)
+				require.NotEmpty(t, rsp.RefreshToken)
+			},
+		},
+		{
+			desc: "MissingUsersWriteScope",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addScopedAuthorization(
+					t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role,
+					[]string{util.AccountsReadScope, util.TransfersReadScope}, time.Minute,
+				)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc: "IncorrectCurrentPassword",
+			body: gin.H{
+				"current_password": "incorrect",
+				"new_password":     newPassword,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+				buildLoginFailureStubs(store, 1)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, errIncorrectPassword)
+			},
+		},
+		{
+			desc: "NewPasswordContainsUsername",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     newPassword + user.Username,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+				requireBodyMatchFieldError(t, recoder.Body, "new_password", util.ErrPasswordPersonal)
+			},
+		},
+		{
+			desc: "NewPasswordBreached",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     testBreachedPassword,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+				requireBodyMatchFieldError(t, recoder.Body, "new_password", util.ErrPasswordBreached)
+			},
+		},
+		{
+			desc: "Locked",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListLoginLocks(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.LoginFailure{
+						{
+							Key:         loginUsernameKey(user.Username),
+							FailedCount: 5,
+							LockedUntil: sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+						},
+					}, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recoder.Code)
+			},
+		},
+		{
+			desc: "PasswordTooShort",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     "short",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "NoAuthorization",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "UpdateError",
+			body: gin.H{
+				"current_password": password,
+				"new_password":     newPassword,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+			buildLoginThrottleStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tC.body)
+			require.NoError(t, err)
+
+			url := "/users/password"
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUpdateUserAPI(t *testing.T) {
+	user, _ := randomUser()
+	user.IsEmailVerified = true
+
+	admin, _ := randomUser()
+	admin.Role = util.AdminRole
+
+	newFullName := util.RandomOwner()
+	newEmail := util.RandomEmail()
+
+	testCases := []struct {
+		desc          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender)
+	}{
+		{
+			desc: "FullNameOnly",
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.FullName = newFullName
+
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						require.Equal(t, db.UpdateUserParams{
+							Username: user.Username,
+							FullName: sql.NullString{String: newFullName, Valid: true},
+						}, arg.UpdateUserParams)
+						return db.UpdateUserTxResult{User: updated}, nil
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				updated := user
+				updated.FullName = newFullName
+				requireBodyMatchUser(t, recoder.Body, updated)
+				require.Empty(t, mailer.Emails())
+			},
+		},
+		{
+			desc: "EmailChanged",
+			body: gin.H{
+				"email": newEmail,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Email = newEmail
+				updated.IsEmailVerified = false
+
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ interface{}, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						require.Equal(t, db.UpdateUserParams{
+							Username: user.Username,
+							Email:    sql.NullString{String: newEmail, Valid: true},
+						}, arg.UpdateUserParams)
+
+						verifyEmail := db.VerifyEmail{
+							ID:               util.RandomInt(1, 1000),
+							Username:         updated.Username,
+							Email:            updated.Email,
+							HashedSecretCode: arg.HashedSecretCode,
+							ExpiredAt:        arg.VerifyEmailExpiredAt,
+						}
+						err := arg.AfterEmailChange(updated, verifyEmail)
+						return db.UpdateUserTxResult{User: updated, VerifyEmail: verifyEmail}, err
+					})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				updated := user
+				updated.Email = newEmail
+				updated.IsEmailVerified = false
+				requireBodyMatchUser(t, recoder.Body, updated)
+
+				emails := mailer.Emails()
+				require.Len(t, emails, 1)
+				require.Equal(t, []string{newEmail}, emails[0].To)
+			},
+		},
+		{
+			desc: "AdminUpdatesOtherUser",
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{User: user}, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc: "OtherUser",
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "other", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc: "NoAuthorization",
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusUnauthorized, recoder.Code)
+			},
+		},
+		{
+			desc: "DuplicateEmail",
+			body: gin.H{
+				"email": newEmail,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{}, &pq.Error{Code: "23505"})
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusConflict, recoder.Code)
+				requireBodyMatchError(t, recoder.Body, errEmailTaken)
+			},
+		},
+		{
+			desc: "UserNotFound",
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+		{
+			desc: "InvalidEmail",
+			body: gin.H{
+				"email": "invalid-email",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "EmptyFullName",
+			body: gin.H{
+				"full_name": "",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc: "InternalError",
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder, mailer *mail.MemorySender) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tC.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s", user.Username)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder, server.mailer.(*mail.MemorySender))
+		})
+	}
+}
+
+func TestSetUserFrozenAPI(t *testing.T) {
+	admin, _ := randomUser()
+	admin.Role = util.AdminRole
+
+	user, _ := randomUser()
+
+	testCases := []struct {
+		desc          string
+		action        string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc:   "Freeze",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				frozenUser := user
+				frozenUser.IsFrozen = true
+
+				store.EXPECT().
+					UpdateUserFrozen(gomock.Any(), gomock.Eq(db.UpdateUserFrozenParams{
+						Username: user.Username,
+						IsFrozen: true,
+					})).
+					Times(1).
+					Return(frozenUser, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc:   "Unfreeze",
+			action: "unfreeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserFrozen(gomock.Any(), gomock.Eq(db.UpdateUserFrozenParams{
+						Username: user.Username,
+						IsFrozen: false,
+					})).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+			},
+		},
+		{
+			desc:   "DepositorForbidden",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserFrozen(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recoder.Code)
+			},
+		},
+		{
+			desc:   "UserNotFound",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserFrozen(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+			buildAuthStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/admin/users/%s/%s", user.Username, tC.action)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			tC.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
+
 func randomUser() (user db.User, password string) {
-	password = util.RandomString(6)
+	password = util.RandomPassword()
 
 	user = db.User{
 		Username: util.RandomString(5),
+		Role:     util.DepositorRole,
 		Email:    util.RandomEmail(),
 		FullName: util.RandomOwner(),
 	}
@@ -1,7 +1,14 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
 	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -12,3 +19,107 @@ var validCurrency validator.Func = func(fieldLevel validator.FieldLevel) bool {
 
 	return false
 }
+
+var validTier validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if tier, ok := fieldLevel.Field().Interface().(string); ok {
+		return util.IsSupportedTier(tier)
+	}
+
+	return false
+}
+
+var validScope validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if scope, ok := fieldLevel.Field().Interface().(string); ok {
+		return util.IsSupportedScope(scope)
+	}
+
+	return false
+}
+
+// validatedPasswordPolicy is the policy checked by validPassword. Validators are registered once per process
+// and cached with the requests they validate, so NewServer sets the policy here instead.
+var validatedPasswordPolicy atomic.Pointer[util.PasswordPolicy]
+
+// validPassword checks passwords against the password policy.
+// The username and email of the request, if it has them, must not appear in the password.
+var validPassword validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	password, ok := fieldLevel.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+
+	policy := validatedPasswordPolicy.Load()
+	if policy == nil {
+		return false
+	}
+	return policy.Check(password, requestUserInputs(fieldLevel.Parent())...) == nil
+}
+
+func requestUserInputs(request reflect.Value) []string {
+	if request.Kind() == reflect.Pointer {
+		request = request.Elem()
+	}
+	if request.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var inputs []string
+	for _, name := range []string{"Username", "Email"} {
+		field := request.FieldByName(name)
+		if field.IsValid() && field.Kind() == reflect.String {
+			inputs = append(inputs, field.String())
+		}
+	}
+	return inputs
+}
+
+// jsonFieldName names fields in validation errors as clients know them
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// bindingErrorResponse is errorResponse with a message for each invalid field of the request.
+// Password policy failures get their reason spelled out.
+func (server *Server) bindingErrorResponse(err error) gin.H {
+	rsp := errorResponse(err)
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return rsp
+	}
+
+	fields := gin.H{}
+	for _, fieldErr := range validationErrs {
+		fields[fieldErr.Field()] = server.fieldErrorMessage(fieldErr)
+	}
+	rsp["fields"] = fields
+	return rsp
+}
+
+func (server *Server) fieldErrorMessage(fieldErr validator.FieldError) string {
+	if fieldErr.Tag() == "password" {
+		password, _ := fieldErr.Value().(string)
+		if err := server.passwordPolicy.Check(password); err != nil {
+			return err.Error()
+		}
+		// the password only broke the rule needing the rest of the request
+		return util.ErrPasswordPersonal.Error()
+	}
+
+	if fieldErr.Param() != "" {
+		return fmt.Sprintf("failed on the '%s=%s' rule", fieldErr.Tag(), fieldErr.Param())
+	}
+	return fmt.Sprintf("failed on the '%s' rule", fieldErr.Tag())
+}
+
+// fieldErrorResponse reports an invalid field found after binding the request
+func fieldErrorResponse(field string, err error) gin.H {
+	return gin.H{
+		"error":  err.Error(),
+		"fields": gin.H{field: err.Error()},
+	}
+}
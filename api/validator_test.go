@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordValidator(t *testing.T) {
+	user, _ := randomUser()
+
+	testCases := []struct {
+		desc     string
+		password string
+		err      error
+	}{
+		{
+			desc:     "TooShort",
+			password: "x7#Kq",
+			err:      util.ErrPasswordTooShort,
+		},
+		{
+			desc:     "TooWeak",
+			password: "Password123",
+			err:      util.ErrPasswordTooWeak,
+		},
+		{
+			desc:     "ContainsUsername",
+			password: user.Username + util.RandomPassword(),
+			err:      util.ErrPasswordPersonal,
+		},
+		{
+			desc:     "ContainsEmail",
+			password: util.RandomPassword() + user.Email,
+			err:      util.ErrPasswordPersonal,
+		},
+		{
+			desc:     "Breached",
+			password: testBreachedPassword,
+			err:      util.ErrPasswordBreached,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				CreateUserTx(gomock.Any(), gomock.Any()).
+				Times(0)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"username":  user.Username,
+				"password":  tC.password,
+				"full_name": user.FullName,
+				"email":     user.Email,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusBadRequest, recorder.Code)
+			requireBodyMatchFieldError(t, recorder.Body, "password", tC.err)
+		})
+	}
+}
+
+// requireBodyMatchFieldError checks the message of the field explains the error
+func requireBodyMatchFieldError(t *testing.T, body *bytes.Buffer, field string, err error) {
+	var rsp struct {
+		Fields map[string]string `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(body.Bytes(), &rsp))
+	require.Contains(t, rsp.Fields[field], err.Error())
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errEmailNotVerified   = errors.New("email address is not verified")
+	errInvalidVerifyEmail = errors.New("invalid or expired verification link")
+)
+
+// sendVerifyEmail emails the user the link to verify their email address
+func (server *Server) sendVerifyEmail(user db.User, verifyEmail db.VerifyEmail, secretCode string) error {
+	subject := "Welcome to Simple Bank"
+	content := fmt.Sprintf(`Hello %s,<br/>
+Thank you for registering with us!<br/>
+Please <a href="%s">click here</a> to verify your email address.<br/>
+`, html.EscapeString(user.FullName), html.EscapeString(server.verifyEmailURL(verifyEmail, secretCode)))
+
+	return server.mailer.SendEmail(subject, content, []string{verifyEmail.Email})
+}
+
+// sendVerifyChangedEmail emails the link to verify the new address of a user who changed their email
+func (server *Server) sendVerifyChangedEmail(user db.User, verifyEmail db.VerifyEmail, secretCode string) error {
+	subject := "Verify your new email address"
+	content := fmt.Sprintf(`Hello %s,<br/>
+The email address of your Simple Bank account was changed to this one.<br/>
+Please <a href="%s">click here</a> to verify it.<br/>
+`, html.EscapeString(user.FullName), html.EscapeString(server.verifyEmailURL(verifyEmail, secretCode)))
+
+	return server.mailer.SendEmail(subject, content, []string{verifyEmail.Email})
+}
+
+func (server *Server) verifyEmailURL(verifyEmail db.VerifyEmail, secretCode string) string {
+	query := url.Values{}
+	query.Set("id", fmt.Sprint(verifyEmail.ID))
+	query.Set("code", secretCode)
+	return server.config.VerifyEmailURL + "?" + query.Encode()
+}
+
+type verifyEmailRequest struct {
+	ID   int64  `form:"id" binding:"required,min=1"`
+	Code string `form:"code" binding:"required"`
+}
+
+type verifyEmailResponse struct {
+	IsVerified bool `json:"is_verified"`
+}
+
+// verifyEmail handles the link sent by sendVerifyEmail
+func (server *Server) verifyEmail(ctx *gin.Context) {
+	var req verifyEmailRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	result, err := server.store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{
+		ID:               req.ID,
+		HashedSecretCode: util.HashSecretCode(req.Code),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errInvalidVerifyEmail))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verifyEmailResponse{IsVerified: result.User.IsEmailVerified})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyEmailAPI(t *testing.T) {
+	user, _ := randomUser()
+	secretCode, err := util.GenerateSecretCode()
+	require.NoError(t, err)
+
+	verifyEmailID := util.RandomInt(1, 1000)
+
+	testCases := []struct {
+		desc          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			desc:  "OK",
+			query: fmt.Sprintf("id=%d&code=%s", verifyEmailID, secretCode),
+			buildStubs: func(store *mockdb.MockStore) {
+				verifiedUser := user
+				verifiedUser.IsEmailVerified = true
+
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Eq(db.VerifyEmailTxParams{
+						ID:               verifyEmailID,
+						HashedSecretCode: util.HashSecretCode(secretCode),
+					})).
+					Times(1).
+					Return(db.VerifyEmailTxResult{User: verifiedUser}, nil)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recoder.Code)
+
+				var rsp verifyEmailResponse
+				err := json.Unmarshal(recoder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.True(t, rsp.IsVerified)
+			},
+		},
+		{
+			desc:  "InvalidOrExpiredCode",
+			query: fmt.Sprintf("id=%d&code=%s", verifyEmailID, secretCode),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc:  "InternalError",
+			query: fmt.Sprintf("id=%d&code=%s", verifyEmailID, secretCode),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recoder.Code)
+			},
+		},
+		{
+			desc:  "MissingCode",
+			query: fmt.Sprintf("id=%d", verifyEmailID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+		{
+			desc:  "InvalidID",
+			query: fmt.Sprintf("id=0&code=%s", secretCode),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recoder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recoder.Code)
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tC.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/verify_email?"+tC.query, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tC.checkResponse(recorder)
+		})
+	}
+}
@@ -0,0 +1,5 @@
+ALTER TABLE "users" ADD COLUMN "role" varchar NOT NULL DEFAULT 'depositor';
+
+ALTER TABLE "users" ADD COLUMN "is_frozen" boolean NOT NULL DEFAULT false;
+
+ALTER TABLE "accounts" ADD COLUMN "is_frozen" boolean NOT NULL DEFAULT false;
@@ -0,0 +1,15 @@
+CREATE TABLE "api_keys" (
+  "id" uuid PRIMARY KEY,
+  "prefix" varchar UNIQUE NOT NULL,
+  "hashed_key" varchar NOT NULL,
+  "owner" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "last_used_at" timestamptz,
+  "expires_at" timestamptz,
+  "revoked_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "api_keys" ("owner");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
@@ -0,0 +1,7 @@
+ALTER TABLE IF EXISTS "users" DROP COLUMN IF EXISTS "hashed_recovery_codes";
+
+ALTER TABLE IF EXISTS "users" DROP COLUMN IF EXISTS "totp_last_step";
+
+ALTER TABLE IF EXISTS "users" DROP COLUMN IF EXISTS "is_totp_enabled";
+
+ALTER TABLE IF EXISTS "users" DROP COLUMN IF EXISTS "totp_secret";
\ No newline at end of file
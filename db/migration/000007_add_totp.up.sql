@@ -0,0 +1,7 @@
+ALTER TABLE "users" ADD COLUMN "totp_secret" varchar;
+
+ALTER TABLE "users" ADD COLUMN "is_totp_enabled" boolean NOT NULL DEFAULT false;
+
+ALTER TABLE "users" ADD COLUMN "totp_last_step" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "users" ADD COLUMN "hashed_recovery_codes" varchar[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+CREATE TABLE "login_failures" (
+  "key" varchar PRIMARY KEY,
+  "failed_count" integer NOT NULL,
+  "locked_until" timestamptz,
+  "last_failed_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "login_failures" ("last_failed_at");
@@ -0,0 +1,12 @@
+CREATE TABLE "password_resets" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "hashed_token" varchar UNIQUE NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL
+);
+
+CREATE INDEX ON "password_resets" ("username");
+
+ALTER TABLE "password_resets" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
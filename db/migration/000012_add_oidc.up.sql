@@ -0,0 +1,19 @@
+CREATE TABLE "user_identities" (
+  "issuer" varchar NOT NULL,
+  "subject" varchar NOT NULL,
+  "username" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("issuer", "subject")
+);
+
+CREATE INDEX ON "user_identities" ("username");
+
+ALTER TABLE "user_identities" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE TABLE "oidc_auth_requests" (
+  "hashed_state" varchar PRIMARY KEY,
+  "nonce" varchar NOT NULL,
+  "code_verifier" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL
+);
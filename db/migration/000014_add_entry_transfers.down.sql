@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "entries_accounts_id_created_at_idx";
+
+ALTER TABLE IF EXISTS "entries" DROP COLUMN IF EXISTS "transfer_id";
@@ -0,0 +1,13 @@
+ALTER TABLE "entries" ADD COLUMN "transfer_id" bigint;
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+-- both entries of a transfer were made in its transaction, so they share its created_at
+UPDATE "entries" e SET "transfer_id" = t."id"
+FROM "transfers" t
+WHERE e."created_at" = t."created_at" AND (
+  (e."accounts_id" = t."from_account_id" AND e."amount" = -t."amount") OR
+  (e."accounts_id" = t."to_account_id" AND e."amount" = t."amount")
+);
+
+CREATE INDEX ON "entries" ("accounts_id", "created_at");
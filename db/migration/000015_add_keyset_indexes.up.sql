@@ -0,0 +1,9 @@
+CREATE INDEX ON "accounts" ("owner", "created_at", "id");
+
+DROP INDEX IF EXISTS "entries_accounts_id_created_at_idx";
+
+CREATE INDEX ON "entries" ("accounts_id", "created_at", "id");
+
+CREATE INDEX ON "transfers" ("from_account_id", "created_at", "id");
+
+CREATE INDEX ON "transfers" ("to_account_id", "created_at", "id");
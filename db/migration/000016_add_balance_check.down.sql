@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_nonnegative";
@@ -0,0 +1,33 @@
+CREATE TABLE "fx_rates" (
+  "id" bigserial PRIMARY KEY,
+  "from_currency" varchar NOT NULL,
+  "to_currency" varchar NOT NULL,
+  "rate" numeric(24,12) NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "fx_rates_rate_positive" CHECK ("rate" > 0)
+);
+
+CREATE INDEX ON "fx_rates" ("from_currency", "to_currency", "created_at");
+
+CREATE TABLE "fx_quotes" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "from_currency" varchar NOT NULL,
+  "to_currency" varchar NOT NULL,
+  "rate" numeric(24,12) NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL
+);
+
+ALTER TABLE "fx_quotes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "transfers" ADD COLUMN "to_amount" bigint;
+
+ALTER TABLE "transfers" ADD COLUMN "fx_rate" numeric(24,12);
+
+ALTER TABLE "transfers" ADD COLUMN "fx_quote_id" uuid;
+
+ALTER TABLE "transfers" ADD FOREIGN KEY ("fx_quote_id") REFERENCES "fx_quotes" ("id");
+
+COMMENT ON COLUMN "transfers"."to_amount" IS 'credited in the currency of the to account, when it differs';
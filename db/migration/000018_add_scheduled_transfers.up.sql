@@ -0,0 +1,48 @@
+CREATE TABLE "scheduled_transfers" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "schedule" varchar NOT NULL DEFAULT '',
+  "start_at" timestamptz NOT NULL,
+  "end_at" timestamptz,
+  "next_run_at" timestamptz NOT NULL,
+  "retry_at" timestamptz,
+  "failed_attempts" integer NOT NULL DEFAULT 0,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "scheduled_transfers_amount_positive" CHECK ("amount" > 0)
+);
+
+CREATE TABLE "scheduled_transfer_runs" (
+  "id" bigserial PRIMARY KEY,
+  "scheduled_transfer_id" bigint NOT NULL,
+  "due_at" timestamptz NOT NULL,
+  "attempt" integer NOT NULL,
+  "status" varchar NOT NULL,
+  "transfer_id" bigint,
+  "error" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfer_runs" ADD FOREIGN KEY ("scheduled_transfer_id") REFERENCES "scheduled_transfers" ("id");
+
+ALTER TABLE "scheduled_transfer_runs" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+CREATE INDEX ON "scheduled_transfers" ("owner", "created_at", "id");
+
+CREATE INDEX ON "scheduled_transfers" ("next_run_at") WHERE "status" = 'active';
+
+CREATE INDEX ON "scheduled_transfer_runs" ("scheduled_transfer_id", "created_at", "id");
+
+COMMENT ON COLUMN "scheduled_transfers"."schedule" IS 'cron expression or RRULE, empty for a one-off transfer';
+
+COMMENT ON COLUMN "scheduled_transfers"."retry_at" IS 'set while the run due at next_run_at waits to be retried';
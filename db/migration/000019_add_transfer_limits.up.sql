@@ -0,0 +1,25 @@
+ALTER TABLE "users" ADD COLUMN "tier" varchar NOT NULL DEFAULT 'standard';
+
+CREATE TABLE "tier_transfer_limits" (
+  "tier" varchar NOT NULL,
+  "currency" varchar NOT NULL,
+  "max_per_transaction" bigint,
+  "max_daily_amount" bigint,
+  "max_daily_count" integer,
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("tier", "currency")
+);
+
+CREATE TABLE "account_transfer_limits" (
+  "account_id" bigint PRIMARY KEY,
+  "max_per_transaction" bigint,
+  "max_daily_amount" bigint,
+  "max_daily_count" integer,
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "account_transfer_limits" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+COMMENT ON TABLE "tier_transfer_limits" IS 'limits of the outgoing transfers of the accounts of users of a tier, a missing limit is unlimited';
+
+COMMENT ON TABLE "account_transfer_limits" IS 'limits of the outgoing transfers of an account, a missing limit falls back to the tier of its owner';
@@ -0,0 +1,9 @@
+CREATE TABLE "rate_limits" (
+  "key" varchar PRIMARY KEY,
+  "hits" integer NOT NULL,
+  "window_started_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "rate_limits" ("window_started_at");
+
+COMMENT ON TABLE "rate_limits" IS 'requests counted per key, such as an email or a client IP, in a fixed window';
@@ -7,9 +7,11 @@ package mockdb
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	db "github.com/amrizal94/simplebank/db/sqlc"
 	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
 )
 
 // MockStore is a mock of Store interface.
@@ -35,6 +37,21 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// AccountStatementTx mocks base method.
+func (m *MockStore) AccountStatementTx(arg0 context.Context, arg1 db.AccountStatementTxParams) (db.AccountStatementTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccountStatementTx", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountStatementTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccountStatementTx indicates an expected call of AccountStatementTx.
+func (mr *MockStoreMockRecorder) AccountStatementTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccountStatementTx", reflect.TypeOf((*MockStore)(nil).AccountStatementTx), arg0, arg1)
+}
+
 // AddAccountBalance mocks base method.
 func (m *MockStore) AddAccountBalance(arg0 context.Context, arg1 db.AddAccountBalanceParams) (db.Account, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +67,95 @@ func (mr *MockStoreMockRecorder) AddAccountBalance(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountBalance", reflect.TypeOf((*MockStore)(nil).AddAccountBalance), arg0, arg1)
 }
 
+// AdvanceScheduledTransfer mocks base method.
+func (m *MockStore) AdvanceScheduledTransfer(arg0 context.Context, arg1 db.AdvanceScheduledTransferParams) (db.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdvanceScheduledTransfer", arg0, arg1)
+	ret0, _ := ret[0].(db.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdvanceScheduledTransfer indicates an expected call of AdvanceScheduledTransfer.
+func (mr *MockStoreMockRecorder) AdvanceScheduledTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdvanceScheduledTransfer", reflect.TypeOf((*MockStore)(nil).AdvanceScheduledTransfer), arg0, arg1)
+}
+
+// BlockSession mocks base method.
+func (m *MockStore) BlockSession(arg0 context.Context, arg1 db.BlockSessionParams) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSession", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockSession indicates an expected call of BlockSession.
+func (mr *MockStoreMockRecorder) BlockSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSession", reflect.TypeOf((*MockStore)(nil).BlockSession), arg0, arg1)
+}
+
+// BlockUserSessions mocks base method.
+func (m *MockStore) BlockUserSessions(arg0 context.Context, arg1 db.BlockUserSessionsParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockUserSessions", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockUserSessions indicates an expected call of BlockUserSessions.
+func (mr *MockStoreMockRecorder) BlockUserSessions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockUserSessions", reflect.TypeOf((*MockStore)(nil).BlockUserSessions), arg0, arg1)
+}
+
+// ClaimDueScheduledTransfer mocks base method.
+func (m *MockStore) ClaimDueScheduledTransfer(arg0 context.Context) (db.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueScheduledTransfer", arg0)
+	ret0, _ := ret[0].(db.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueScheduledTransfer indicates an expected call of ClaimDueScheduledTransfer.
+func (mr *MockStoreMockRecorder) ClaimDueScheduledTransfer(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueScheduledTransfer", reflect.TypeOf((*MockStore)(nil).ClaimDueScheduledTransfer), arg0)
+}
+
+// ClaimIdempotencyKey mocks base method.
+func (m *MockStore) ClaimIdempotencyKey(arg0 context.Context, arg1 db.ClaimIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(db.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimIdempotencyKey indicates an expected call of ClaimIdempotencyKey.
+func (mr *MockStoreMockRecorder) ClaimIdempotencyKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimIdempotencyKey", reflect.TypeOf((*MockStore)(nil).ClaimIdempotencyKey), arg0, arg1)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockStore) CreateAPIKey(arg0 context.Context, arg1 db.CreateAPIKeyParams) (db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockStoreMockRecorder) CreateAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockStore)(nil).CreateAPIKey), arg0, arg1)
+}
+
 // CreateAccount mocks base method.
 func (m *MockStore) CreateAccount(arg0 context.Context, arg1 db.CreateAccountParams) (db.Account, error) {
 	m.ctrl.T.Helper()
@@ -80,196 +186,1340 @@ func (mr *MockStoreMockRecorder) CreateEntry(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntry", reflect.TypeOf((*MockStore)(nil).CreateEntry), arg0, arg1)
 }
 
-// CreateTransfer mocks base method.
-func (m *MockStore) CreateTransfer(arg0 context.Context, arg1 db.CreateTransferParams) (db.Transfer, error) {
+// CreateFXQuote mocks base method.
+func (m *MockStore) CreateFXQuote(arg0 context.Context, arg1 db.CreateFXQuoteParams) (db.FxQuote, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTransfer", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateFXQuote", arg0, arg1)
+	ret0, _ := ret[0].(db.FxQuote)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateFXQuote indicates an expected call of CreateFXQuote.
+func (mr *MockStoreMockRecorder) CreateFXQuote(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFXQuote", reflect.TypeOf((*MockStore)(nil).CreateFXQuote), arg0, arg1)
+}
+
+// CreateFXRate mocks base method.
+func (m *MockStore) CreateFXRate(arg0 context.Context, arg1 db.CreateFXRateParams) (db.FxRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateFXRate", arg0, arg1)
+	ret0, _ := ret[0].(db.FxRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateFXRate indicates an expected call of CreateFXRate.
+func (mr *MockStoreMockRecorder) CreateFXRate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFXRate", reflect.TypeOf((*MockStore)(nil).CreateFXRate), arg0, arg1)
+}
+
+// CreateFXTransfer mocks base method.
+func (m *MockStore) CreateFXTransfer(arg0 context.Context, arg1 db.CreateFXTransferParams) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateFXTransfer", arg0, arg1)
 	ret0, _ := ret[0].(db.Transfer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateTransfer indicates an expected call of CreateTransfer.
-func (mr *MockStoreMockRecorder) CreateTransfer(arg0, arg1 interface{}) *gomock.Call {
+// CreateFXTransfer indicates an expected call of CreateFXTransfer.
+func (mr *MockStoreMockRecorder) CreateFXTransfer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockStore)(nil).CreateTransfer), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFXTransfer", reflect.TypeOf((*MockStore)(nil).CreateFXTransfer), arg0, arg1)
 }
 
-// CreateUser mocks base method.
-func (m *MockStore) CreateUser(arg0 context.Context, arg1 db.CreateUserParams) (db.User, error) {
+// CreateOIDCAuthRequest mocks base method.
+func (m *MockStore) CreateOIDCAuthRequest(arg0 context.Context, arg1 db.CreateOIDCAuthRequestParams) (db.OidcAuthRequest, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", arg0, arg1)
-	ret0, _ := ret[0].(db.User)
+	ret := m.ctrl.Call(m, "CreateOIDCAuthRequest", arg0, arg1)
+	ret0, _ := ret[0].(db.OidcAuthRequest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockStoreMockRecorder) CreateUser(arg0, arg1 interface{}) *gomock.Call {
+// CreateOIDCAuthRequest indicates an expected call of CreateOIDCAuthRequest.
+func (mr *MockStoreMockRecorder) CreateOIDCAuthRequest(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOIDCAuthRequest", reflect.TypeOf((*MockStore)(nil).CreateOIDCAuthRequest), arg0, arg1)
 }
 
-// DeleteAccount mocks base method.
-func (m *MockStore) DeleteAccount(arg0 context.Context, arg1 int64) error {
+// CreateOIDCUserTx mocks base method.
+func (m *MockStore) CreateOIDCUserTx(arg0 context.Context, arg1 db.CreateOIDCUserTxParams) (db.CreateOIDCUserTxResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAccount", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateOIDCUserTx", arg0, arg1)
+	ret0, _ := ret[0].(db.CreateOIDCUserTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteAccount indicates an expected call of DeleteAccount.
-func (mr *MockStoreMockRecorder) DeleteAccount(arg0, arg1 interface{}) *gomock.Call {
+// CreateOIDCUserTx indicates an expected call of CreateOIDCUserTx.
+func (mr *MockStoreMockRecorder) CreateOIDCUserTx(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockStore)(nil).DeleteAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOIDCUserTx", reflect.TypeOf((*MockStore)(nil).CreateOIDCUserTx), arg0, arg1)
 }
 
-// GetAccount mocks base method.
-func (m *MockStore) GetAccount(arg0 context.Context, arg1 int64) (db.Account, error) {
+// CreatePasswordReset mocks base method.
+func (m *MockStore) CreatePasswordReset(arg0 context.Context, arg1 db.CreatePasswordResetParams) (db.PasswordReset, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccount", arg0, arg1)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "CreatePasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(db.PasswordReset)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccount indicates an expected call of GetAccount.
-func (mr *MockStoreMockRecorder) GetAccount(arg0, arg1 interface{}) *gomock.Call {
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockStoreMockRecorder) CreatePasswordReset(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockStore)(nil).GetAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockStore)(nil).CreatePasswordReset), arg0, arg1)
 }
 
-// GetAccountForUpdate mocks base method.
-func (m *MockStore) GetAccountForUpdate(arg0 context.Context, arg1 int64) (db.Account, error) {
+// CreateRevokedToken mocks base method.
+func (m *MockStore) CreateRevokedToken(arg0 context.Context, arg1 db.CreateRevokedTokenParams) (db.RevokedToken, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccountForUpdate", arg0, arg1)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "CreateRevokedToken", arg0, arg1)
+	ret0, _ := ret[0].(db.RevokedToken)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
-func (mr *MockStoreMockRecorder) GetAccountForUpdate(arg0, arg1 interface{}) *gomock.Call {
+// CreateRevokedToken indicates an expected call of CreateRevokedToken.
+func (mr *MockStoreMockRecorder) CreateRevokedToken(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockStore)(nil).GetAccountForUpdate), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRevokedToken", reflect.TypeOf((*MockStore)(nil).CreateRevokedToken), arg0, arg1)
 }
 
-// GetEntry mocks base method.
-func (m *MockStore) GetEntry(arg0 context.Context, arg1 int64) (db.Entry, error) {
+// CreateScheduledTransfer mocks base method.
+func (m *MockStore) CreateScheduledTransfer(arg0 context.Context, arg1 db.CreateScheduledTransferParams) (db.ScheduledTransfer, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEntry", arg0, arg1)
-	ret0, _ := ret[0].(db.Entry)
+	ret := m.ctrl.Call(m, "CreateScheduledTransfer", arg0, arg1)
+	ret0, _ := ret[0].(db.ScheduledTransfer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetEntry indicates an expected call of GetEntry.
-func (mr *MockStoreMockRecorder) GetEntry(arg0, arg1 interface{}) *gomock.Call {
+// CreateScheduledTransfer indicates an expected call of CreateScheduledTransfer.
+func (mr *MockStoreMockRecorder) CreateScheduledTransfer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntry", reflect.TypeOf((*MockStore)(nil).GetEntry), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledTransfer", reflect.TypeOf((*MockStore)(nil).CreateScheduledTransfer), arg0, arg1)
 }
 
-// GetTransfer mocks base method.
-func (m *MockStore) GetTransfer(arg0 context.Context, arg1 int64) (db.Transfer, error) {
+// CreateScheduledTransferRun mocks base method.
+func (m *MockStore) CreateScheduledTransferRun(arg0 context.Context, arg1 db.CreateScheduledTransferRunParams) (db.ScheduledTransferRun, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransfer", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateScheduledTransferRun", arg0, arg1)
+	ret0, _ := ret[0].(db.ScheduledTransferRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateScheduledTransferRun indicates an expected call of CreateScheduledTransferRun.
+func (mr *MockStoreMockRecorder) CreateScheduledTransferRun(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledTransferRun", reflect.TypeOf((*MockStore)(nil).CreateScheduledTransferRun), arg0, arg1)
+}
+
+// CreateSession mocks base method.
+func (m *MockStore) CreateSession(arg0 context.Context, arg1 db.CreateSessionParams) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockStoreMockRecorder) CreateSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStore)(nil).CreateSession), arg0, arg1)
+}
+
+// CreateSkippedScheduledTransferRuns mocks base method.
+func (m *MockStore) CreateSkippedScheduledTransferRuns(arg0 context.Context, arg1 db.CreateSkippedScheduledTransferRunsParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSkippedScheduledTransferRuns", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSkippedScheduledTransferRuns indicates an expected call of CreateSkippedScheduledTransferRuns.
+func (mr *MockStoreMockRecorder) CreateSkippedScheduledTransferRuns(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSkippedScheduledTransferRuns", reflect.TypeOf((*MockStore)(nil).CreateSkippedScheduledTransferRuns), arg0, arg1)
+}
+
+// CreateTransfer mocks base method.
+func (m *MockStore) CreateTransfer(arg0 context.Context, arg1 db.CreateTransferParams) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransfer", arg0, arg1)
 	ret0, _ := ret[0].(db.Transfer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetTransfer indicates an expected call of GetTransfer.
-func (mr *MockStoreMockRecorder) GetTransfer(arg0, arg1 interface{}) *gomock.Call {
+// CreateTransfer indicates an expected call of CreateTransfer.
+func (mr *MockStoreMockRecorder) CreateTransfer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfer", reflect.TypeOf((*MockStore)(nil).GetTransfer), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockStore)(nil).CreateTransfer), arg0, arg1)
 }
 
-// GetUser mocks base method.
-func (m *MockStore) GetUser(arg0 context.Context, arg1 string) (db.User, error) {
+// CreateUser mocks base method.
+func (m *MockStore) CreateUser(arg0 context.Context, arg1 db.CreateUserParams) (db.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateUser", arg0, arg1)
 	ret0, _ := ret[0].(db.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUser indicates an expected call of GetUser.
-func (mr *MockStoreMockRecorder) GetUser(arg0, arg1 interface{}) *gomock.Call {
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockStoreMockRecorder) CreateUser(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), arg0, arg1)
 }
 
-// ListAccounts mocks base method.
-func (m *MockStore) ListAccounts(arg0 context.Context, arg1 db.ListAccountsParams) ([]db.Account, error) {
+// CreateUserIdentity mocks base method.
+func (m *MockStore) CreateUserIdentity(arg0 context.Context, arg1 db.CreateUserIdentityParams) (db.UserIdentity, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListAccounts", arg0, arg1)
-	ret0, _ := ret[0].([]db.Account)
+	ret := m.ctrl.Call(m, "CreateUserIdentity", arg0, arg1)
+	ret0, _ := ret[0].(db.UserIdentity)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListAccounts indicates an expected call of ListAccounts.
-func (mr *MockStoreMockRecorder) ListAccounts(arg0, arg1 interface{}) *gomock.Call {
+// CreateUserIdentity indicates an expected call of CreateUserIdentity.
+func (mr *MockStoreMockRecorder) CreateUserIdentity(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockStore)(nil).ListAccounts), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserIdentity", reflect.TypeOf((*MockStore)(nil).CreateUserIdentity), arg0, arg1)
 }
 
-// ListEntries mocks base method.
-func (m *MockStore) ListEntries(arg0 context.Context, arg1 db.ListEntriesParams) ([]db.Entry, error) {
+// CreateUserTx mocks base method.
+func (m *MockStore) CreateUserTx(arg0 context.Context, arg1 db.CreateUserTxParams) (db.CreateUserTxResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListEntries", arg0, arg1)
-	ret0, _ := ret[0].([]db.Entry)
+	ret := m.ctrl.Call(m, "CreateUserTx", arg0, arg1)
+	ret0, _ := ret[0].(db.CreateUserTxResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListEntries indicates an expected call of ListEntries.
-func (mr *MockStoreMockRecorder) ListEntries(arg0, arg1 interface{}) *gomock.Call {
+// CreateUserTx indicates an expected call of CreateUserTx.
+func (mr *MockStoreMockRecorder) CreateUserTx(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockStore)(nil).ListEntries), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserTx", reflect.TypeOf((*MockStore)(nil).CreateUserTx), arg0, arg1)
 }
 
-// ListTransfers mocks base method.
-func (m *MockStore) ListTransfers(arg0 context.Context, arg1 db.ListTransfersParams) ([]db.Transfer, error) {
+// CreateVerifyEmail mocks base method.
+func (m *MockStore) CreateVerifyEmail(arg0 context.Context, arg1 db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListTransfers", arg0, arg1)
-	ret0, _ := ret[0].([]db.Transfer)
+	ret := m.ctrl.Call(m, "CreateVerifyEmail", arg0, arg1)
+	ret0, _ := ret[0].(db.VerifyEmail)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListTransfers indicates an expected call of ListTransfers.
-func (mr *MockStoreMockRecorder) ListTransfers(arg0, arg1 interface{}) *gomock.Call {
+// CreateVerifyEmail indicates an expected call of CreateVerifyEmail.
+func (mr *MockStoreMockRecorder) CreateVerifyEmail(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfers", reflect.TypeOf((*MockStore)(nil).ListTransfers), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVerifyEmail", reflect.TypeOf((*MockStore)(nil).CreateVerifyEmail), arg0, arg1)
 }
 
-// TransferTx mocks base method.
-func (m *MockStore) TransferTx(arg0 context.Context, arg1 db.TranferTxParams) (db.TransferTxResult, error) {
+// CrossCurrencyTransferTx mocks base method.
+func (m *MockStore) CrossCurrencyTransferTx(arg0 context.Context, arg1 db.CrossCurrencyTransferTxParams) (db.TransferTxResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TransferTx", arg0, arg1)
+	ret := m.ctrl.Call(m, "CrossCurrencyTransferTx", arg0, arg1)
 	ret0, _ := ret[0].(db.TransferTxResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// TransferTx indicates an expected call of TransferTx.
-func (mr *MockStoreMockRecorder) TransferTx(arg0, arg1 interface{}) *gomock.Call {
+// CrossCurrencyTransferTx indicates an expected call of CrossCurrencyTransferTx.
+func (mr *MockStoreMockRecorder) CrossCurrencyTransferTx(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferTx", reflect.TypeOf((*MockStore)(nil).TransferTx), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CrossCurrencyTransferTx", reflect.TypeOf((*MockStore)(nil).CrossCurrencyTransferTx), arg0, arg1)
 }
 
-// UpdateAccount mocks base method.
-func (m *MockStore) UpdateAccount(arg0 context.Context, arg1 db.UpdateAccountParams) (db.Account, error) {
+// DeleteAccount mocks base method.
+func (m *MockStore) DeleteAccount(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateAccount", arg0, arg1)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "DeleteAccount", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccount indicates an expected call of DeleteAccount.
+func (mr *MockStoreMockRecorder) DeleteAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockStore)(nil).DeleteAccount), arg0, arg1)
+}
+
+// DeleteExpiredOIDCAuthRequests mocks base method.
+func (m *MockStore) DeleteExpiredOIDCAuthRequests(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredOIDCAuthRequests", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredOIDCAuthRequests indicates an expected call of DeleteExpiredOIDCAuthRequests.
+func (mr *MockStoreMockRecorder) DeleteExpiredOIDCAuthRequests(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredOIDCAuthRequests", reflect.TypeOf((*MockStore)(nil).DeleteExpiredOIDCAuthRequests), arg0)
+}
+
+// DeleteExpiredRevokedTokens mocks base method.
+func (m *MockStore) DeleteExpiredRevokedTokens(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRevokedTokens", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredRevokedTokens indicates an expected call of DeleteExpiredRevokedTokens.
+func (mr *MockStoreMockRecorder) DeleteExpiredRevokedTokens(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRevokedTokens", reflect.TypeOf((*MockStore)(nil).DeleteExpiredRevokedTokens), arg0)
+}
+
+// DeleteLoginFailures mocks base method.
+func (m *MockStore) DeleteLoginFailures(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLoginFailures", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLoginFailures indicates an expected call of DeleteLoginFailures.
+func (mr *MockStoreMockRecorder) DeleteLoginFailures(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLoginFailures", reflect.TypeOf((*MockStore)(nil).DeleteLoginFailures), arg0, arg1)
+}
+
+// DeleteStaleLoginFailures mocks base method.
+func (m *MockStore) DeleteStaleLoginFailures(arg0 context.Context, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteStaleLoginFailures", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteStaleLoginFailures indicates an expected call of DeleteStaleLoginFailures.
+func (mr *MockStoreMockRecorder) DeleteStaleLoginFailures(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStaleLoginFailures", reflect.TypeOf((*MockStore)(nil).DeleteStaleLoginFailures), arg0, arg1)
+}
+
+// DeleteStaleRateLimits mocks base method.
+func (m *MockStore) DeleteStaleRateLimits(arg0 context.Context, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteStaleRateLimits", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteStaleRateLimits indicates an expected call of DeleteStaleRateLimits.
+func (mr *MockStoreMockRecorder) DeleteStaleRateLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStaleRateLimits", reflect.TypeOf((*MockStore)(nil).DeleteStaleRateLimits), arg0, arg1)
+}
+
+// EnableUserTOTP mocks base method.
+func (m *MockStore) EnableUserTOTP(arg0 context.Context, arg1 db.EnableUserTOTPParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableUserTOTP", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdateAccount indicates an expected call of UpdateAccount.
-func (mr *MockStoreMockRecorder) UpdateAccount(arg0, arg1 interface{}) *gomock.Call {
+// EnableUserTOTP indicates an expected call of EnableUserTOTP.
+func (mr *MockStoreMockRecorder) EnableUserTOTP(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccount", reflect.TypeOf((*MockStore)(nil).UpdateAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableUserTOTP", reflect.TypeOf((*MockStore)(nil).EnableUserTOTP), arg0, arg1)
+}
+
+// GetAPIKeyByPrefix mocks base method.
+func (m *MockStore) GetAPIKeyByPrefix(arg0 context.Context, arg1 string) (db.GetAPIKeyByPrefixRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByPrefix", arg0, arg1)
+	ret0, _ := ret[0].(db.GetAPIKeyByPrefixRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByPrefix indicates an expected call of GetAPIKeyByPrefix.
+func (mr *MockStoreMockRecorder) GetAPIKeyByPrefix(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByPrefix", reflect.TypeOf((*MockStore)(nil).GetAPIKeyByPrefix), arg0, arg1)
+}
+
+// GetAccount mocks base method.
+func (m *MockStore) GetAccount(arg0 context.Context, arg1 int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccount", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccount indicates an expected call of GetAccount.
+func (mr *MockStoreMockRecorder) GetAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockStore)(nil).GetAccount), arg0, arg1)
+}
+
+// GetAccountBalanceAfterEntry mocks base method.
+func (m *MockStore) GetAccountBalanceAfterEntry(arg0 context.Context, arg1 db.GetAccountBalanceAfterEntryParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountBalanceAfterEntry", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountBalanceAfterEntry indicates an expected call of GetAccountBalanceAfterEntry.
+func (mr *MockStoreMockRecorder) GetAccountBalanceAfterEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountBalanceAfterEntry", reflect.TypeOf((*MockStore)(nil).GetAccountBalanceAfterEntry), arg0, arg1)
+}
+
+// GetAccountForUpdate mocks base method.
+func (m *MockStore) GetAccountForUpdate(arg0 context.Context, arg1 int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
+func (mr *MockStoreMockRecorder) GetAccountForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockStore)(nil).GetAccountForUpdate), arg0, arg1)
+}
+
+// GetEntry mocks base method.
+func (m *MockStore) GetEntry(arg0 context.Context, arg1 int64) (db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEntry", arg0, arg1)
+	ret0, _ := ret[0].(db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEntry indicates an expected call of GetEntry.
+func (mr *MockStoreMockRecorder) GetEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntry", reflect.TypeOf((*MockStore)(nil).GetEntry), arg0, arg1)
+}
+
+// GetFXQuote mocks base method.
+func (m *MockStore) GetFXQuote(arg0 context.Context, arg1 uuid.UUID) (db.FxQuote, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFXQuote", arg0, arg1)
+	ret0, _ := ret[0].(db.FxQuote)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFXQuote indicates an expected call of GetFXQuote.
+func (mr *MockStoreMockRecorder) GetFXQuote(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFXQuote", reflect.TypeOf((*MockStore)(nil).GetFXQuote), arg0, arg1)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockStore) GetIdempotencyKey(arg0 context.Context, arg1 db.GetIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(db.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockStoreMockRecorder) GetIdempotencyKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockStore)(nil).GetIdempotencyKey), arg0, arg1)
+}
+
+// GetLatestFXRate mocks base method.
+func (m *MockStore) GetLatestFXRate(arg0 context.Context, arg1 db.GetLatestFXRateParams) (db.FxRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestFXRate", arg0, arg1)
+	ret0, _ := ret[0].(db.FxRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestFXRate indicates an expected call of GetLatestFXRate.
+func (mr *MockStoreMockRecorder) GetLatestFXRate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestFXRate", reflect.TypeOf((*MockStore)(nil).GetLatestFXRate), arg0, arg1)
+}
+
+// GetRevokedToken mocks base method.
+func (m *MockStore) GetRevokedToken(arg0 context.Context, arg1 uuid.UUID) (db.RevokedToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevokedToken", arg0, arg1)
+	ret0, _ := ret[0].(db.RevokedToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevokedToken indicates an expected call of GetRevokedToken.
+func (mr *MockStoreMockRecorder) GetRevokedToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevokedToken", reflect.TypeOf((*MockStore)(nil).GetRevokedToken), arg0, arg1)
+}
+
+// GetScheduledTransfer mocks base method.
+func (m *MockStore) GetScheduledTransfer(arg0 context.Context, arg1 int64) (db.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScheduledTransfer", arg0, arg1)
+	ret0, _ := ret[0].(db.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScheduledTransfer indicates an expected call of GetScheduledTransfer.
+func (mr *MockStoreMockRecorder) GetScheduledTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScheduledTransfer", reflect.TypeOf((*MockStore)(nil).GetScheduledTransfer), arg0, arg1)
+}
+
+// GetSession mocks base method.
+func (m *MockStore) GetSession(arg0 context.Context, arg1 uuid.UUID) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSession", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSession indicates an expected call of GetSession.
+func (mr *MockStoreMockRecorder) GetSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockStore)(nil).GetSession), arg0, arg1)
+}
+
+// GetStatementBalances mocks base method.
+func (m *MockStore) GetStatementBalances(arg0 context.Context, arg1 db.GetStatementBalancesParams) (db.GetStatementBalancesRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatementBalances", arg0, arg1)
+	ret0, _ := ret[0].(db.GetStatementBalancesRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatementBalances indicates an expected call of GetStatementBalances.
+func (mr *MockStoreMockRecorder) GetStatementBalances(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatementBalances", reflect.TypeOf((*MockStore)(nil).GetStatementBalances), arg0, arg1)
+}
+
+// GetTransfer mocks base method.
+func (m *MockStore) GetTransfer(arg0 context.Context, arg1 int64) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfer", arg0, arg1)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfer indicates an expected call of GetTransfer.
+func (mr *MockStoreMockRecorder) GetTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfer", reflect.TypeOf((*MockStore)(nil).GetTransfer), arg0, arg1)
+}
+
+// GetTransferLimits mocks base method.
+func (m *MockStore) GetTransferLimits(arg0 context.Context, arg1 int64) (db.GetTransferLimitsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferLimits", arg0, arg1)
+	ret0, _ := ret[0].(db.GetTransferLimitsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferLimits indicates an expected call of GetTransferLimits.
+func (mr *MockStoreMockRecorder) GetTransferLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferLimits", reflect.TypeOf((*MockStore)(nil).GetTransferLimits), arg0, arg1)
+}
+
+// GetUser mocks base method.
+func (m *MockStore) GetUser(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockStoreMockRecorder) GetUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), arg0, arg1)
+}
+
+// GetUserAuth mocks base method.
+func (m *MockStore) GetUserAuth(arg0 context.Context, arg1 string) (db.GetUserAuthRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserAuth", arg0, arg1)
+	ret0, _ := ret[0].(db.GetUserAuthRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserAuth indicates an expected call of GetUserAuth.
+func (mr *MockStoreMockRecorder) GetUserAuth(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAuth", reflect.TypeOf((*MockStore)(nil).GetUserAuth), arg0, arg1)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockStore) GetUserByEmail(arg0 context.Context, arg1 string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockStoreMockRecorder) GetUserByEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockStore)(nil).GetUserByEmail), arg0, arg1)
+}
+
+// GetUserIdentity mocks base method.
+func (m *MockStore) GetUserIdentity(arg0 context.Context, arg1 db.GetUserIdentityParams) (db.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserIdentity", arg0, arg1)
+	ret0, _ := ret[0].(db.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserIdentity indicates an expected call of GetUserIdentity.
+func (mr *MockStoreMockRecorder) GetUserIdentity(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserIdentity", reflect.TypeOf((*MockStore)(nil).GetUserIdentity), arg0, arg1)
+}
+
+// HitRateLimit mocks base method.
+func (m *MockStore) HitRateLimit(arg0 context.Context, arg1 db.HitRateLimitParams) (db.RateLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HitRateLimit", arg0, arg1)
+	ret0, _ := ret[0].(db.RateLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HitRateLimit indicates an expected call of HitRateLimit.
+func (mr *MockStoreMockRecorder) HitRateLimit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HitRateLimit", reflect.TypeOf((*MockStore)(nil).HitRateLimit), arg0, arg1)
+}
+
+// IdempotentTransferTx mocks base method.
+func (m *MockStore) IdempotentTransferTx(arg0 context.Context, arg1 db.IdempotentTransferTxParams) (db.IdempotentTransferTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdempotentTransferTx", arg0, arg1)
+	ret0, _ := ret[0].(db.IdempotentTransferTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IdempotentTransferTx indicates an expected call of IdempotentTransferTx.
+func (mr *MockStoreMockRecorder) IdempotentTransferTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdempotentTransferTx", reflect.TypeOf((*MockStore)(nil).IdempotentTransferTx), arg0, arg1)
+}
+
+// InvalidatePasswordResets mocks base method.
+func (m *MockStore) InvalidatePasswordResets(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidatePasswordResets", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidatePasswordResets indicates an expected call of InvalidatePasswordResets.
+func (mr *MockStoreMockRecorder) InvalidatePasswordResets(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidatePasswordResets", reflect.TypeOf((*MockStore)(nil).InvalidatePasswordResets), arg0, arg1)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockStore) ListAPIKeys(arg0 context.Context, arg1 string) ([]db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", arg0, arg1)
+	ret0, _ := ret[0].([]db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockStoreMockRecorder) ListAPIKeys(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockStore)(nil).ListAPIKeys), arg0, arg1)
+}
+
+// ListAccountTransfers mocks base method.
+func (m *MockStore) ListAccountTransfers(arg0 context.Context, arg1 db.ListAccountTransfersParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountTransfers indicates an expected call of ListAccountTransfers.
+func (mr *MockStoreMockRecorder) ListAccountTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountTransfers", reflect.TypeOf((*MockStore)(nil).ListAccountTransfers), arg0, arg1)
+}
+
+// ListAccountTransfersReverse mocks base method.
+func (m *MockStore) ListAccountTransfersReverse(arg0 context.Context, arg1 db.ListAccountTransfersReverseParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountTransfersReverse", arg0, arg1)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountTransfersReverse indicates an expected call of ListAccountTransfersReverse.
+func (mr *MockStoreMockRecorder) ListAccountTransfersReverse(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountTransfersReverse", reflect.TypeOf((*MockStore)(nil).ListAccountTransfersReverse), arg0, arg1)
+}
+
+// ListAccounts mocks base method.
+func (m *MockStore) ListAccounts(arg0 context.Context, arg1 db.ListAccountsParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccounts", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccounts indicates an expected call of ListAccounts.
+func (mr *MockStoreMockRecorder) ListAccounts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockStore)(nil).ListAccounts), arg0, arg1)
+}
+
+// ListAccountsReverse mocks base method.
+func (m *MockStore) ListAccountsReverse(arg0 context.Context, arg1 db.ListAccountsReverseParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountsReverse", arg0, arg1)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountsReverse indicates an expected call of ListAccountsReverse.
+func (mr *MockStoreMockRecorder) ListAccountsReverse(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountsReverse", reflect.TypeOf((*MockStore)(nil).ListAccountsReverse), arg0, arg1)
+}
+
+// ListEntries mocks base method.
+func (m *MockStore) ListEntries(arg0 context.Context, arg1 db.ListEntriesParams) ([]db.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEntries", arg0, arg1)
+	ret0, _ := ret[0].([]db.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListEntries indicates an expected call of ListEntries.
+func (mr *MockStoreMockRecorder) ListEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntries", reflect.TypeOf((*MockStore)(nil).ListEntries), arg0, arg1)
+}
+
+// ListLoginLocks mocks base method.
+func (m *MockStore) ListLoginLocks(arg0 context.Context, arg1 []string) ([]db.LoginFailure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLoginLocks", arg0, arg1)
+	ret0, _ := ret[0].([]db.LoginFailure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLoginLocks indicates an expected call of ListLoginLocks.
+func (mr *MockStoreMockRecorder) ListLoginLocks(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLoginLocks", reflect.TypeOf((*MockStore)(nil).ListLoginLocks), arg0, arg1)
+}
+
+// ListScheduledTransferRuns mocks base method.
+func (m *MockStore) ListScheduledTransferRuns(arg0 context.Context, arg1 db.ListScheduledTransferRunsParams) ([]db.ScheduledTransferRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledTransferRuns", arg0, arg1)
+	ret0, _ := ret[0].([]db.ScheduledTransferRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledTransferRuns indicates an expected call of ListScheduledTransferRuns.
+func (mr *MockStoreMockRecorder) ListScheduledTransferRuns(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledTransferRuns", reflect.TypeOf((*MockStore)(nil).ListScheduledTransferRuns), arg0, arg1)
+}
+
+// ListScheduledTransferRunsReverse mocks base method.
+func (m *MockStore) ListScheduledTransferRunsReverse(arg0 context.Context, arg1 db.ListScheduledTransferRunsReverseParams) ([]db.ScheduledTransferRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledTransferRunsReverse", arg0, arg1)
+	ret0, _ := ret[0].([]db.ScheduledTransferRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledTransferRunsReverse indicates an expected call of ListScheduledTransferRunsReverse.
+func (mr *MockStoreMockRecorder) ListScheduledTransferRunsReverse(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledTransferRunsReverse", reflect.TypeOf((*MockStore)(nil).ListScheduledTransferRunsReverse), arg0, arg1)
+}
+
+// ListScheduledTransfers mocks base method.
+func (m *MockStore) ListScheduledTransfers(arg0 context.Context, arg1 db.ListScheduledTransfersParams) ([]db.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]db.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledTransfers indicates an expected call of ListScheduledTransfers.
+func (mr *MockStoreMockRecorder) ListScheduledTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledTransfers", reflect.TypeOf((*MockStore)(nil).ListScheduledTransfers), arg0, arg1)
+}
+
+// ListScheduledTransfersReverse mocks base method.
+func (m *MockStore) ListScheduledTransfersReverse(arg0 context.Context, arg1 db.ListScheduledTransfersReverseParams) ([]db.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledTransfersReverse", arg0, arg1)
+	ret0, _ := ret[0].([]db.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledTransfersReverse indicates an expected call of ListScheduledTransfersReverse.
+func (mr *MockStoreMockRecorder) ListScheduledTransfersReverse(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledTransfersReverse", reflect.TypeOf((*MockStore)(nil).ListScheduledTransfersReverse), arg0, arg1)
+}
+
+// ListSessions mocks base method.
+func (m *MockStore) ListSessions(arg0 context.Context, arg1 string) ([]db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", arg0, arg1)
+	ret0, _ := ret[0].([]db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockStoreMockRecorder) ListSessions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockStore)(nil).ListSessions), arg0, arg1)
+}
+
+// ListStatementEntries mocks base method.
+func (m *MockStore) ListStatementEntries(arg0 context.Context, arg1 db.ListStatementEntriesParams) ([]db.ListStatementEntriesRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStatementEntries", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListStatementEntriesRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStatementEntries indicates an expected call of ListStatementEntries.
+func (mr *MockStoreMockRecorder) ListStatementEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStatementEntries", reflect.TypeOf((*MockStore)(nil).ListStatementEntries), arg0, arg1)
+}
+
+// ListStatementEntriesReverse mocks base method.
+func (m *MockStore) ListStatementEntriesReverse(arg0 context.Context, arg1 db.ListStatementEntriesReverseParams) ([]db.ListStatementEntriesReverseRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStatementEntriesReverse", arg0, arg1)
+	ret0, _ := ret[0].([]db.ListStatementEntriesReverseRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStatementEntriesReverse indicates an expected call of ListStatementEntriesReverse.
+func (mr *MockStoreMockRecorder) ListStatementEntriesReverse(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStatementEntriesReverse", reflect.TypeOf((*MockStore)(nil).ListStatementEntriesReverse), arg0, arg1)
+}
+
+// ListTransfers mocks base method.
+func (m *MockStore) ListTransfers(arg0 context.Context, arg1 db.ListTransfersParams) ([]db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransfers indicates an expected call of ListTransfers.
+func (mr *MockStoreMockRecorder) ListTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransfers", reflect.TypeOf((*MockStore)(nil).ListTransfers), arg0, arg1)
+}
+
+// LockLoginKey mocks base method.
+func (m *MockStore) LockLoginKey(arg0 context.Context, arg1 db.LockLoginKeyParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLoginKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockLoginKey indicates an expected call of LockLoginKey.
+func (mr *MockStoreMockRecorder) LockLoginKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLoginKey", reflect.TypeOf((*MockStore)(nil).LockLoginKey), arg0, arg1)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockStore) RecordLoginFailure(arg0 context.Context, arg1 db.RecordLoginFailureParams) (db.LoginFailure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", arg0, arg1)
+	ret0, _ := ret[0].(db.LoginFailure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockStoreMockRecorder) RecordLoginFailure(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockStore)(nil).RecordLoginFailure), arg0, arg1)
+}
+
+// RehashUserPassword mocks base method.
+func (m *MockStore) RehashUserPassword(arg0 context.Context, arg1 db.RehashUserPasswordParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RehashUserPassword", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RehashUserPassword indicates an expected call of RehashUserPassword.
+func (mr *MockStoreMockRecorder) RehashUserPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RehashUserPassword", reflect.TypeOf((*MockStore)(nil).RehashUserPassword), arg0, arg1)
+}
+
+// ResetPasswordTx mocks base method.
+func (m *MockStore) ResetPasswordTx(arg0 context.Context, arg1 db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPasswordTx", arg0, arg1)
+	ret0, _ := ret[0].(db.ResetPasswordTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPasswordTx indicates an expected call of ResetPasswordTx.
+func (mr *MockStoreMockRecorder) ResetPasswordTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPasswordTx", reflect.TypeOf((*MockStore)(nil).ResetPasswordTx), arg0, arg1)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockStore) RevokeAPIKey(arg0 context.Context, arg1 db.RevokeAPIKeyParams) (db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockStoreMockRecorder) RevokeAPIKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockStore)(nil).RevokeAPIKey), arg0, arg1)
+}
+
+// RevokeAPIKeyByID mocks base method.
+func (m *MockStore) RevokeAPIKeyByID(arg0 context.Context, arg1 uuid.UUID) (db.ApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKeyByID", arg0, arg1)
+	ret0, _ := ret[0].(db.ApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKeyByID indicates an expected call of RevokeAPIKeyByID.
+func (mr *MockStoreMockRecorder) RevokeAPIKeyByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKeyByID", reflect.TypeOf((*MockStore)(nil).RevokeAPIKeyByID), arg0, arg1)
+}
+
+// RunScheduledTransferTx mocks base method.
+func (m *MockStore) RunScheduledTransferTx(arg0 context.Context, arg1 db.RunScheduledTransferTxParams) (db.RunScheduledTransferTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunScheduledTransferTx", arg0, arg1)
+	ret0, _ := ret[0].(db.RunScheduledTransferTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RunScheduledTransferTx indicates an expected call of RunScheduledTransferTx.
+func (mr *MockStoreMockRecorder) RunScheduledTransferTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunScheduledTransferTx", reflect.TypeOf((*MockStore)(nil).RunScheduledTransferTx), arg0, arg1)
+}
+
+// SetAccountTransferLimits mocks base method.
+func (m *MockStore) SetAccountTransferLimits(arg0 context.Context, arg1 db.SetAccountTransferLimitsParams) (db.AccountTransferLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountTransferLimits", arg0, arg1)
+	ret0, _ := ret[0].(db.AccountTransferLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAccountTransferLimits indicates an expected call of SetAccountTransferLimits.
+func (mr *MockStoreMockRecorder) SetAccountTransferLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountTransferLimits", reflect.TypeOf((*MockStore)(nil).SetAccountTransferLimits), arg0, arg1)
+}
+
+// SetIdempotencyKeyResponse mocks base method.
+func (m *MockStore) SetIdempotencyKeyResponse(arg0 context.Context, arg1 db.SetIdempotencyKeyResponseParams) (db.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetIdempotencyKeyResponse", arg0, arg1)
+	ret0, _ := ret[0].(db.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetIdempotencyKeyResponse indicates an expected call of SetIdempotencyKeyResponse.
+func (mr *MockStoreMockRecorder) SetIdempotencyKeyResponse(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetIdempotencyKeyResponse", reflect.TypeOf((*MockStore)(nil).SetIdempotencyKeyResponse), arg0, arg1)
+}
+
+// SetTierTransferLimits mocks base method.
+func (m *MockStore) SetTierTransferLimits(arg0 context.Context, arg1 db.SetTierTransferLimitsParams) (db.TierTransferLimit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTierTransferLimits", arg0, arg1)
+	ret0, _ := ret[0].(db.TierTransferLimit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetTierTransferLimits indicates an expected call of SetTierTransferLimits.
+func (mr *MockStoreMockRecorder) SetTierTransferLimits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTierTransferLimits", reflect.TypeOf((*MockStore)(nil).SetTierTransferLimits), arg0, arg1)
+}
+
+// TouchSession mocks base method.
+func (m *MockStore) TouchSession(arg0 context.Context, arg1 db.TouchSessionParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchSession", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchSession indicates an expected call of TouchSession.
+func (mr *MockStoreMockRecorder) TouchSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchSession", reflect.TypeOf((*MockStore)(nil).TouchSession), arg0, arg1)
+}
+
+// TransferTx mocks base method.
+func (m *MockStore) TransferTx(arg0 context.Context, arg1 db.TranferTxParams) (db.TransferTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferTx", arg0, arg1)
+	ret0, _ := ret[0].(db.TransferTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TransferTx indicates an expected call of TransferTx.
+func (mr *MockStoreMockRecorder) TransferTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferTx", reflect.TypeOf((*MockStore)(nil).TransferTx), arg0, arg1)
+}
+
+// UpdateAPIKeyLastUsed mocks base method.
+func (m *MockStore) UpdateAPIKeyLastUsed(arg0 context.Context, arg1 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAPIKeyLastUsed", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAPIKeyLastUsed indicates an expected call of UpdateAPIKeyLastUsed.
+func (mr *MockStoreMockRecorder) UpdateAPIKeyLastUsed(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAPIKeyLastUsed", reflect.TypeOf((*MockStore)(nil).UpdateAPIKeyLastUsed), arg0, arg1)
+}
+
+// UpdateAccount mocks base method.
+func (m *MockStore) UpdateAccount(arg0 context.Context, arg1 db.UpdateAccountParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccount", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccount indicates an expected call of UpdateAccount.
+func (mr *MockStoreMockRecorder) UpdateAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccount", reflect.TypeOf((*MockStore)(nil).UpdateAccount), arg0, arg1)
+}
+
+// UpdateAccountFrozen mocks base method.
+func (m *MockStore) UpdateAccountFrozen(arg0 context.Context, arg1 db.UpdateAccountFrozenParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountFrozen", arg0, arg1)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountFrozen indicates an expected call of UpdateAccountFrozen.
+func (mr *MockStoreMockRecorder) UpdateAccountFrozen(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountFrozen", reflect.TypeOf((*MockStore)(nil).UpdateAccountFrozen), arg0, arg1)
+}
+
+// UpdateScheduledTransfer mocks base method.
+func (m *MockStore) UpdateScheduledTransfer(arg0 context.Context, arg1 db.UpdateScheduledTransferParams) (db.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateScheduledTransfer", arg0, arg1)
+	ret0, _ := ret[0].(db.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateScheduledTransfer indicates an expected call of UpdateScheduledTransfer.
+func (mr *MockStoreMockRecorder) UpdateScheduledTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateScheduledTransfer", reflect.TypeOf((*MockStore)(nil).UpdateScheduledTransfer), arg0, arg1)
+}
+
+// UpdateUser mocks base method.
+func (m *MockStore) UpdateUser(arg0 context.Context, arg1 db.UpdateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockStoreMockRecorder) UpdateUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStore)(nil).UpdateUser), arg0, arg1)
+}
+
+// UpdateUserEmailVerified mocks base method.
+func (m *MockStore) UpdateUserEmailVerified(arg0 context.Context, arg1 db.UpdateUserEmailVerifiedParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserEmailVerified", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserEmailVerified indicates an expected call of UpdateUserEmailVerified.
+func (mr *MockStoreMockRecorder) UpdateUserEmailVerified(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserEmailVerified", reflect.TypeOf((*MockStore)(nil).UpdateUserEmailVerified), arg0, arg1)
+}
+
+// UpdateUserFrozen mocks base method.
+func (m *MockStore) UpdateUserFrozen(arg0 context.Context, arg1 db.UpdateUserFrozenParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserFrozen", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserFrozen indicates an expected call of UpdateUserFrozen.
+func (mr *MockStoreMockRecorder) UpdateUserFrozen(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserFrozen", reflect.TypeOf((*MockStore)(nil).UpdateUserFrozen), arg0, arg1)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockStore) UpdateUserPassword(arg0 context.Context, arg1 db.UpdateUserPasswordParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockStoreMockRecorder) UpdateUserPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockStore)(nil).UpdateUserPassword), arg0, arg1)
+}
+
+// UpdateUserTOTPSecret mocks base method.
+func (m *MockStore) UpdateUserTOTPSecret(arg0 context.Context, arg1 db.UpdateUserTOTPSecretParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTOTPSecret", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserTOTPSecret indicates an expected call of UpdateUserTOTPSecret.
+func (mr *MockStoreMockRecorder) UpdateUserTOTPSecret(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTOTPSecret", reflect.TypeOf((*MockStore)(nil).UpdateUserTOTPSecret), arg0, arg1)
+}
+
+// UpdateUserTier mocks base method.
+func (m *MockStore) UpdateUserTier(arg0 context.Context, arg1 db.UpdateUserTierParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTier", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserTier indicates an expected call of UpdateUserTier.
+func (mr *MockStoreMockRecorder) UpdateUserTier(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTier", reflect.TypeOf((*MockStore)(nil).UpdateUserTier), arg0, arg1)
+}
+
+// UpdateUserTx mocks base method.
+func (m *MockStore) UpdateUserTx(arg0 context.Context, arg1 db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTx", arg0, arg1)
+	ret0, _ := ret[0].(db.UpdateUserTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserTx indicates an expected call of UpdateUserTx.
+func (mr *MockStoreMockRecorder) UpdateUserTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTx", reflect.TypeOf((*MockStore)(nil).UpdateUserTx), arg0, arg1)
+}
+
+// UseFXQuote mocks base method.
+func (m *MockStore) UseFXQuote(arg0 context.Context, arg1 db.UseFXQuoteParams) (db.FxQuote, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseFXQuote", arg0, arg1)
+	ret0, _ := ret[0].(db.FxQuote)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseFXQuote indicates an expected call of UseFXQuote.
+func (mr *MockStoreMockRecorder) UseFXQuote(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseFXQuote", reflect.TypeOf((*MockStore)(nil).UseFXQuote), arg0, arg1)
+}
+
+// UseOIDCAuthRequest mocks base method.
+func (m *MockStore) UseOIDCAuthRequest(arg0 context.Context, arg1 string) (db.OidcAuthRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseOIDCAuthRequest", arg0, arg1)
+	ret0, _ := ret[0].(db.OidcAuthRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseOIDCAuthRequest indicates an expected call of UseOIDCAuthRequest.
+func (mr *MockStoreMockRecorder) UseOIDCAuthRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseOIDCAuthRequest", reflect.TypeOf((*MockStore)(nil).UseOIDCAuthRequest), arg0, arg1)
+}
+
+// UsePasswordReset mocks base method.
+func (m *MockStore) UsePasswordReset(arg0 context.Context, arg1 string) (db.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordReset", arg0, arg1)
+	ret0, _ := ret[0].(db.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UsePasswordReset indicates an expected call of UsePasswordReset.
+func (mr *MockStoreMockRecorder) UsePasswordReset(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordReset", reflect.TypeOf((*MockStore)(nil).UsePasswordReset), arg0, arg1)
+}
+
+// UseUserRecoveryCode mocks base method.
+func (m *MockStore) UseUserRecoveryCode(arg0 context.Context, arg1 db.UseUserRecoveryCodeParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseUserRecoveryCode", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseUserRecoveryCode indicates an expected call of UseUserRecoveryCode.
+func (mr *MockStoreMockRecorder) UseUserRecoveryCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseUserRecoveryCode", reflect.TypeOf((*MockStore)(nil).UseUserRecoveryCode), arg0, arg1)
+}
+
+// UseUserTOTPStep mocks base method.
+func (m *MockStore) UseUserTOTPStep(arg0 context.Context, arg1 db.UseUserTOTPStepParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseUserTOTPStep", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseUserTOTPStep indicates an expected call of UseUserTOTPStep.
+func (mr *MockStoreMockRecorder) UseUserTOTPStep(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseUserTOTPStep", reflect.TypeOf((*MockStore)(nil).UseUserTOTPStep), arg0, arg1)
+}
+
+// UseVerifyEmail mocks base method.
+func (m *MockStore) UseVerifyEmail(arg0 context.Context, arg1 db.UseVerifyEmailParams) (db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseVerifyEmail", arg0, arg1)
+	ret0, _ := ret[0].(db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseVerifyEmail indicates an expected call of UseVerifyEmail.
+func (mr *MockStoreMockRecorder) UseVerifyEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseVerifyEmail", reflect.TypeOf((*MockStore)(nil).UseVerifyEmail), arg0, arg1)
+}
+
+// VerifyEmailTx mocks base method.
+func (m *MockStore) VerifyEmailTx(arg0 context.Context, arg1 db.VerifyEmailTxParams) (db.VerifyEmailTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailTx", arg0, arg1)
+	ret0, _ := ret[0].(db.VerifyEmailTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmailTx indicates an expected call of VerifyEmailTx.
+func (mr *MockStoreMockRecorder) VerifyEmailTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailTx", reflect.TypeOf((*MockStore)(nil).VerifyEmailTx), arg0, arg1)
 }
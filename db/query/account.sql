@@ -19,10 +19,18 @@ FOR NO KEY UPDATE;
 
 -- name: ListAccounts :many
 SELECT * FROM accounts
-WHERE owner = $1
-ORDER BY id
-LIMIT $2
-OFFSET $3;
+WHERE owner = sqlc.arg(owner)
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR
+    (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::bigint))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: ListAccountsReverse :many
+SELECT * FROM accounts
+WHERE owner = sqlc.arg(owner)
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
 
 -- name: UpdateAccount :one
 UPDATE accounts
@@ -37,4 +45,10 @@ WHERE id = sqlc.arg(id)
 RETURNING *;
 
 -- name: DeleteAccount :exec
-DELETE FROM accounts WHERE id = $1;
\ No newline at end of file
+DELETE FROM accounts WHERE id = $1;
+
+-- name: UpdateAccountFrozen :one
+UPDATE accounts
+SET is_frozen = $2
+WHERE id = $1
+RETURNING *;
\ No newline at end of file
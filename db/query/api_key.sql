@@ -0,0 +1,38 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+  id,
+  prefix,
+  hashed_key,
+  owner,
+  scopes,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetAPIKeyByPrefix :one
+SELECT api_keys.*, users.role, users.is_frozen FROM api_keys
+JOIN users ON users.username = api_keys.owner
+WHERE api_keys.prefix = $1 LIMIT 1;
+
+-- name: ListAPIKeys :many
+SELECT * FROM api_keys
+WHERE owner = $1
+ORDER BY created_at;
+
+-- name: UpdateAPIKeyLastUsed :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1;
+
+-- name: RevokeAPIKey :one
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = $1 AND owner = $2 AND revoked_at IS NULL
+RETURNING *;
+-- name: RevokeAPIKeyByID :one
+UPDATE api_keys
+SET revoked_at = COALESCE(revoked_at, now())
+WHERE id = $1
+RETURNING *;
@@ -1,9 +1,10 @@
 -- name: CreateEntry :one
 INSERT INTO entries (
   accounts_id,
-  amount 
+  amount,
+  transfer_id
 ) VALUES (
-  $1, $2
+  $1, $2, $3
 )
 RETURNING *;
 
@@ -13,7 +14,53 @@ WHERE id = $1 LIMIT 1;
 
 -- name: ListEntries :many
 SELECT * FROM entries
-WHERE accounts_id = $1
-ORDER BY id
-LIMIT $2
-OFFSET $3;
\ No newline at end of file
+WHERE accounts_id = sqlc.arg(accounts_id)
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR
+    (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::bigint))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: GetStatementBalances :one
+SELECT
+  (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS opening_balance,
+  (a.balance - COALESCE(SUM(e.amount) FILTER (WHERE e.created_at >= sqlc.arg(to_time)), 0))::bigint AS closing_balance
+FROM accounts a
+LEFT JOIN entries e ON e.accounts_id = a.id AND e.created_at >= sqlc.arg(from_time)
+WHERE a.id = sqlc.arg(account_id)
+GROUP BY a.id;
+
+-- name: GetAccountBalanceAfterEntry :one
+-- the balance an entry left is the current one without the entries made since
+SELECT (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS balance
+FROM accounts a
+LEFT JOIN entries e ON e.accounts_id = a.id
+  AND (e.created_at, e.id) > (sqlc.arg(entry_created_at)::timestamptz, sqlc.arg(entry_id)::bigint)
+WHERE a.id = sqlc.arg(account_id)
+GROUP BY a.id;
+
+-- name: ListStatementEntries :many
+SELECT
+  e.*,
+  COALESCE(CASE WHEN t.from_account_id = e.accounts_id THEN t.to_account_id ELSE t.from_account_id END, 0)::bigint AS counterparty_account_id
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+WHERE e.accounts_id = sqlc.arg(account_id)
+  AND e.created_at >= sqlc.arg(from_time)
+  AND e.created_at < sqlc.arg(to_time)
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR
+    (e.created_at, e.id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::bigint))
+ORDER BY e.created_at, e.id
+LIMIT sqlc.arg('limit');
+
+-- name: ListStatementEntriesReverse :many
+SELECT
+  e.*,
+  COALESCE(CASE WHEN t.from_account_id = e.accounts_id THEN t.to_account_id ELSE t.from_account_id END, 0)::bigint AS counterparty_account_id
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+WHERE e.accounts_id = sqlc.arg(account_id)
+  AND e.created_at >= sqlc.arg(from_time)
+  AND e.created_at < sqlc.arg(to_time)
+  AND (e.created_at, e.id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::bigint)
+ORDER BY e.created_at DESC, e.id DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,42 @@
+-- name: CreateFXRate :one
+INSERT INTO fx_rates (
+  from_currency,
+  to_currency,
+  rate
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetLatestFXRate :one
+SELECT * FROM fx_rates
+WHERE from_currency = $1 AND to_currency = $2
+ORDER BY created_at DESC, id DESC
+LIMIT 1;
+
+-- name: CreateFXQuote :one
+INSERT INTO fx_quotes (
+  id,
+  username,
+  from_currency,
+  to_currency,
+  rate,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetFXQuote :one
+SELECT * FROM fx_quotes
+WHERE id = $1 LIMIT 1;
+
+-- name: UseFXQuote :one
+UPDATE fx_quotes
+SET is_used = true
+WHERE
+  id = $1
+  AND username = $2
+  AND is_used = false
+  AND expired_at > now()
+RETURNING *;
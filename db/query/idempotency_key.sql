@@ -0,0 +1,28 @@
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (
+  username,
+  key,
+  request_hash,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (username, key) DO UPDATE
+SET
+  request_hash = EXCLUDED.request_hash,
+  response = '{}',
+  created_at = now(),
+  expired_at = EXCLUDED.expired_at
+WHERE idempotency_keys.expired_at <= now()
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE username = $1 AND key = $2 AND expired_at > now()
+LIMIT 1;
+
+-- name: SetIdempotencyKeyResponse :one
+UPDATE idempotency_keys
+SET response = $3
+WHERE username = $1 AND key = $2
+RETURNING *;
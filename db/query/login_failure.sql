@@ -0,0 +1,32 @@
+-- name: RecordLoginFailure :one
+INSERT INTO login_failures (
+  key,
+  failed_count
+) VALUES (
+  sqlc.arg(key), 1
+)
+ON CONFLICT (key) DO UPDATE
+SET
+  failed_count = CASE
+    WHEN login_failures.last_failed_at < sqlc.arg(reset_before) THEN 1
+    ELSE login_failures.failed_count + 1
+  END,
+  last_failed_at = now()
+RETURNING *;
+
+-- name: LockLoginKey :exec
+UPDATE login_failures
+SET locked_until = $2
+WHERE key = $1;
+
+-- name: ListLoginLocks :many
+SELECT * FROM login_failures
+WHERE key = ANY(sqlc.arg(keys)::varchar[]) AND locked_until > now();
+
+-- name: DeleteLoginFailures :exec
+DELETE FROM login_failures
+WHERE key = $1;
+
+-- name: DeleteStaleLoginFailures :exec
+DELETE FROM login_failures
+WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < now());
@@ -0,0 +1,33 @@
+-- name: CreateOIDCAuthRequest :one
+INSERT INTO oidc_auth_requests (
+  hashed_state,
+  nonce,
+  code_verifier,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: UseOIDCAuthRequest :one
+DELETE FROM oidc_auth_requests
+WHERE hashed_state = $1 AND expired_at > now()
+RETURNING *;
+
+-- name: DeleteExpiredOIDCAuthRequests :exec
+DELETE FROM oidc_auth_requests
+WHERE expired_at <= now();
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (
+  issuer,
+  subject,
+  username
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = $1 AND subject = $2 LIMIT 1;
@@ -0,0 +1,23 @@
+-- name: CreatePasswordReset :one
+INSERT INTO password_resets (
+  username,
+  hashed_token,
+  expired_at
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: UsePasswordReset :one
+UPDATE password_resets
+SET is_used = true
+WHERE
+  hashed_token = $1
+  AND is_used = false
+  AND expired_at > now()
+RETURNING *;
+
+-- name: InvalidatePasswordResets :exec
+UPDATE password_resets
+SET is_used = true
+WHERE username = $1 AND is_used = false;
@@ -0,0 +1,22 @@
+-- name: HitRateLimit :one
+INSERT INTO rate_limits (
+  key,
+  hits
+) VALUES (
+  sqlc.arg(key), 1
+)
+ON CONFLICT (key) DO UPDATE
+SET
+  hits = CASE
+    WHEN rate_limits.window_started_at < sqlc.arg(window_start) THEN 1
+    ELSE rate_limits.hits + 1
+  END,
+  window_started_at = CASE
+    WHEN rate_limits.window_started_at < sqlc.arg(window_start) THEN now()
+    ELSE rate_limits.window_started_at
+  END
+RETURNING *;
+
+-- name: DeleteStaleRateLimits :exec
+DELETE FROM rate_limits
+WHERE window_started_at < $1;
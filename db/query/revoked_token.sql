@@ -0,0 +1,18 @@
+-- name: CreateRevokedToken :one
+INSERT INTO revoked_tokens (
+  id,
+  expires_at
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (id) DO UPDATE
+SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+RETURNING *;
+
+-- name: GetRevokedToken :one
+SELECT * FROM revoked_tokens
+WHERE id = $1 LIMIT 1;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < now();
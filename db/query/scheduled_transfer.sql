@@ -0,0 +1,103 @@
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  owner,
+  from_account_id,
+  to_account_id,
+  amount,
+  schedule,
+  start_at,
+  end_at,
+  next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1 LIMIT 1;
+
+-- name: ListScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE owner = sqlc.arg(owner)
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR
+    (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::bigint))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: ListScheduledTransfersReverse :many
+SELECT * FROM scheduled_transfers
+WHERE owner = sqlc.arg(owner)
+  AND (created_at, id) < (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: UpdateScheduledTransfer :one
+UPDATE scheduled_transfers
+SET
+  amount = COALESCE(sqlc.narg(amount), amount),
+  end_at = COALESCE(sqlc.narg(end_at), end_at),
+  status = COALESCE(sqlc.narg(status), status),
+  next_run_at = COALESCE(sqlc.narg(next_run_at), next_run_at),
+  retry_at = CASE WHEN sqlc.narg(status)::varchar IS NULL THEN retry_at ELSE NULL END,
+  failed_attempts = CASE WHEN sqlc.narg(status)::varchar IS NULL THEN failed_attempts ELSE 0 END,
+  updated_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ClaimDueScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE status = 'active'
+  AND next_run_at <= now()
+  AND (retry_at IS NULL OR retry_at <= now())
+ORDER BY next_run_at, id
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: AdvanceScheduledTransfer :one
+UPDATE scheduled_transfers
+SET
+  next_run_at = sqlc.arg(next_run_at),
+  retry_at = sqlc.narg(retry_at),
+  failed_attempts = sqlc.arg(failed_attempts),
+  status = sqlc.arg(status),
+  updated_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateScheduledTransferRun :one
+INSERT INTO scheduled_transfer_runs (
+  scheduled_transfer_id,
+  due_at,
+  attempt,
+  status,
+  transfer_id,
+  error
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: CreateSkippedScheduledTransferRuns :exec
+INSERT INTO scheduled_transfer_runs (
+  scheduled_transfer_id,
+  due_at,
+  attempt,
+  status
+)
+SELECT sqlc.arg(scheduled_transfer_id)::bigint, unnest(sqlc.arg(due_ats)::timestamptz[]), 0, 'skipped';
+
+-- name: ListScheduledTransferRuns :many
+SELECT * FROM scheduled_transfer_runs
+WHERE scheduled_transfer_id = sqlc.arg(scheduled_transfer_id)
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR
+    (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::bigint))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListScheduledTransferRunsReverse :many
+SELECT * FROM scheduled_transfer_runs
+WHERE scheduled_transfer_id = sqlc.arg(scheduled_transfer_id)
+  AND (created_at, id) > (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::bigint)
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,40 @@
+-- name: CreateSession :one
+INSERT INTO sessions (
+  id,
+  username,
+  refresh_token,
+  user_agent,
+  client_ip,
+  is_blocked,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE id = $1 LIMIT 1;
+
+-- name: ListSessions :many
+SELECT * FROM sessions
+WHERE username = $1 AND NOT is_blocked AND expires_at > now()
+ORDER BY last_seen_at DESC;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET
+  last_seen_at = now(),
+  client_ip = $2
+WHERE id = $1;
+
+-- name: BlockSession :one
+UPDATE sessions
+SET is_blocked = true
+WHERE id = $1 AND username = $2
+RETURNING *;
+
+-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE username = sqlc.arg(username) AND id <> sqlc.arg(except_id) AND NOT is_blocked;
@@ -14,9 +14,59 @@ WHERE id = $1 LIMIT 1;
 
 -- name: ListTransfers :many
 SELECT * FROM transfers
-WHERE 
-    from_account_id = $1 OR
-    to_account_id = $2
-ORDER BY id
-LIMIT $3
-OFFSET $4;
\ No newline at end of file
+WHERE
+  (from_account_id = sqlc.arg(from_account_id) OR to_account_id = sqlc.arg(to_account_id))
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR
+    (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::bigint))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: ListAccountTransfers :many
+SELECT * FROM transfers
+WHERE
+  (
+    (from_account_id = sqlc.arg(account_id) AND sqlc.narg(direction)::varchar IS DISTINCT FROM 'incoming') OR
+    (to_account_id = sqlc.arg(account_id) AND sqlc.narg(direction)::varchar IS DISTINCT FROM 'outgoing')
+  )
+  AND (sqlc.narg(counterparty_id)::bigint IS NULL OR from_account_id = sqlc.narg(counterparty_id) OR to_account_id = sqlc.narg(counterparty_id))
+  AND (sqlc.narg(min_amount)::bigint IS NULL OR
+    (CASE WHEN to_account_id = sqlc.arg(account_id) THEN COALESCE(to_amount, amount) ELSE amount END) >= sqlc.narg(min_amount))
+  AND (sqlc.narg(max_amount)::bigint IS NULL OR
+    (CASE WHEN to_account_id = sqlc.arg(account_id) THEN COALESCE(to_amount, amount) ELSE amount END) <= sqlc.narg(max_amount))
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL OR
+    (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::bigint))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListAccountTransfersReverse :many
+SELECT * FROM transfers
+WHERE
+  (
+    (from_account_id = sqlc.arg(account_id) AND sqlc.narg(direction)::varchar IS DISTINCT FROM 'incoming') OR
+    (to_account_id = sqlc.arg(account_id) AND sqlc.narg(direction)::varchar IS DISTINCT FROM 'outgoing')
+  )
+  AND (sqlc.narg(counterparty_id)::bigint IS NULL OR from_account_id = sqlc.narg(counterparty_id) OR to_account_id = sqlc.narg(counterparty_id))
+  AND (sqlc.narg(min_amount)::bigint IS NULL OR
+    (CASE WHEN to_account_id = sqlc.arg(account_id) THEN COALESCE(to_amount, amount) ELSE amount END) >= sqlc.narg(min_amount))
+  AND (sqlc.narg(max_amount)::bigint IS NULL OR
+    (CASE WHEN to_account_id = sqlc.arg(account_id) THEN COALESCE(to_amount, amount) ELSE amount END) <= sqlc.narg(max_amount))
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+  AND (created_at, id) > (sqlc.arg(cursor_created_at)::timestamptz, sqlc.arg(cursor_id)::bigint)
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: CreateFXTransfer :one
+INSERT INTO transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  to_amount,
+  fx_rate,
+  fx_quote_id
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
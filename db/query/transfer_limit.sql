@@ -0,0 +1,54 @@
+-- name: GetTransferLimits :one
+SELECT
+  accounts.id AS account_id,
+  users.tier,
+  accounts.currency,
+  COALESCE(account_transfer_limits.max_per_transaction, tier_transfer_limits.max_per_transaction) AS max_per_transaction,
+  COALESCE(account_transfer_limits.max_daily_amount, tier_transfer_limits.max_daily_amount) AS max_daily_amount,
+  COALESCE(account_transfer_limits.max_daily_count, tier_transfer_limits.max_daily_count) AS max_daily_count,
+  (
+    SELECT COALESCE(SUM(amount), 0) FROM transfers
+    WHERE from_account_id = accounts.id AND created_at >= date_trunc('day', now(), 'UTC')
+  )::bigint AS daily_amount,
+  (
+    SELECT COUNT(*) FROM transfers
+    WHERE from_account_id = accounts.id AND created_at >= date_trunc('day', now(), 'UTC')
+  )::integer AS daily_count
+FROM accounts
+JOIN users ON users.username = accounts.owner
+LEFT JOIN account_transfer_limits ON account_transfer_limits.account_id = accounts.id
+LEFT JOIN tier_transfer_limits ON tier_transfer_limits.tier = users.tier AND tier_transfer_limits.currency = accounts.currency
+WHERE accounts.id = sqlc.arg(account_id);
+
+-- name: SetAccountTransferLimits :one
+INSERT INTO account_transfer_limits (
+  account_id,
+  max_per_transaction,
+  max_daily_amount,
+  max_daily_count
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (account_id) DO UPDATE SET
+  max_per_transaction = EXCLUDED.max_per_transaction,
+  max_daily_amount = EXCLUDED.max_daily_amount,
+  max_daily_count = EXCLUDED.max_daily_count,
+  updated_at = now()
+RETURNING *;
+
+-- name: SetTierTransferLimits :one
+INSERT INTO tier_transfer_limits (
+  tier,
+  currency,
+  max_per_transaction,
+  max_daily_amount,
+  max_daily_count
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+ON CONFLICT (tier, currency) DO UPDATE SET
+  max_per_transaction = EXCLUDED.max_per_transaction,
+  max_daily_amount = EXCLUDED.max_daily_amount,
+  max_daily_count = EXCLUDED.max_daily_count,
+  updated_at = now()
+RETURNING *;
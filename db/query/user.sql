@@ -11,4 +11,85 @@ RETURNING *;
 
 -- name: GetUser :one
 SELECT * FROM users
-WHERE username = $1 LIMIT 1;
\ No newline at end of file
+WHERE username = $1 LIMIT 1;
+
+-- name: UpdateUserFrozen :one
+UPDATE users
+SET is_frozen = $2
+WHERE username = $1
+RETURNING *;
+
+-- name: UpdateUserTOTPSecret :one
+UPDATE users
+SET
+  totp_secret = $2,
+  is_totp_enabled = false,
+  hashed_recovery_codes = '{}'
+WHERE username = $1 AND NOT is_totp_enabled
+RETURNING *;
+
+-- name: EnableUserTOTP :one
+UPDATE users
+SET
+  is_totp_enabled = true,
+  totp_last_step = $2,
+  hashed_recovery_codes = $3
+WHERE username = $1 AND totp_secret IS NOT NULL
+RETURNING *;
+
+-- name: UseUserTOTPStep :one
+UPDATE users
+SET totp_last_step = sqlc.arg(step)
+WHERE username = sqlc.arg(username) AND totp_last_step < sqlc.arg(step)
+RETURNING *;
+
+-- name: UseUserRecoveryCode :one
+UPDATE users
+SET hashed_recovery_codes = array_remove(hashed_recovery_codes, sqlc.arg(hashed_code)::varchar)
+WHERE username = sqlc.arg(username) AND sqlc.arg(hashed_code)::varchar = ANY(hashed_recovery_codes)
+RETURNING *;
+
+-- name: UpdateUserEmailVerified :one
+UPDATE users
+SET is_email_verified = true
+WHERE username = $1 AND email = $2
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1 LIMIT 1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET
+  hashed_password = $2,
+  password_changed_at = $3
+WHERE username = $1
+RETURNING *;
+
+-- name: GetUserAuth :one
+SELECT password_changed_at, is_frozen, role FROM users
+WHERE username = $1 LIMIT 1;
+
+-- name: UpdateUser :one
+UPDATE users
+SET
+  full_name = COALESCE(sqlc.narg(full_name), full_name),
+  email = COALESCE(sqlc.narg(email), email),
+  is_email_verified = CASE
+    WHEN sqlc.narg(email)::varchar IS NULL OR sqlc.narg(email)::varchar = email THEN is_email_verified
+    ELSE false
+  END
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = sqlc.arg(new_hashed_password)
+WHERE username = sqlc.arg(username) AND hashed_password = sqlc.arg(old_hashed_password);
+
+-- name: UpdateUserTier :one
+UPDATE users
+SET tier = $2
+WHERE username = $1
+RETURNING *;
@@ -0,0 +1,20 @@
+-- name: CreateVerifyEmail :one
+INSERT INTO verify_emails (
+  username,
+  email,
+  hashed_secret_code,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: UseVerifyEmail :one
+UPDATE verify_emails
+SET is_used = true
+WHERE
+  id = $1
+  AND hashed_secret_code = $2
+  AND is_used = false
+  AND expired_at > now()
+RETURNING *;
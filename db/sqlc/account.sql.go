@@ -7,13 +7,15 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen
 `
 
 type AddAccountBalanceParams struct {
@@ -30,6 +32,7 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
 	)
 	return i, err
 }
@@ -42,7 +45,7 @@ INSERT INTO accounts (
 ) VALUES (
   $1, $2, $3
 )
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen
 `
 
 type CreateAccountParams struct {
@@ -60,6 +63,7 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
 	)
 	return i, err
 }
@@ -74,7 +78,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, is_frozen FROM accounts
 WHERE id = $1 LIMIT 1
 `
 
@@ -87,12 +91,13 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, is_frozen FROM accounts
 WHERE id = $1 LIMIT 1
 FOR NO KEY UPDATE
 `
@@ -106,26 +111,34 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
 	)
 	return i, err
 }
 
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, is_frozen FROM accounts
 WHERE owner = $1
-ORDER BY id
-LIMIT $2
-OFFSET $3
+  AND ($2::timestamptz IS NULL OR
+    (created_at, id) > ($2, $3::bigint))
+ORDER BY created_at, id
+LIMIT $4
 `
 
 type ListAccountsParams struct {
-	Owner  string `json:"owner"`
-	Limit  int32  `json:"limit"`
-	Offset int32  `json:"offset"`
+	Owner           string        `json:"owner"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        sql.NullInt64 `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
 }
 
 func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error) {
-	rows, err := q.db.QueryContext(ctx, listAccounts, arg.Owner, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listAccounts,
+		arg.Owner,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -139,6 +152,57 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.IsFrozen,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsReverse = `-- name: ListAccountsReverse :many
+SELECT id, owner, balance, currency, created_at, is_frozen FROM accounts
+WHERE owner = $1
+  AND (created_at, id) < ($2::timestamptz, $3::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListAccountsReverseParams struct {
+	Owner           string    `json:"owner"`
+	CursorCreatedAt time.Time `json:"cursor_created_at"`
+	CursorID        int64     `json:"cursor_id"`
+	Limit           int32     `json:"limit"`
+}
+
+func (q *Queries) ListAccountsReverse(ctx context.Context, arg ListAccountsReverseParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountsReverse,
+		arg.Owner,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.IsFrozen,
 		); err != nil {
 			return nil, err
 		}
@@ -157,7 +221,7 @@ const updateAccount = `-- name: UpdateAccount :one
 UPDATE accounts
 SET balance = $2
 WHERE id = $1
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen
 `
 
 type UpdateAccountParams struct {
@@ -174,6 +238,33 @@ func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+	)
+	return i, err
+}
+
+const updateAccountFrozen = `-- name: UpdateAccountFrozen :one
+UPDATE accounts
+SET is_frozen = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, is_frozen
+`
+
+type UpdateAccountFrozenParams struct {
+	ID       int64 `json:"id"`
+	IsFrozen bool  `json:"is_frozen"`
+}
+
+func (q *Queries) UpdateAccountFrozen(ctx context.Context, arg UpdateAccountFrozenParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountFrozen, arg.ID, arg.IsFrozen)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.IsFrozen,
 	)
 	return i, err
 }
@@ -7,14 +7,19 @@ import (
 	"time"
 
 	"github.com/amrizal94/simplebank/util"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/require"
 )
 
 func createRandomAccount(t *testing.T) Account {
+	return createRandomAccountWithBalance(t, util.RandomMoney())
+}
+
+func createRandomAccountWithBalance(t *testing.T, balance int64) Account {
 	user := createRandomUser(t)
 	arg := CreateAccountParams{
 		Owner:    user.Username,
-		Balance:  util.RandomMoney(),
+		Balance:  balance,
 		Currency: util.RandomCurrecy(),
 	}
 
@@ -86,9 +91,8 @@ func TestListAccounts(t *testing.T) {
 	}
 
 	arg := ListAccountsParams{
-		Owner:  lastAccount.Owner,
-		Limit:  5,
-		Offset: 0,
+		Owner: lastAccount.Owner,
+		Limit: 5,
 	}
 
 	accounts, err := testQueries.ListAccounts(context.Background(), arg)
@@ -99,4 +103,47 @@ func TestListAccounts(t *testing.T) {
 		require.NotEmpty(t, account)
 		require.Equal(t, lastAccount.Owner, account.Owner)
 	}
+
+	// seeking past the last account of the owner
+	last := accounts[len(accounts)-1]
+	arg.CursorCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+	arg.CursorID = sql.NullInt64{Int64: last.ID, Valid: true}
+	accounts, err = testQueries.ListAccounts(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+
+	reversed, err := testQueries.ListAccountsReverse(context.Background(), ListAccountsReverseParams{
+		Owner:           lastAccount.Owner,
+		CursorCreatedAt: last.CreatedAt,
+		CursorID:        last.ID + 1,
+		Limit:           5,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, reversed)
+	require.Equal(t, last.ID, reversed[0].ID)
+}
+
+func TestAccountBalanceNonNegative(t *testing.T) {
+	account := createRandomAccount(t)
+
+	_, err := testQueries.AddAccountBalance(context.Background(), AddAccountBalanceParams{
+		ID:     account.ID,
+		Amount: -account.Balance - 1,
+	})
+	require.Error(t, err)
+	require.Equal(t, accountBalanceConstraint, err.(*pq.Error).Constraint)
+}
+
+func TestUpdateAccountFrozen(t *testing.T) {
+	account1 := createRandomAccount(t)
+	require.False(t, account1.IsFrozen)
+
+	account2, err := testQueries.UpdateAccountFrozen(context.Background(), UpdateAccountFrozenParams{
+		ID:       account1.ID,
+		IsFrozen: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, account2.ID)
+	require.Equal(t, account1.Balance, account2.Balance)
+	require.True(t, account2.IsFrozen)
 }
@@ -0,0 +1,204 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: api_key.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createAPIKey = `-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+  id,
+  prefix,
+  hashed_key,
+  owner,
+  scopes,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING id, prefix, hashed_key, owner, scopes, last_used_at, expires_at, revoked_at, created_at
+`
+
+type CreateAPIKeyParams struct {
+	ID        uuid.UUID    `json:"id"`
+	Prefix    string       `json:"prefix"`
+	HashedKey string       `json:"hashed_key"`
+	Owner     string       `json:"owner"`
+	Scopes    []string     `json:"scopes"`
+	ExpiresAt sql.NullTime `json:"expires_at"`
+}
+
+func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createAPIKey,
+		arg.ID,
+		arg.Prefix,
+		arg.HashedKey,
+		arg.Owner,
+		pq.Array(arg.Scopes),
+		arg.ExpiresAt,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Prefix,
+		&i.HashedKey,
+		&i.Owner,
+		pq.Array(&i.Scopes),
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAPIKeyByPrefix = `-- name: GetAPIKeyByPrefix :one
+SELECT api_keys.id, api_keys.prefix, api_keys.hashed_key, api_keys.owner, api_keys.scopes, api_keys.last_used_at, api_keys.expires_at, api_keys.revoked_at, api_keys.created_at, users.role, users.is_frozen FROM api_keys
+JOIN users ON users.username = api_keys.owner
+WHERE api_keys.prefix = $1 LIMIT 1
+`
+
+type GetAPIKeyByPrefixRow struct {
+	ID         uuid.UUID    `json:"id"`
+	Prefix     string       `json:"prefix"`
+	HashedKey  string       `json:"hashed_key"`
+	Owner      string       `json:"owner"`
+	Scopes     []string     `json:"scopes"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Role       string       `json:"role"`
+	IsFrozen   bool         `json:"is_frozen"`
+}
+
+func (q *Queries) GetAPIKeyByPrefix(ctx context.Context, prefix string) (GetAPIKeyByPrefixRow, error) {
+	row := q.db.QueryRowContext(ctx, getAPIKeyByPrefix, prefix)
+	var i GetAPIKeyByPrefixRow
+	err := row.Scan(
+		&i.ID,
+		&i.Prefix,
+		&i.HashedKey,
+		&i.Owner,
+		pq.Array(&i.Scopes),
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+	)
+	return i, err
+}
+
+const listAPIKeys = `-- name: ListAPIKeys :many
+SELECT id, prefix, hashed_key, owner, scopes, last_used_at, expires_at, revoked_at, created_at FROM api_keys
+WHERE owner = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListAPIKeys(ctx context.Context, owner string) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, listAPIKeys, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ApiKey{}
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.Prefix,
+			&i.HashedKey,
+			&i.Owner,
+			pq.Array(&i.Scopes),
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :one
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = $1 AND owner = $2 AND revoked_at IS NULL
+RETURNING id, prefix, hashed_key, owner, scopes, last_used_at, expires_at, revoked_at, created_at
+`
+
+type RevokeAPIKeyParams struct {
+	ID    uuid.UUID `json:"id"`
+	Owner string    `json:"owner"`
+}
+
+func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, revokeAPIKey, arg.ID, arg.Owner)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Prefix,
+		&i.HashedKey,
+		&i.Owner,
+		pq.Array(&i.Scopes),
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const revokeAPIKeyByID = `-- name: RevokeAPIKeyByID :one
+UPDATE api_keys
+SET revoked_at = COALESCE(revoked_at, now())
+WHERE id = $1
+RETURNING id, prefix, hashed_key, owner, scopes, last_used_at, expires_at, revoked_at, created_at
+`
+
+func (q *Queries) RevokeAPIKeyByID(ctx context.Context, id uuid.UUID) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, revokeAPIKeyByID, id)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Prefix,
+		&i.HashedKey,
+		&i.Owner,
+		pq.Array(&i.Scopes),
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateAPIKeyLastUsed = `-- name: UpdateAPIKeyLastUsed :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) UpdateAPIKeyLastUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, updateAPIKeyLastUsed, id)
+	return err
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomAPIKey(t *testing.T, user User) ApiKey {
+	prefix, key, err := util.GenerateAPIKey()
+	require.NoError(t, err)
+
+	arg := CreateAPIKeyParams{
+		ID:        uuid.New(),
+		Prefix:    prefix,
+		HashedKey: util.HashAPIKey(key),
+		Owner:     user.Username,
+		Scopes:    util.AllScopes(),
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	apiKey, err := testQueries.CreateAPIKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, apiKey)
+
+	require.Equal(t, arg.ID, apiKey.ID)
+	require.Equal(t, arg.Prefix, apiKey.Prefix)
+	require.Equal(t, arg.HashedKey, apiKey.HashedKey)
+	require.Equal(t, arg.Owner, apiKey.Owner)
+	require.Equal(t, arg.Scopes, apiKey.Scopes)
+	require.WithinDuration(t, arg.ExpiresAt.Time, apiKey.ExpiresAt.Time, time.Second)
+	require.False(t, apiKey.LastUsedAt.Valid)
+	require.False(t, apiKey.RevokedAt.Valid)
+	require.NotZero(t, apiKey.CreatedAt)
+
+	return apiKey
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	createRandomAPIKey(t, createRandomUser(t))
+}
+
+func TestGetAPIKeyByPrefix(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey := createRandomAPIKey(t, user)
+
+	row, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, apiKey.ID, row.ID)
+	require.Equal(t, apiKey.HashedKey, row.HashedKey)
+	require.Equal(t, user.Role, row.Role)
+	require.Equal(t, user.IsFrozen, row.IsFrozen)
+}
+
+func TestListAPIKeys(t *testing.T) {
+	user := createRandomUser(t)
+	for i := 0; i < 3; i++ {
+		createRandomAPIKey(t, user)
+	}
+
+	apiKeys, err := testQueries.ListAPIKeys(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, apiKeys, 3)
+
+	for _, apiKey := range apiKeys {
+		require.Equal(t, user.Username, apiKey.Owner)
+	}
+}
+
+func TestUpdateAPIKeyLastUsed(t *testing.T) {
+	apiKey1 := createRandomAPIKey(t, createRandomUser(t))
+
+	err := testQueries.UpdateAPIKeyLastUsed(context.Background(), apiKey1.ID)
+	require.NoError(t, err)
+
+	apiKey2, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey1.Prefix)
+	require.NoError(t, err)
+	require.True(t, apiKey2.LastUsedAt.Valid)
+	require.WithinDuration(t, time.Now(), apiKey2.LastUsedAt.Time, time.Second)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey1 := createRandomAPIKey(t, user)
+
+	_, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{
+		ID:    apiKey1.ID,
+		Owner: createRandomUser(t).Username,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	apiKey2, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{
+		ID:    apiKey1.ID,
+		Owner: user.Username,
+	})
+	require.NoError(t, err)
+	require.True(t, apiKey2.RevokedAt.Valid)
+
+	_, err = testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{
+		ID:    apiKey1.ID,
+		Owner: user.Username,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestRevokeAPIKeyByID(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey1 := createRandomAPIKey(t, user)
+
+	apiKey2, err := testQueries.RevokeAPIKeyByID(context.Background(), apiKey1.ID)
+	require.NoError(t, err)
+	require.True(t, apiKey2.RevokedAt.Valid)
+
+	// revoking again keeps the time it was first revoked at
+	apiKey3, err := testQueries.RevokeAPIKeyByID(context.Background(), apiKey1.ID)
+	require.NoError(t, err)
+	require.Equal(t, apiKey2.RevokedAt, apiKey3.RevokedAt)
+
+	_, err = testQueries.RevokeAPIKeyByID(context.Background(), uuid.New())
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
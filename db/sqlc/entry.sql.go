@@ -7,37 +7,65 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const createEntry = `-- name: CreateEntry :one
 INSERT INTO entries (
   accounts_id,
-  amount 
+  amount,
+  transfer_id
 ) VALUES (
-  $1, $2
+  $1, $2, $3
 )
-RETURNING id, accounts_id, amount, created_at
+RETURNING id, accounts_id, amount, created_at, transfer_id
 `
 
 type CreateEntryParams struct {
-	AccountsID int64 `json:"accounts_id"`
-	Amount     int64 `json:"amount"`
+	AccountsID int64         `json:"accounts_id"`
+	Amount     int64         `json:"amount"`
+	TransferID sql.NullInt64 `json:"transfer_id"`
 }
 
 func (q *Queries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error) {
-	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountsID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountsID, arg.Amount, arg.TransferID)
 	var i Entry
 	err := row.Scan(
 		&i.ID,
 		&i.AccountsID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.TransferID,
 	)
 	return i, err
 }
 
+const getAccountBalanceAfterEntry = `-- name: GetAccountBalanceAfterEntry :one
+SELECT (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS balance
+FROM accounts a
+LEFT JOIN entries e ON e.accounts_id = a.id
+  AND (e.created_at, e.id) > ($1::timestamptz, $2::bigint)
+WHERE a.id = $3
+GROUP BY a.id
+`
+
+type GetAccountBalanceAfterEntryParams struct {
+	EntryCreatedAt time.Time `json:"entry_created_at"`
+	EntryID        int64     `json:"entry_id"`
+	AccountID      int64     `json:"account_id"`
+}
+
+// the balance an entry left is the current one without the entries made since
+func (q *Queries) GetAccountBalanceAfterEntry(ctx context.Context, arg GetAccountBalanceAfterEntryParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getAccountBalanceAfterEntry, arg.EntryCreatedAt, arg.EntryID, arg.AccountID)
+	var balance int64
+	err := row.Scan(&balance)
+	return balance, err
+}
+
 const getEntry = `-- name: GetEntry :one
-SELECT id, accounts_id, amount, created_at FROM entries
+SELECT id, accounts_id, amount, created_at, transfer_id FROM entries
 WHERE id = $1 LIMIT 1
 `
 
@@ -49,26 +77,62 @@ func (q *Queries) GetEntry(ctx context.Context, id int64) (Entry, error) {
 		&i.AccountsID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.TransferID,
 	)
 	return i, err
 }
 
+const getStatementBalances = `-- name: GetStatementBalances :one
+SELECT
+  (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS opening_balance,
+  (a.balance - COALESCE(SUM(e.amount) FILTER (WHERE e.created_at >= $1), 0))::bigint AS closing_balance
+FROM accounts a
+LEFT JOIN entries e ON e.accounts_id = a.id AND e.created_at >= $2
+WHERE a.id = $3
+GROUP BY a.id
+`
+
+type GetStatementBalancesParams struct {
+	ToTime    time.Time `json:"to_time"`
+	FromTime  time.Time `json:"from_time"`
+	AccountID int64     `json:"account_id"`
+}
+
+type GetStatementBalancesRow struct {
+	OpeningBalance int64 `json:"opening_balance"`
+	ClosingBalance int64 `json:"closing_balance"`
+}
+
+func (q *Queries) GetStatementBalances(ctx context.Context, arg GetStatementBalancesParams) (GetStatementBalancesRow, error) {
+	row := q.db.QueryRowContext(ctx, getStatementBalances, arg.ToTime, arg.FromTime, arg.AccountID)
+	var i GetStatementBalancesRow
+	err := row.Scan(&i.OpeningBalance, &i.ClosingBalance)
+	return i, err
+}
+
 const listEntries = `-- name: ListEntries :many
-SELECT id, accounts_id, amount, created_at FROM entries
+SELECT id, accounts_id, amount, created_at, transfer_id FROM entries
 WHERE accounts_id = $1
-ORDER BY id
-LIMIT $2
-OFFSET $3
+  AND ($2::timestamptz IS NULL OR
+    (created_at, id) > ($2, $3::bigint))
+ORDER BY created_at, id
+LIMIT $4
 `
 
 type ListEntriesParams struct {
-	AccountsID int64 `json:"accounts_id"`
-	Limit      int32 `json:"limit"`
-	Offset     int32 `json:"offset"`
+	AccountsID      int64         `json:"accounts_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        sql.NullInt64 `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
 }
 
 func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error) {
-	rows, err := q.db.QueryContext(ctx, listEntries, arg.AccountsID, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listEntries,
+		arg.AccountsID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +145,146 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 			&i.AccountsID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.TransferID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStatementEntries = `-- name: ListStatementEntries :many
+SELECT
+  e.id, e.accounts_id, e.amount, e.created_at, e.transfer_id,
+  COALESCE(CASE WHEN t.from_account_id = e.accounts_id THEN t.to_account_id ELSE t.from_account_id END, 0)::bigint AS counterparty_account_id
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+WHERE e.accounts_id = $1
+  AND e.created_at >= $2
+  AND e.created_at < $3
+  AND ($4::timestamptz IS NULL OR
+    (e.created_at, e.id) > ($4, $5::bigint))
+ORDER BY e.created_at, e.id
+LIMIT $6
+`
+
+type ListStatementEntriesParams struct {
+	AccountID       int64         `json:"account_id"`
+	FromTime        time.Time     `json:"from_time"`
+	ToTime          time.Time     `json:"to_time"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        sql.NullInt64 `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+type ListStatementEntriesRow struct {
+	ID                    int64         `json:"id"`
+	AccountsID            int64         `json:"accounts_id"`
+	Amount                int64         `json:"amount"`
+	CreatedAt             time.Time     `json:"created_at"`
+	TransferID            sql.NullInt64 `json:"transfer_id"`
+	CounterpartyAccountID int64         `json:"counterparty_account_id"`
+}
+
+func (q *Queries) ListStatementEntries(ctx context.Context, arg ListStatementEntriesParams) ([]ListStatementEntriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listStatementEntries,
+		arg.AccountID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStatementEntriesRow{}
+	for rows.Next() {
+		var i ListStatementEntriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountsID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.TransferID,
+			&i.CounterpartyAccountID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStatementEntriesReverse = `-- name: ListStatementEntriesReverse :many
+SELECT
+  e.id, e.accounts_id, e.amount, e.created_at, e.transfer_id,
+  COALESCE(CASE WHEN t.from_account_id = e.accounts_id THEN t.to_account_id ELSE t.from_account_id END, 0)::bigint AS counterparty_account_id
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+WHERE e.accounts_id = $1
+  AND e.created_at >= $2
+  AND e.created_at < $3
+  AND (e.created_at, e.id) < ($4::timestamptz, $5::bigint)
+ORDER BY e.created_at DESC, e.id DESC
+LIMIT $6
+`
+
+type ListStatementEntriesReverseParams struct {
+	AccountID       int64     `json:"account_id"`
+	FromTime        time.Time `json:"from_time"`
+	ToTime          time.Time `json:"to_time"`
+	CursorCreatedAt time.Time `json:"cursor_created_at"`
+	CursorID        int64     `json:"cursor_id"`
+	Limit           int32     `json:"limit"`
+}
+
+type ListStatementEntriesReverseRow struct {
+	ID                    int64         `json:"id"`
+	AccountsID            int64         `json:"accounts_id"`
+	Amount                int64         `json:"amount"`
+	CreatedAt             time.Time     `json:"created_at"`
+	TransferID            sql.NullInt64 `json:"transfer_id"`
+	CounterpartyAccountID int64         `json:"counterparty_account_id"`
+}
+
+func (q *Queries) ListStatementEntriesReverse(ctx context.Context, arg ListStatementEntriesReverseParams) ([]ListStatementEntriesReverseRow, error) {
+	rows, err := q.db.QueryContext(ctx, listStatementEntriesReverse,
+		arg.AccountID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStatementEntriesReverseRow{}
+	for rows.Next() {
+		var i ListStatementEntriesReverseRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountsID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.TransferID,
+			&i.CounterpartyAccountID,
 		); err != nil {
 			return nil, err
 		}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -55,9 +56,16 @@ func TestListEntries(t *testing.T) {
 	arg := ListEntriesParams{
 		AccountsID: account.ID,
 		Limit:      5,
-		Offset:     5,
 	}
 
+	firstPage, err := testQueries.ListEntries(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, firstPage, 5)
+
+	last := firstPage[len(firstPage)-1]
+	arg.CursorCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+	arg.CursorID = sql.NullInt64{Int64: last.ID, Valid: true}
+
 	entries, err := testQueries.ListEntries(context.Background(), arg)
 	require.NoError(t, err)
 	require.Len(t, entries, 5)
@@ -65,5 +73,6 @@ func TestListEntries(t *testing.T) {
 	for _, entry := range entries {
 		require.NotEmpty(t, entry)
 		require.Equal(t, arg.AccountsID, entry.AccountsID)
+		require.Greater(t, entry.ID, last.ID)
 	}
 }
@@ -0,0 +1,167 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: fx.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFXQuote = `-- name: CreateFXQuote :one
+INSERT INTO fx_quotes (
+  id,
+  username,
+  from_currency,
+  to_currency,
+  rate,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING id, username, from_currency, to_currency, rate, is_used, created_at, expired_at
+`
+
+type CreateFXQuoteParams struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	Rate         string    `json:"rate"`
+	ExpiredAt    time.Time `json:"expired_at"`
+}
+
+func (q *Queries) CreateFXQuote(ctx context.Context, arg CreateFXQuoteParams) (FxQuote, error) {
+	row := q.db.QueryRowContext(ctx, createFXQuote,
+		arg.ID,
+		arg.Username,
+		arg.FromCurrency,
+		arg.ToCurrency,
+		arg.Rate,
+		arg.ExpiredAt,
+	)
+	var i FxQuote
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.IsUsed,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
+
+const createFXRate = `-- name: CreateFXRate :one
+INSERT INTO fx_rates (
+  from_currency,
+  to_currency,
+  rate
+) VALUES (
+  $1, $2, $3
+)
+RETURNING id, from_currency, to_currency, rate, created_at
+`
+
+type CreateFXRateParams struct {
+	FromCurrency string `json:"from_currency"`
+	ToCurrency   string `json:"to_currency"`
+	Rate         string `json:"rate"`
+}
+
+func (q *Queries) CreateFXRate(ctx context.Context, arg CreateFXRateParams) (FxRate, error) {
+	row := q.db.QueryRowContext(ctx, createFXRate, arg.FromCurrency, arg.ToCurrency, arg.Rate)
+	var i FxRate
+	err := row.Scan(
+		&i.ID,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getFXQuote = `-- name: GetFXQuote :one
+SELECT id, username, from_currency, to_currency, rate, is_used, created_at, expired_at FROM fx_quotes
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetFXQuote(ctx context.Context, id uuid.UUID) (FxQuote, error) {
+	row := q.db.QueryRowContext(ctx, getFXQuote, id)
+	var i FxQuote
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.IsUsed,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
+
+const getLatestFXRate = `-- name: GetLatestFXRate :one
+SELECT id, from_currency, to_currency, rate, created_at FROM fx_rates
+WHERE from_currency = $1 AND to_currency = $2
+ORDER BY created_at DESC, id DESC
+LIMIT 1
+`
+
+type GetLatestFXRateParams struct {
+	FromCurrency string `json:"from_currency"`
+	ToCurrency   string `json:"to_currency"`
+}
+
+func (q *Queries) GetLatestFXRate(ctx context.Context, arg GetLatestFXRateParams) (FxRate, error) {
+	row := q.db.QueryRowContext(ctx, getLatestFXRate, arg.FromCurrency, arg.ToCurrency)
+	var i FxRate
+	err := row.Scan(
+		&i.ID,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useFXQuote = `-- name: UseFXQuote :one
+UPDATE fx_quotes
+SET is_used = true
+WHERE
+  id = $1
+  AND username = $2
+  AND is_used = false
+  AND expired_at > now()
+RETURNING id, username, from_currency, to_currency, rate, is_used, created_at, expired_at
+`
+
+type UseFXQuoteParams struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+func (q *Queries) UseFXQuote(ctx context.Context, arg UseFXQuoteParams) (FxQuote, error) {
+	row := q.db.QueryRowContext(ctx, useFXQuote, arg.ID, arg.Username)
+	var i FxQuote
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.IsUsed,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomFXQuote(t *testing.T, username string, from string, to string, expiredAt time.Time) FxQuote {
+	arg := CreateFXQuoteParams{
+		ID:           uuid.New(),
+		Username:     username,
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         "1.250000000000",
+		ExpiredAt:    expiredAt,
+	}
+
+	quote, err := testQueries.CreateFXQuote(context.Background(), arg)
+	require.NoError(t, err)
+
+	require.Equal(t, arg.ID, quote.ID)
+	require.Equal(t, arg.Username, quote.Username)
+	require.Equal(t, arg.FromCurrency, quote.FromCurrency)
+	require.Equal(t, arg.ToCurrency, quote.ToCurrency)
+	require.Equal(t, arg.Rate, quote.Rate)
+	require.False(t, quote.IsUsed)
+	require.WithinDuration(t, arg.ExpiredAt, quote.ExpiredAt, time.Second)
+	require.NotZero(t, quote.CreatedAt)
+
+	return quote
+}
+
+func TestGetLatestFXRate(t *testing.T) {
+	arg := CreateFXRateParams{
+		FromCurrency: util.EUR,
+		ToCurrency:   util.CAD,
+		Rate:         "1.480000000000",
+	}
+	_, err := testQueries.CreateFXRate(context.Background(), arg)
+	require.NoError(t, err)
+
+	arg.Rate = "1.490000000000"
+	rate2, err := testQueries.CreateFXRate(context.Background(), arg)
+	require.NoError(t, err)
+
+	latest, err := testQueries.GetLatestFXRate(context.Background(), GetLatestFXRateParams{
+		FromCurrency: util.EUR,
+		ToCurrency:   util.CAD,
+	})
+	require.NoError(t, err)
+	require.Equal(t, rate2, latest)
+
+	_, err = testQueries.CreateFXRate(context.Background(), CreateFXRateParams{
+		FromCurrency: util.EUR,
+		ToCurrency:   util.CAD,
+		Rate:         "0",
+	})
+	require.Error(t, err)
+}
+
+func TestUseFXQuote(t *testing.T) {
+	user := createRandomUser(t)
+	quote := createRandomFXQuote(t, user.Username, util.USD, util.EUR, time.Now().Add(time.Minute))
+
+	// only the user of the quote can use it
+	_, err := testQueries.UseFXQuote(context.Background(), UseFXQuoteParams{
+		ID:       quote.ID,
+		Username: util.RandomOwner(),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	used, err := testQueries.UseFXQuote(context.Background(), UseFXQuoteParams{
+		ID:       quote.ID,
+		Username: user.Username,
+	})
+	require.NoError(t, err)
+	require.True(t, used.IsUsed)
+
+	// a quote is only good once
+	_, err = testQueries.UseFXQuote(context.Background(), UseFXQuoteParams{
+		ID:       quote.ID,
+		Username: user.Username,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestUseFXQuoteExpired(t *testing.T) {
+	user := createRandomUser(t)
+	quote := createRandomFXQuote(t, user.Username, util.USD, util.EUR, time.Now().Add(-time.Minute))
+
+	_, err := testQueries.UseFXQuote(context.Background(), UseFXQuoteParams{
+		ID:       quote.ID,
+		Username: user.Username,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,109 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: idempotency_key.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const claimIdempotencyKey = `-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (
+  username,
+  key,
+  request_hash,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (username, key) DO UPDATE
+SET
+  request_hash = EXCLUDED.request_hash,
+  response = '{}',
+  created_at = now(),
+  expired_at = EXCLUDED.expired_at
+WHERE idempotency_keys.expired_at <= now()
+RETURNING username, key, request_hash, response, created_at, expired_at
+`
+
+type ClaimIdempotencyKeyParams struct {
+	Username    string    `json:"username"`
+	Key         string    `json:"key"`
+	RequestHash string    `json:"request_hash"`
+	ExpiredAt   time.Time `json:"expired_at"`
+}
+
+func (q *Queries) ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, claimIdempotencyKey,
+		arg.Username,
+		arg.Key,
+		arg.RequestHash,
+		arg.ExpiredAt,
+	)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Username,
+		&i.Key,
+		&i.RequestHash,
+		&i.Response,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT username, key, request_hash, response, created_at, expired_at FROM idempotency_keys
+WHERE username = $1 AND key = $2 AND expired_at > now()
+LIMIT 1
+`
+
+type GetIdempotencyKeyParams struct {
+	Username string `json:"username"`
+	Key      string `json:"key"`
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, getIdempotencyKey, arg.Username, arg.Key)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Username,
+		&i.Key,
+		&i.RequestHash,
+		&i.Response,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
+
+const setIdempotencyKeyResponse = `-- name: SetIdempotencyKeyResponse :one
+UPDATE idempotency_keys
+SET response = $3
+WHERE username = $1 AND key = $2
+RETURNING username, key, request_hash, response, created_at, expired_at
+`
+
+type SetIdempotencyKeyResponseParams struct {
+	Username string          `json:"username"`
+	Key      string          `json:"key"`
+	Response json.RawMessage `json:"response"`
+}
+
+func (q *Queries) SetIdempotencyKeyResponse(ctx context.Context, arg SetIdempotencyKeyResponseParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, setIdempotencyKeyResponse, arg.Username, arg.Key, arg.Response)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Username,
+		&i.Key,
+		&i.RequestHash,
+		&i.Response,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
@@ -0,0 +1,117 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: login_failure.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const deleteLoginFailures = `-- name: DeleteLoginFailures :exec
+DELETE FROM login_failures
+WHERE key = $1
+`
+
+func (q *Queries) DeleteLoginFailures(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, deleteLoginFailures, key)
+	return err
+}
+
+const deleteStaleLoginFailures = `-- name: DeleteStaleLoginFailures :exec
+DELETE FROM login_failures
+WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < now())
+`
+
+func (q *Queries) DeleteStaleLoginFailures(ctx context.Context, lastFailedAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteStaleLoginFailures, lastFailedAt)
+	return err
+}
+
+const listLoginLocks = `-- name: ListLoginLocks :many
+SELECT key, failed_count, locked_until, last_failed_at FROM login_failures
+WHERE key = ANY($1::varchar[]) AND locked_until > now()
+`
+
+func (q *Queries) ListLoginLocks(ctx context.Context, keys []string) ([]LoginFailure, error) {
+	rows, err := q.db.QueryContext(ctx, listLoginLocks, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LoginFailure{}
+	for rows.Next() {
+		var i LoginFailure
+		if err := rows.Scan(
+			&i.Key,
+			&i.FailedCount,
+			&i.LockedUntil,
+			&i.LastFailedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockLoginKey = `-- name: LockLoginKey :exec
+UPDATE login_failures
+SET locked_until = $2
+WHERE key = $1
+`
+
+type LockLoginKeyParams struct {
+	Key         string       `json:"key"`
+	LockedUntil sql.NullTime `json:"locked_until"`
+}
+
+func (q *Queries) LockLoginKey(ctx context.Context, arg LockLoginKeyParams) error {
+	_, err := q.db.ExecContext(ctx, lockLoginKey, arg.Key, arg.LockedUntil)
+	return err
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+INSERT INTO login_failures (
+  key,
+  failed_count
+) VALUES (
+  $1, 1
+)
+ON CONFLICT (key) DO UPDATE
+SET
+  failed_count = CASE
+    WHEN login_failures.last_failed_at < $2 THEN 1
+    ELSE login_failures.failed_count + 1
+  END,
+  last_failed_at = now()
+RETURNING key, failed_count, locked_until, last_failed_at
+`
+
+type RecordLoginFailureParams struct {
+	Key         string    `json:"key"`
+	ResetBefore time.Time `json:"reset_before"`
+}
+
+func (q *Queries) RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginFailure, error) {
+	row := q.db.QueryRowContext(ctx, recordLoginFailure, arg.Key, arg.ResetBefore)
+	var i LoginFailure
+	err := row.Scan(
+		&i.Key,
+		&i.FailedCount,
+		&i.LockedUntil,
+		&i.LastFailedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func recordRandomLoginFailure(t *testing.T, key string, expectedCount int32) LoginFailure {
+	failure, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+		Key:         key,
+		ResetBefore: time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, key, failure.Key)
+	require.Equal(t, expectedCount, failure.FailedCount)
+	require.WithinDuration(t, time.Now(), failure.LastFailedAt, time.Second)
+
+	return failure
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	key := "username:" + util.RandomOwner()
+
+	recordRandomLoginFailure(t, key, 1)
+	recordRandomLoginFailure(t, key, 2)
+
+	// failures older than the window start over
+	failure, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+		Key:         key,
+		ResetBefore: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), failure.FailedCount)
+}
+
+func TestListLoginLocks(t *testing.T) {
+	lockedKey := "username:" + util.RandomOwner()
+	expiredKey := "username:" + util.RandomOwner()
+	unlockedKey := "ip:" + util.RandomString(12)
+
+	for _, key := range []string{lockedKey, expiredKey, unlockedKey} {
+		recordRandomLoginFailure(t, key, 1)
+	}
+
+	err := testQueries.LockLoginKey(context.Background(), LockLoginKeyParams{
+		Key:         lockedKey,
+		LockedUntil: sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+	})
+	require.NoError(t, err)
+
+	err = testQueries.LockLoginKey(context.Background(), LockLoginKeyParams{
+		Key:         expiredKey,
+		LockedUntil: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	require.NoError(t, err)
+
+	locks, err := testQueries.ListLoginLocks(context.Background(), []string{lockedKey, expiredKey, unlockedKey})
+	require.NoError(t, err)
+	require.Len(t, locks, 1)
+	require.Equal(t, lockedKey, locks[0].Key)
+
+	err = testQueries.DeleteLoginFailures(context.Background(), lockedKey)
+	require.NoError(t, err)
+
+	locks, err = testQueries.ListLoginLocks(context.Background(), []string{lockedKey})
+	require.NoError(t, err)
+	require.Empty(t, locks)
+}
+
+func TestDeleteStaleLoginFailures(t *testing.T) {
+	key := "ip:" + util.RandomString(12)
+	recordRandomLoginFailure(t, key, 1)
+
+	err := testQueries.DeleteStaleLoginFailures(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	// counting starts over once the row is gone
+	recordRandomLoginFailure(t, key, 1)
+}
@@ -5,7 +5,11 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Account struct {
@@ -14,14 +18,154 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
+	IsFrozen  bool      `json:"is_frozen"`
+}
+
+// limits of the outgoing transfers of an account, a missing limit falls back to the tier of its owner
+type AccountTransferLimit struct {
+	AccountID         int64         `json:"account_id"`
+	MaxPerTransaction sql.NullInt64 `json:"max_per_transaction"`
+	MaxDailyAmount    sql.NullInt64 `json:"max_daily_amount"`
+	MaxDailyCount     sql.NullInt32 `json:"max_daily_count"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+type ApiKey struct {
+	ID         uuid.UUID    `json:"id"`
+	Prefix     string       `json:"prefix"`
+	HashedKey  string       `json:"hashed_key"`
+	Owner      string       `json:"owner"`
+	Scopes     []string     `json:"scopes"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+	CreatedAt  time.Time    `json:"created_at"`
 }
 
 type Entry struct {
 	ID         int64 `json:"id"`
 	AccountsID int64 `json:"accounts_id"`
 	// can be negative or positive
-	Amount    int64     `json:"amount"`
-	CreatedAt time.Time `json:"created_at"`
+	Amount     int64         `json:"amount"`
+	CreatedAt  time.Time     `json:"created_at"`
+	TransferID sql.NullInt64 `json:"transfer_id"`
+}
+
+type FxQuote struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	Rate         string    `json:"rate"`
+	IsUsed       bool      `json:"is_used"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiredAt    time.Time `json:"expired_at"`
+}
+
+type FxRate struct {
+	ID           int64     `json:"id"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	Rate         string    `json:"rate"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type IdempotencyKey struct {
+	Username    string          `json:"username"`
+	Key         string          `json:"key"`
+	RequestHash string          `json:"request_hash"`
+	Response    json.RawMessage `json:"response"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ExpiredAt   time.Time       `json:"expired_at"`
+}
+
+type LoginFailure struct {
+	Key          string       `json:"key"`
+	FailedCount  int32        `json:"failed_count"`
+	LockedUntil  sql.NullTime `json:"locked_until"`
+	LastFailedAt time.Time    `json:"last_failed_at"`
+}
+
+type OidcAuthRequest struct {
+	HashedState  string    `json:"hashed_state"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiredAt    time.Time `json:"expired_at"`
+}
+
+type PasswordReset struct {
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	HashedToken string    `json:"hashed_token"`
+	IsUsed      bool      `json:"is_used"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiredAt   time.Time `json:"expired_at"`
+}
+
+// requests counted per key, such as an email or a client IP, in a fixed window
+type RateLimit struct {
+	Key             string    `json:"key"`
+	Hits            int32     `json:"hits"`
+	WindowStartedAt time.Time `json:"window_started_at"`
+}
+
+type RevokedToken struct {
+	ID        uuid.UUID `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `json:"id"`
+	Owner         string `json:"owner"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	Amount        int64  `json:"amount"`
+	// cron expression or RRULE, empty for a one-off transfer
+	Schedule  string       `json:"schedule"`
+	StartAt   time.Time    `json:"start_at"`
+	EndAt     sql.NullTime `json:"end_at"`
+	NextRunAt time.Time    `json:"next_run_at"`
+	// set while the run due at next_run_at waits to be retried
+	RetryAt        sql.NullTime `json:"retry_at"`
+	FailedAttempts int32        `json:"failed_attempts"`
+	Status         string       `json:"status"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+type ScheduledTransferRun struct {
+	ID                  int64         `json:"id"`
+	ScheduledTransferID int64         `json:"scheduled_transfer_id"`
+	DueAt               time.Time     `json:"due_at"`
+	Attempt             int32         `json:"attempt"`
+	Status              string        `json:"status"`
+	TransferID          sql.NullInt64 `json:"transfer_id"`
+	Error               string        `json:"error"`
+	CreatedAt           time.Time     `json:"created_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	RefreshToken string    `json:"refresh_token"`
+	UserAgent    string    `json:"user_agent"`
+	ClientIp     string    `json:"client_ip"`
+	IsBlocked    bool      `json:"is_blocked"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+}
+
+// limits of the outgoing transfers of the accounts of users of a tier, a missing limit is unlimited
+type TierTransferLimit struct {
+	Tier              string        `json:"tier"`
+	Currency          string        `json:"currency"`
+	MaxPerTransaction sql.NullInt64 `json:"max_per_transaction"`
+	MaxDailyAmount    sql.NullInt64 `json:"max_daily_amount"`
+	MaxDailyCount     sql.NullInt32 `json:"max_daily_count"`
+	UpdatedAt         time.Time     `json:"updated_at"`
 }
 
 type Transfer struct {
@@ -31,13 +175,42 @@ type Transfer struct {
 	// must be positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// credited in the currency of the to account, when it differs
+	ToAmount  sql.NullInt64  `json:"to_amount"`
+	FxRate    sql.NullString `json:"fx_rate"`
+	FxQuoteID uuid.NullUUID  `json:"fx_quote_id"`
 }
 
 type User struct {
-	Username          string    `json:"username"`
-	HashedPassword    string    `json:"hashed_password"`
-	FullName          string    `json:"full_name"`
-	Email             string    `json:"email"`
-	PasswordChangedAt time.Time `json:"password_changed_at"`
-	CreatedAt         time.Time `json:"created_at"`
+	Username            string         `json:"username"`
+	HashedPassword      string         `json:"hashed_password"`
+	FullName            string         `json:"full_name"`
+	Email               string         `json:"email"`
+	PasswordChangedAt   time.Time      `json:"password_changed_at"`
+	CreatedAt           time.Time      `json:"created_at"`
+	Role                string         `json:"role"`
+	IsFrozen            bool           `json:"is_frozen"`
+	TotpSecret          sql.NullString `json:"totp_secret"`
+	IsTotpEnabled       bool           `json:"is_totp_enabled"`
+	TotpLastStep        int64          `json:"totp_last_step"`
+	HashedRecoveryCodes []string       `json:"hashed_recovery_codes"`
+	IsEmailVerified     bool           `json:"is_email_verified"`
+	Tier                string         `json:"tier"`
+}
+
+type UserIdentity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type VerifyEmail struct {
+	ID               int64     `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	HashedSecretCode string    `json:"hashed_secret_code"`
+	IsUsed           bool      `json:"is_used"`
+	CreatedAt        time.Time `json:"created_at"`
+	ExpiredAt        time.Time `json:"expired_at"`
 }
@@ -0,0 +1,128 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: oidc.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const createOIDCAuthRequest = `-- name: CreateOIDCAuthRequest :one
+INSERT INTO oidc_auth_requests (
+  hashed_state,
+  nonce,
+  code_verifier,
+  expired_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING hashed_state, nonce, code_verifier, created_at, expired_at
+`
+
+type CreateOIDCAuthRequestParams struct {
+	HashedState  string    `json:"hashed_state"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiredAt    time.Time `json:"expired_at"`
+}
+
+func (q *Queries) CreateOIDCAuthRequest(ctx context.Context, arg CreateOIDCAuthRequestParams) (OidcAuthRequest, error) {
+	row := q.db.QueryRowContext(ctx, createOIDCAuthRequest,
+		arg.HashedState,
+		arg.Nonce,
+		arg.CodeVerifier,
+		arg.ExpiredAt,
+	)
+	var i OidcAuthRequest
+	err := row.Scan(
+		&i.HashedState,
+		&i.Nonce,
+		&i.CodeVerifier,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
+
+const createUserIdentity = `-- name: CreateUserIdentity :one
+INSERT INTO user_identities (
+  issuer,
+  subject,
+  username
+) VALUES (
+  $1, $2, $3
+)
+RETURNING issuer, subject, username, created_at
+`
+
+type CreateUserIdentityParams struct {
+	Issuer   string `json:"issuer"`
+	Subject  string `json:"subject"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error) {
+	row := q.db.QueryRowContext(ctx, createUserIdentity, arg.Issuer, arg.Subject, arg.Username)
+	var i UserIdentity
+	err := row.Scan(
+		&i.Issuer,
+		&i.Subject,
+		&i.Username,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteExpiredOIDCAuthRequests = `-- name: DeleteExpiredOIDCAuthRequests :exec
+DELETE FROM oidc_auth_requests
+WHERE expired_at <= now()
+`
+
+func (q *Queries) DeleteExpiredOIDCAuthRequests(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredOIDCAuthRequests)
+	return err
+}
+
+const getUserIdentity = `-- name: GetUserIdentity :one
+SELECT issuer, subject, username, created_at FROM user_identities
+WHERE issuer = $1 AND subject = $2 LIMIT 1
+`
+
+type GetUserIdentityParams struct {
+	Issuer  string `json:"issuer"`
+	Subject string `json:"subject"`
+}
+
+func (q *Queries) GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (UserIdentity, error) {
+	row := q.db.QueryRowContext(ctx, getUserIdentity, arg.Issuer, arg.Subject)
+	var i UserIdentity
+	err := row.Scan(
+		&i.Issuer,
+		&i.Subject,
+		&i.Username,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useOIDCAuthRequest = `-- name: UseOIDCAuthRequest :one
+DELETE FROM oidc_auth_requests
+WHERE hashed_state = $1 AND expired_at > now()
+RETURNING hashed_state, nonce, code_verifier, created_at, expired_at
+`
+
+func (q *Queries) UseOIDCAuthRequest(ctx context.Context, hashedState string) (OidcAuthRequest, error) {
+	row := q.db.QueryRowContext(ctx, useOIDCAuthRequest, hashedState)
+	var i OidcAuthRequest
+	err := row.Scan(
+		&i.HashedState,
+		&i.Nonce,
+		&i.CodeVerifier,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
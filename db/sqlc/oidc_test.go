@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomOIDCAuthRequest(t *testing.T, expiredAt time.Time) OidcAuthRequest {
+	arg := CreateOIDCAuthRequestParams{
+		HashedState:  util.HashSecretCode(util.RandomString(32)),
+		Nonce:        util.RandomString(32),
+		CodeVerifier: util.RandomString(43),
+		ExpiredAt:    expiredAt,
+	}
+
+	authRequest, err := testQueries.CreateOIDCAuthRequest(context.Background(), arg)
+	require.NoError(t, err)
+
+	require.Equal(t, arg.HashedState, authRequest.HashedState)
+	require.Equal(t, arg.Nonce, authRequest.Nonce)
+	require.Equal(t, arg.CodeVerifier, authRequest.CodeVerifier)
+	require.WithinDuration(t, arg.ExpiredAt, authRequest.ExpiredAt, time.Second)
+	require.NotZero(t, authRequest.CreatedAt)
+
+	return authRequest
+}
+
+func TestUseOIDCAuthRequest(t *testing.T) {
+	authRequest1 := createRandomOIDCAuthRequest(t, time.Now().Add(time.Minute))
+
+	authRequest2, err := testQueries.UseOIDCAuthRequest(context.Background(), authRequest1.HashedState)
+	require.NoError(t, err)
+	require.Equal(t, authRequest1.Nonce, authRequest2.Nonce)
+	require.Equal(t, authRequest1.CodeVerifier, authRequest2.CodeVerifier)
+
+	// a state is only good once
+	_, err = testQueries.UseOIDCAuthRequest(context.Background(), authRequest1.HashedState)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestUseOIDCAuthRequestExpired(t *testing.T) {
+	authRequest := createRandomOIDCAuthRequest(t, time.Now().Add(-time.Minute))
+
+	_, err := testQueries.UseOIDCAuthRequest(context.Background(), authRequest.HashedState)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	err = testQueries.DeleteExpiredOIDCAuthRequests(context.Background())
+	require.NoError(t, err)
+}
+
+func TestGetUserIdentity(t *testing.T) {
+	user := createRandomUser(t)
+
+	arg := CreateUserIdentityParams{
+		Issuer:   "https://idp.example.com",
+		Subject:  util.RandomString(16),
+		Username: user.Username,
+	}
+	identity1, err := testQueries.CreateUserIdentity(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Issuer, identity1.Issuer)
+	require.Equal(t, arg.Subject, identity1.Subject)
+	require.Equal(t, arg.Username, identity1.Username)
+	require.NotZero(t, identity1.CreatedAt)
+
+	identity2, err := testQueries.GetUserIdentity(context.Background(), GetUserIdentityParams{
+		Issuer:  arg.Issuer,
+		Subject: arg.Subject,
+	})
+	require.NoError(t, err)
+	require.Equal(t, identity1.Username, identity2.Username)
+
+	// the subject is only unique at its issuer
+	_, err = testQueries.GetUserIdentity(context.Background(), GetUserIdentityParams{
+		Issuer:  "https://other-idp.example.com",
+		Subject: arg.Subject,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -6,24 +6,103 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	AdvanceScheduledTransfer(ctx context.Context, arg AdvanceScheduledTransferParams) (ScheduledTransfer, error)
+	BlockSession(ctx context.Context, arg BlockSessionParams) (Session, error)
+	BlockUserSessions(ctx context.Context, arg BlockUserSessionsParams) error
+	ClaimDueScheduledTransfer(ctx context.Context) (ScheduledTransfer, error)
+	ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (IdempotencyKey, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateFXQuote(ctx context.Context, arg CreateFXQuoteParams) (FxQuote, error)
+	CreateFXRate(ctx context.Context, arg CreateFXRateParams) (FxRate, error)
+	CreateFXTransfer(ctx context.Context, arg CreateFXTransferParams) (Transfer, error)
+	CreateOIDCAuthRequest(ctx context.Context, arg CreateOIDCAuthRequestParams) (OidcAuthRequest, error)
+	CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error)
+	CreateRevokedToken(ctx context.Context, arg CreateRevokedTokenParams) (RevokedToken, error)
+	CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error)
+	CreateScheduledTransferRun(ctx context.Context, arg CreateScheduledTransferRunParams) (ScheduledTransferRun, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateSkippedScheduledTransferRuns(ctx context.Context, arg CreateSkippedScheduledTransferRunsParams) error
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error)
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	DeleteExpiredOIDCAuthRequests(ctx context.Context) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
+	DeleteLoginFailures(ctx context.Context, key string) error
+	DeleteStaleLoginFailures(ctx context.Context, lastFailedAt time.Time) error
+	DeleteStaleRateLimits(ctx context.Context, windowStartedAt time.Time) error
+	EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) (User, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (GetAPIKeyByPrefixRow, error)
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	// the balance an entry left is the current one without the entries made since
+	GetAccountBalanceAfterEntry(ctx context.Context, arg GetAccountBalanceAfterEntryParams) (int64, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetFXQuote(ctx context.Context, id uuid.UUID) (FxQuote, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetLatestFXRate(ctx context.Context, arg GetLatestFXRateParams) (FxRate, error)
+	GetRevokedToken(ctx context.Context, id uuid.UUID) (RevokedToken, error)
+	GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
+	GetStatementBalances(ctx context.Context, arg GetStatementBalancesParams) (GetStatementBalancesRow, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferLimits(ctx context.Context, accountID int64) (GetTransferLimitsRow, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	GetUserAuth(ctx context.Context, username string) (GetUserAuthRow, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (UserIdentity, error)
+	HitRateLimit(ctx context.Context, arg HitRateLimitParams) (RateLimit, error)
+	InvalidatePasswordResets(ctx context.Context, username string) error
+	ListAPIKeys(ctx context.Context, owner string) ([]ApiKey, error)
+	ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]Transfer, error)
+	ListAccountTransfersReverse(ctx context.Context, arg ListAccountTransfersReverseParams) ([]Transfer, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	ListAccountsReverse(ctx context.Context, arg ListAccountsReverseParams) ([]Account, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	ListLoginLocks(ctx context.Context, keys []string) ([]LoginFailure, error)
+	ListScheduledTransferRuns(ctx context.Context, arg ListScheduledTransferRunsParams) ([]ScheduledTransferRun, error)
+	ListScheduledTransferRunsReverse(ctx context.Context, arg ListScheduledTransferRunsReverseParams) ([]ScheduledTransferRun, error)
+	ListScheduledTransfers(ctx context.Context, arg ListScheduledTransfersParams) ([]ScheduledTransfer, error)
+	ListScheduledTransfersReverse(ctx context.Context, arg ListScheduledTransfersReverseParams) ([]ScheduledTransfer, error)
+	ListSessions(ctx context.Context, username string) ([]Session, error)
+	ListStatementEntries(ctx context.Context, arg ListStatementEntriesParams) ([]ListStatementEntriesRow, error)
+	ListStatementEntriesReverse(ctx context.Context, arg ListStatementEntriesReverseParams) ([]ListStatementEntriesReverseRow, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	LockLoginKey(ctx context.Context, arg LockLoginKeyParams) error
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginFailure, error)
+	RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error
+	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error)
+	RevokeAPIKeyByID(ctx context.Context, id uuid.UUID) (ApiKey, error)
+	SetAccountTransferLimits(ctx context.Context, arg SetAccountTransferLimitsParams) (AccountTransferLimit, error)
+	SetIdempotencyKeyResponse(ctx context.Context, arg SetIdempotencyKeyResponseParams) (IdempotencyKey, error)
+	SetTierTransferLimits(ctx context.Context, arg SetTierTransferLimitsParams) (TierTransferLimit, error)
+	TouchSession(ctx context.Context, arg TouchSessionParams) error
+	UpdateAPIKeyLastUsed(ctx context.Context, id uuid.UUID) error
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error)
+	UpdateAccountFrozen(ctx context.Context, arg UpdateAccountFrozenParams) (Account, error)
+	UpdateScheduledTransfer(ctx context.Context, arg UpdateScheduledTransferParams) (ScheduledTransfer, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserEmailVerified(ctx context.Context, arg UpdateUserEmailVerifiedParams) (User, error)
+	UpdateUserFrozen(ctx context.Context, arg UpdateUserFrozenParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserTOTPSecret(ctx context.Context, arg UpdateUserTOTPSecretParams) (User, error)
+	UpdateUserTier(ctx context.Context, arg UpdateUserTierParams) (User, error)
+	UseFXQuote(ctx context.Context, arg UseFXQuoteParams) (FxQuote, error)
+	UseOIDCAuthRequest(ctx context.Context, hashedState string) (OidcAuthRequest, error)
+	UsePasswordReset(ctx context.Context, hashedToken string) (PasswordReset, error)
+	UseUserRecoveryCode(ctx context.Context, arg UseUserRecoveryCodeParams) (User, error)
+	UseUserTOTPStep(ctx context.Context, arg UseUserTOTPStepParams) (User, error)
+	UseVerifyEmail(ctx context.Context, arg UseVerifyEmailParams) (VerifyEmail, error)
 }
 
 var _ Querier = (*Queries)(nil)
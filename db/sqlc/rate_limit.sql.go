@@ -0,0 +1,53 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: rate_limit.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const deleteStaleRateLimits = `-- name: DeleteStaleRateLimits :exec
+DELETE FROM rate_limits
+WHERE window_started_at < $1
+`
+
+func (q *Queries) DeleteStaleRateLimits(ctx context.Context, windowStartedAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteStaleRateLimits, windowStartedAt)
+	return err
+}
+
+const hitRateLimit = `-- name: HitRateLimit :one
+INSERT INTO rate_limits (
+  key,
+  hits
+) VALUES (
+  $1, 1
+)
+ON CONFLICT (key) DO UPDATE
+SET
+  hits = CASE
+    WHEN rate_limits.window_started_at < $2 THEN 1
+    ELSE rate_limits.hits + 1
+  END,
+  window_started_at = CASE
+    WHEN rate_limits.window_started_at < $2 THEN now()
+    ELSE rate_limits.window_started_at
+  END
+RETURNING key, hits, window_started_at
+`
+
+type HitRateLimitParams struct {
+	Key         string    `json:"key"`
+	WindowStart time.Time `json:"window_start"`
+}
+
+func (q *Queries) HitRateLimit(ctx context.Context, arg HitRateLimitParams) (RateLimit, error) {
+	row := q.db.QueryRowContext(ctx, hitRateLimit, arg.Key, arg.WindowStart)
+	var i RateLimit
+	err := row.Scan(&i.Key, &i.Hits, &i.WindowStartedAt)
+	return i, err
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRateLimitHit(t *testing.T, key string, windowStart time.Time) RateLimit {
+	rateLimit, err := testQueries.HitRateLimit(context.Background(), HitRateLimitParams{
+		Key:         key,
+		WindowStart: windowStart,
+	})
+	require.NoError(t, err)
+	require.Equal(t, key, rateLimit.Key)
+	return rateLimit
+}
+
+func TestHitRateLimit(t *testing.T) {
+	key := "password_reset:email:" + util.RandomEmail()
+
+	first := createRateLimitHit(t, key, time.Now().Add(-time.Hour))
+	require.Equal(t, int32(1), first.Hits)
+	require.WithinDuration(t, time.Now(), first.WindowStartedAt, time.Second)
+
+	second := createRateLimitHit(t, key, time.Now().Add(-time.Hour))
+	require.Equal(t, int32(2), second.Hits)
+	require.Equal(t, first.WindowStartedAt, second.WindowStartedAt)
+
+	// a hit after the window starts a new one
+	third := createRateLimitHit(t, key, time.Now().Add(time.Minute))
+	require.Equal(t, int32(1), third.Hits)
+	require.True(t, third.WindowStartedAt.After(first.WindowStartedAt))
+}
+
+func TestDeleteStaleRateLimits(t *testing.T) {
+	key := "password_reset:ip:" + util.RandomString(12)
+	createRateLimitHit(t, key, time.Now().Add(-time.Hour))
+
+	err := testQueries.DeleteStaleRateLimits(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	// the count starts over once deleted
+	rateLimit := createRateLimitHit(t, key, time.Now().Add(-time.Hour))
+	require.Equal(t, int32(1), rateLimit.Hits)
+}
@@ -0,0 +1,59 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: revoked_token.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createRevokedToken = `-- name: CreateRevokedToken :one
+INSERT INTO revoked_tokens (
+  id,
+  expires_at
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (id) DO UPDATE
+SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+RETURNING id, expires_at, revoked_at
+`
+
+type CreateRevokedTokenParams struct {
+	ID        uuid.UUID `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateRevokedToken(ctx context.Context, arg CreateRevokedTokenParams) (RevokedToken, error) {
+	row := q.db.QueryRowContext(ctx, createRevokedToken, arg.ID, arg.ExpiresAt)
+	var i RevokedToken
+	err := row.Scan(&i.ID, &i.ExpiresAt, &i.RevokedAt)
+	return i, err
+}
+
+const deleteExpiredRevokedTokens = `-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredRevokedTokens(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredRevokedTokens)
+	return err
+}
+
+const getRevokedToken = `-- name: GetRevokedToken :one
+SELECT id, expires_at, revoked_at FROM revoked_tokens
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetRevokedToken(ctx context.Context, id uuid.UUID) (RevokedToken, error) {
+	row := q.db.QueryRowContext(ctx, getRevokedToken, id)
+	var i RevokedToken
+	err := row.Scan(&i.ID, &i.ExpiresAt, &i.RevokedAt)
+	return i, err
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomRevokedToken(t *testing.T, expiresAt time.Time) RevokedToken {
+	arg := CreateRevokedTokenParams{
+		ID:        uuid.New(),
+		ExpiresAt: expiresAt,
+	}
+
+	revokedToken, err := testQueries.CreateRevokedToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, revokedToken)
+
+	require.Equal(t, arg.ID, revokedToken.ID)
+	require.WithinDuration(t, arg.ExpiresAt, revokedToken.ExpiresAt, time.Second)
+	require.NotZero(t, revokedToken.RevokedAt)
+
+	return revokedToken
+}
+
+func TestCreateRevokedToken(t *testing.T) {
+	revokedToken1 := createRandomRevokedToken(t, time.Now().Add(time.Minute))
+
+	// revoking again keeps the latest expiry
+	revokedToken2, err := testQueries.CreateRevokedToken(context.Background(), CreateRevokedTokenParams{
+		ID:        revokedToken1.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, revokedToken1.ID, revokedToken2.ID)
+	require.True(t, revokedToken2.ExpiresAt.After(revokedToken1.ExpiresAt))
+}
+
+func TestGetRevokedToken(t *testing.T) {
+	revokedToken1 := createRandomRevokedToken(t, time.Now().Add(time.Minute))
+	revokedToken2, err := testQueries.GetRevokedToken(context.Background(), revokedToken1.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, revokedToken2)
+
+	require.Equal(t, revokedToken1.ID, revokedToken2.ID)
+	require.WithinDuration(t, revokedToken1.ExpiresAt, revokedToken2.ExpiresAt, time.Second)
+	require.WithinDuration(t, revokedToken1.RevokedAt, revokedToken2.RevokedAt, time.Second)
+}
+
+func TestDeleteExpiredRevokedTokens(t *testing.T) {
+	expired := createRandomRevokedToken(t, time.Now().Add(-time.Minute))
+	live := createRandomRevokedToken(t, time.Now().Add(time.Minute))
+
+	err := testQueries.DeleteExpiredRevokedTokens(context.Background())
+	require.NoError(t, err)
+
+	_, err = testQueries.GetRevokedToken(context.Background(), expired.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetRevokedToken(context.Background(), live.ID)
+	require.NoError(t, err)
+}
@@ -0,0 +1,517 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.18.0
+// source: scheduled_transfer.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const advanceScheduledTransfer = `-- name: AdvanceScheduledTransfer :one
+UPDATE scheduled_transfers
+SET
+  next_run_at = $1,
+  retry_at = $2,
+  failed_attempts = $3,
+  status = $4,
+  updated_at = now()
+WHERE id = $5
+RETURNING id, owner, from_account_id, to_account_id, amount, schedule, start_at, end_at, next_run_at, retry_at, failed_attempts, status, created_at, updated_at
+`
+
+type AdvanceScheduledTransferParams struct {
+	NextRunAt      time.Time    `json:"next_run_at"`
+	RetryAt        sql.NullTime `json:"retry_at"`
+	FailedAttempts int32        `json:"failed_attempts"`
+	Status         string       `json:"status"`
+	ID             int64        `json:"id"`
+}
+
+func (q *Queries) AdvanceScheduledTransfer(ctx context.Context, arg AdvanceScheduledTransferParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, advanceScheduledTransfer,
+		arg.NextRunAt,
+		arg.RetryAt,
+		arg.FailedAttempts,
+		arg.Status,
+		arg.ID,
+	)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Schedule,
+		&i.StartAt,
+		&i.EndAt,
+		&i.NextRunAt,
+		&i.RetryAt,
+		&i.FailedAttempts,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const claimDueScheduledTransfer = `-- name: ClaimDueScheduledTransfer :one
+SELECT id, owner, from_account_id, to_account_id, amount, schedule, start_at, end_at, next_run_at, retry_at, failed_attempts, status, created_at, updated_at FROM scheduled_transfers
+WHERE status = 'active'
+  AND next_run_at <= now()
+  AND (retry_at IS NULL OR retry_at <= now())
+ORDER BY next_run_at, id
+LIMIT 1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) ClaimDueScheduledTransfer(ctx context.Context) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, claimDueScheduledTransfer)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Schedule,
+		&i.StartAt,
+		&i.EndAt,
+		&i.NextRunAt,
+		&i.RetryAt,
+		&i.FailedAttempts,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createScheduledTransfer = `-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  owner,
+  from_account_id,
+  to_account_id,
+  amount,
+  schedule,
+  start_at,
+  end_at,
+  next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING id, owner, from_account_id, to_account_id, amount, schedule, start_at, end_at, next_run_at, retry_at, failed_attempts, status, created_at, updated_at
+`
+
+type CreateScheduledTransferParams struct {
+	Owner         string       `json:"owner"`
+	FromAccountID int64        `json:"from_account_id"`
+	ToAccountID   int64        `json:"to_account_id"`
+	Amount        int64        `json:"amount"`
+	Schedule      string       `json:"schedule"`
+	StartAt       time.Time    `json:"start_at"`
+	EndAt         sql.NullTime `json:"end_at"`
+	NextRunAt     time.Time    `json:"next_run_at"`
+}
+
+func (q *Queries) CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledTransfer,
+		arg.Owner,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.Schedule,
+		arg.StartAt,
+		arg.EndAt,
+		arg.NextRunAt,
+	)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Schedule,
+		&i.StartAt,
+		&i.EndAt,
+		&i.NextRunAt,
+		&i.RetryAt,
+		&i.FailedAttempts,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createScheduledTransferRun = `-- name: CreateScheduledTransferRun :one
+INSERT INTO scheduled_transfer_runs (
+  scheduled_transfer_id,
+  due_at,
+  attempt,
+  status,
+  transfer_id,
+  error
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING id, scheduled_transfer_id, due_at, attempt, status, transfer_id, error, created_at
+`
+
+type CreateScheduledTransferRunParams struct {
+	ScheduledTransferID int64         `json:"scheduled_transfer_id"`
+	DueAt               time.Time     `json:"due_at"`
+	Attempt             int32         `json:"attempt"`
+	Status              string        `json:"status"`
+	TransferID          sql.NullInt64 `json:"transfer_id"`
+	Error               string        `json:"error"`
+}
+
+func (q *Queries) CreateScheduledTransferRun(ctx context.Context, arg CreateScheduledTransferRunParams) (ScheduledTransferRun, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledTransferRun,
+		arg.ScheduledTransferID,
+		arg.DueAt,
+		arg.Attempt,
+		arg.Status,
+		arg.TransferID,
+		arg.Error,
+	)
+	var i ScheduledTransferRun
+	err := row.Scan(
+		&i.ID,
+		&i.ScheduledTransferID,
+		&i.DueAt,
+		&i.Attempt,
+		&i.Status,
+		&i.TransferID,
+		&i.Error,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createSkippedScheduledTransferRuns = `-- name: CreateSkippedScheduledTransferRuns :exec
+INSERT INTO scheduled_transfer_runs (
+  scheduled_transfer_id,
+  due_at,
+  attempt,
+  status
+)
+SELECT $1::bigint, unnest($2::timestamptz[]), 0, 'skipped'
+`
+
+type CreateSkippedScheduledTransferRunsParams struct {
+	ScheduledTransferID int64       `json:"scheduled_transfer_id"`
+	DueAts              []time.Time `json:"due_ats"`
+}
+
+func (q *Queries) CreateSkippedScheduledTransferRuns(ctx context.Context, arg CreateSkippedScheduledTransferRunsParams) error {
+	_, err := q.db.ExecContext(ctx, createSkippedScheduledTransferRuns, arg.ScheduledTransferID, pq.Array(arg.DueAts))
+	return err
+}
+
+const getScheduledTransfer = `-- name: GetScheduledTransfer :one
+SELECT id, owner, from_account_id, to_account_id, amount, schedule, start_at, end_at, next_run_at, retry_at, failed_attempts, status, created_at, updated_at FROM scheduled_transfers
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, getScheduledTransfer, id)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Schedule,
+		&i.StartAt,
+		&i.EndAt,
+		&i.NextRunAt,
+		&i.RetryAt,
+		&i.FailedAttempts,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listScheduledTransferRuns = `-- name: ListScheduledTransferRuns :many
+SELECT id, scheduled_transfer_id, due_at, attempt, status, transfer_id, error, created_at FROM scheduled_transfer_runs
+WHERE scheduled_transfer_id = $1
+  AND ($2::timestamptz IS NULL OR
+    (created_at, id) < ($2, $3::bigint))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListScheduledTransferRunsParams struct {
+	ScheduledTransferID int64         `json:"scheduled_transfer_id"`
+	CursorCreatedAt     sql.NullTime  `json:"cursor_created_at"`
+	CursorID            sql.NullInt64 `json:"cursor_id"`
+	Limit               int32         `json:"limit"`
+}
+
+func (q *Queries) ListScheduledTransferRuns(ctx context.Context, arg ListScheduledTransferRunsParams) ([]ScheduledTransferRun, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledTransferRuns,
+		arg.ScheduledTransferID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledTransferRun{}
+	for rows.Next() {
+		var i ScheduledTransferRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.ScheduledTransferID,
+			&i.DueAt,
+			&i.Attempt,
+			&i.Status,
+			&i.TransferID,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledTransferRunsReverse = `-- name: ListScheduledTransferRunsReverse :many
+SELECT id, scheduled_transfer_id, due_at, attempt, status, transfer_id, error, created_at FROM scheduled_transfer_runs
+WHERE scheduled_transfer_id = $1
+  AND (created_at, id) > ($2::timestamptz, $3::bigint)
+ORDER BY created_at, id
+LIMIT $4
+`
+
+type ListScheduledTransferRunsReverseParams struct {
+	ScheduledTransferID int64     `json:"scheduled_transfer_id"`
+	CursorCreatedAt     time.Time `json:"cursor_created_at"`
+	CursorID            int64     `json:"cursor_id"`
+	Limit               int32     `json:"limit"`
+}
+
+func (q *Queries) ListScheduledTransferRunsReverse(ctx context.Context, arg ListScheduledTransferRunsReverseParams) ([]ScheduledTransferRun, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledTransferRunsReverse,
+		arg.ScheduledTransferID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledTransferRun{}
+	for rows.Next() {
+		var i ScheduledTransferRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.ScheduledTransferID,
+			&i.DueAt,
+			&i.Attempt,
+			&i.Status,
+			&i.TransferID,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledTransfers = `-- name: ListScheduledTransfers :many
+SELECT id, owner, from_account_id, to_account_id, amount, schedule, start_at, end_at, next_run_at, retry_at, failed_attempts, status, created_at, updated_at FROM scheduled_transfers
+WHERE owner = $1
+  AND ($2::timestamptz IS NULL OR
+    (created_at, id) > ($2, $3::bigint))
+ORDER BY created_at, id
+LIMIT $4
+`
+
+type ListScheduledTransfersParams struct {
+	Owner           string        `json:"owner"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        sql.NullInt64 `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) ListScheduledTransfers(ctx context.Context, arg ListScheduledTransfersParams) ([]ScheduledTransfer, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledTransfers,
+		arg.Owner,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledTransfer{}
+	for rows.Next() {
+		var i ScheduledTransfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.Schedule,
+			&i.StartAt,
+			&i.EndAt,
+			&i.NextRunAt,
+			&i.RetryAt,
+			&i.FailedAttempts,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledTransfersReverse = `-- name: ListScheduledTransfersReverse :many
+SELECT id, owner, from_account_id, to_account_id, amount, schedule, start_at, end_at, next_run_at, retry_at, failed_attempts, status, created_at, updated_at FROM scheduled_transfers
+WHERE owner = $1
+  AND (created_at, id) < ($2::timestamptz, $3::bigint)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListScheduledTransfersReverseParams struct {
+	Owner           string    `json:"owner"`
+	CursorCreatedAt time.Time `json:"cursor_created_at"`
+	CursorID        int64     `json:"cursor_id"`
+	Limit           int32     `json:"limit"`
+}
+
+func (q *Queries) ListScheduledTransfersReverse(ctx context.Context, arg ListScheduledTransfersReverseParams) ([]ScheduledTransfer, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledTransfersReverse,
+		arg.Owner,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledTransfer{}
+	for rows.Next() {
+		var i ScheduledTransfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.Schedule,
+			&i.StartAt,
+			&i.EndAt,
+			&i.NextRunAt,
+			&i.RetryAt,
+			&i.FailedAttempts,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateScheduledTransfer = `-- name: UpdateScheduledTransfer :one
+UPDATE scheduled_transfers
+SET
+  amount = COALESCE($1, amount),
+  end_at = COALESCE($2, end_at),
+  status = COALESCE($3, status),
+  next_run_at = COALESCE($4, next_run_at),
+  retry_at = CASE WHEN $3::varchar IS NULL THEN retry_at ELSE NULL END,
+  failed_attempts = CASE WHEN $3::varchar IS NULL THEN failed_attempts ELSE 0 END,
+  updated_at = now()
+WHERE id = $5
+RETURNING id, owner, from_account_id, to_account_id, amount, schedule, start_at, end_at, next_run_at, retry_at, failed_attempts, status, created_at, updated_at
+`
+
+type UpdateScheduledTransferParams struct {
+	Amount    sql.NullInt64  `json:"amount"`
+	EndAt     sql.NullTime   `json:"end_at"`
+	Status    sql.NullString `json:"status"`
+	NextRunAt sql.NullTime   `json:"next_run_at"`
+	ID        int64          `json:"id"`
+}
+
+func (q *Queries) UpdateScheduledTransfer(ctx context.Context, arg UpdateScheduledTransferParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRowContext(ctx, updateScheduledTransfer,
+		arg.Amount,
+		arg.EndAt,
+		arg.Status,
+		arg.NextRunAt,
+		arg.ID,
+	)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.Schedule,
+		&i.StartAt,
+		&i.EndAt,
+		&i.NextRunAt,
+		&i.RetryAt,
+		&i.FailedAttempts,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomScheduledTransfer(t *testing.T, account1, account2 Account, schedule string, nextRunAt time.Time) ScheduledTransfer {
+	arg := CreateScheduledTransferParams{
+		Owner:         account1.Owner,
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+		Schedule:      schedule,
+		StartAt:       nextRunAt,
+		NextRunAt:     nextRunAt,
+	}
+
+	scheduledTransfer, err := testQueries.CreateScheduledTransfer(context.Background(), arg)
+	require.NoError(t, err)
+
+	require.Equal(t, arg.Owner, scheduledTransfer.Owner)
+	require.Equal(t, arg.FromAccountID, scheduledTransfer.FromAccountID)
+	require.Equal(t, arg.ToAccountID, scheduledTransfer.ToAccountID)
+	require.Equal(t, arg.Amount, scheduledTransfer.Amount)
+	require.Equal(t, arg.Schedule, scheduledTransfer.Schedule)
+	require.WithinDuration(t, arg.NextRunAt, scheduledTransfer.NextRunAt, time.Second)
+	require.False(t, scheduledTransfer.EndAt.Valid)
+	require.False(t, scheduledTransfer.RetryAt.Valid)
+	require.Zero(t, scheduledTransfer.FailedAttempts)
+	require.Equal(t, ScheduledTransferActive, scheduledTransfer.Status)
+	require.NotZero(t, scheduledTransfer.CreatedAt)
+
+	return scheduledTransfer
+}
+
+func TestListScheduledTransfers(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	var scheduledTransfers []ScheduledTransfer
+	for i := 0; i < 3; i++ {
+		scheduledTransfers = append(scheduledTransfers, createRandomScheduledTransfer(t, account1, account2, "", time.Now().Add(time.Hour)))
+	}
+
+	page, err := testQueries.ListScheduledTransfers(context.Background(), ListScheduledTransfersParams{
+		Owner: account1.Owner,
+		Limit: 2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, scheduledTransfers[:2], page)
+
+	page, err = testQueries.ListScheduledTransfersReverse(context.Background(), ListScheduledTransfersReverseParams{
+		Owner:           account1.Owner,
+		CursorCreatedAt: scheduledTransfers[2].CreatedAt,
+		CursorID:        scheduledTransfers[2].ID,
+		Limit:           5,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []ScheduledTransfer{scheduledTransfers[1], scheduledTransfers[0]}, page)
+}
+
+func TestUpdateScheduledTransfer(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+	scheduledTransfer1 := createRandomScheduledTransfer(t, account1, account2, "RRULE:FREQ=DAILY", time.Now().Add(time.Hour))
+
+	scheduledTransfer2, err := testQueries.UpdateScheduledTransfer(context.Background(), UpdateScheduledTransferParams{
+		ID:     scheduledTransfer1.ID,
+		Amount: sql.NullInt64{Int64: 20, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(20), scheduledTransfer2.Amount)
+	require.Equal(t, scheduledTransfer1.Status, scheduledTransfer2.Status)
+	require.Equal(t, scheduledTransfer1.NextRunAt, scheduledTransfer2.NextRunAt)
+
+	scheduledTransfer3, err := testQueries.UpdateScheduledTransfer(context.Background(), UpdateScheduledTransferParams{
+		ID:     scheduledTransfer1.ID,
+		Status: sql.NullString{String: ScheduledTransferPaused, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferPaused, scheduledTransfer3.Status)
+	require.Equal(t, int64(20), scheduledTransfer3.Amount)
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomSession(t *testing.T, user User) Session {
+	arg := CreateSessionParams{
+		ID:           uuid.New(),
+		Username:     user.Username,
+		RefreshToken: util.RandomString(32),
+		UserAgent:    util.RandomString(10),
+		ClientIp:     "127.0.0.1",
+		IsBlocked:    false,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	session, err := testQueries.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, session)
+
+	require.Equal(t, arg.ID, session.ID)
+	require.Equal(t, arg.Username, session.Username)
+	require.Equal(t, arg.RefreshToken, session.RefreshToken)
+	require.Equal(t, arg.UserAgent, session.UserAgent)
+	require.Equal(t, arg.ClientIp, session.ClientIp)
+	require.False(t, session.IsBlocked)
+	require.WithinDuration(t, arg.ExpiresAt, session.ExpiresAt, time.Second)
+	require.NotZero(t, session.CreatedAt)
+
+	return session
+}
+
+func TestCreateSession(t *testing.T) {
+	createRandomSession(t, createRandomUser(t))
+}
+
+func TestGetSession(t *testing.T) {
+	session1 := createRandomSession(t, createRandomUser(t))
+	session2, err := testQueries.GetSession(context.Background(), session1.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, session2)
+
+	require.Equal(t, session1.ID, session2.ID)
+	require.Equal(t, session1.Username, session2.Username)
+	require.Equal(t, session1.RefreshToken, session2.RefreshToken)
+	require.Equal(t, session1.IsBlocked, session2.IsBlocked)
+	require.WithinDuration(t, session1.ExpiresAt, session2.ExpiresAt, time.Second)
+	require.WithinDuration(t, session1.CreatedAt, session2.CreatedAt, time.Second)
+}
+
+func TestListSessions(t *testing.T) {
+	user := createRandomUser(t)
+	session1 := createRandomSession(t, user)
+	session2 := createRandomSession(t, user)
+
+	err := testQueries.TouchSession(context.Background(), TouchSessionParams{
+		ID:       session1.ID,
+		ClientIp: "10.0.0.1",
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.BlockSession(context.Background(), BlockSessionParams{
+		ID:       createRandomSession(t, user).ID,
+		Username: user.Username,
+	})
+	require.NoError(t, err)
+
+	sessions, err := testQueries.ListSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	// the most recently used session comes first
+	require.Equal(t, session1.ID, sessions[0].ID)
+	require.Equal(t, "10.0.0.1", sessions[0].ClientIp)
+	require.Equal(t, session2.ID, sessions[1].ID)
+}
+
+func TestBlockSession(t *testing.T) {
+	user := createRandomUser(t)
+	session1 := createRandomSession(t, user)
+
+	_, err := testQueries.BlockSession(context.Background(), BlockSessionParams{
+		ID:       session1.ID,
+		Username: createRandomUser(t).Username,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	session2, err := testQueries.BlockSession(context.Background(), BlockSessionParams{
+		ID:       session1.ID,
+		Username: user.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, session1.ID, session2.ID)
+	require.True(t, session2.IsBlocked)
+}
+
+func TestBlockUserSessions(t *testing.T) {
+	user := createRandomUser(t)
+	current := createRandomSession(t, user)
+	other := createRandomSession(t, user)
+
+	err := testQueries.BlockUserSessions(context.Background(), BlockUserSessionsParams{
+		Username: user.Username,
+		ExceptID: current.ID,
+	})
+	require.NoError(t, err)
+
+	session, err := testQueries.GetSession(context.Background(), current.ID)
+	require.NoError(t, err)
+	require.False(t, session.IsBlocked)
+
+	session, err = testQueries.GetSession(context.Background(), other.ID)
+	require.NoError(t, err)
+	require.True(t, session.IsBlocked)
+}
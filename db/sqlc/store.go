@@ -3,13 +3,43 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// accountBalanceConstraint keeps account balances from going negative
+const accountBalanceConstraint = "accounts_balance_nonnegative"
+
+// ErrInsufficientFunds is returned when a transfer would leave the sending account with a negative balance
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ErrTransferLimitExceeded is returned when a transfer would go over a limit of the from account
+var ErrTransferLimitExceeded = errors.New("transfer limit exceeded")
+
+// ErrInvalidQuote is returned when a cross-currency transfer can't be made at the rate of a quote
+var ErrInvalidQuote = errors.New("invalid or expired quote")
+
+// ErrAccountFrozen is returned when an account of a transfer is frozen by the time it is locked
+var ErrAccountFrozen = errors.New("account is frozen")
+
 // Store provides all fuctions to execute db Queries and transactions
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TranferTxParams) (TransferTxResult, error)
+	CrossCurrencyTransferTx(ctx context.Context, arg CrossCurrencyTransferTxParams) (TransferTxResult, error)
+	IdempotentTransferTx(ctx context.Context, arg IdempotentTransferTxParams) (IdempotentTransferTxResult, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error)
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error)
+	UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error)
+	CreateOIDCUserTx(ctx context.Context, arg CreateOIDCUserTxParams) (CreateOIDCUserTxResult, error)
+	AccountStatementTx(ctx context.Context, arg AccountStatementTxParams) (AccountStatementTxResult, error)
+	RunScheduledTransferTx(ctx context.Context, arg RunScheduledTransferTxParams) (RunScheduledTransferTxResult, error)
 }
 
 // SQLStore provides all fuctions to execute SQL Queries and transactions
@@ -28,7 +58,12 @@ func NewStore(db *sql.DB) Store {
 
 // execTx executes a function within a database transaction
 func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
-	tx, err := store.db.BeginTx(ctx, nil)
+	return store.execTxOptions(ctx, nil, fn)
+}
+
+// execTxOptions executes a function within a database transaction started with opts
+func (store *SQLStore) execTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(*Queries) error) error {
+	tx, err := store.db.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
@@ -62,48 +97,264 @@ type TransferTxResult struct {
 }
 
 // TransferTx performs a money transfer from one account to the other.
-// It creates a transfer record, add account entries, and update account's balance within a single database transaction
+// It creates a transfer record, add account entries, and update account's balance within a single database transaction.
+// It returns ErrInsufficientFunds if the from account doesn't have the amount,
+// and ErrTransferLimitExceeded if the transfer would go over a limit of the from account.
 func (store *SQLStore) TransferTx(ctx context.Context, arg TranferTxParams) (TransferTxResult, error) {
 	var result TransferTxResult
 
 	err := store.execTx(ctx, func(q *Queries) error {
 		var err error
+		result, err = transfer(ctx, q, arg)
+		return err
+	})
+
+	return result, err
+}
+
+// transfer moves the money within the transaction of q
+func transfer(ctx context.Context, q *Queries, arg TranferTxParams) (TransferTxResult, error) {
+	_, _, err := lockTransferAccounts(ctx, q, arg)
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+
+	transfer, err := q.CreateTransfer(ctx, CreateTransferParams(arg))
+	if err != nil {
+		return TransferTxResult{}, err
+	}
 
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams(arg))
+	return bookTransfer(ctx, q, transfer, arg.Amount)
+}
 
+// lockTransferAccounts locks the accounts of a transfer, checks neither is frozen
+// and the from account has the amount and may send it
+func lockTransferAccounts(ctx context.Context, q *Queries, arg TranferTxParams) (fromAccount, toAccount Account, err error) {
+	// the accounts are locked in the same order by every transfer so they can't deadlock,
+	// and the balance can't change between the check and the update
+	accountIDs := []int64{arg.FromAccountID, arg.ToAccountID}
+	if arg.ToAccountID < arg.FromAccountID {
+		accountIDs[0], accountIDs[1] = accountIDs[1], accountIDs[0]
+	}
+	for _, id := range accountIDs {
+		account, err := q.GetAccountForUpdate(ctx, id)
 		if err != nil {
-			return err
+			return fromAccount, toAccount, err
+		}
+		// checked again on the locked row, the account may have been frozen since the request was checked
+		if account.IsFrozen {
+			return fromAccount, toAccount, fmt.Errorf("%w: [%d]", ErrAccountFrozen, account.ID)
+		}
+		if id == arg.FromAccountID {
+			fromAccount = account
+		} else {
+			toAccount = account
 		}
+	}
+	if fromAccount.Balance < arg.Amount {
+		return fromAccount, toAccount, ErrInsufficientFunds
+	}
+	return fromAccount, toAccount, checkTransferLimits(ctx, q, arg)
+}
 
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountsID: arg.FromAccountID,
-			Amount:     -arg.Amount,
-		})
+// checkTransferLimits checks a transfer keeps within the limits of the from account.
+// The from account must be locked so the transfers of the day can't change until the transfer is made.
+func checkTransferLimits(ctx context.Context, q *Queries, arg TranferTxParams) error {
+	limits, err := q.GetTransferLimits(ctx, arg.FromAccountID)
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			return err
+	if limits.MaxPerTransaction.Valid && arg.Amount > limits.MaxPerTransaction.Int64 {
+		return fmt.Errorf("%w: at most %d per transfer", ErrTransferLimitExceeded, limits.MaxPerTransaction.Int64)
+	}
+	if limits.MaxDailyAmount.Valid && limits.DailyAmount+arg.Amount > limits.MaxDailyAmount.Int64 {
+		return fmt.Errorf("%w: at most %d a day, %d already sent today",
+			ErrTransferLimitExceeded, limits.MaxDailyAmount.Int64, limits.DailyAmount)
+	}
+	if limits.MaxDailyCount.Valid && limits.DailyCount >= limits.MaxDailyCount.Int32 {
+		return fmt.Errorf("%w: at most %d transfers a day", ErrTransferLimitExceeded, limits.MaxDailyCount.Int32)
+	}
+	return nil
+}
+
+// bookTransfer adds the entries of a transfer and updates the balances of its accounts.
+// The from account is debited the amount of the transfer and the to account credited toAmount.
+func bookTransfer(ctx context.Context, q *Queries, transfer Transfer, toAmount int64) (TransferTxResult, error) {
+	result := TransferTxResult{Transfer: transfer}
+	transferID := sql.NullInt64{Int64: transfer.ID, Valid: true}
+
+	var err error
+	result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountsID: transfer.FromAccountID,
+		Amount:     -transfer.Amount,
+		TransferID: transferID,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountsID: transfer.ToAccountID,
+		Amount:     toAmount,
+		TransferID: transferID,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// update account's balance
+	if transfer.FromAccountID < transfer.ToAccountID {
+		result.FromAccount, result.ToAccount, err = addMoney(ctx, q, transfer.FromAccountID, -transfer.Amount, transfer.ToAccountID, toAmount)
+	} else {
+		result.ToAccount, result.FromAccount, err = addMoney(ctx, q, transfer.ToAccountID, toAmount, transfer.FromAccountID, -transfer.Amount)
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == accountBalanceConstraint {
+		return result, ErrInsufficientFunds
+	}
+	return result, err
+}
+
+// FXQuoteParams identifies the quote a cross-currency transfer is made at
+type FXQuoteParams struct {
+	Username string
+	QuoteID  uuid.UUID
+	// Convert works out the amount credited to the to account at the rate of the quote
+	Convert func(quote FxQuote, amount int64) (int64, error)
+}
+
+// CrossCurrencyTransferTxParams contains the input parameters of the cross-currency transfer transaction
+type CrossCurrencyTransferTxParams struct {
+	TranferTxParams
+	FXQuoteParams
+}
+
+// CrossCurrencyTransferTx performs a money transfer between accounts of different currencies.
+// The from account is debited the amount in its currency and the to account credited
+// the converted amount in its own, at the rate of a quote of the user which is used up.
+// It returns ErrInvalidQuote if the quote is unknown, used, expired or for other currencies,
+// and ErrInsufficientFunds if the from account doesn't have the amount.
+func (store *SQLStore) CrossCurrencyTransferTx(ctx context.Context, arg CrossCurrencyTransferTxParams) (TransferTxResult, error) {
+	var result TransferTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result, err = crossCurrencyTransfer(ctx, q, arg.TranferTxParams, arg.FXQuoteParams)
+		return err
+	})
+
+	return result, err
+}
+
+// crossCurrencyTransfer moves the money at the rate of the quote within the transaction of q
+func crossCurrencyTransfer(ctx context.Context, q *Queries, arg TranferTxParams, quoteArg FXQuoteParams) (TransferTxResult, error) {
+	fromAccount, toAccount, err := lockTransferAccounts(ctx, q, arg)
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+
+	quote, err := q.UseFXQuote(ctx, UseFXQuoteParams{
+		ID:       quoteArg.QuoteID,
+		Username: quoteArg.Username,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return TransferTxResult{}, ErrInvalidQuote
 		}
+		return TransferTxResult{}, err
+	}
+	if quote.FromCurrency != fromAccount.Currency || quote.ToCurrency != toAccount.Currency {
+		return TransferTxResult{}, ErrInvalidQuote
+	}
 
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountsID: arg.ToAccountID,
-			Amount:     arg.Amount,
-		})
+	toAmount, err := quoteArg.Convert(quote, arg.Amount)
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+
+	transfer, err := q.CreateFXTransfer(ctx, CreateFXTransferParams{
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount,
+		ToAmount:      sql.NullInt64{Int64: toAmount, Valid: true},
+		FxRate:        sql.NullString{String: quote.Rate, Valid: true},
+		FxQuoteID:     uuid.NullUUID{UUID: quote.ID, Valid: true},
+	})
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+
+	return bookTransfer(ctx, q, transfer, toAmount)
+}
+
+// IdempotentTransferTxParams contains the input parameters of the idempotent transfer transaction
+type IdempotentTransferTxParams struct {
+	TranferTxParams
+	Username       string
+	IdempotencyKey string
+	// Quote makes it a cross-currency transfer if set
+	Quote *FXQuoteParams
+	// RequestHash identifies the request the key was sent with
+	RequestHash string
+	ExpiredAt   time.Time
+}
 
+// IdempotentTransferTxResult is the result of the idempotent transfer transaction
+type IdempotentTransferTxResult struct {
+	TransferTxResult
+	// IdempotencyKey holds the response stored for the key
+	IdempotencyKey IdempotencyKey
+	// Replayed is set when an earlier request already used the key,
+	// nothing was transferred and only IdempotencyKey is set
+	Replayed bool
+}
+
+// IdempotentTransferTx performs a money transfer at most once per idempotency key of the user.
+// The key is stored with the response of the transfer in the same transaction,
+// until it expires a request with it gets the stored key back instead.
+func (store *SQLStore) IdempotentTransferTx(ctx context.Context, arg IdempotentTransferTxParams) (IdempotentTransferTxResult, error) {
+	var result IdempotentTransferTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		// claiming the key first makes concurrent requests with it wait for this transaction
+		_, err := q.ClaimIdempotencyKey(ctx, ClaimIdempotencyKeyParams{
+			Username:    arg.Username,
+			Key:         arg.IdempotencyKey,
+			RequestHash: arg.RequestHash,
+			ExpiredAt:   arg.ExpiredAt,
+		})
+		if err == sql.ErrNoRows {
+			result.Replayed = true
+			result.IdempotencyKey, err = q.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{
+				Username: arg.Username,
+				Key:      arg.IdempotencyKey,
+			})
+			return err
+		}
 		if err != nil {
 			return err
 		}
 
-		// update account's balance
-		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
+		if arg.Quote != nil {
+			result.TransferTxResult, err = crossCurrencyTransfer(ctx, q, arg.TranferTxParams, *arg.Quote)
 		} else {
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
+			result.TransferTxResult, err = transfer(ctx, q, arg.TranferTxParams)
 		}
 		if err != nil {
 			return err
 		}
 
-		return nil
+		response, err := json.Marshal(result.TransferTxResult)
+		if err != nil {
+			return err
+		}
+
+		result.IdempotencyKey, err = q.SetIdempotencyKeyResponse(ctx, SetIdempotencyKeyResponseParams{
+			Username: arg.Username,
+			Key:      arg.IdempotencyKey,
+			Response: response,
+		})
+		return err
 	})
 
 	return result, err
@@ -130,3 +381,512 @@ func addMoney(
 	})
 	return
 }
+
+// CreateUserTxParams contains the input parameters of the create user transaction
+type CreateUserTxParams struct {
+	CreateUserParams
+	HashedSecretCode     string
+	VerifyEmailExpiredAt time.Time
+	// AfterCreate runs last inside the transaction, an error rolls the user back
+	AfterCreate func(user User, verifyEmail VerifyEmail) error
+}
+
+// CreateUserTxResult is the result of the create user transaction
+type CreateUserTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// CreateUserTx creates a user together with the record needed to verify their email.
+// The user is only kept if AfterCreate, which sends the verification email, succeeds.
+func (store *SQLStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error) {
+	var result CreateUserTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:         result.User.Username,
+			Email:            result.User.Email,
+			HashedSecretCode: arg.HashedSecretCode,
+			ExpiredAt:        arg.VerifyEmailExpiredAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return arg.AfterCreate(result.User, result.VerifyEmail)
+	})
+
+	return result, err
+}
+
+// VerifyEmailTxParams contains the input parameters of the verify email transaction
+type VerifyEmailTxParams struct {
+	ID               int64
+	HashedSecretCode string
+}
+
+// VerifyEmailTxResult is the result of the verify email transaction
+type VerifyEmailTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// VerifyEmailTx uses up a verification code and marks the email of its user as verified.
+// It returns sql.ErrNoRows if the code is unknown, used or expired,
+// or if the user has changed their email since it was sent.
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error) {
+	var result VerifyEmailTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.VerifyEmail, err = q.UseVerifyEmail(ctx, UseVerifyEmailParams{
+			ID:               arg.ID,
+			HashedSecretCode: arg.HashedSecretCode,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.UpdateUserEmailVerified(ctx, UpdateUserEmailVerifiedParams{
+			Username: result.VerifyEmail.Username,
+			Email:    result.VerifyEmail.Email,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// ResetPasswordTxParams contains the input parameters of the reset password transaction
+type ResetPasswordTxParams struct {
+	HashedToken    string
+	HashedPassword string
+	// CheckUser runs before the password is replaced, an error leaves the token unused
+	CheckUser func(user User) error
+}
+
+// ResetPasswordTxResult is the result of the reset password transaction
+type ResetPasswordTxResult struct {
+	User          User          `json:"user"`
+	PasswordReset PasswordReset `json:"password_reset"`
+}
+
+// ResetPasswordTx uses up a password reset token and sets the new password of its user.
+// Every other pending reset of the user is invalidated as well.
+// It returns sql.ErrNoRows if the token is unknown, used or expired,
+// or the error of CheckUser if it rejects the user.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error) {
+	var result ResetPasswordTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.PasswordReset, err = q.UsePasswordReset(ctx, arg.HashedToken)
+		if err != nil {
+			return err
+		}
+
+		user, err := q.GetUser(ctx, result.PasswordReset.Username)
+		if err != nil {
+			return err
+		}
+
+		err = arg.CheckUser(user)
+		if err != nil {
+			return err
+		}
+
+		err = q.InvalidatePasswordResets(ctx, result.PasswordReset.Username)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:          result.PasswordReset.Username,
+			HashedPassword:    arg.HashedPassword,
+			PasswordChangedAt: time.Now(),
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// UpdateUserTxParams contains the input parameters of the update user transaction
+type UpdateUserTxParams struct {
+	UpdateUserParams
+	HashedSecretCode     string
+	VerifyEmailExpiredAt time.Time
+	// AfterEmailChange runs last inside the transaction when a new email must be verified,
+	// an error rolls the update back
+	AfterEmailChange func(user User, verifyEmail VerifyEmail) error
+}
+
+// UpdateUserTxResult is the result of the update user transaction
+type UpdateUserTxResult struct {
+	User User `json:"user"`
+	// VerifyEmail is only set if the update left an email to verify
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// UpdateUserTx updates the given fields of a user.
+// Setting an email the user hasn't verified yet, which a changed email never is,
+// creates a new verification record and calls AfterEmailChange to send it.
+func (store *SQLStore) UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error) {
+	var result UpdateUserTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.UpdateUser(ctx, arg.UpdateUserParams)
+		if err != nil {
+			return err
+		}
+
+		if !arg.Email.Valid || result.User.IsEmailVerified {
+			return nil
+		}
+
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:         result.User.Username,
+			Email:            result.User.Email,
+			HashedSecretCode: arg.HashedSecretCode,
+			ExpiredAt:        arg.VerifyEmailExpiredAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return arg.AfterEmailChange(result.User, result.VerifyEmail)
+	})
+
+	return result, err
+}
+
+// CreateOIDCUserTxParams contains the input parameters of the create OIDC user transaction
+type CreateOIDCUserTxParams struct {
+	CreateUserParams
+	Issuer  string
+	Subject string
+	// IsEmailVerified is set when the identity provider vouches for the email
+	IsEmailVerified bool
+}
+
+// CreateOIDCUserTxResult is the result of the create OIDC user transaction
+type CreateOIDCUserTxResult struct {
+	User     User         `json:"user"`
+	Identity UserIdentity `json:"identity"`
+}
+
+// CreateOIDCUserTx creates a user signing up through an identity provider,
+// linked to their subject at the provider
+func (store *SQLStore) CreateOIDCUserTx(ctx context.Context, arg CreateOIDCUserTxParams) (CreateOIDCUserTxResult, error) {
+	var result CreateOIDCUserTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		if arg.IsEmailVerified {
+			result.User, err = q.UpdateUserEmailVerified(ctx, UpdateUserEmailVerifiedParams{
+				Username: result.User.Username,
+				Email:    result.User.Email,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		result.Identity, err = q.CreateUserIdentity(ctx, CreateUserIdentityParams{
+			Issuer:   arg.Issuer,
+			Subject:  arg.Subject,
+			Username: result.User.Username,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// AccountStatementTxParams contains the input parameters of the account statement transaction
+type AccountStatementTxParams struct {
+	AccountID int64
+	FromTime  time.Time
+	ToTime    time.Time
+	// the entries after the cursor are read, or the ones before it in reverse order with Reverse
+	CursorCreatedAt sql.NullTime
+	CursorID        sql.NullInt64
+	Reverse         bool
+	Limit           int32
+}
+
+// StatementEntry is an entry of a statement with the balance of the account right after it
+type StatementEntry struct {
+	ListStatementEntriesRow
+	Balance int64 `json:"balance"`
+}
+
+// AccountStatementTxResult is the result of the account statement transaction
+type AccountStatementTxResult struct {
+	OpeningBalance int64            `json:"opening_balance"`
+	ClosingBalance int64            `json:"closing_balance"`
+	Entries        []StatementEntry `json:"entries"`
+}
+
+// AccountStatementTx reads a page of the entries of an account made from FromTime until before ToTime,
+// with the balances around them. It reads from a single snapshot
+// so the balances add up with the entries while transfers go on.
+func (store *SQLStore) AccountStatementTx(ctx context.Context, arg AccountStatementTxParams) (AccountStatementTxResult, error) {
+	var result AccountStatementTxResult
+
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := store.execTxOptions(ctx, opts, func(q *Queries) error {
+		balances, err := q.GetStatementBalances(ctx, GetStatementBalancesParams{
+			AccountID: arg.AccountID,
+			FromTime:  arg.FromTime,
+			ToTime:    arg.ToTime,
+		})
+		if err != nil {
+			return err
+		}
+		result.OpeningBalance = balances.OpeningBalance
+		result.ClosingBalance = balances.ClosingBalance
+
+		var rows []ListStatementEntriesRow
+		if arg.Reverse {
+			reverseRows, err := q.ListStatementEntriesReverse(ctx, ListStatementEntriesReverseParams{
+				AccountID:       arg.AccountID,
+				FromTime:        arg.FromTime,
+				ToTime:          arg.ToTime,
+				CursorCreatedAt: arg.CursorCreatedAt.Time,
+				CursorID:        arg.CursorID.Int64,
+				Limit:           arg.Limit,
+			})
+			if err != nil {
+				return err
+			}
+			for _, row := range reverseRows {
+				rows = append(rows, ListStatementEntriesRow(row))
+			}
+		} else {
+			rows, err = q.ListStatementEntries(ctx, ListStatementEntriesParams{
+				AccountID:       arg.AccountID,
+				FromTime:        arg.FromTime,
+				ToTime:          arg.ToTime,
+				CursorCreatedAt: arg.CursorCreatedAt,
+				CursorID:        arg.CursorID,
+				Limit:           arg.Limit,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		result.Entries = make([]StatementEntry, len(rows))
+		if len(rows) == 0 {
+			return nil
+		}
+
+		// the balances are worked out back in time from the newest entry of the page
+		newest, step := len(rows)-1, -1
+		if arg.Reverse {
+			newest, step = 0, 1
+		}
+		balance, err := q.GetAccountBalanceAfterEntry(ctx, GetAccountBalanceAfterEntryParams{
+			AccountID:      arg.AccountID,
+			EntryCreatedAt: rows[newest].CreatedAt,
+			EntryID:        rows[newest].ID,
+		})
+		if err != nil {
+			return err
+		}
+		for i := newest; i >= 0 && i < len(rows); i += step {
+			result.Entries[i] = StatementEntry{ListStatementEntriesRow: rows[i], Balance: balance}
+			balance -= rows[i].Amount
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// Statuses of a scheduled transfer
+const (
+	ScheduledTransferActive    = "active"
+	ScheduledTransferPaused    = "paused"
+	ScheduledTransferCompleted = "completed"
+	ScheduledTransferFailed    = "failed"
+	ScheduledTransferCancelled = "cancelled"
+)
+
+// Statuses of a run of a scheduled transfer
+const (
+	ScheduledTransferRunSucceeded = "succeeded"
+	ScheduledTransferRunFailed    = "failed"
+	// ScheduledTransferRunSkipped runs were missed while no worker was running and never attempted
+	ScheduledTransferRunSkipped = "skipped"
+)
+
+// RunScheduledTransferTxParams contains the input parameters of the run scheduled transfer transaction
+type RunScheduledTransferTxParams struct {
+	// Next returns the run of the scheduled transfer after the one due at the given time,
+	// or the zero time if there is none
+	Next func(scheduledTransfer ScheduledTransfer, after time.Time) (time.Time, error)
+	// RetryAt is when a failed run is tried again
+	RetryAt time.Time
+	// MaxAttempts is how many times a run is tried before it's skipped
+	MaxAttempts int32
+}
+
+// RunScheduledTransferTxResult is the result of the run scheduled transfer transaction
+type RunScheduledTransferTxResult struct {
+	// ScheduledTransfer is the scheduled transfer after the run
+	ScheduledTransfer ScheduledTransfer    `json:"scheduled_transfer"`
+	Run               ScheduledTransferRun `json:"run"`
+	// Transfer is only set if the run succeeded
+	Transfer TransferTxResult `json:"transfer"`
+}
+
+// RunScheduledTransferTx claims a scheduled transfer that is due and runs it.
+// Concurrent transactions skip the transfers claimed by others instead of waiting for them.
+// The run is recorded whether the transfer succeeds or not, a failed run is retried at RetryAt
+// until it has been tried MaxAttempts times. It returns sql.ErrNoRows if nothing is due.
+// The transfer then moves to its first run after now, the runs missed in between are recorded as skipped.
+func (store *SQLStore) RunScheduledTransferTx(ctx context.Context, arg RunScheduledTransferTxParams) (RunScheduledTransferTxResult, error) {
+	var result RunScheduledTransferTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		scheduledTransfer, err := q.ClaimDueScheduledTransfer(ctx)
+		if err != nil {
+			return err
+		}
+
+		runArg := CreateScheduledTransferRunParams{
+			ScheduledTransferID: scheduledTransfer.ID,
+			DueAt:               scheduledTransfer.NextRunAt,
+			Attempt:             scheduledTransfer.FailedAttempts + 1,
+			Status:              ScheduledTransferRunSucceeded,
+		}
+
+		// a failed transfer is rolled back to the savepoint so the failure can still be recorded
+		_, err = q.db.ExecContext(ctx, "SAVEPOINT scheduled_transfer")
+		if err != nil {
+			return err
+		}
+		result.Transfer, err = runScheduledTransfer(ctx, q, scheduledTransfer)
+		if err != nil {
+			if _, rbErr := q.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT scheduled_transfer"); rbErr != nil {
+				return fmt.Errorf("transfer err: %v, rb err: %v", err, rbErr)
+			}
+			result.Transfer = TransferTxResult{}
+			runArg.Status = ScheduledTransferRunFailed
+			runArg.Error = err.Error()
+		} else {
+			runArg.TransferID = sql.NullInt64{Int64: result.Transfer.Transfer.ID, Valid: true}
+		}
+
+		result.Run, err = q.CreateScheduledTransferRun(ctx, runArg)
+		if err != nil {
+			return err
+		}
+
+		advanceArg := AdvanceScheduledTransferParams{
+			ID:        scheduledTransfer.ID,
+			NextRunAt: scheduledTransfer.NextRunAt,
+			Status:    ScheduledTransferActive,
+		}
+		if runArg.Status == ScheduledTransferRunFailed && runArg.Attempt < arg.MaxAttempts {
+			advanceArg.RetryAt = sql.NullTime{Time: arg.RetryAt, Valid: true}
+			advanceArg.FailedAttempts = runArg.Attempt
+		} else {
+			next, skipped, err := nextScheduledRun(scheduledTransfer, arg.Next, time.Now())
+			if err != nil {
+				return err
+			}
+			if len(skipped) > 0 {
+				err = q.CreateSkippedScheduledTransferRuns(ctx, CreateSkippedScheduledTransferRunsParams{
+					ScheduledTransferID: scheduledTransfer.ID,
+					DueAts:              skipped,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if next.IsZero() || (scheduledTransfer.EndAt.Valid && next.After(scheduledTransfer.EndAt.Time)) {
+				// a one-off transfer that never went through failed, the last run of a schedule completes it
+				advanceArg.Status = ScheduledTransferCompleted
+				if runArg.Status == ScheduledTransferRunFailed && scheduledTransfer.Schedule == "" {
+					advanceArg.Status = ScheduledTransferFailed
+				}
+			} else {
+				advanceArg.NextRunAt = next
+			}
+		}
+
+		result.ScheduledTransfer, err = q.AdvanceScheduledTransfer(ctx, advanceArg)
+		return err
+	})
+
+	return result, err
+}
+
+// nextScheduledRun returns the first run of a scheduled transfer after the one due at its NextRunAt
+// that is still to come at now, and the runs due until then. Those were missed while no worker was running,
+// they are skipped instead of being made one after the other.
+func nextScheduledRun(
+	scheduledTransfer ScheduledTransfer,
+	next func(scheduledTransfer ScheduledTransfer, after time.Time) (time.Time, error),
+	now time.Time,
+) (time.Time, []time.Time, error) {
+	var skipped []time.Time
+
+	run, err := next(scheduledTransfer, scheduledTransfer.NextRunAt)
+	for err == nil && !run.IsZero() && !run.After(now) {
+		if scheduledTransfer.EndAt.Valid && run.After(scheduledTransfer.EndAt.Time) {
+			break
+		}
+		skipped = append(skipped, run)
+		run, err = next(scheduledTransfer, run)
+	}
+	return run, skipped, err
+}
+
+// runScheduledTransfer makes the transfer of a scheduled transfer within the transaction of q,
+// if its owner and accounts aren't frozen
+func runScheduledTransfer(ctx context.Context, q *Queries, scheduledTransfer ScheduledTransfer) (TransferTxResult, error) {
+	owner, err := q.GetUser(ctx, scheduledTransfer.Owner)
+	if err != nil {
+		return TransferTxResult{}, err
+	}
+	if owner.IsFrozen {
+		return TransferTxResult{}, fmt.Errorf("user %s is frozen", owner.Username)
+	}
+
+	for _, accountID := range []int64{scheduledTransfer.FromAccountID, scheduledTransfer.ToAccountID} {
+		account, err := q.GetAccount(ctx, accountID)
+		if err != nil {
+			return TransferTxResult{}, err
+		}
+		if account.IsFrozen {
+			return TransferTxResult{}, fmt.Errorf("account [%d] is frozen", account.ID)
+		}
+	}
+
+	return transfer(ctx, q, TranferTxParams{
+		FromAccountID: scheduledTransfer.FromAccountID,
+		ToAccountID:   scheduledTransfer.ToAccountID,
+		Amount:        scheduledTransfer.Amount,
+	})
+}
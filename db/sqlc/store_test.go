@@ -2,16 +2,54 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/amrizal94/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
 
+func TestTransferTxFrozenAccount(t *testing.T) {
+	store := NewStore(testDB)
+
+	for _, frozen := range []string{"from", "to"} {
+		account1 := createRandomAccountWithBalance(t, 1000)
+		account2 := createRandomAccount(t)
+
+		frozenAccount := account1
+		if frozen == "to" {
+			frozenAccount = account2
+		}
+		_, err := testQueries.UpdateAccountFrozen(context.Background(), UpdateAccountFrozenParams{
+			ID:       frozenAccount.ID,
+			IsFrozen: true,
+		})
+		require.NoError(t, err)
+
+		_, err = store.TransferTx(context.Background(), TranferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+		})
+		require.ErrorIs(t, err, ErrAccountFrozen, frozen)
+
+		// nothing moved
+		updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+		require.NoError(t, err)
+		require.Equal(t, account1.Balance, updatedAccount1.Balance)
+	}
+}
+
 func TestTransferTx(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
+	account1 := createRandomAccountWithBalance(t, 1000)
 	account2 := createRandomAccount(t)
 	fmt.Println(">> before :", account1.Balance, account2.Balance)
 
@@ -60,6 +98,7 @@ func TestTransferTx(t *testing.T) {
 		require.NotEmpty(t, fromEntry)
 		require.Equal(t, fromEntry.AccountsID, account1.ID)
 		require.Equal(t, -amount, fromEntry.Amount)
+		require.Equal(t, transfer.ID, fromEntry.TransferID.Int64)
 		require.NotZero(t, fromEntry.ID)
 		require.NotZero(t, fromEntry.CreatedAt)
 
@@ -70,6 +109,7 @@ func TestTransferTx(t *testing.T) {
 		require.NotEmpty(t, ToEntry)
 		require.Equal(t, ToEntry.AccountsID, account2.ID)
 		require.Equal(t, amount, ToEntry.Amount)
+		require.Equal(t, transfer.ID, ToEntry.TransferID.Int64)
 		require.NotZero(t, ToEntry.ID)
 		require.NotZero(t, ToEntry.CreatedAt)
 
@@ -115,8 +155,8 @@ func TestTransferTx(t *testing.T) {
 func TestTransferTxDeadLock(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account1 := createRandomAccountWithBalance(t, 1000)
+	account2 := createRandomAccountWithBalance(t, 1000)
 	fmt.Println(">> before :", account1.Balance, account2.Balance)
 
 	n := 10
@@ -161,3 +201,907 @@ func TestTransferTxDeadLock(t *testing.T) {
 	require.Equal(t, account1.Balance, updateAccount1.Balance)
 	require.Equal(t, account2.Balance, updateAccount2.Balance)
 }
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	n := 10
+	amount := int64(10)
+	account1 := createRandomAccountWithBalance(t, amount*int64(n)/2)
+	account2 := createRandomAccount(t)
+
+	errs := make(chan error)
+
+	// twice as many concurrent transfers as the account can pay for
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(context.Background(), TranferTxParams{
+				FromAccountID: account1.ID,
+				ToAccountID:   account2.ID,
+				Amount:        amount,
+			})
+
+			errs <- err
+		}()
+	}
+
+	failed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+			failed++
+		}
+	}
+	require.Equal(t, n/2, failed)
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, updatedAccount1.Balance)
+
+	updatedAccount2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance+amount*int64(n)/2, updatedAccount2.Balance)
+
+	// nothing is left of the transfers that failed
+	transfers, err := testQueries.ListTransfers(context.Background(), ListTransfersParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account1.ID,
+		Limit:         int32(n),
+	})
+	require.NoError(t, err)
+	require.Len(t, transfers, n/2)
+}
+
+func TestTransferTxLimits(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1, tier := createRandomTierAccount(t, 1000)
+	account2 := createRandomAccount(t)
+
+	_, err := testQueries.SetTierTransferLimits(context.Background(), SetTierTransferLimitsParams{
+		Tier:              tier,
+		Currency:          account1.Currency,
+		MaxPerTransaction: sql.NullInt64{Int64: 100, Valid: true},
+		MaxDailyAmount:    sql.NullInt64{Int64: 250, Valid: true},
+		MaxDailyCount:     sql.NullInt32{Int32: 3, Valid: true},
+	})
+	require.NoError(t, err)
+
+	transfer := func(amount int64) error {
+		_, err := store.TransferTx(context.Background(), TranferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		})
+		return err
+	}
+
+	require.ErrorIs(t, transfer(101), ErrTransferLimitExceeded)
+	require.NoError(t, transfer(100))
+	require.NoError(t, transfer(100))
+	require.ErrorIs(t, transfer(51), ErrTransferLimitExceeded)
+	require.NoError(t, transfer(50))
+
+	// the account raises the limits of its tier but not the count of transfers
+	_, err = testQueries.SetAccountTransferLimits(context.Background(), SetAccountTransferLimitsParams{
+		AccountID:         account1.ID,
+		MaxPerTransaction: sql.NullInt64{Int64: 500, Valid: true},
+		MaxDailyAmount:    sql.NullInt64{Int64: 1000, Valid: true},
+	})
+	require.NoError(t, err)
+	require.ErrorIs(t, transfer(10), ErrTransferLimitExceeded)
+
+	// nothing is left of the transfers over the limits
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-250, updatedAccount1.Balance)
+}
+
+func TestTransferTxDailyLimitConcurrent(t *testing.T) {
+	store := NewStore(testDB)
+
+	n := 10
+	amount := int64(10)
+	account1 := createRandomAccountWithBalance(t, amount*int64(n))
+	account2 := createRandomAccount(t)
+
+	_, err := testQueries.SetAccountTransferLimits(context.Background(), SetAccountTransferLimitsParams{
+		AccountID:      account1.ID,
+		MaxDailyAmount: sql.NullInt64{Int64: amount * int64(n) / 2, Valid: true},
+	})
+	require.NoError(t, err)
+
+	errs := make(chan error)
+
+	// twice as many concurrent transfers as the limit of the day allows
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(context.Background(), TranferTxParams{
+				FromAccountID: account1.ID,
+				ToAccountID:   account2.ID,
+				Amount:        amount,
+			})
+
+			errs <- err
+		}()
+	}
+
+	failed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, ErrTransferLimitExceeded)
+			failed++
+		}
+	}
+	require.Equal(t, n/2, failed)
+
+	limits, err := testQueries.GetTransferLimits(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, amount*int64(n)/2, limits.DailyAmount)
+	require.Equal(t, int32(n/2), limits.DailyCount)
+}
+
+func createRandomUserTx(t *testing.T, store Store, secretCode string) CreateUserTxResult {
+	hashedPassword, err := util.HashPassword(util.RandomString(6))
+	require.NoError(t, err)
+
+	result, err := store.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       util.RandomOwner(),
+			HashedPassword: hashedPassword,
+			FullName:       util.RandomOwner(),
+			Email:          util.RandomEmail(),
+		},
+		HashedSecretCode:     util.HashSecretCode(secretCode),
+		VerifyEmailExpiredAt: time.Now().Add(time.Minute),
+		AfterCreate: func(user User, verifyEmail VerifyEmail) error {
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, result.User.IsEmailVerified)
+	require.Equal(t, result.User.Username, result.VerifyEmail.Username)
+	require.Equal(t, result.User.Email, result.VerifyEmail.Email)
+	require.False(t, result.VerifyEmail.IsUsed)
+
+	return result
+}
+
+func TestCreateUserTx(t *testing.T) {
+	store := NewStore(testDB)
+	createRandomUserTx(t, store, util.RandomString(32))
+}
+
+func TestCreateUserTxRollback(t *testing.T) {
+	store := NewStore(testDB)
+	username := util.RandomOwner()
+	afterCreateErr := errors.New("cannot send email")
+
+	_, err := store.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       username,
+			HashedPassword: util.RandomString(32),
+			FullName:       util.RandomOwner(),
+			Email:          util.RandomEmail(),
+		},
+		HashedSecretCode:     util.HashSecretCode(util.RandomString(32)),
+		VerifyEmailExpiredAt: time.Now().Add(time.Minute),
+		AfterCreate: func(user User, verifyEmail VerifyEmail) error {
+			return afterCreateErr
+		},
+	})
+	require.ErrorIs(t, err, afterCreateErr)
+
+	_, err = testQueries.GetUser(context.Background(), username)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	store := NewStore(testDB)
+	secretCode := util.RandomString(32)
+	created := createRandomUserTx(t, store, secretCode)
+
+	_, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		ID:               created.VerifyEmail.ID,
+		HashedSecretCode: util.HashSecretCode("wrong code"),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	result, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		ID:               created.VerifyEmail.ID,
+		HashedSecretCode: util.HashSecretCode(secretCode),
+	})
+	require.NoError(t, err)
+	require.True(t, result.VerifyEmail.IsUsed)
+	require.True(t, result.User.IsEmailVerified)
+
+	// the code can only be used once
+	_, err = store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		ID:               created.VerifyEmail.ID,
+		HashedSecretCode: util.HashSecretCode(secretCode),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	resetToken := util.RandomString(32)
+	otherToken := util.RandomString(32)
+	for _, token := range []string{resetToken, otherToken} {
+		_, err := testQueries.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+			Username:    user.Username,
+			HashedToken: util.HashSecretCode(token),
+			ExpiredAt:   time.Now().Add(time.Minute),
+		})
+		require.NoError(t, err)
+	}
+
+	hashedPassword, err := util.HashPassword(util.RandomString(8))
+	require.NoError(t, err)
+
+	result, err := store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		HashedToken:    util.HashSecretCode(resetToken),
+		HashedPassword: hashedPassword,
+		CheckUser: func(checked User) error {
+			require.Equal(t, user.Username, checked.Username)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, result.PasswordReset.IsUsed)
+	require.Equal(t, user.Username, result.User.Username)
+	require.Equal(t, hashedPassword, result.User.HashedPassword)
+	require.True(t, result.User.PasswordChangedAt.After(user.PasswordChangedAt))
+
+	// neither the used token nor the other pending one work anymore
+	for _, token := range []string{resetToken, otherToken} {
+		_, err = store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+			HashedToken:    util.HashSecretCode(token),
+			HashedPassword: hashedPassword,
+			CheckUser:      acceptUser,
+		})
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	}
+}
+
+func TestResetPasswordTxExpired(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	resetToken := util.RandomString(32)
+	_, err := testQueries.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+		Username:    user.Username,
+		HashedToken: util.HashSecretCode(resetToken),
+		ExpiredAt:   time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	_, err = store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		HashedToken:    util.HashSecretCode(resetToken),
+		HashedPassword: util.RandomString(32),
+		CheckUser:      acceptUser,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestResetPasswordTxRejectedPassword(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	resetToken := util.RandomString(32)
+	_, err := testQueries.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+		Username:    user.Username,
+		HashedToken: util.HashSecretCode(resetToken),
+		ExpiredAt:   time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	arg := ResetPasswordTxParams{
+		HashedToken:    util.HashSecretCode(resetToken),
+		HashedPassword: util.RandomString(32),
+		CheckUser: func(user User) error {
+			return util.ErrPasswordPersonal
+		},
+	}
+	_, err = store.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, util.ErrPasswordPersonal)
+
+	// the token can still be used with a better password
+	arg.CheckUser = acceptUser
+	_, err = store.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+}
+
+func acceptUser(user User) error {
+	return nil
+}
+
+func TestUpdateUserTx(t *testing.T) {
+	store := NewStore(testDB)
+	created := createRandomUserTx(t, store, util.RandomString(32))
+
+	// no email, no verification
+	result, err := store.UpdateUserTx(context.Background(), UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			Username: created.User.Username,
+			FullName: sql.NullString{String: util.RandomOwner(), Valid: true},
+		},
+		AfterEmailChange: func(user User, verifyEmail VerifyEmail) error {
+			t.Fatal("no email was changed")
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Zero(t, result.VerifyEmail.ID)
+
+	secretCode := util.RandomString(32)
+	newEmail := util.RandomEmail()
+	result, err = store.UpdateUserTx(context.Background(), UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			Username: created.User.Username,
+			Email:    sql.NullString{String: newEmail, Valid: true},
+		},
+		HashedSecretCode:     util.HashSecretCode(secretCode),
+		VerifyEmailExpiredAt: time.Now().Add(time.Minute),
+		AfterEmailChange: func(user User, verifyEmail VerifyEmail) error {
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newEmail, result.User.Email)
+	require.False(t, result.User.IsEmailVerified)
+	require.Equal(t, newEmail, result.VerifyEmail.Email)
+
+	// the link sent to the old address no longer verifies anything
+	_, err = store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		ID:               created.VerifyEmail.ID,
+		HashedSecretCode: created.VerifyEmail.HashedSecretCode,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	verified, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		ID:               result.VerifyEmail.ID,
+		HashedSecretCode: util.HashSecretCode(secretCode),
+	})
+	require.NoError(t, err)
+	require.True(t, verified.User.IsEmailVerified)
+}
+
+func TestUpdateUserTxRollback(t *testing.T) {
+	store := NewStore(testDB)
+	created := createRandomUserTx(t, store, util.RandomString(32))
+	afterEmailChangeErr := errors.New("cannot send email")
+
+	_, err := store.UpdateUserTx(context.Background(), UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			Username: created.User.Username,
+			Email:    sql.NullString{String: util.RandomEmail(), Valid: true},
+		},
+		HashedSecretCode:     util.HashSecretCode(util.RandomString(32)),
+		VerifyEmailExpiredAt: time.Now().Add(time.Minute),
+		AfterEmailChange: func(user User, verifyEmail VerifyEmail) error {
+			return afterEmailChangeErr
+		},
+	})
+	require.ErrorIs(t, err, afterEmailChangeErr)
+
+	user, err := testQueries.GetUser(context.Background(), created.User.Username)
+	require.NoError(t, err)
+	require.Equal(t, created.User.Email, user.Email)
+}
+
+func TestCreateOIDCUserTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	arg := CreateOIDCUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       util.RandomOwner(),
+			HashedPassword: util.RandomString(32),
+			FullName:       util.RandomOwner(),
+			Email:          util.RandomEmail(),
+		},
+		Issuer:          "https://idp.example.com",
+		Subject:         util.RandomString(16),
+		IsEmailVerified: true,
+	}
+
+	result, err := store.CreateOIDCUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, result.User.Username)
+	require.True(t, result.User.IsEmailVerified)
+	require.Equal(t, arg.Issuer, result.Identity.Issuer)
+	require.Equal(t, arg.Subject, result.Identity.Subject)
+	require.Equal(t, arg.Username, result.Identity.Username)
+
+	// the subject is already linked, so the second user is rolled back
+	arg.Username = util.RandomOwner()
+	arg.Email = util.RandomEmail()
+	_, err = store.CreateOIDCUserTx(context.Background(), arg)
+	require.Error(t, err)
+
+	_, err = testQueries.GetUser(context.Background(), arg.Username)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestIdempotentTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, 1000)
+	account2 := createRandomAccount(t)
+	amount := int64(10)
+
+	arg := IdempotentTransferTxParams{
+		TranferTxParams: TranferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		},
+		Username:       account1.Owner,
+		IdempotencyKey: util.RandomString(32),
+		RequestHash:    util.RandomString(64),
+		ExpiredAt:      time.Now().Add(time.Hour),
+	}
+
+	// run n concurrent requests with the same key, only one of them transfers
+	n := 5
+	errs := make(chan error)
+	results := make(chan IdempotentTransferTxResult)
+
+	for i := 0; i < n; i++ {
+		go func() {
+			result, err := store.IdempotentTransferTx(context.Background(), arg)
+			errs <- err
+			results <- result
+		}()
+	}
+
+	var transferred IdempotentTransferTxResult
+	replayed := 0
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+		result := <-results
+
+		if result.Replayed {
+			replayed++
+			require.Empty(t, result.Transfer)
+		} else {
+			transferred = result
+		}
+		require.Equal(t, arg.RequestHash, result.IdempotencyKey.RequestHash)
+	}
+	require.Equal(t, n-1, replayed)
+	require.NotZero(t, transferred.Transfer.ID)
+
+	// the stored response is the result of the transfer
+	var stored TransferTxResult
+	err := json.Unmarshal(transferred.IdempotencyKey.Response, &stored)
+	require.NoError(t, err)
+	require.Equal(t, transferred.Transfer.ID, stored.Transfer.ID)
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-amount, updatedAccount1.Balance)
+}
+
+func TestIdempotentTransferTxExpiredKey(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, 1000)
+	account2 := createRandomAccount(t)
+
+	arg := IdempotentTransferTxParams{
+		TranferTxParams: TranferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+		},
+		Username:       account1.Owner,
+		IdempotencyKey: util.RandomString(32),
+		RequestHash:    util.RandomString(64),
+		ExpiredAt:      time.Now().Add(-time.Minute),
+	}
+
+	result1, err := store.IdempotentTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, result1.Replayed)
+
+	_, err = testQueries.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		Username: arg.Username,
+		Key:      arg.IdempotencyKey,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// an expired key is free to use again
+	arg.ExpiredAt = time.Now().Add(time.Hour)
+	result2, err := store.IdempotentTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, result2.Replayed)
+	require.NotEqual(t, result1.Transfer.ID, result2.Transfer.ID)
+}
+
+func TestAccountStatementTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, 1000)
+	account2 := createRandomAccountWithBalance(t, 1000)
+
+	makeTransfer := func(from, to Account, amount int64) TransferTxResult {
+		result, err := store.TransferTx(context.Background(), TranferTxParams{
+			FromAccountID: from.ID,
+			ToAccountID:   to.ID,
+			Amount:        amount,
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	makeTransfer(account1, account2, 10)
+	fromTime := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	result1 := makeTransfer(account2, account1, 25)
+	result2 := makeTransfer(account1, account2, 5)
+	time.Sleep(10 * time.Millisecond)
+	toTime := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	last := makeTransfer(account1, account2, 7)
+
+	statement, err := store.AccountStatementTx(context.Background(), AccountStatementTxParams{
+		AccountID: account1.ID,
+		FromTime:  fromTime,
+		ToTime:    toTime,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, account1.Balance-10, statement.OpeningBalance)
+	require.Equal(t, account1.Balance-10+25-5, statement.ClosingBalance)
+	require.Equal(t, last.FromAccount.Balance+7, statement.ClosingBalance)
+
+	require.Len(t, statement.Entries, 2)
+	require.Equal(t, result1.ToEntry.ID, statement.Entries[0].ID)
+	require.Equal(t, result1.Transfer.ID, statement.Entries[0].TransferID.Int64)
+	require.Equal(t, account2.ID, statement.Entries[0].CounterpartyAccountID)
+	require.Equal(t, statement.OpeningBalance+25, statement.Entries[0].Balance)
+	require.Equal(t, result2.FromEntry.ID, statement.Entries[1].ID)
+	require.Equal(t, statement.ClosingBalance, statement.Entries[1].Balance)
+
+	// a page before the last entry has the same balances
+	page, err := store.AccountStatementTx(context.Background(), AccountStatementTxParams{
+		AccountID:       account1.ID,
+		FromTime:        fromTime,
+		ToTime:          toTime,
+		CursorCreatedAt: sql.NullTime{Time: statement.Entries[1].CreatedAt, Valid: true},
+		CursorID:        sql.NullInt64{Int64: statement.Entries[1].ID, Valid: true},
+		Reverse:         true,
+		Limit:           10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, statement.Entries[:1], page.Entries)
+
+	// a period without entries keeps the balance
+	statement, err = store.AccountStatementTx(context.Background(), AccountStatementTxParams{
+		AccountID: account1.ID,
+		FromTime:  fromTime.Add(-time.Hour),
+		ToTime:    fromTime.Add(-time.Minute),
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, statement.Entries)
+	require.Equal(t, account1.Balance, statement.OpeningBalance)
+	require.Equal(t, account1.Balance, statement.ClosingBalance)
+}
+
+func createRandomAccountInCurrency(t *testing.T, currency string, balance int64) Account {
+	user := createRandomUser(t)
+	account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  balance,
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+// convertAtQuote converts at the rate of the quote, rounding down
+func convertAtQuote(quote FxQuote, amount int64) (int64, error) {
+	rate, ok := new(big.Rat).SetString(quote.Rate)
+	if !ok {
+		return 0, fmt.Errorf("invalid rate %q", quote.Rate)
+	}
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), rate)
+	return new(big.Int).Quo(converted.Num(), converted.Denom()).Int64(), nil
+}
+
+func TestCrossCurrencyTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountInCurrency(t, util.USD, 1000)
+	account2 := createRandomAccountInCurrency(t, util.EUR, 0)
+	quote := createRandomFXQuote(t, account1.Owner, util.USD, util.EUR, time.Now().Add(time.Minute))
+
+	amount := int64(101)
+	arg := CrossCurrencyTransferTxParams{
+		TranferTxParams: TranferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		},
+		FXQuoteParams: FXQuoteParams{
+			Username: account1.Owner,
+			QuoteID:  quote.ID,
+			Convert:  convertAtQuote,
+		},
+	}
+
+	result, err := store.CrossCurrencyTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	// 101 at 1.25 is 126.25, rounded down
+	toAmount := int64(126)
+	require.Equal(t, amount, result.Transfer.Amount)
+	require.Equal(t, toAmount, result.Transfer.ToAmount.Int64)
+	require.Equal(t, quote.Rate, result.Transfer.FxRate.String)
+	require.Equal(t, quote.ID, result.Transfer.FxQuoteID.UUID)
+
+	require.Equal(t, -amount, result.FromEntry.Amount)
+	require.Equal(t, toAmount, result.ToEntry.Amount)
+	require.Equal(t, account1.Balance-amount, result.FromAccount.Balance)
+	require.Equal(t, account2.Balance+toAmount, result.ToAccount.Balance)
+
+	transfer, err := testQueries.GetTransfer(context.Background(), result.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Transfer, transfer)
+
+	// the quote is used up
+	_, err = store.CrossCurrencyTransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidQuote)
+
+	// a quote only converts between its currencies
+	account3 := createRandomAccountInCurrency(t, util.CAD, 0)
+	arg.ToAccountID = account3.ID
+	arg.QuoteID = createRandomFXQuote(t, account1.Owner, util.USD, util.EUR, time.Now().Add(time.Minute)).ID
+	_, err = store.CrossCurrencyTransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidQuote)
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-amount, updatedAccount1.Balance)
+}
+
+// runScheduledTransferTx runs due scheduled transfers until the one with id has run.
+// Other tests may have left transfers that are due as well.
+func runScheduledTransferTx(t *testing.T, store Store, arg RunScheduledTransferTxParams, id int64) RunScheduledTransferTxResult {
+	for {
+		result, err := store.RunScheduledTransferTx(context.Background(), arg)
+		require.NoError(t, err)
+		if result.ScheduledTransfer.ID == id {
+			return result
+		}
+	}
+}
+
+func nextDay(scheduledTransfer ScheduledTransfer, after time.Time) (time.Time, error) {
+	return after.AddDate(0, 0, 1), nil
+}
+
+func TestRunScheduledTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, 15)
+	account2 := createRandomAccount(t)
+	dueAt := time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+	scheduledTransfer := createRandomScheduledTransfer(t, account1, account2, "RRULE:FREQ=DAILY", dueAt)
+
+	arg := RunScheduledTransferTxParams{
+		Next:        nextDay,
+		RetryAt:     time.Now().Add(-time.Second),
+		MaxAttempts: 2,
+	}
+
+	// the first run goes through and the transfer is due the next day
+	result := runScheduledTransferTx(t, store, arg, scheduledTransfer.ID)
+	require.Equal(t, ScheduledTransferRunSucceeded, result.Run.Status)
+	require.Equal(t, int32(1), result.Run.Attempt)
+	require.True(t, result.Run.DueAt.Equal(dueAt))
+	require.Equal(t, result.Transfer.Transfer.ID, result.Run.TransferID.Int64)
+	require.Equal(t, int64(5), result.Transfer.FromAccount.Balance)
+	require.True(t, result.ScheduledTransfer.NextRunAt.Equal(dueAt.AddDate(0, 0, 1)))
+	require.Equal(t, ScheduledTransferActive, result.ScheduledTransfer.Status)
+
+	// the next run can't be paid for, it's retried until MaxAttempts
+	_, err := testQueries.AdvanceScheduledTransfer(context.Background(), AdvanceScheduledTransferParams{
+		ID:        scheduledTransfer.ID,
+		NextRunAt: dueAt,
+		Status:    ScheduledTransferActive,
+	})
+	require.NoError(t, err)
+
+	result = runScheduledTransferTx(t, store, arg, scheduledTransfer.ID)
+	require.Equal(t, ScheduledTransferRunFailed, result.Run.Status)
+	require.Equal(t, ErrInsufficientFunds.Error(), result.Run.Error)
+	require.False(t, result.Run.TransferID.Valid)
+	require.Empty(t, result.Transfer)
+	require.True(t, result.ScheduledTransfer.NextRunAt.Equal(dueAt))
+	require.True(t, result.ScheduledTransfer.RetryAt.Valid)
+	require.Equal(t, int32(1), result.ScheduledTransfer.FailedAttempts)
+
+	result = runScheduledTransferTx(t, store, arg, scheduledTransfer.ID)
+	require.Equal(t, ScheduledTransferRunFailed, result.Run.Status)
+	require.Equal(t, int32(2), result.Run.Attempt)
+	// the run is skipped
+	require.True(t, result.ScheduledTransfer.NextRunAt.Equal(dueAt.AddDate(0, 0, 1)))
+	require.False(t, result.ScheduledTransfer.RetryAt.Valid)
+	require.Zero(t, result.ScheduledTransfer.FailedAttempts)
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), updatedAccount1.Balance)
+
+	runs, err := testQueries.ListScheduledTransferRuns(context.Background(), ListScheduledTransferRunsParams{
+		ScheduledTransferID: scheduledTransfer.ID,
+		Limit:               10,
+	})
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	require.Equal(t, result.Run, runs[0])
+}
+
+func TestRunScheduledTransferTxSkipsMissedRuns(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, 1000)
+	account2 := createRandomAccount(t)
+	dueAt := time.Now().AddDate(0, 0, -3).Add(-time.Minute).Truncate(time.Microsecond)
+	scheduledTransfer := createRandomScheduledTransfer(t, account1, account2, "RRULE:FREQ=DAILY", dueAt)
+
+	arg := RunScheduledTransferTxParams{
+		Next:        nextDay,
+		RetryAt:     time.Now().Add(time.Hour),
+		MaxAttempts: 1,
+	}
+
+	// only the run the transfer is due for is made, the ones missed since are skipped
+	result := runScheduledTransferTx(t, store, arg, scheduledTransfer.ID)
+	require.Equal(t, ScheduledTransferRunSucceeded, result.Run.Status)
+	require.True(t, result.Run.DueAt.Equal(dueAt))
+	require.True(t, result.ScheduledTransfer.NextRunAt.Equal(dueAt.AddDate(0, 0, 4)))
+	require.True(t, result.ScheduledTransfer.NextRunAt.After(time.Now()))
+
+	runs, err := testQueries.ListScheduledTransferRuns(context.Background(), ListScheduledTransferRunsParams{
+		ScheduledTransferID: scheduledTransfer.ID,
+		Limit:               10,
+	})
+	require.NoError(t, err)
+	require.Len(t, runs, 4)
+
+	var skipped []time.Time
+	for _, run := range runs {
+		if run.ID == result.Run.ID {
+			continue
+		}
+		require.Equal(t, ScheduledTransferRunSkipped, run.Status)
+		require.Zero(t, run.Attempt)
+		require.False(t, run.TransferID.Valid)
+		skipped = append(skipped, run.DueAt)
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Before(skipped[j]) })
+	for i, due := range skipped {
+		require.True(t, due.Equal(dueAt.AddDate(0, 0, i+1)))
+	}
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-scheduledTransfer.Amount, updatedAccount1.Balance)
+}
+
+func TestNextScheduledRun(t *testing.T) {
+	now := time.Now()
+	dueAt := now.AddDate(0, 0, -3).Add(-time.Minute)
+
+	next, skipped, err := nextScheduledRun(ScheduledTransfer{NextRunAt: dueAt}, nextDay, now)
+	require.NoError(t, err)
+	require.Equal(t, dueAt.AddDate(0, 0, 4), next)
+	require.Equal(t, []time.Time{dueAt.AddDate(0, 0, 1), dueAt.AddDate(0, 0, 2), dueAt.AddDate(0, 0, 3)}, skipped)
+
+	// runs after the end aren't skipped, the transfer is over
+	endAt := sql.NullTime{Time: dueAt.AddDate(0, 0, 1), Valid: true}
+	next, skipped, err = nextScheduledRun(ScheduledTransfer{NextRunAt: dueAt, EndAt: endAt}, nextDay, now)
+	require.NoError(t, err)
+	require.Equal(t, dueAt.AddDate(0, 0, 2), next)
+	require.Equal(t, []time.Time{dueAt.AddDate(0, 0, 1)}, skipped)
+
+	// a run that isn't late has nothing to skip
+	next, skipped, err = nextScheduledRun(ScheduledTransfer{NextRunAt: now.Add(-time.Minute)}, nextDay, now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(-time.Minute).AddDate(0, 0, 1), next)
+	require.Empty(t, skipped)
+}
+
+func TestRunScheduledTransferTxOneOff(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, 1000)
+	account2 := createRandomAccount(t)
+	scheduledTransfer := createRandomScheduledTransfer(t, account1, account2, "", time.Now().Add(-time.Minute))
+
+	arg := RunScheduledTransferTxParams{
+		Next: func(scheduledTransfer ScheduledTransfer, after time.Time) (time.Time, error) {
+			return time.Time{}, nil
+		},
+		RetryAt:     time.Now().Add(time.Hour),
+		MaxAttempts: 1,
+	}
+
+	result := runScheduledTransferTx(t, store, arg, scheduledTransfer.ID)
+	require.Equal(t, ScheduledTransferRunSucceeded, result.Run.Status)
+	require.Equal(t, ScheduledTransferCompleted, result.ScheduledTransfer.Status)
+
+	// a one-off transfer of a frozen account fails for good after its last attempt
+	scheduledTransfer = createRandomScheduledTransfer(t, account1, account2, "", time.Now().Add(-time.Minute))
+	_, err := testQueries.UpdateAccountFrozen(context.Background(), UpdateAccountFrozenParams{ID: account2.ID, IsFrozen: true})
+	require.NoError(t, err)
+
+	result = runScheduledTransferTx(t, store, arg, scheduledTransfer.ID)
+	require.Equal(t, ScheduledTransferRunFailed, result.Run.Status)
+	require.Contains(t, result.Run.Error, "frozen")
+	require.Equal(t, ScheduledTransferFailed, result.ScheduledTransfer.Status)
+}
+
+func TestRunScheduledTransferTxConcurrent(t *testing.T) {
+	store := NewStore(testDB)
+
+	n := 5
+	account1 := createRandomAccountWithBalance(t, 1000)
+	account2 := createRandomAccount(t)
+
+	ids := make(map[int64]bool)
+	for i := 0; i < n; i++ {
+		scheduledTransfer := createRandomScheduledTransfer(t, account1, account2, "", time.Now().Add(-time.Minute))
+		ids[scheduledTransfer.ID] = true
+	}
+
+	arg := RunScheduledTransferTxParams{
+		Next: func(scheduledTransfer ScheduledTransfer, after time.Time) (time.Time, error) {
+			return time.Time{}, nil
+		},
+		RetryAt:     time.Now().Add(time.Hour),
+		MaxAttempts: 1,
+	}
+
+	// concurrent workers never run the same scheduled transfer
+	results := make(chan int64)
+	for i := 0; i < n; i++ {
+		go func() {
+			for {
+				result, err := store.RunScheduledTransferTx(context.Background(), arg)
+				if err != nil {
+					results <- 0
+					return
+				}
+				results <- result.ScheduledTransfer.ID
+			}
+		}()
+	}
+
+	ran := make(map[int64]bool)
+	for done := 0; done < n; {
+		id := <-results
+		if id == 0 {
+			done++
+			continue
+		}
+		require.False(t, ran[id])
+		ran[id] = true
+	}
+	for id := range ids {
+		require.True(t, ran[id])
+	}
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-int64(n)*10, updatedAccount1.Balance)
+}
@@ -7,7 +7,57 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFXTransfer = `-- name: CreateFXTransfer :one
+INSERT INTO transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  to_amount,
+  fx_rate,
+  fx_quote_id
+) VALUES (
+  $1, $2, $3, $4, $5, $6
 )
+RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, fx_quote_id
+`
+
+type CreateFXTransferParams struct {
+	FromAccountID int64          `json:"from_account_id"`
+	ToAccountID   int64          `json:"to_account_id"`
+	Amount        int64          `json:"amount"`
+	ToAmount      sql.NullInt64  `json:"to_amount"`
+	FxRate        sql.NullString `json:"fx_rate"`
+	FxQuoteID     uuid.NullUUID  `json:"fx_quote_id"`
+}
+
+func (q *Queries) CreateFXTransfer(ctx context.Context, arg CreateFXTransferParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, createFXTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ToAmount,
+		arg.FxRate,
+		arg.FxQuoteID,
+	)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.FxQuoteID,
+	)
+	return i, err
+}
 
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers (
@@ -17,7 +67,7 @@ INSERT INTO transfers (
 ) VALUES (
   $1, $2, $3
 )
-RETURNING id, from_account_id, to_account_id, amount, created_at
+RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, fx_quote_id
 `
 
 type CreateTransferParams struct {
@@ -35,12 +85,15 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.FxQuoteID,
 	)
 	return i, err
 }
 
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, fx_quote_id FROM transfers
 WHERE id = $1 LIMIT 1
 `
 
@@ -53,33 +106,189 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.FxQuoteID,
 	)
 	return i, err
 }
 
+const listAccountTransfers = `-- name: ListAccountTransfers :many
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, fx_quote_id FROM transfers
+WHERE
+  (
+    (from_account_id = $1 AND $2::varchar IS DISTINCT FROM 'incoming') OR
+    (to_account_id = $1 AND $2::varchar IS DISTINCT FROM 'outgoing')
+  )
+  AND ($3::bigint IS NULL OR from_account_id = $3 OR to_account_id = $3)
+  AND ($4::bigint IS NULL OR
+    (CASE WHEN to_account_id = $1 THEN COALESCE(to_amount, amount) ELSE amount END) >= $4)
+  AND ($5::bigint IS NULL OR
+    (CASE WHEN to_account_id = $1 THEN COALESCE(to_amount, amount) ELSE amount END) <= $5)
+  AND ($6::timestamptz IS NULL OR created_at >= $6)
+  AND ($7::timestamptz IS NULL OR created_at < $7)
+  AND ($8::timestamptz IS NULL OR
+    (created_at, id) < ($8, $9::bigint))
+ORDER BY created_at DESC, id DESC
+LIMIT $10
+`
+
+type ListAccountTransfersParams struct {
+	AccountID       int64          `json:"account_id"`
+	Direction       sql.NullString `json:"direction"`
+	CounterpartyID  sql.NullInt64  `json:"counterparty_id"`
+	MinAmount       sql.NullInt64  `json:"min_amount"`
+	MaxAmount       sql.NullInt64  `json:"max_amount"`
+	CreatedAfter    sql.NullTime   `json:"created_after"`
+	CreatedBefore   sql.NullTime   `json:"created_before"`
+	CursorCreatedAt sql.NullTime   `json:"cursor_created_at"`
+	CursorID        sql.NullInt64  `json:"cursor_id"`
+	Limit           int32          `json:"limit"`
+}
+
+func (q *Queries) ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountTransfers,
+		arg.AccountID,
+		arg.Direction,
+		arg.CounterpartyID,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.ToAmount,
+			&i.FxRate,
+			&i.FxQuoteID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountTransfersReverse = `-- name: ListAccountTransfersReverse :many
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, fx_quote_id FROM transfers
+WHERE
+  (
+    (from_account_id = $1 AND $2::varchar IS DISTINCT FROM 'incoming') OR
+    (to_account_id = $1 AND $2::varchar IS DISTINCT FROM 'outgoing')
+  )
+  AND ($3::bigint IS NULL OR from_account_id = $3 OR to_account_id = $3)
+  AND ($4::bigint IS NULL OR
+    (CASE WHEN to_account_id = $1 THEN COALESCE(to_amount, amount) ELSE amount END) >= $4)
+  AND ($5::bigint IS NULL OR
+    (CASE WHEN to_account_id = $1 THEN COALESCE(to_amount, amount) ELSE amount END) <= $5)
+  AND ($6::timestamptz IS NULL OR created_at >= $6)
+  AND ($7::timestamptz IS NULL OR created_at < $7)
+  AND (created_at, id) > ($8::timestamptz, $9::bigint)
+ORDER BY created_at, id
+LIMIT $10
+`
+
+type ListAccountTransfersReverseParams struct {
+	AccountID       int64          `json:"account_id"`
+	Direction       sql.NullString `json:"direction"`
+	CounterpartyID  sql.NullInt64  `json:"counterparty_id"`
+	MinAmount       sql.NullInt64  `json:"min_amount"`
+	MaxAmount       sql.NullInt64  `json:"max_amount"`
+	CreatedAfter    sql.NullTime   `json:"created_after"`
+	CreatedBefore   sql.NullTime   `json:"created_before"`
+	CursorCreatedAt time.Time      `json:"cursor_created_at"`
+	CursorID        int64          `json:"cursor_id"`
+	Limit           int32          `json:"limit"`
+}
+
+func (q *Queries) ListAccountTransfersReverse(ctx context.Context, arg ListAccountTransfersReverseParams) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountTransfersReverse,
+		arg.AccountID,
+		arg.Direction,
+		arg.CounterpartyID,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.ToAmount,
+			&i.FxRate,
+			&i.FxQuoteID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
-WHERE 
-    from_account_id = $1 OR
-    to_account_id = $2
-ORDER BY id
-LIMIT $3
-OFFSET $4
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, fx_quote_id FROM transfers
+WHERE
+  (from_account_id = $1 OR to_account_id = $2)
+  AND ($3::timestamptz IS NULL OR
+    (created_at, id) > ($3, $4::bigint))
+ORDER BY created_at, id
+LIMIT $5
 `
 
 type ListTransfersParams struct {
-	FromAccountID int64 `json:"from_account_id"`
-	ToAccountID   int64 `json:"to_account_id"`
-	Limit         int32 `json:"limit"`
-	Offset        int32 `json:"offset"`
+	FromAccountID   int64         `json:"from_account_id"`
+	ToAccountID     int64         `json:"to_account_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        sql.NullInt64 `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
 }
 
 func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error) {
 	rows, err := q.db.QueryContext(ctx, listTransfers,
 		arg.FromAccountID,
 		arg.ToAccountID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
 		arg.Limit,
-		arg.Offset,
 	)
 	if err != nil {
 		return nil, err
@@ -94,6 +303,9 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ToAmount,
+			&i.FxRate,
+			&i.FxQuoteID,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// createRandomTierAccount creates an account of a user moved to a tier of its own,
+// so the limits set on the tier don't reach the accounts of other tests
+func createRandomTierAccount(t *testing.T, balance int64) (Account, string) {
+	account := createRandomAccountWithBalance(t, balance)
+	tier := util.RandomString(8)
+
+	_, err := testQueries.UpdateUserTier(context.Background(), UpdateUserTierParams{
+		Username: account.Owner,
+		Tier:     tier,
+	})
+	require.NoError(t, err)
+	return account, tier
+}
+
+func TestGetTransferLimitsUnlimited(t *testing.T) {
+	account := createRandomAccount(t)
+
+	limits, err := testQueries.GetTransferLimits(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.ID, limits.AccountID)
+	require.Equal(t, util.StandardTier, limits.Tier)
+	require.Equal(t, account.Currency, limits.Currency)
+	require.False(t, limits.MaxPerTransaction.Valid)
+	require.False(t, limits.MaxDailyAmount.Valid)
+	require.False(t, limits.MaxDailyCount.Valid)
+	require.Zero(t, limits.DailyAmount)
+	require.Zero(t, limits.DailyCount)
+}
+
+func TestGetTransferLimits(t *testing.T) {
+	account, tier := createRandomTierAccount(t, 1000)
+
+	tierLimits, err := testQueries.SetTierTransferLimits(context.Background(), SetTierTransferLimitsParams{
+		Tier:              tier,
+		Currency:          account.Currency,
+		MaxPerTransaction: sql.NullInt64{Int64: 100, Valid: true},
+		MaxDailyAmount:    sql.NullInt64{Int64: 500, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, tier, tierLimits.Tier)
+	require.NotZero(t, tierLimits.UpdatedAt)
+
+	// the account overrides the limits it sets and keeps the others of the tier
+	_, err = testQueries.SetAccountTransferLimits(context.Background(), SetAccountTransferLimitsParams{
+		AccountID:         account.ID,
+		MaxPerTransaction: sql.NullInt64{Int64: 50, Valid: true},
+		MaxDailyCount:     sql.NullInt32{Int32: 3, Valid: true},
+	})
+	require.NoError(t, err)
+
+	// only the transfers sent by the account count
+	account2 := createRandomAccount(t)
+	createTransferBetween(t, account, account2, 20)
+	createTransferBetween(t, account, account2, 30)
+	createTransferBetween(t, account2, account, 40)
+
+	limits, err := testQueries.GetTransferLimits(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, tier, limits.Tier)
+	require.Equal(t, sql.NullInt64{Int64: 50, Valid: true}, limits.MaxPerTransaction)
+	require.Equal(t, sql.NullInt64{Int64: 500, Valid: true}, limits.MaxDailyAmount)
+	require.Equal(t, sql.NullInt32{Int32: 3, Valid: true}, limits.MaxDailyCount)
+	require.Equal(t, int64(50), limits.DailyAmount)
+	require.Equal(t, int32(2), limits.DailyCount)
+}
+
+func TestSetAccountTransferLimitsReplaces(t *testing.T) {
+	account := createRandomAccount(t)
+
+	_, err := testQueries.SetAccountTransferLimits(context.Background(), SetAccountTransferLimitsParams{
+		AccountID:         account.ID,
+		MaxPerTransaction: sql.NullInt64{Int64: 50, Valid: true},
+	})
+	require.NoError(t, err)
+
+	accountLimits, err := testQueries.SetAccountTransferLimits(context.Background(), SetAccountTransferLimitsParams{
+		AccountID:      account.ID,
+		MaxDailyAmount: sql.NullInt64{Int64: 500, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, account.ID, accountLimits.AccountID)
+	require.False(t, accountLimits.MaxPerTransaction.Valid)
+	require.Equal(t, sql.NullInt64{Int64: 500, Valid: true}, accountLimits.MaxDailyAmount)
+}
+
+func createTransferBetween(t *testing.T, from Account, to Account, amount int64) Transfer {
+	transfer, err := testQueries.CreateTransfer(context.Background(), CreateTransferParams{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        amount,
+	})
+	require.NoError(t, err)
+	return transfer
+}
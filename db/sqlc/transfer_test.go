@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -65,8 +66,15 @@ func TestListTransfers(t *testing.T) {
 		FromAccountID: account1.ID,
 		ToAccountID:   account1.ID,
 		Limit:         5,
-		Offset:        5,
 	}
+	firstPage, err := testQueries.ListTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, firstPage, 5)
+
+	last := firstPage[len(firstPage)-1]
+	arg.CursorCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+	arg.CursorID = sql.NullInt64{Int64: last.ID, Valid: true}
+
 	transfers, err := testQueries.ListTransfers(context.Background(), arg)
 	require.NoError(t, err)
 	require.Len(t, transfers, 5)
@@ -74,5 +82,145 @@ func TestListTransfers(t *testing.T) {
 	for _, transfer := range transfers {
 		require.NotEmpty(t, transfer)
 		require.True(t, transfer.FromAccountID == account1.ID || transfer.ToAccountID == account1.ID)
+		require.Greater(t, transfer.ID, last.ID)
+	}
+}
+
+func TestListAccountTransfers(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+	account3 := createRandomAccount(t)
+
+	var outgoing, incoming []Transfer
+	for i := 0; i < 3; i++ {
+		outgoing = append(outgoing, createRandomTransfer(t, account1, account2))
+		incoming = append(incoming, createRandomTransfer(t, account3, account1))
+	}
+
+	arg := ListAccountTransfersParams{
+		AccountID: account1.ID,
+		Limit:     10,
+	}
+	transfers, err := testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 6)
+	// newest first
+	require.Equal(t, incoming[2].ID, transfers[0].ID)
+	require.Equal(t, outgoing[0].ID, transfers[5].ID)
+
+	seekArg := arg
+	seekArg.CursorCreatedAt = sql.NullTime{Time: transfers[1].CreatedAt, Valid: true}
+	seekArg.CursorID = sql.NullInt64{Int64: transfers[1].ID, Valid: true}
+	older, err := testQueries.ListAccountTransfers(context.Background(), seekArg)
+	require.NoError(t, err)
+	require.Equal(t, transfers[2:], older)
+
+	newer, err := testQueries.ListAccountTransfersReverse(context.Background(), ListAccountTransfersReverseParams{
+		AccountID:       account1.ID,
+		CursorCreatedAt: transfers[3].CreatedAt,
+		CursorID:        transfers[3].ID,
+		Limit:           10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Transfer{transfers[2], transfers[1], transfers[0]}, newer)
+
+	arg.Direction = sql.NullString{String: "outgoing", Valid: true}
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 3)
+	for _, transfer := range transfers {
+		require.Equal(t, account1.ID, transfer.FromAccountID)
+	}
+
+	arg.Direction = sql.NullString{String: "incoming", Valid: true}
+	arg.CounterpartyID = sql.NullInt64{Int64: account2.ID, Valid: true}
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, transfers)
+
+	arg.Direction = sql.NullString{}
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 3)
+
+	arg.CounterpartyID = sql.NullInt64{}
+	arg.MinAmount = sql.NullInt64{Int64: incoming[0].Amount, Valid: true}
+	arg.MaxAmount = sql.NullInt64{Int64: incoming[0].Amount, Valid: true}
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, transfers)
+	for _, transfer := range transfers {
+		require.Equal(t, incoming[0].Amount, transfer.Amount)
+	}
+
+	arg.MinAmount = sql.NullInt64{}
+	arg.MaxAmount = sql.NullInt64{}
+	arg.CreatedAfter = sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true}
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Empty(t, transfers)
+
+	arg.CreatedAfter = sql.NullTime{}
+	arg.CreatedBefore = sql.NullTime{Time: outgoing[0].CreatedAt, Valid: true}
+	transfers, err = testQueries.ListAccountTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	for _, transfer := range transfers {
+		require.True(t, transfer.CreatedAt.Before(outgoing[0].CreatedAt))
+	}
+}
+
+func TestListAccountTransfersAmountInAccountCurrency(t *testing.T) {
+	sender := createRandomAccount(t)
+	recipient := createRandomAccount(t)
+
+	// 10 sent are credited as 150000 in the currency of the recipient
+	transfer, err := testQueries.CreateFXTransfer(context.Background(), CreateFXTransferParams{
+		FromAccountID: sender.ID,
+		ToAccountID:   recipient.ID,
+		Amount:        10,
+		ToAmount:      sql.NullInt64{Int64: 150000, Valid: true},
+		FxRate:        sql.NullString{String: "15000", Valid: true},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc      string
+		accountID int64
+		minAmount int64
+		maxAmount int64
+		found     bool
+	}{
+		{desc: "RecipientToAmount", accountID: recipient.ID, minAmount: 150000, maxAmount: 150000, found: true},
+		{desc: "RecipientAmountSent", accountID: recipient.ID, minAmount: 10, maxAmount: 10},
+		{desc: "SenderAmountSent", accountID: sender.ID, minAmount: 10, maxAmount: 10, found: true},
+		{desc: "SenderToAmount", accountID: sender.ID, minAmount: 150000, maxAmount: 150000},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			arg := ListAccountTransfersParams{
+				AccountID: tC.accountID,
+				MinAmount: sql.NullInt64{Int64: tC.minAmount, Valid: true},
+				MaxAmount: sql.NullInt64{Int64: tC.maxAmount, Valid: true},
+				Limit:     10,
+			}
+			transfers, err := testQueries.ListAccountTransfers(context.Background(), arg)
+			require.NoError(t, err)
+
+			reverse, err := testQueries.ListAccountTransfersReverse(context.Background(), ListAccountTransfersReverseParams{
+				AccountID: arg.AccountID,
+				MinAmount: arg.MinAmount,
+				MaxAmount: arg.MaxAmount,
+				Limit:     arg.Limit,
+			})
+			require.NoError(t, err)
+
+			if tC.found {
+				require.Equal(t, []Transfer{transfer}, transfers)
+				require.Equal(t, []Transfer{transfer}, reverse)
+				return
+			}
+			require.Empty(t, transfers)
+			require.Empty(t, reverse)
+		})
 	}
 }
@@ -7,6 +7,10 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -18,7 +22,7 @@ INSERT INTO users (
 ) VALUES (
   $1, $2, $3, $4
 )
-RETURNING username, hashed_password, full_name, email, password_changed_at, created_at
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
 `
 
 type CreateUserParams struct {
@@ -43,12 +47,58 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :one
+UPDATE users
+SET
+  is_totp_enabled = true,
+  totp_last_step = $2,
+  hashed_recovery_codes = $3
+WHERE username = $1 AND totp_secret IS NOT NULL
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type EnableUserTOTPParams struct {
+	Username            string   `json:"username"`
+	TotpLastStep        int64    `json:"totp_last_step"`
+	HashedRecoveryCodes []string `json:"hashed_recovery_codes"`
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableUserTOTP, arg.Username, arg.TotpLastStep, pq.Array(arg.HashedRecoveryCodes))
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, hashed_password, full_name, email, password_changed_at, created_at FROM users
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier FROM users
 WHERE username = $1 LIMIT 1
 `
 
@@ -62,6 +112,361 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const getUserAuth = `-- name: GetUserAuth :one
+SELECT password_changed_at, is_frozen, role FROM users
+WHERE username = $1 LIMIT 1
+`
+
+type GetUserAuthRow struct {
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	IsFrozen          bool      `json:"is_frozen"`
+	Role              string    `json:"role"`
+}
+
+func (q *Queries) GetUserAuth(ctx context.Context, username string) (GetUserAuthRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserAuth, username)
+	var i GetUserAuthRow
+	err := row.Scan(&i.PasswordChangedAt, &i.IsFrozen, &i.Role)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier FROM users
+WHERE email = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $1
+WHERE username = $2 AND hashed_password = $3
+`
+
+type RehashUserPasswordParams struct {
+	NewHashedPassword string `json:"new_hashed_password"`
+	Username          string `json:"username"`
+	OldHashedPassword string `json:"old_hashed_password"`
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewHashedPassword, arg.Username, arg.OldHashedPassword)
+	return err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET
+  full_name = COALESCE($1, full_name),
+  email = COALESCE($2, email),
+  is_email_verified = CASE
+    WHEN $2::varchar IS NULL OR $2::varchar = email THEN is_email_verified
+    ELSE false
+  END
+WHERE username = $3
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type UpdateUserParams struct {
+	FullName sql.NullString `json:"full_name"`
+	Email    sql.NullString `json:"email"`
+	Username string         `json:"username"`
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser, arg.FullName, arg.Email, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const updateUserEmailVerified = `-- name: UpdateUserEmailVerified :one
+UPDATE users
+SET is_email_verified = true
+WHERE username = $1 AND email = $2
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type UpdateUserEmailVerifiedParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) UpdateUserEmailVerified(ctx context.Context, arg UpdateUserEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmailVerified, arg.Username, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const updateUserFrozen = `-- name: UpdateUserFrozen :one
+UPDATE users
+SET is_frozen = $2
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type UpdateUserFrozenParams struct {
+	Username string `json:"username"`
+	IsFrozen bool   `json:"is_frozen"`
+}
+
+func (q *Queries) UpdateUserFrozen(ctx context.Context, arg UpdateUserFrozenParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserFrozen, arg.Username, arg.IsFrozen)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET
+  hashed_password = $2,
+  password_changed_at = $3
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type UpdateUserPasswordParams struct {
+	Username          string    `json:"username"`
+	HashedPassword    string    `json:"hashed_password"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.Username, arg.HashedPassword, arg.PasswordChangedAt)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const updateUserTOTPSecret = `-- name: UpdateUserTOTPSecret :one
+UPDATE users
+SET
+  totp_secret = $2,
+  is_totp_enabled = false,
+  hashed_recovery_codes = '{}'
+WHERE username = $1 AND NOT is_totp_enabled
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type UpdateUserTOTPSecretParams struct {
+	Username   string         `json:"username"`
+	TotpSecret sql.NullString `json:"totp_secret"`
+}
+
+func (q *Queries) UpdateUserTOTPSecret(ctx context.Context, arg UpdateUserTOTPSecretParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserTOTPSecret, arg.Username, arg.TotpSecret)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const updateUserTier = `-- name: UpdateUserTier :one
+UPDATE users
+SET tier = $2
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type UpdateUserTierParams struct {
+	Username string `json:"username"`
+	Tier     string `json:"tier"`
+}
+
+func (q *Queries) UpdateUserTier(ctx context.Context, arg UpdateUserTierParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserTier, arg.Username, arg.Tier)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const useUserRecoveryCode = `-- name: UseUserRecoveryCode :one
+UPDATE users
+SET hashed_recovery_codes = array_remove(hashed_recovery_codes, $1::varchar)
+WHERE username = $2 AND $1::varchar = ANY(hashed_recovery_codes)
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type UseUserRecoveryCodeParams struct {
+	HashedCode string `json:"hashed_code"`
+	Username   string `json:"username"`
+}
+
+func (q *Queries) UseUserRecoveryCode(ctx context.Context, arg UseUserRecoveryCodeParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, useUserRecoveryCode, arg.HashedCode, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
+	)
+	return i, err
+}
+
+const useUserTOTPStep = `-- name: UseUserTOTPStep :one
+UPDATE users
+SET totp_last_step = $1
+WHERE username = $2 AND totp_last_step < $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, is_frozen, totp_secret, is_totp_enabled, totp_last_step, hashed_recovery_codes, is_email_verified, tier
+`
+
+type UseUserTOTPStepParams struct {
+	Step     int64  `json:"step"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) UseUserTOTPStep(ctx context.Context, arg UseUserTOTPStepParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, useUserTOTPStep, arg.Step, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.IsFrozen,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		pq.Array(&i.HashedRecoveryCodes),
+		&i.IsEmailVerified,
+		&i.Tier,
 	)
 	return i, err
 }
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -29,6 +30,9 @@ func createRandomUser(t *testing.T) User {
 	require.Equal(t, arg.FullName, user.FullName)
 	require.Equal(t, arg.Email, user.Email)
 
+	require.Equal(t, util.DepositorRole, user.Role)
+	require.Equal(t, util.StandardTier, user.Tier)
+	require.False(t, user.IsFrozen)
 	require.True(t, user.PasswordChangedAt.IsZero())
 	require.NotZero(t, user.CreatedAt)
 
@@ -52,3 +56,218 @@ func TestGetUser(t *testing.T) {
 	require.WithinDuration(t, user1.PasswordChangedAt, user2.PasswordChangedAt, time.Second)
 	require.WithinDuration(t, user1.CreatedAt, user2.CreatedAt, time.Second)
 }
+
+func TestUpdateUserFrozen(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	user2, err := testQueries.UpdateUserFrozen(context.Background(), UpdateUserFrozenParams{
+		Username: user1.Username,
+		IsFrozen: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, user2.Username)
+	require.True(t, user2.IsFrozen)
+}
+
+func TestUpdateUserTier(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	user2, err := testQueries.UpdateUserTier(context.Background(), UpdateUserTierParams{
+		Username: user1.Username,
+		Tier:     util.PremiumTier,
+	})
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, user2.Username)
+	require.Equal(t, util.PremiumTier, user2.Tier)
+}
+
+func enableRandomUserTOTP(t *testing.T, hashedRecoveryCodes []string) User {
+	user := createRandomUser(t)
+
+	secret, err := util.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	user, err = testQueries.UpdateUserTOTPSecret(context.Background(), UpdateUserTOTPSecretParams{
+		Username:   user.Username,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, secret, user.TotpSecret.String)
+	require.False(t, user.IsTotpEnabled)
+
+	step := util.TOTPStep(time.Now())
+	user, err = testQueries.EnableUserTOTP(context.Background(), EnableUserTOTPParams{
+		Username:            user.Username,
+		TotpLastStep:        step,
+		HashedRecoveryCodes: hashedRecoveryCodes,
+	})
+	require.NoError(t, err)
+	require.True(t, user.IsTotpEnabled)
+	require.Equal(t, step, user.TotpLastStep)
+	require.Equal(t, hashedRecoveryCodes, user.HashedRecoveryCodes)
+
+	return user
+}
+
+func TestEnableUserTOTP(t *testing.T) {
+	user := enableRandomUserTOTP(t, []string{util.RandomString(64)})
+
+	// an enabled secret can't be replaced by a new enrollment
+	_, err := testQueries.UpdateUserTOTPSecret(context.Background(), UpdateUserTOTPSecretParams{
+		Username:   user.Username,
+		TotpSecret: sql.NullString{String: util.RandomString(32), Valid: true},
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestEnableUserTOTPNotEnrolled(t *testing.T) {
+	user := createRandomUser(t)
+
+	_, err := testQueries.EnableUserTOTP(context.Background(), EnableUserTOTPParams{
+		Username:            user.Username,
+		HashedRecoveryCodes: []string{},
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestUseUserTOTPStep(t *testing.T) {
+	user1 := enableRandomUserTOTP(t, []string{})
+
+	user2, err := testQueries.UseUserTOTPStep(context.Background(), UseUserTOTPStepParams{
+		Username: user1.Username,
+		Step:     user1.TotpLastStep + 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, user1.TotpLastStep+1, user2.TotpLastStep)
+
+	for _, step := range []int64{user2.TotpLastStep, user1.TotpLastStep} {
+		_, err = testQueries.UseUserTOTPStep(context.Background(), UseUserTOTPStepParams{
+			Username: user1.Username,
+			Step:     step,
+		})
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	}
+}
+
+func TestUseUserRecoveryCode(t *testing.T) {
+	hashedCode1 := util.HashRecoveryCode(util.RandomString(10))
+	hashedCode2 := util.HashRecoveryCode(util.RandomString(10))
+	user1 := enableRandomUserTOTP(t, []string{hashedCode1, hashedCode2})
+
+	user2, err := testQueries.UseUserRecoveryCode(context.Background(), UseUserRecoveryCodeParams{
+		Username:   user1.Username,
+		HashedCode: hashedCode1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{hashedCode2}, user2.HashedRecoveryCodes)
+
+	_, err = testQueries.UseUserRecoveryCode(context.Background(), UseUserRecoveryCodeParams{
+		Username:   user1.Username,
+		HashedCode: hashedCode1,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestGetUserByEmail(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	user2, err := testQueries.GetUserByEmail(context.Background(), user1.Email)
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, user2.Username)
+
+	_, err = testQueries.GetUserByEmail(context.Background(), util.RandomEmail())
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestUpdateUserPassword(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	hashedPassword, err := util.HashPassword(util.RandomString(8))
+	require.NoError(t, err)
+	changedAt := time.Now()
+
+	user2, err := testQueries.UpdateUserPassword(context.Background(), UpdateUserPasswordParams{
+		Username:          user1.Username,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: changedAt,
+	})
+	require.NoError(t, err)
+	require.Equal(t, hashedPassword, user2.HashedPassword)
+	require.WithinDuration(t, changedAt, user2.PasswordChangedAt, time.Millisecond)
+
+	auth, err := testQueries.GetUserAuth(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.False(t, auth.IsFrozen)
+	require.Equal(t, user1.Role, auth.Role)
+	require.WithinDuration(t, changedAt, auth.PasswordChangedAt, time.Millisecond)
+}
+
+func TestUpdateUserOnlyFullName(t *testing.T) {
+	oldUser := createRandomUser(t)
+
+	newFullName := util.RandomOwner()
+	updatedUser, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: oldUser.Username,
+		FullName: sql.NullString{String: newFullName, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newFullName, updatedUser.FullName)
+	require.Equal(t, oldUser.Email, updatedUser.Email)
+	require.Equal(t, oldUser.HashedPassword, updatedUser.HashedPassword)
+}
+
+func TestUpdateUserEmailResetsVerification(t *testing.T) {
+	oldUser := createRandomUser(t)
+	oldUser, err := testQueries.UpdateUserEmailVerified(context.Background(), UpdateUserEmailVerifiedParams{
+		Username: oldUser.Username,
+		Email:    oldUser.Email,
+	})
+	require.NoError(t, err)
+	require.True(t, oldUser.IsEmailVerified)
+
+	// setting the same email keeps it verified
+	updatedUser, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: oldUser.Username,
+		Email:    sql.NullString{String: oldUser.Email, Valid: true},
+	})
+	require.NoError(t, err)
+	require.True(t, updatedUser.IsEmailVerified)
+
+	newEmail := util.RandomEmail()
+	updatedUser, err = testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: oldUser.Username,
+		Email:    sql.NullString{String: newEmail, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newEmail, updatedUser.Email)
+	require.Equal(t, oldUser.FullName, updatedUser.FullName)
+	require.False(t, updatedUser.IsEmailVerified)
+}
+
+func TestRehashUserPassword(t *testing.T) {
+	user1 := createRandomUser(t)
+
+	// a hash that was replaced in the meantime is left alone
+	err := testQueries.RehashUserPassword(context.Background(), RehashUserPasswordParams{
+		Username:          user1.Username,
+		OldHashedPassword: "outdated",
+		NewHashedPassword: "rehashed",
+	})
+	require.NoError(t, err)
+
+	user2, err := testQueries.GetUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Equal(t, user1.HashedPassword, user2.HashedPassword)
+
+	err = testQueries.RehashUserPassword(context.Background(), RehashUserPasswordParams{
+		Username:          user1.Username,
+		OldHashedPassword: user1.HashedPassword,
+		NewHashedPassword: "rehashed",
+	})
+	require.NoError(t, err)
+
+	user2, err = testQueries.GetUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Equal(t, "rehashed", user2.HashedPassword)
+	require.Equal(t, user1.PasswordChangedAt, user2.PasswordChangedAt)
+}
@@ -0,0 +1,62 @@
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+)
+
+// RateStore reads the rates stored in the fx_rates table
+type RateStore interface {
+	GetLatestFXRate(ctx context.Context, arg db.GetLatestFXRateParams) (db.FxRate, error)
+}
+
+// DBRateProvider provides the latest rates stored in the database.
+// The rate between two currencies is also used the other way round if there isn't one for it.
+type DBRateProvider struct {
+	store RateStore
+}
+
+// NewDBRateProvider creates a new DBRateProvider reading from store
+func NewDBRateProvider(store RateStore) *DBRateProvider {
+	return &DBRateProvider{store: store}
+}
+
+// Rate returns the latest rate between two currencies
+func (provider *DBRateProvider) Rate(ctx context.Context, from string, to string) (Rate, error) {
+	if from == to {
+		return identityRate(from), nil
+	}
+
+	rate, err := provider.latestRate(ctx, from, to)
+	if err != ErrRateNotFound {
+		return rate, err
+	}
+
+	rate, err = provider.latestRate(ctx, to, from)
+	if err != nil {
+		return rate, err
+	}
+	return rate.inverse(), nil
+}
+
+func (provider *DBRateProvider) latestRate(ctx context.Context, from string, to string) (Rate, error) {
+	row, err := provider.store.GetLatestFXRate(ctx, db.GetLatestFXRateParams{
+		FromCurrency: from,
+		ToCurrency:   to,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Rate{}, ErrRateNotFound
+		}
+		return Rate{}, err
+	}
+
+	value, err := ParseRate(row.Rate)
+	if err != nil {
+		return Rate{}, fmt.Errorf("invalid stored rate %d: %w", row.ID, err)
+	}
+	return Rate{From: row.FromCurrency, To: row.ToCurrency, Value: value}, nil
+}
@@ -0,0 +1,43 @@
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"testing"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBRateProvider(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetLatestFXRate(gomock.Any(), gomock.Eq(db.GetLatestFXRateParams{FromCurrency: util.EUR, ToCurrency: util.USD})).
+		AnyTimes().
+		Return(db.FxRate{ID: 1, FromCurrency: util.EUR, ToCurrency: util.USD, Rate: "1.250000000000"}, nil)
+	store.EXPECT().
+		GetLatestFXRate(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.FxRate{}, sql.ErrNoRows)
+
+	provider := NewDBRateProvider(store)
+
+	rate, err := provider.Rate(context.Background(), util.EUR, util.USD)
+	require.NoError(t, err)
+	require.Equal(t, big.NewRat(5, 4), rate.Value)
+
+	rate, err = provider.Rate(context.Background(), util.USD, util.EUR)
+	require.NoError(t, err)
+	require.Equal(t, util.USD, rate.From)
+	require.Equal(t, big.NewRat(4, 5), rate.Value)
+
+	_, err = provider.Rate(context.Background(), util.IDR, util.CAD)
+	require.ErrorIs(t, err, ErrRateNotFound)
+}
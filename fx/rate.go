@@ -0,0 +1,108 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Types of rate provider, selected with FX_RATE_PROVIDER
+const (
+	TypeDB   = "db"
+	TypeFile = "file"
+)
+
+// Rates are stored as numeric(rateDigits, rateDecimals)
+const (
+	rateDigits   = 24
+	rateDecimals = 12
+)
+
+var (
+	// rateScale shifts a rate by rateDecimals
+	rateScale = new(big.Int).Exp(big.NewInt(10), big.NewInt(rateDecimals), nil)
+	// minRate is the smallest rate that can be stored, smaller ones would be stored as zero
+	minRate = new(big.Rat).SetFrac(big.NewInt(1), rateScale)
+	// maxRate is the first rate too large to be stored
+	maxRate = new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(rateDigits-rateDecimals), nil))
+)
+
+var (
+	// ErrRateNotFound is returned when there is no rate between two currencies
+	ErrRateNotFound = errors.New("exchange rate not found")
+	// ErrAmountTooSmall is returned when an amount converts to nothing
+	ErrAmountTooSmall = errors.New("amount is too small to convert")
+)
+
+// Rate is the price of one unit of From in units of To.
+// It applies to amounts as they are stored, in the smallest unit of each currency.
+type Rate struct {
+	From  string
+	To    string
+	Value *big.Rat
+}
+
+// RateProvider provides the current exchange rates between currencies
+type RateProvider interface {
+	// Rate returns the rate to convert from one currency to the other,
+	// or ErrRateNotFound if there is none
+	Rate(ctx context.Context, from string, to string) (Rate, error)
+}
+
+// ParseRate parses a positive decimal rate that can be stored
+func ParseRate(s string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid rate %q", s)
+	}
+	if err := CheckRate(rate); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, s)
+	}
+	return rate, nil
+}
+
+// CheckRate checks a rate is positive and within what can be stored
+func CheckRate(rate *big.Rat) error {
+	if rate.Sign() <= 0 {
+		return errors.New("rate must be positive")
+	}
+	if rate.Cmp(minRate) < 0 {
+		return fmt.Errorf("rate must be at least %s", FormatRate(minRate))
+	}
+	if rate.Cmp(maxRate) >= 0 {
+		return fmt.Errorf("rate must be less than %s", maxRate.RatString())
+	}
+	return nil
+}
+
+// FormatRate formats a rate with the decimals it is stored with.
+// Like Convert, it rounds down so a stored rate never credits more than the actual one.
+func FormatRate(rate *big.Rat) string {
+	scaled := new(big.Int).Quo(new(big.Int).Mul(rate.Num(), rateScale), rate.Denom())
+	return new(big.Rat).SetFrac(scaled, rateScale).FloatString(rateDecimals)
+}
+
+// Convert returns amount at rate, rounded down so the bank never credits more than it debits.
+// It returns ErrAmountTooSmall if nothing would be credited.
+func Convert(amount int64, rate *big.Rat) (int64, error) {
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), rate)
+	result := new(big.Int).Quo(converted.Num(), converted.Denom())
+	if !result.IsInt64() {
+		return 0, fmt.Errorf("converted amount overflows: %s", result)
+	}
+	if result.Sign() <= 0 {
+		return 0, ErrAmountTooSmall
+	}
+	return result.Int64(), nil
+}
+
+// identityRate is the rate of a currency to itself
+func identityRate(currency string) Rate {
+	return Rate{From: currency, To: currency, Value: big.NewRat(1, 1)}
+}
+
+// inverse returns the rate to convert the other way
+func (rate Rate) inverse() Rate {
+	return Rate{From: rate.To, To: rate.From, Value: new(big.Rat).Inv(rate.Value)}
+}
@@ -0,0 +1,65 @@
+package fx
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	rate, err := ParseRate("15000.5")
+	require.NoError(t, err)
+	require.Equal(t, "15000.500000000000", FormatRate(rate))
+
+	rate, err = ParseRate("0.000000000001")
+	require.NoError(t, err)
+	require.Equal(t, "0.000000000001", FormatRate(rate))
+
+	rate, err = ParseRate("999999999999.999999999999")
+	require.NoError(t, err)
+	require.Equal(t, "999999999999.999999999999", FormatRate(rate))
+
+	// the last ones are positive but too small or too large to be stored
+	for _, invalid := range []string{"", "abc", "0", "-1.5", "0.0000000000009", "1e-13", "1000000000000", "1e15"} {
+		_, err := ParseRate(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestFormatRateRoundsDown(t *testing.T) {
+	// 2/3 would round up to ...667
+	require.Equal(t, "0.666666666666", FormatRate(big.NewRat(2, 3)))
+	require.Equal(t, "1.000000000000", FormatRate(big.NewRat(1, 1)))
+	require.Equal(t, "0.000000000001", FormatRate(big.NewRat(19, 10_000_000_000_000)))
+
+	// the stored rate never converts to more than the actual one
+	rate := big.NewRat(1_000_000, 1_499_999)
+	stored, err := ParseRate(FormatRate(rate))
+	require.NoError(t, err)
+	require.LessOrEqual(t, stored.Cmp(rate), 0)
+}
+
+func TestCheckRate(t *testing.T) {
+	require.NoError(t, CheckRate(minRate))
+	require.Error(t, CheckRate(maxRate))
+	require.Error(t, CheckRate(new(big.Rat).Inv(minRate)))
+	require.NoError(t, CheckRate(new(big.Rat).Inv(big.NewRat(2, 1_000_000_000_000))))
+}
+
+func TestConvert(t *testing.T) {
+	rate, err := ParseRate("0.000066666667")
+	require.NoError(t, err)
+
+	converted, err := Convert(1_000_000, rate)
+	require.NoError(t, err)
+	// rounded down
+	require.Equal(t, int64(66), converted)
+
+	_, err = Convert(10, rate)
+	require.ErrorIs(t, err, ErrAmountTooSmall)
+
+	_, err = Convert(math.MaxInt64, big.NewRat(2, 1))
+	require.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// StaticRateProvider provides a fixed set of rates.
+// The rate between two currencies is also used the other way round if there isn't one for it.
+type StaticRateProvider struct {
+	rates map[[2]string]Rate
+}
+
+// NewStaticRateProvider creates a new StaticRateProvider with rates
+func NewStaticRateProvider(rates ...Rate) *StaticRateProvider {
+	provider := &StaticRateProvider{rates: make(map[[2]string]Rate, len(rates))}
+	for _, rate := range rates {
+		provider.rates[[2]string{rate.From, rate.To}] = rate
+	}
+	return provider
+}
+
+// fileRate is a rate in a rates file
+type fileRate struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Rate string `json:"rate"`
+}
+
+// NewFileRateProvider creates a new StaticRateProvider with the rates of a JSON file,
+// a list of objects with from, to and a decimal rate.
+// It's meant for local development.
+func NewFileRateProvider(path string) (*StaticRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rates file: %w", err)
+	}
+
+	var fileRates []fileRate
+	if err := json.Unmarshal(data, &fileRates); err != nil {
+		return nil, fmt.Errorf("cannot parse rates file: %w", err)
+	}
+
+	rates := make([]Rate, len(fileRates))
+	for i, fileRate := range fileRates {
+		value, err := ParseRate(fileRate.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate from %s to %s: %w", fileRate.From, fileRate.To, err)
+		}
+		rates[i] = Rate{From: fileRate.From, To: fileRate.To, Value: value}
+	}
+	return NewStaticRateProvider(rates...), nil
+}
+
+// Rate returns the rate between two currencies
+func (provider *StaticRateProvider) Rate(ctx context.Context, from string, to string) (Rate, error) {
+	if from == to {
+		return identityRate(from), nil
+	}
+	if rate, ok := provider.rates[[2]string{from, to}]; ok {
+		return rate, nil
+	}
+	if rate, ok := provider.rates[[2]string{to, from}]; ok {
+		return rate.inverse(), nil
+	}
+	return Rate{}, ErrRateNotFound
+}
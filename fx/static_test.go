@@ -0,0 +1,54 @@
+package fx
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticRateProvider(t *testing.T) {
+	provider := NewStaticRateProvider(Rate{From: util.USD, To: util.IDR, Value: big.NewRat(15000, 1)})
+
+	rate, err := provider.Rate(context.Background(), util.USD, util.IDR)
+	require.NoError(t, err)
+	require.Equal(t, "15000.000000000000", FormatRate(rate.Value))
+
+	rate, err = provider.Rate(context.Background(), util.IDR, util.USD)
+	require.NoError(t, err)
+	require.Equal(t, util.IDR, rate.From)
+	require.Equal(t, util.USD, rate.To)
+	require.Equal(t, big.NewRat(1, 15000), rate.Value)
+
+	rate, err = provider.Rate(context.Background(), util.CAD, util.CAD)
+	require.NoError(t, err)
+	require.Equal(t, big.NewRat(1, 1), rate.Value)
+
+	_, err = provider.Rate(context.Background(), util.EUR, util.USD)
+	require.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestFileRateProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	err := os.WriteFile(path, []byte(`[{"from": "EUR", "to": "USD", "rate": "1.0875"}]`), 0o644)
+	require.NoError(t, err)
+
+	provider, err := NewFileRateProvider(path)
+	require.NoError(t, err)
+
+	rate, err := provider.Rate(context.Background(), util.EUR, util.USD)
+	require.NoError(t, err)
+	require.Equal(t, "1.087500000000", FormatRate(rate.Value))
+
+	err = os.WriteFile(path, []byte(`[{"from": "EUR", "to": "USD", "rate": "0"}]`), 0o644)
+	require.NoError(t, err)
+	_, err = NewFileRateProvider(path)
+	require.Error(t, err)
+
+	_, err = NewFileRateProvider(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const fileSenderFrom = "simplebank@localhost"
+
+// FileSender writes emails as .eml files to a directory instead of sending them.
+// It's meant for local development.
+type FileSender struct {
+	dir   string
+	count atomic.Int64
+}
+
+// NewFileSender creates a new FileSender writing to dir
+func NewFileSender(dir string) (Sender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create email directory: %w", err)
+	}
+	return &FileSender{dir: dir}, nil
+}
+
+// SendEmail writes the email to a new file
+func (sender *FileSender) SendEmail(subject string, content string, to []string) error {
+	msg := buildMessage(fileSenderFrom, subject, content, to)
+
+	name := fmt.Sprintf("%d-%d.eml", time.Now().UnixNano(), sender.count.Add(1))
+	if err := os.WriteFile(filepath.Join(sender.dir, name), msg, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package mail
+
+import "sync"
+
+// Email is an email kept by MemorySender
+type Email struct {
+	Subject string
+	Content string
+	To      []string
+}
+
+// MemorySender keeps emails in memory instead of sending them.
+// It's meant for tests.
+type MemorySender struct {
+	mu     sync.Mutex
+	emails []Email
+}
+
+// NewMemorySender creates a new MemorySender
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+// SendEmail keeps the email
+func (sender *MemorySender) SendEmail(subject string, content string, to []string) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	sender.emails = append(sender.emails, Email{
+		Subject: subject,
+		Content: content,
+		To:      append([]string(nil), to...),
+	})
+	return nil
+}
+
+// Emails returns the emails sent so far
+func (sender *MemorySender) Emails() []Email {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	return append([]Email(nil), sender.emails...)
+}
@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Types of email sender, selected with EMAIL_SENDER_TYPE
+const (
+	TypeSMTP   = "smtp"
+	TypeFile   = "file"
+	TypeMemory = "memory"
+)
+
+// Sender is an interface for sending emails
+type Sender interface {
+	SendEmail(subject string, content string, to []string) error
+}
+
+// buildMessage returns the RFC 5322 message of an HTML email
+func buildMessage(from string, subject string, content string, to []string) []byte {
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(content)
+
+	return msg.Bytes()
+}
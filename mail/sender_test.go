@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMessage(t *testing.T) {
+	msg := string(buildMessage("Simple Bank <bank@example.com>", "Welcome", "<h1>Hello</h1>", []string{"a@example.com", "b@example.com"}))
+
+	header, body, ok := strings.Cut(msg, "\r\n\r\n")
+	require.True(t, ok)
+	require.Contains(t, header, "From: Simple Bank <bank@example.com>\r\n")
+	require.Contains(t, header, "To: a@example.com, b@example.com\r\n")
+	require.Contains(t, header, "Subject: Welcome\r\n")
+	require.Contains(t, header, "Content-Type: text/html")
+	require.Equal(t, "<h1>Hello</h1>", body)
+}
+
+func TestMemorySender(t *testing.T) {
+	sender := NewMemorySender()
+
+	err := sender.SendEmail("Welcome", "<h1>Hello</h1>", []string{"a@example.com"})
+	require.NoError(t, err)
+
+	emails := sender.Emails()
+	require.Len(t, emails, 1)
+	require.Equal(t, "Welcome", emails[0].Subject)
+	require.Equal(t, "<h1>Hello</h1>", emails[0].Content)
+	require.Equal(t, []string{"a@example.com"}, emails[0].To)
+}
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sender, err := NewFileSender(dir)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		err = sender.SendEmail("Welcome", "<h1>Hello</h1>", []string{"a@example.com"})
+		require.NoError(t, err)
+	}
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	msg, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(msg), "To: a@example.com\r\n")
+}
+
+func TestNewSMTPSender(t *testing.T) {
+	_, err := NewSMTPSender("Simple Bank", "bank@example.com", "secret", "smtp.example.com:587")
+	require.NoError(t, err)
+
+	_, err = NewSMTPSender("Simple Bank", "bank@example.com", "secret", "smtp.example.com")
+	require.Error(t, err)
+
+	_, err = NewSMTPSender("Simple Bank", "not an address", "secret", "smtp.example.com:587")
+	require.Error(t, err)
+}
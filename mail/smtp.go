@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPSender sends emails through an SMTP server
+type SMTPSender struct {
+	name        string
+	fromAddress string
+	password    string
+	address     string
+}
+
+// NewSMTPSender creates a new SMTPSender.
+// address is the host:port of the SMTP server; the sender authenticates with PLAIN auth.
+func NewSMTPSender(name string, fromAddress string, password string, address string) (Sender, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid smtp address: %w", err)
+	}
+
+	if _, err := mail.ParseAddress(fromAddress); err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	sender := &SMTPSender{
+		name:        name,
+		fromAddress: fromAddress,
+		password:    password,
+		address:     address,
+	}
+	return sender, nil
+}
+
+// SendEmail sends an HTML email to the recipients
+func (sender *SMTPSender) SendEmail(subject string, content string, to []string) error {
+	host, _, err := net.SplitHostPort(sender.address)
+	if err != nil {
+		return err
+	}
+
+	from := mail.Address{Name: sender.name, Address: sender.fromAddress}
+	msg := buildMessage(from.String(), subject, content, to)
+
+	auth := smtp.PlainAuth("", sender.fromAddress, sender.password, host)
+	if err := smtp.SendMail(sender.address, auth, sender.fromAddress, to, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
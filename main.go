@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/amrizal94/simplebank/api"
 	db "github.com/amrizal94/simplebank/db/sqlc"
 	"github.com/amrizal94/simplebank/util"
+	"github.com/amrizal94/simplebank/worker"
 	_ "github.com/lib/pq"
 )
 
+// shutdownTimeout is how long the server waits for requests and background work on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	config, err := util.LoadConfig(".")
 	if err != nil {
@@ -27,9 +35,24 @@ func main() {
 		log.Fatal("cannot create server:", err)
 	}
 
-	err = server.Start(config.ServerAddress)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	if err != nil {
-		log.Fatal("cannot start server:", err)
+	go worker.NewScheduledTransferWorker(store, config).Start(ctx)
+
+	go func() {
+		err := server.Start(config.ServerAddress)
+		if err != nil {
+			log.Fatal("cannot start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	// let the requests in flight and the emails they send finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Fatal("cannot shut down server:", err)
 	}
 }
@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// clockSkew is how far the clocks of the provider and ours may drift apart
+const clockSkew = time.Minute
+
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// signingMethods are the ID token algorithms we accept, the ones for the key types of keySet
+var signingMethods = []string{
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodES256.Alg(),
+}
+
+// IDToken contains the claims of a verified ID token
+type IDToken struct {
+	jwt.RegisteredClaims
+	AuthorizedParty   string `json:"azp,omitempty"`
+	Nonce             string `json:"nonce,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified,omitempty"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
+// VerifyIDToken checks the ID token was signed by the provider for us,
+// in response to the authentication request with the nonce, and hasn't expired
+func (provider *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDToken, error) {
+	metadata, err := provider.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		key, err := provider.keys.key(ctx, keyID)
+		if err != nil {
+			return nil, err
+		}
+
+		// the key type must match the algorithm, or an attacker could pick how the key is used
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if token.Method != jwt.SigningMethodRS256 {
+				return nil, fmt.Errorf("algorithm %s doesn't match the RSA key", token.Method.Alg())
+			}
+		case *ecdsa.PublicKey:
+			if token.Method != jwt.SigningMethodES256 {
+				return nil, fmt.Errorf("algorithm %s doesn't match the EC key", token.Method.Alg())
+			}
+		}
+		return key, nil
+	}
+
+	var idToken IDToken
+	_, err = jwt.ParseWithClaims(
+		rawIDToken,
+		&idToken,
+		keyFunc,
+		jwt.WithValidMethods(signingMethods),
+		// checked below, with some leeway for the clock of the provider
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if err := provider.validateClaims(&idToken, metadata.Issuer, nonce, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	return &idToken, nil
+}
+
+// validateClaims checks the claims of an ID token whose signature is valid, as of now.
+// The issuer must be exactly the one of the discovery document, trailing slash included.
+func (provider *Provider) validateClaims(idToken *IDToken, issuer, nonce string, now time.Time) error {
+	if !idToken.VerifyIssuer(issuer, true) {
+		return fmt.Errorf("issued by %q", idToken.Issuer)
+	}
+	if !idToken.VerifyAudience(provider.clientID, true) {
+		return fmt.Errorf("issued for another client")
+	}
+	// with several audiences, the party it was issued to must be us
+	if len(idToken.Audience) > 1 && idToken.AuthorizedParty != provider.clientID {
+		return fmt.Errorf("issued for another client")
+	}
+	if idToken.Subject == "" {
+		return fmt.Errorf("no subject")
+	}
+	if !idToken.VerifyExpiresAt(now.Add(-clockSkew), true) {
+		return fmt.Errorf("expired")
+	}
+	if !idToken.VerifyIssuedAt(now.Add(clockSkew), false) {
+		return fmt.Errorf("issued in the future")
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return fmt.Errorf("nonce mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// minKeyRefreshInterval keeps tokens with unknown key IDs from hammering the provider
+const minKeyRefreshInterval = time.Minute
+
+// jsonWebKey is a public key of a JSON Web Key Set, RFC 7517
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// keySet caches the signing keys of the provider, refetched when a token names a key it doesn't know
+// so the provider can rotate its keys
+type keySet struct {
+	uri     string
+	getJSON func(ctx context.Context, url string, v interface{}) error
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	refreshedAt time.Time
+}
+
+func newKeySet(uri string, getJSON func(ctx context.Context, url string, v interface{}) error) *keySet {
+	return &keySet{
+		uri:     uri,
+		getJSON: getJSON,
+	}
+}
+
+// key returns the public key with the key ID
+func (set *keySet) key(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if key, ok := set.keys[keyID]; ok {
+		return key, nil
+	}
+
+	if time.Since(set.refreshedAt) < minKeyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", keyID)
+	}
+
+	if err := set.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	key, ok := set.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", keyID)
+	}
+	return key, nil
+}
+
+// refresh replaces the cached keys with the ones the provider publishes now
+func (set *keySet) refresh(ctx context.Context) error {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := set.getJSON(ctx, set.uri, &jwks); err != nil {
+		return fmt.Errorf("cannot fetch identity provider keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		// encryption keys can't sign ID tokens
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			// keys of unsupported types are skipped, the others may still be used
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	set.keys = keys
+	set.refreshedAt = time.Now()
+	return nil
+}
+
+// publicKey decodes the RSA or P-256 public key
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !key.Curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("invalid EC public key")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
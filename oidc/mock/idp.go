@@ -0,0 +1,274 @@
+// Package mockoidc provides an in-process OpenID Connect identity provider for tests
+package mockoidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// IDTokenDuration is how long the ID tokens of the IdP are valid
+const IDTokenDuration = 5 * time.Minute
+
+// Identity is the user signing in at the IdP
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// authorization is an authorization code waiting to be redeemed
+type authorization struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	identity      Identity
+}
+
+// IdP is an identity provider serving the discovery document, its keys
+// and a token endpoint on a local HTTP server. Users sign in with Authorize.
+type IdP struct {
+	ClientID     string
+	ClientSecret string
+	// TrailingSlash makes the issuer identifier end with a slash, as it is for some providers
+	TrailingSlash bool
+
+	server *httptest.Server
+
+	mu         sync.Mutex
+	key        *rsa.PrivateKey
+	keyID      string
+	keyVersion int
+	codes      map[string]authorization
+}
+
+// NewIdP starts an IdP with a single client. Close it once done.
+func NewIdP(clientID, clientSecret string) (*IdP, error) {
+	idp := &IdP{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]authorization),
+	}
+	if err := idp.RotateKey(); err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.serveDiscovery)
+	mux.HandleFunc("/keys", idp.serveKeys)
+	mux.HandleFunc("/token", idp.serveToken)
+	idp.server = httptest.NewServer(mux)
+
+	return idp, nil
+}
+
+// Close shuts the IdP down
+func (idp *IdP) Close() {
+	idp.server.Close()
+}
+
+// Issuer returns the issuer identifier, the URL of the IdP
+func (idp *IdP) Issuer() string {
+	if idp.TrailingSlash {
+		return idp.server.URL + "/"
+	}
+	return idp.server.URL
+}
+
+// RotateKey replaces the signing key, as providers regularly do
+func (idp *IdP) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	idp.keyVersion++
+	idp.key = key
+	idp.keyID = fmt.Sprintf("key-%d", idp.keyVersion)
+	return nil
+}
+
+// Authorize signs the identity in at the authorization URL a relying party sent the user to.
+// It returns the authorization code and state the IdP redirects back with.
+func (idp *IdP) Authorize(authURL string, identity Identity) (code string, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := u.Query()
+
+	switch {
+	case query.Get("response_type") != "code":
+		return "", "", fmt.Errorf("unsupported response type %q", query.Get("response_type"))
+	case query.Get("client_id") != idp.ClientID:
+		return "", "", fmt.Errorf("unknown client %q", query.Get("client_id"))
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		return "", "", fmt.Errorf("missing S256 code challenge")
+	case query.Get("redirect_uri") == "":
+		return "", "", fmt.Errorf("missing redirect URI")
+	}
+
+	code = randomString()
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	idp.codes[code] = authorization{
+		redirectURI:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		identity:      identity,
+	}
+	return code, query.Get("state"), nil
+}
+
+// SignIDToken signs an ID token with the current key of the IdP, whatever its claims
+func (idp *IdP) SignIDToken(claims jwt.MapClaims) (string, error) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.keyID
+	return token.SignedString(idp.key)
+}
+
+// IDTokenClaims returns the claims of a valid ID token of the identity
+func (idp *IdP) IDTokenClaims(identity Identity, nonce string) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   idp.Issuer(),
+		"sub":   identity.Subject,
+		"aud":   idp.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(IDTokenDuration).Unix(),
+		"nonce": nonce,
+	}
+	if identity.Email != "" {
+		claims["email"] = identity.Email
+		claims["email_verified"] = identity.EmailVerified
+	}
+	if identity.Name != "" {
+		claims["name"] = identity.Name
+	}
+	if identity.PreferredUsername != "" {
+		claims["preferred_username"] = identity.PreferredUsername
+	}
+	return claims
+}
+
+func (idp *IdP) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                idp.Issuer(),
+		"authorization_endpoint":                idp.server.URL + "/authorize",
+		"token_endpoint":                        idp.server.URL + "/token",
+		"jwks_uri":                              idp.server.URL + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (idp *IdP) serveKeys(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	key := idp.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": idp.keyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	})
+}
+
+func (idp *IdP) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+	if !ok || clientID != idp.ClientID || clientSecret != idp.ClientSecret {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// codes are single-use, even when the request fails
+	code := r.PostFormValue("code")
+	idp.mu.Lock()
+	auth, ok := idp.codes[code]
+	delete(idp.codes, code)
+	idp.mu.Unlock()
+
+	if !ok || auth.redirectURI != r.PostFormValue("redirect_uri") {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	idToken, err := idp.SignIDToken(idp.IDTokenClaims(auth.identity, auth.nonce))
+	if err != nil {
+		writeTokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   int(IDTokenDuration.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
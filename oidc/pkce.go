@@ -0,0 +1,15 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// CodeChallengeMethod is the only PKCE method we use, plain challenges would leak the verifier
+const CodeChallengeMethod = "S256"
+
+// CodeChallenge returns the S256 PKCE challenge of the code verifier
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
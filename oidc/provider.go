@@ -0,0 +1,189 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultScopes are requested when the config doesn't list any
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+const httpTimeout = 10 * time.Second
+
+var ErrTokenExchange = errors.New("cannot exchange the authorization code")
+
+// providerMetadata is the part of the OpenID Provider discovery document the relying party uses
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect relying party of one identity provider.
+// It signs users in with the authorization code flow protected by PKCE.
+// The discovery document and the signing keys of the provider are fetched on first use.
+type Provider struct {
+	issuerURL    string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	client       *http.Client
+
+	mu       sync.Mutex
+	metadata *providerMetadata
+	keys     *keySet
+}
+
+// NewProvider creates a new Provider for the identity provider at issuerURL
+func NewProvider(issuerURL, clientID, clientSecret, redirectURL string, scopes []string) (*Provider, error) {
+	if issuerURL == "" || clientID == "" || redirectURL == "" {
+		return nil, fmt.Errorf("the issuer URL, client ID and redirect URL of the identity provider are required")
+	}
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+
+	provider := &Provider{
+		issuerURL:    strings.TrimSuffix(issuerURL, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		client:       &http.Client{Timeout: httpTimeout},
+	}
+	return provider, nil
+}
+
+// Issuer returns the issuer identifier of the provider, which scopes its subjects
+func (provider *Provider) Issuer() string {
+	return provider.issuerURL
+}
+
+// AuthCodeURL returns the URL of the provider to send the user to.
+// The state and nonce are echoed back to tie the response to this request,
+// the code verifier is only sent as its challenge.
+func (provider *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := provider.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.clientID)
+	query.Set("redirect_uri", provider.redirectURL)
+	query.Set("scope", strings.Join(provider.scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", CodeChallengeMethod)
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint and returns the ID token it gets
+func (provider *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	metadata, err := provider.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.redirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(provider.clientID), url.QueryEscape(provider.clientSecret))
+
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+	defer response.Body.Close()
+
+	var rsp struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&rsp); err != nil {
+		return "", fmt.Errorf("%w: invalid token response: %v", ErrTokenExchange, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %s %s", ErrTokenExchange, rsp.Error, rsp.ErrorDescription)
+	}
+	if rsp.IDToken == "" {
+		return "", fmt.Errorf("%w: no ID token in the response", ErrTokenExchange)
+	}
+
+	return rsp.IDToken, nil
+}
+
+// discover returns the discovery document of the provider, fetching it on first use
+func (provider *Provider) discover(ctx context.Context) (*providerMetadata, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if provider.metadata != nil {
+		return provider.metadata, nil
+	}
+
+	var metadata providerMetadata
+	err := provider.getJSON(ctx, provider.issuerURL+"/.well-known/openid-configuration", &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("cannot discover identity provider: %w", err)
+	}
+
+	// the document must describe the provider we were told to trust
+	if strings.TrimSuffix(metadata.Issuer, "/") != provider.issuerURL {
+		return nil, fmt.Errorf("identity provider issuer %q doesn't match %q", metadata.Issuer, provider.issuerURL)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("identity provider discovery document is incomplete")
+	}
+
+	provider.metadata = &metadata
+	provider.keys = newKeySet(metadata.JWKSURI, provider.getJSON)
+	return provider.metadata, nil
+}
+
+// getJSON decodes the JSON document at the URL into v
+func (provider *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, response.Status)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(v)
+}
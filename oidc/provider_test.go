@@ -0,0 +1,309 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mockoidc "github.com/amrizal94/simplebank/oidc/mock"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID     = "simplebank"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost:8080/users/login/oidc/callback"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *mockoidc.IdP) {
+	idp, err := mockoidc.NewIdP(testClientID, testClientSecret)
+	require.NoError(t, err)
+	t.Cleanup(idp.Close)
+
+	provider, err := NewProvider(idp.Issuer(), testClientID, testClientSecret, testRedirectURL, nil)
+	require.NoError(t, err)
+	return provider, idp
+}
+
+func randomIdentity() mockoidc.Identity {
+	return mockoidc.Identity{
+		Subject:       util.RandomString(12),
+		Email:         util.RandomEmail(),
+		EmailVerified: true,
+		Name:          util.RandomOwner(),
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	identity := randomIdentity()
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	require.NoError(t, err)
+
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	require.Equal(t, idp.Issuer()+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	require.Equal(t, "openid email profile", u.Query().Get("scope"))
+	require.Equal(t, testRedirectURL, u.Query().Get("redirect_uri"))
+
+	code, state, err := idp.Authorize(authURL, identity)
+	require.NoError(t, err)
+	require.Equal(t, "state", state)
+
+	rawIDToken, err := provider.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verifier")
+	require.NoError(t, err)
+
+	idToken, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce")
+	require.NoError(t, err)
+	require.Equal(t, idp.Issuer(), idToken.Issuer)
+	require.Equal(t, identity.Subject, idToken.Subject)
+	require.Equal(t, identity.Email, idToken.Email)
+	require.True(t, idToken.EmailVerified)
+	require.Equal(t, identity.Name, idToken.Name)
+}
+
+func TestExchangeWrongCodeVerifier(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	require.NoError(t, err)
+
+	code, _, err := idp.Authorize(authURL, randomIdentity())
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(ctx, code, "another-verifier-another-verifier-another")
+	require.ErrorIs(t, err, ErrTokenExchange)
+}
+
+func TestExchangeCodeTwice(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	ctx := context.Background()
+	verifier := "verifier-verifier-verifier-verifier-verifier"
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+	require.NoError(t, err)
+
+	code, _, err := idp.Authorize(authURL, randomIdentity())
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(ctx, code, verifier)
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(ctx, code, verifier)
+	require.ErrorIs(t, err, ErrTokenExchange)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	identity := randomIdentity()
+
+	otherIdP, err := mockoidc.NewIdP(testClientID, testClientSecret)
+	require.NoError(t, err)
+	defer otherIdP.Close()
+
+	testCases := []struct {
+		desc        string
+		buildClaims func() jwt.MapClaims
+		sign        func(claims jwt.MapClaims) (string, error)
+		nonce       string
+		ok          bool
+	}{
+		{
+			desc: "OK",
+			buildClaims: func() jwt.MapClaims {
+				return idp.IDTokenClaims(identity, "nonce")
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+			ok:    true,
+		},
+		{
+			desc: "WrongNonce",
+			buildClaims: func() jwt.MapClaims {
+				return idp.IDTokenClaims(identity, "other-nonce")
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+		},
+		{
+			desc: "EmptyNonce",
+			buildClaims: func() jwt.MapClaims {
+				return idp.IDTokenClaims(identity, "")
+			},
+			sign:  idp.SignIDToken,
+			nonce: "",
+		},
+		{
+			desc: "WrongIssuer",
+			buildClaims: func() jwt.MapClaims {
+				claims := idp.IDTokenClaims(identity, "nonce")
+				claims["iss"] = "https://evil.example.com"
+				return claims
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+		},
+		{
+			desc: "WrongAudience",
+			buildClaims: func() jwt.MapClaims {
+				claims := idp.IDTokenClaims(identity, "nonce")
+				claims["aud"] = "another-client"
+				return claims
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+		},
+		{
+			desc: "SeveralAudiencesWithoutAuthorizedParty",
+			buildClaims: func() jwt.MapClaims {
+				claims := idp.IDTokenClaims(identity, "nonce")
+				claims["aud"] = []string{testClientID, "another-client"}
+				return claims
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+		},
+		{
+			desc: "SeveralAudiencesForUs",
+			buildClaims: func() jwt.MapClaims {
+				claims := idp.IDTokenClaims(identity, "nonce")
+				claims["aud"] = []string{testClientID, "another-client"}
+				claims["azp"] = testClientID
+				return claims
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+			ok:    true,
+		},
+		{
+			desc: "Expired",
+			buildClaims: func() jwt.MapClaims {
+				claims := idp.IDTokenClaims(identity, "nonce")
+				claims["exp"] = time.Now().Add(-clockSkew - time.Minute).Unix()
+				return claims
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+		},
+		{
+			desc: "ExpiredWithinClockSkew",
+			buildClaims: func() jwt.MapClaims {
+				claims := idp.IDTokenClaims(identity, "nonce")
+				claims["exp"] = time.Now().Add(-clockSkew / 2).Unix()
+				return claims
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+			ok:    true,
+		},
+		{
+			desc: "NoSubject",
+			buildClaims: func() jwt.MapClaims {
+				claims := idp.IDTokenClaims(identity, "nonce")
+				delete(claims, "sub")
+				return claims
+			},
+			sign:  idp.SignIDToken,
+			nonce: "nonce",
+		},
+		{
+			desc: "SignedByAnotherProvider",
+			buildClaims: func() jwt.MapClaims {
+				return idp.IDTokenClaims(identity, "nonce")
+			},
+			sign:  otherIdP.SignIDToken,
+			nonce: "nonce",
+		},
+		{
+			desc: "NoneAlgorithm",
+			buildClaims: func() jwt.MapClaims {
+				return idp.IDTokenClaims(identity, "nonce")
+			},
+			sign: func(claims jwt.MapClaims) (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+				token.Header["kid"] = "key-1"
+				return token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			},
+			nonce: "nonce",
+		},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			rawIDToken, err := tC.sign(tC.buildClaims())
+			require.NoError(t, err)
+
+			idToken, err := provider.VerifyIDToken(context.Background(), rawIDToken, tC.nonce)
+			if tC.ok {
+				require.NoError(t, err)
+				require.Equal(t, identity.Subject, idToken.Subject)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidIDToken)
+		})
+	}
+}
+
+func TestVerifyIDTokenAfterKeyRotation(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	identity := randomIdentity()
+	ctx := context.Background()
+
+	rawIDToken, err := idp.SignIDToken(idp.IDTokenClaims(identity, "nonce"))
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, rawIDToken, "nonce")
+	require.NoError(t, err)
+
+	require.NoError(t, idp.RotateKey())
+	rawIDToken, err = idp.SignIDToken(idp.IDTokenClaims(identity, "nonce"))
+	require.NoError(t, err)
+
+	// the keys were just fetched, unknown ones aren't looked up again right away
+	_, err = provider.VerifyIDToken(ctx, rawIDToken, "nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+
+	provider.keys.refreshedAt = time.Now().Add(-minKeyRefreshInterval)
+	_, err = provider.VerifyIDToken(ctx, rawIDToken, "nonce")
+	require.NoError(t, err)
+}
+
+func TestVerifyIDTokenIssuerWithTrailingSlash(t *testing.T) {
+	idp, err := mockoidc.NewIdP(testClientID, testClientSecret)
+	require.NoError(t, err)
+	defer idp.Close()
+	idp.TrailingSlash = true
+
+	provider, err := NewProvider(idp.Issuer(), testClientID, testClientSecret, testRedirectURL, nil)
+	require.NoError(t, err)
+	identity := randomIdentity()
+	ctx := context.Background()
+
+	// the issuer is compared as the provider serves it
+	rawIDToken, err := idp.SignIDToken(idp.IDTokenClaims(identity, "nonce"))
+	require.NoError(t, err)
+	idToken, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce")
+	require.NoError(t, err)
+	require.Equal(t, idp.Issuer(), idToken.Issuer)
+
+	claims := idp.IDTokenClaims(identity, "nonce")
+	claims["iss"] = strings.TrimSuffix(idp.Issuer(), "/")
+	rawIDToken, err = idp.SignIDToken(claims)
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, rawIDToken, "nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestNewProviderMissingSettings(t *testing.T) {
+	_, err := NewProvider("", testClientID, testClientSecret, testRedirectURL, nil)
+	require.Error(t, err)
+
+	_, err = NewProvider("https://idp.example.com", "", testClientSecret, testRedirectURL, nil)
+	require.Error(t, err)
+}
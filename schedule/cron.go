@@ -0,0 +1,152 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the range of the values of a field of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// cronSchedule runs at the minutes matching every field of a cron expression
+type cronSchedule struct {
+	start                                  time.Time
+	minutes, hours, days, months, weekdays uint64
+	// as in cron, a day matches either field when both day fields are restricted
+	anyDay, anyWeekday bool
+}
+
+func parseCron(spec string, start time.Time) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: must have %d fields", spec, len(cronFields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+	}
+
+	// Sunday may be written 7 as well as 0
+	weekdays := bits[4]
+	if weekdays&(1<<7) != 0 {
+		weekdays = weekdays&^(1<<7) | 1
+	}
+
+	return &cronSchedule{
+		start:      start,
+		minutes:    bits[0],
+		hours:      bits[1],
+		days:       bits[2],
+		months:     bits[3],
+		weekdays:   weekdays,
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma separated list of *, values or ranges, each with an optional step
+func parseCronField(field string, r cronField) (uint64, error) {
+	max := r.max
+	if r.name == "day of week" {
+		max = 7
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepExpr)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepExpr, r.name)
+			}
+		}
+
+		low, high := r.min, r.max
+		if expr != "*" {
+			lowExpr, highExpr, isRange := strings.Cut(expr, "-")
+			var err error
+			low, err = strconv.Atoi(lowExpr)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s", lowExpr, r.name)
+			}
+			high = low
+			if isRange {
+				high, err = strconv.Atoi(highExpr)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s", highExpr, r.name)
+				}
+			} else if hasStep {
+				high = r.max
+			}
+		}
+		if low < r.min || high > max || low > high {
+			return 0, fmt.Errorf("%s out of range %d-%d: %q", r.name, r.min, r.max, part)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first minute after t, and at or after the start, matching the expression
+func (schedule *cronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC()
+	if t.Before(schedule.start) {
+		t = schedule.start.Add(-time.Nanosecond)
+	}
+	// the runs are whole minutes
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		if schedule.months&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !schedule.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if schedule.hours&(1<<t.Hour()) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if schedule.minutes&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (schedule *cronSchedule) matchDay(t time.Time) bool {
+	day := schedule.days&(1<<t.Day()) != 0
+	weekday := schedule.weekdays&(1<<int(t.Weekday())) != 0
+	if schedule.anyDay || schedule.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
@@ -0,0 +1,177 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies of an RRULE
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// rruleSchedule runs on the days of a recurrence rule, at the time of day of its start.
+// Only FREQ, INTERVAL, BYDAY with WEEKLY and BYMONTHDAY with MONTHLY are supported,
+// the end of a schedule is kept apart from it.
+type rruleSchedule struct {
+	start    time.Time
+	freq     string
+	interval int
+	weekdays map[time.Weekday]bool
+	// monthDay is negative to count from the end of the month
+	monthDay int
+}
+
+func parseRRule(spec string, start time.Time) (*rruleSchedule, error) {
+	rule := strings.TrimPrefix(strings.ToUpper(spec), "RRULE:")
+	schedule := &rruleSchedule{
+		start:    start,
+		interval: 1,
+		weekdays: map[time.Weekday]bool{start.Weekday(): true},
+		monthDay: start.Day(),
+	}
+
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+
+		switch name {
+		case "FREQ":
+			if value != FreqDaily && value != FreqWeekly && value != FreqMonthly {
+				return nil, fmt.Errorf("unsupported RRULE frequency %q", value)
+			}
+			schedule.freq = value
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid RRULE interval %q", value)
+			}
+			schedule.interval = interval
+		case "BYDAY":
+			schedule.weekdays = make(map[time.Weekday]bool)
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("invalid RRULE day %q", day)
+				}
+				schedule.weekdays[weekday] = true
+			}
+		case "BYMONTHDAY":
+			monthDay, err := strconv.Atoi(value)
+			if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+				return nil, fmt.Errorf("invalid RRULE month day %q", value)
+			}
+			schedule.monthDay = monthDay
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %q", name)
+		}
+	}
+
+	if schedule.freq == "" {
+		return nil, fmt.Errorf("invalid RRULE %q: FREQ is required", spec)
+	}
+	if strings.Contains(rule, "BYDAY=") && schedule.freq != FreqWeekly {
+		return nil, fmt.Errorf("BYDAY is only supported with FREQ=%s", FreqWeekly)
+	}
+	if strings.Contains(rule, "BYMONTHDAY=") && schedule.freq != FreqMonthly {
+		return nil, fmt.Errorf("BYMONTHDAY is only supported with FREQ=%s", FreqMonthly)
+	}
+	return schedule, nil
+}
+
+// Next returns the first run of the rule after t
+func (schedule *rruleSchedule) Next(t time.Time) time.Time {
+	t = t.UTC()
+	if t.Before(schedule.start) {
+		t = schedule.start.Add(-time.Nanosecond)
+	}
+
+	switch schedule.freq {
+	case FreqDaily:
+		return schedule.nextDaily(t)
+	case FreqWeekly:
+		return schedule.nextWeekly(t)
+	default:
+		return schedule.nextMonthly(t)
+	}
+}
+
+// onDay returns the run on the day of year, month and day at the time of day of the start
+func (schedule *rruleSchedule) onDay(year int, month time.Month, day int) time.Time {
+	start := schedule.start
+	return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+}
+
+func (schedule *rruleSchedule) nextDaily(t time.Time) time.Time {
+	days := daysBetween(schedule.start, t)
+	run := schedule.start.AddDate(0, 0, days-days%schedule.interval)
+	for !run.After(t) {
+		run = run.AddDate(0, 0, schedule.interval)
+	}
+	return run
+}
+
+func (schedule *rruleSchedule) nextWeekly(t time.Time) time.Time {
+	firstWeek := weekStart(schedule.start)
+	day := schedule.onDay(t.Year(), t.Month(), t.Day())
+	for i := 0; i <= 7*(schedule.interval+1); i++ {
+		weeks := daysBetween(firstWeek, weekStart(day)) / 7
+		if day.After(t) && weeks%schedule.interval == 0 && schedule.weekdays[day.Weekday()] {
+			return day
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+func (schedule *rruleSchedule) nextMonthly(t time.Time) time.Time {
+	months := (t.Year()-schedule.start.Year())*12 + int(t.Month()-schedule.start.Month())
+	months -= months % schedule.interval
+
+	// months without the day are skipped, a year of them at most
+	for i := 0; i <= 12; i++ {
+		first := time.Date(schedule.start.Year(), schedule.start.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+		lastDay := first.AddDate(0, 1, -1).Day()
+
+		day := schedule.monthDay
+		if day < 0 {
+			day = lastDay + 1 + day
+		}
+		if day >= 1 && day <= lastDay {
+			if run := schedule.onDay(first.Year(), first.Month(), day); run.After(t) {
+				return run
+			}
+		}
+		months += schedule.interval
+	}
+	return time.Time{}
+}
+
+// daysBetween returns the number of whole days from the date of a to the date of b
+func daysBetween(a, b time.Time) int {
+	dateA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dateB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(dateB.Sub(dateA).Hours() / 24)
+}
+
+// weekStart returns the Monday of the week of t, as weeks start in an RRULE by default
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
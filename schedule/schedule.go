@@ -0,0 +1,33 @@
+package schedule
+
+import (
+	"strings"
+	"time"
+)
+
+// maxSearch is how far ahead a schedule is searched for its next run
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Schedule is when a recurring job runs
+type Schedule interface {
+	// Next returns the first run strictly after t, or the zero time if there is none
+	Next(t time.Time) time.Time
+}
+
+// Parse parses a recurrence, either a 5-field cron expression or an RRULE.
+// Runs are worked out in UTC. An RRULE runs at the time of day of start and counts its
+// intervals from start, a cron expression only runs at or after start.
+func Parse(spec string, start time.Time) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	start = start.UTC()
+
+	if strings.HasPrefix(strings.ToUpper(spec), "RRULE:") || strings.Contains(strings.ToUpper(spec), "FREQ=") {
+		return parseRRule(spec, start)
+	}
+	return parseCron(spec, start)
+}
+
+// First returns the first run of a schedule at or after start
+func First(schedule Schedule, start time.Time) time.Time {
+	return schedule.Next(start.Add(-time.Nanosecond))
+}
@@ -0,0 +1,182 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+// runs returns the first n runs of a schedule from start
+func runs(t *testing.T, spec string, start time.Time, n int) []time.Time {
+	schedule, err := Parse(spec, start)
+	require.NoError(t, err)
+
+	var result []time.Time
+	run := First(schedule, start)
+	for i := 0; i < n && !run.IsZero(); i++ {
+		result = append(result, run)
+		run = schedule.Next(run)
+	}
+	return result
+}
+
+func TestCron(t *testing.T) {
+	start := date(2023, time.January, 30, 10, 30)
+
+	testCases := []struct {
+		spec string
+		runs []time.Time
+	}{
+		{
+			spec: "0 9 1 * *",
+			runs: []time.Time{
+				date(2023, time.February, 1, 9, 0),
+				date(2023, time.March, 1, 9, 0),
+				date(2023, time.April, 1, 9, 0),
+			},
+		},
+		{
+			spec: "*/20 10 * * *",
+			runs: []time.Time{
+				date(2023, time.January, 30, 10, 40),
+				date(2023, time.January, 31, 10, 0),
+				date(2023, time.January, 31, 10, 20),
+			},
+		},
+		{
+			spec: "30 10 * * 1-5",
+			runs: []time.Time{
+				date(2023, time.January, 30, 10, 30),
+				date(2023, time.January, 31, 10, 30),
+				date(2023, time.February, 1, 10, 30),
+			},
+		},
+		{
+			// Sunday written as 7
+			spec: "0 0 * * 7",
+			runs: []time.Time{
+				date(2023, time.February, 5, 0, 0),
+				date(2023, time.February, 12, 0, 0),
+			},
+		},
+		{
+			// either day field matches when both are restricted
+			spec: "0 12 15 * 5",
+			runs: []time.Time{
+				date(2023, time.February, 3, 12, 0),
+				date(2023, time.February, 10, 12, 0),
+				date(2023, time.February, 15, 12, 0),
+			},
+		},
+		{
+			spec: "0 0 31 2 *",
+			runs: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.spec, func(t *testing.T) {
+			require.Equal(t, testCase.runs, runs(t, testCase.spec, start, len(testCase.runs)+1)[:len(testCase.runs)])
+		})
+	}
+}
+
+func TestRRule(t *testing.T) {
+	start := date(2023, time.January, 31, 8, 0)
+
+	testCases := []struct {
+		spec string
+		runs []time.Time
+	}{
+		{
+			spec: "RRULE:FREQ=DAILY;INTERVAL=3",
+			runs: []time.Time{
+				date(2023, time.January, 31, 8, 0),
+				date(2023, time.February, 3, 8, 0),
+				date(2023, time.February, 6, 8, 0),
+			},
+		},
+		{
+			// January 31st 2023 is a Tuesday
+			spec: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,FR",
+			runs: []time.Time{
+				date(2023, time.January, 31, 8, 0),
+				date(2023, time.February, 3, 8, 0),
+				date(2023, time.February, 14, 8, 0),
+				date(2023, time.February, 17, 8, 0),
+			},
+		},
+		{
+			// months without a 31st are skipped
+			spec: "RRULE:FREQ=MONTHLY",
+			runs: []time.Time{
+				date(2023, time.January, 31, 8, 0),
+				date(2023, time.March, 31, 8, 0),
+				date(2023, time.May, 31, 8, 0),
+			},
+		},
+		{
+			spec: "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1",
+			runs: []time.Time{
+				date(2023, time.January, 31, 8, 0),
+				date(2023, time.February, 28, 8, 0),
+				date(2023, time.March, 31, 8, 0),
+			},
+		},
+		{
+			spec: "RRULE:FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=15",
+			runs: []time.Time{
+				date(2023, time.April, 15, 8, 0),
+				date(2023, time.July, 15, 8, 0),
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.spec, func(t *testing.T) {
+			require.Equal(t, testCase.runs, runs(t, testCase.spec, start, len(testCase.runs)))
+		})
+	}
+}
+
+func TestNextSkipsMissedRuns(t *testing.T) {
+	start := date(2023, time.January, 1, 9, 0)
+
+	schedule, err := Parse("RRULE:FREQ=DAILY", start)
+	require.NoError(t, err)
+	require.Equal(t, date(2023, time.March, 2, 9, 0), schedule.Next(date(2023, time.March, 1, 9, 0)))
+
+	schedule, err = Parse("0 9 * * *", start)
+	require.NoError(t, err)
+	require.Equal(t, date(2023, time.March, 2, 9, 0), schedule.Next(date(2023, time.March, 1, 9, 0)))
+}
+
+func TestParseInvalid(t *testing.T) {
+	start := date(2023, time.January, 1, 9, 0)
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"RRULE:INTERVAL=2",
+		"RRULE:FREQ=YEARLY",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"RRULE:FREQ=DAILY;INTERVAL=0",
+		"RRULE:FREQ=DAILY;BYDAY=MO",
+		"RRULE:FREQ=WEEKLY;BYDAY=XX",
+		"RRULE:FREQ=MONTHLY;BYMONTHDAY=32",
+	} {
+		_, err := Parse(spec, start)
+		require.Error(t, err, spec)
+	}
+}
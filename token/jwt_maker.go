@@ -0,0 +1,81 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const minSecretKeySize = 32
+
+// JWTMaker is a JSON Web Token maker
+type JWTMaker struct {
+	secretKey string
+}
+
+// NewJWTMaker creates a new JWTMaker
+func NewJWTMaker(secretKey string) (Maker, error) {
+	if len(secretKey) < minSecretKeySize {
+		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	}
+
+	return &JWTMaker{secretKey}, nil
+}
+
+// CreateToken creates a new token of a type for a specific username, role, scopes and duration
+func (maker *JWTMaker) CreateToken(tokenType string, username string, role string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(tokenType, username, role, scopes, uuid.Nil, duration)
+}
+
+// CreateSessionToken creates a new access token for a specific username, role, scopes, login session and duration
+func (maker *JWTMaker) CreateSessionToken(username string, role string, scopes []string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(AccessToken, username, role, scopes, sessionID, duration)
+}
+
+func (maker *JWTMaker) createToken(tokenType string, username string, role string, scopes []string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(tokenType, username, role, scopes, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	payload.SessionID = sessionID
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	token, err := jwtToken.SignedString([]byte(maker.secretKey))
+	return token, payload, err
+}
+
+// VerifyToken verifies check if the token is valid or not
+func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		// only accept the exact algorithm we sign with, so "none" and
+		// algorithm confusion attacks are rejected before the key is used
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, ErrInvalidToken
+		}
+		return []byte(maker.secretKey), nil
+	}
+
+	jwtToken, err := jwt.ParseWithClaims(
+		token,
+		&Payload{},
+		keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+	)
+	if err != nil {
+		verr, ok := err.(*jwt.ValidationError)
+		if ok && errors.Is(verr.Inner, ErrExpiredToken) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	payload, ok := jwtToken.Claims.(*Payload)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
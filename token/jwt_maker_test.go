@@ -0,0 +1,97 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTMaker(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	role := util.DepositorRole
+	scopes := []string{util.AccountsReadScope}
+	duration := time.Minute
+
+	issuedAt := time.Now()
+	expiredAt := time.Now().Add(duration)
+
+	token, payload, err := maker.CreateToken(AccessToken, username, role, scopes, duration)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+	require.NotEmpty(t, token)
+
+	payload, err = maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+
+	require.NotZero(t, payload.ID)
+	require.Equal(t, AccessToken, payload.Type)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, role, payload.Role)
+	require.Equal(t, scopes, payload.Scopes)
+	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
+	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
+}
+
+func TestExpiredJWTToken(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	token, payload, err := maker.CreateToken(AccessToken, util.RandomOwner(), util.DepositorRole, util.AllScopes(), -time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.VerifyToken(token)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestInvalidJWTTokenAlgNone(t *testing.T) {
+	payload, err := NewPayload(AccessToken, util.RandomOwner(), util.DepositorRole, util.AllScopes(), time.Minute)
+	require.NoError(t, err)
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodNone, payload)
+	token, err := jwtToken.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	payload, err = maker.VerifyToken(token)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestInvalidJWTTokenAlgConfusion(t *testing.T) {
+	secretKey := util.RandomString(32)
+	maker, err := NewJWTMaker(secretKey)
+	require.NoError(t, err)
+
+	payload, err := NewPayload(AccessToken, util.RandomOwner(), util.DepositorRole, util.AllScopes(), time.Minute)
+	require.NoError(t, err)
+
+	// same key, different algorithm
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS512, payload)
+	token, err := jwtToken.SignedString([]byte(secretKey))
+	require.NoError(t, err)
+
+	payload, err = maker.VerifyToken(token)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestInvalidJWTKeySize(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(minSecretKeySize - 1))
+	require.Error(t, err)
+	require.Nil(t, maker)
+}
@@ -1,10 +1,24 @@
 package token
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Supported token types, selected by util.Config.TokenType
+const (
+	TypePaseto       = "paseto"
+	TypePasetoPublic = "paseto_public"
+	TypeJWT          = "jwt"
+)
 
 type Maker interface {
-	// CreateToken creates a new token for a specific username and duration
-	CreateToken(username string, duration time.Duration) (string, *Payload, error)
+	// CreateToken creates a new token of a type for a specific username, role, scopes and duration
+	CreateToken(tokenType string, username string, role string, scopes []string, duration time.Duration) (string, *Payload, error)
+
+	// CreateSessionToken creates a new access token like CreateToken, issued for the given login session
+	CreateSessionToken(username string, role string, scopes []string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error)
 
 	// VerifyToken verifies check if the token is valid or not
 	VerifyToken(token string) (*Payload, error)
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/aead/chacha20poly1305"
+	"github.com/google/uuid"
 	"github.com/o1egl/paseto"
 )
 
@@ -28,12 +29,22 @@ func NewPasetoMaker(symmetricKey string) (Maker, error) {
 	return maker, nil
 }
 
-// CreateToken creates a new token for a specific username and duration
-func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(username, duration)
+// CreateToken creates a new token of a type for a specific username, role, scopes and duration
+func (maker *PasetoMaker) CreateToken(tokenType string, username string, role string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(tokenType, username, role, scopes, uuid.Nil, duration)
+}
+
+// CreateSessionToken creates a new access token for a specific username, role, scopes, login session and duration
+func (maker *PasetoMaker) CreateSessionToken(username string, role string, scopes []string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(AccessToken, username, role, scopes, sessionID, duration)
+}
+
+func (maker *PasetoMaker) createToken(tokenType string, username string, role string, scopes []string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(tokenType, username, role, scopes, duration)
 	if err != nil {
 		return "", payload, err
 	}
+	payload.SessionID = sessionID
 
 	token, err := maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
 	return token, payload, err
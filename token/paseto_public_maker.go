@@ -0,0 +1,195 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const pasetoPublicHeader = "v4.public."
+
+// PublicKey is an Ed25519 verification key identified by its key ID
+type PublicKey struct {
+	ID  string
+	Key ed25519.PublicKey
+}
+
+// PublicKeySet is implemented by makers whose tokens can be verified
+// offline with published public keys
+type PublicKeySet interface {
+	PublicKeys() []PublicKey
+}
+
+// PasetoPublicMaker is a PASETO v4.public token maker.
+// Tokens are signed with a single Ed25519 private key and carry its key ID
+// in the footer, so they can be verified against any key in the published set.
+type PasetoPublicMaker struct {
+	keyID      string
+	privateKey ed25519.PrivateKey
+	publicKeys map[string]ed25519.PublicKey
+}
+
+type pasetoPublicFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// NewPasetoPublicMaker creates a new PasetoPublicMaker.
+// privateKey is the hex encoded Ed25519 seed used for signing, and publicKeys is
+// a list of "kid=hex" entries for previous keys that are still accepted.
+func NewPasetoPublicMaker(keyID string, privateKey string, publicKeys []string) (Maker, error) {
+	if len(keyID) == 0 {
+		return nil, fmt.Errorf("key id must not be empty")
+	}
+
+	seed, err := hex.DecodeString(privateKey)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid private key: must be %d hex encoded bytes", ed25519.SeedSize)
+	}
+
+	maker := &PasetoPublicMaker{
+		keyID:      keyID,
+		privateKey: ed25519.NewKeyFromSeed(seed),
+		publicKeys: make(map[string]ed25519.PublicKey),
+	}
+
+	for _, entry := range publicKeys {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || len(id) == 0 {
+			return nil, fmt.Errorf("invalid public key entry %q: must be kid=hex", entry)
+		}
+
+		key, err := hex.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key %q: must be %d hex encoded bytes", id, ed25519.PublicKeySize)
+		}
+		maker.publicKeys[id] = key
+	}
+	maker.publicKeys[keyID] = maker.privateKey.Public().(ed25519.PublicKey)
+
+	return maker, nil
+}
+
+// CreateToken creates a new token of a type for a specific username, role, scopes and duration
+func (maker *PasetoPublicMaker) CreateToken(tokenType string, username string, role string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(tokenType, username, role, scopes, uuid.Nil, duration)
+}
+
+// CreateSessionToken creates a new access token for a specific username, role, scopes, login session and duration
+func (maker *PasetoPublicMaker) CreateSessionToken(username string, role string, scopes []string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	return maker.createToken(AccessToken, username, role, scopes, sessionID, duration)
+}
+
+func (maker *PasetoPublicMaker) createToken(tokenType string, username string, role string, scopes []string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(tokenType, username, role, scopes, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	payload.SessionID = sessionID
+
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return "", payload, err
+	}
+
+	footer, err := json.Marshal(pasetoPublicFooter{KeyID: maker.keyID})
+	if err != nil {
+		return "", payload, err
+	}
+
+	signature := ed25519.Sign(maker.privateKey, pae([]byte(pasetoPublicHeader), message, footer, nil))
+
+	token := pasetoPublicHeader +
+		base64.RawURLEncoding.EncodeToString(append(message, signature...)) +
+		"." + base64.RawURLEncoding.EncodeToString(footer)
+
+	return token, payload, nil
+}
+
+// VerifyToken verifies check if the token is valid or not
+func (maker *PasetoPublicMaker) VerifyToken(token string) (*Payload, error) {
+	if !strings.HasPrefix(token, pasetoPublicHeader) {
+		return nil, ErrInvalidToken
+	}
+
+	parts := strings.Split(strings.TrimPrefix(token, pasetoPublicHeader), ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(body) < ed25519.SignatureSize {
+		return nil, ErrInvalidToken
+	}
+
+	footer, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var f pasetoPublicFooter
+	if err := json.Unmarshal(footer, &f); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	publicKey, ok := maker.publicKeys[f.KeyID]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	message := body[:len(body)-ed25519.SignatureSize]
+	signature := body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(publicKey, pae([]byte(pasetoPublicHeader), message, footer, nil), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err := json.Unmarshal(message, payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	err = payload.Valid()
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// PublicKeys returns every key the maker accepts, sorted by key ID
+func (maker *PasetoPublicMaker) PublicKeys() []PublicKey {
+	keys := make([]PublicKey, 0, len(maker.publicKeys))
+	for id, key := range maker.publicKeys {
+		keys = append(keys, PublicKey{ID: id, Key: key})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// pae implements the PASETO pre-authentication encoding
+func pae(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+
+	le64 := make([]byte, 8)
+	binary.LittleEndian.PutUint64(le64, uint64(len(pieces)))
+	buf.Write(le64)
+
+	for _, piece := range pieces {
+		binary.LittleEndian.PutUint64(le64, uint64(len(piece)))
+		buf.Write(le64)
+		buf.Write(piece)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,149 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomEd25519Key(t *testing.T) (seed string, publicKey string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return hex.EncodeToString(private.Seed()), hex.EncodeToString(public)
+}
+
+func TestPasetoPublicMaker(t *testing.T) {
+	privateKey, _ := randomEd25519Key(t)
+	maker, err := NewPasetoPublicMaker("key-1", privateKey, nil)
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	role := util.DepositorRole
+	scopes := []string{util.AccountsReadScope}
+	duration := time.Minute
+
+	issuedAt := time.Now()
+	expiredAt := time.Now().Add(duration)
+
+	token, payload, err := maker.CreateToken(AccessToken, username, role, scopes, duration)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+	require.True(t, strings.HasPrefix(token, pasetoPublicHeader))
+
+	payload, err = maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+
+	require.NotZero(t, payload.ID)
+	require.Equal(t, AccessToken, payload.Type)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, role, payload.Role)
+	require.Equal(t, scopes, payload.Scopes)
+	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
+	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
+}
+
+func TestExpiredPasetoPublicToken(t *testing.T) {
+	privateKey, _ := randomEd25519Key(t)
+	maker, err := NewPasetoPublicMaker("key-1", privateKey, nil)
+	require.NoError(t, err)
+
+	token, payload, err := maker.CreateToken(AccessToken, util.RandomOwner(), util.DepositorRole, util.AllScopes(), -time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.VerifyToken(token)
+	require.Error(t, err)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestPasetoPublicKeyRotation(t *testing.T) {
+	oldPrivateKey, oldPublicKey := randomEd25519Key(t)
+	oldMaker, err := NewPasetoPublicMaker("key-1", oldPrivateKey, nil)
+	require.NoError(t, err)
+
+	oldToken, _, err := oldMaker.CreateToken(AccessToken, util.RandomOwner(), util.DepositorRole, util.AllScopes(), time.Minute)
+	require.NoError(t, err)
+
+	newPrivateKey, _ := randomEd25519Key(t)
+	newMaker, err := NewPasetoPublicMaker("key-2", newPrivateKey, []string{
+		fmt.Sprintf("key-1=%s", oldPublicKey),
+	})
+	require.NoError(t, err)
+
+	// tokens signed with the retired key are still accepted
+	payload, err := newMaker.VerifyToken(oldToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, payload)
+
+	// but the old maker does not know about the new key
+	newToken, _, err := newMaker.CreateToken(AccessToken, util.RandomOwner(), util.DepositorRole, util.AllScopes(), time.Minute)
+	require.NoError(t, err)
+
+	payload, err = oldMaker.VerifyToken(newToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+
+	keys := newMaker.(PublicKeySet).PublicKeys()
+	require.Len(t, keys, 2)
+	require.Equal(t, "key-1", keys[0].ID)
+	require.Equal(t, "key-2", keys[1].ID)
+}
+
+func TestInvalidPasetoPublicToken(t *testing.T) {
+	privateKey, _ := randomEd25519Key(t)
+	maker, err := NewPasetoPublicMaker("key-1", privateKey, nil)
+	require.NoError(t, err)
+
+	token, _, err := maker.CreateToken(AccessToken, util.RandomOwner(), util.DepositorRole, util.AllScopes(), time.Minute)
+	require.NoError(t, err)
+
+	otherPrivateKey, _ := randomEd25519Key(t)
+	otherMaker, err := NewPasetoPublicMaker("key-1", otherPrivateKey, nil)
+	require.NoError(t, err)
+
+	forgedToken, _, err := otherMaker.CreateToken(AccessToken, util.RandomOwner(), util.DepositorRole, util.AllScopes(), time.Minute)
+	require.NoError(t, err)
+
+	// replacing the footer changes the signed data
+	dot := strings.LastIndex(token, ".")
+	tamperedFooter := token[:dot+1] + strings.TrimRight(token[dot+1:], "=") + "e30"
+
+	for _, invalid := range []string{
+		"",
+		"v2.local.abc",
+		token[:len(token)-10],
+		tamperedFooter,
+		forgedToken,
+	} {
+		payload, err := maker.VerifyToken(invalid)
+		require.EqualError(t, err, ErrInvalidToken.Error())
+		require.Nil(t, payload)
+	}
+}
+
+func TestInvalidPasetoPublicKey(t *testing.T) {
+	privateKey, publicKey := randomEd25519Key(t)
+
+	_, err := NewPasetoPublicMaker("", privateKey, nil)
+	require.Error(t, err)
+
+	_, err = NewPasetoPublicMaker("key-1", privateKey[:10], nil)
+	require.Error(t, err)
+
+	_, err = NewPasetoPublicMaker("key-1", privateKey, []string{publicKey})
+	require.Error(t, err)
+
+	_, err = NewPasetoPublicMaker("key-1", privateKey, []string{"key-0=abc"})
+	require.Error(t, err)
+}
@@ -7,6 +7,13 @@ import (
 	"github.com/google/uuid"
 )
 
+// Token types, so a token can only be used for what it was issued for
+const (
+	AccessToken    = "access"
+	RefreshToken   = "refresh"
+	ChallengeToken = "challenge"
+)
+
 var (
 	ErrExpiredToken = errors.New("token is expired")
 	ErrInvalidToken = errors.New("token is invalid")
@@ -14,14 +21,20 @@ var (
 
 // Payload contains the payload data of the token
 type Payload struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
+	ID       uuid.UUID `json:"id"`
+	Type     string    `json:"type"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Scopes   []string  `json:"scopes"`
+	// SessionID is the login session an access token was issued for.
+	// Refresh tokens don't set it, their ID is the one of their session.
+	SessionID uuid.UUID `json:"session_id,omitempty"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expires_at"`
 }
 
-// NewPayload creates a new token payload with a specified username and duration
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+// NewPayload creates a new token payload with a specified type, username, role, scopes and duration
+func NewPayload(tokenType string, username string, role string, scopes []string, duration time.Duration) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -29,7 +42,10 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 
 	payload := &Payload{
 		ID:        tokenID,
+		Type:      tokenType,
 		Username:  username,
+		Role:      role,
+		Scopes:    scopes,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 	}
@@ -37,6 +53,24 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	return payload, nil
 }
 
+// Session returns the ID of the login session the token belongs to
+func (p *Payload) Session() uuid.UUID {
+	if p.SessionID != uuid.Nil {
+		return p.SessionID
+	}
+	return p.ID
+}
+
+// HasScope checks if the token was granted the scope
+func (p *Payload) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // Valid checks if the token payload is valid or not
 func (p *Payload) Valid() error {
 	if time.Now().After(p.ExpiredAt) {
@@ -0,0 +1,64 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amrizal94/simplebank/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionToken(t *testing.T) {
+	privateKey, _ := randomEd25519Key(t)
+
+	testCases := []struct {
+		desc      string
+		makeMaker func() (Maker, error)
+	}{
+		{
+			desc: "Paseto",
+			makeMaker: func() (Maker, error) {
+				return NewPasetoMaker(util.RandomString(32))
+			},
+		},
+		{
+			desc: "PasetoPublic",
+			makeMaker: func() (Maker, error) {
+				return NewPasetoPublicMaker("key-1", privateKey, nil)
+			},
+		},
+		{
+			desc: "JWT",
+			makeMaker: func() (Maker, error) {
+				return NewJWTMaker(util.RandomString(32))
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			maker, err := tC.makeMaker()
+			require.NoError(t, err)
+
+			sessionID := uuid.New()
+			token, _, err := maker.CreateSessionToken(util.RandomOwner(), util.DepositorRole, nil, sessionID, time.Minute)
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, AccessToken, payload.Type)
+			require.Equal(t, sessionID, payload.SessionID)
+			require.Equal(t, sessionID, payload.Session())
+
+			// tokens without a session, like refresh tokens, are their own session
+			token, _, err = maker.CreateToken(RefreshToken, util.RandomOwner(), util.DepositorRole, nil, time.Minute)
+			require.NoError(t, err)
+
+			payload, err = maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, RefreshToken, payload.Type)
+			require.Equal(t, uuid.Nil, payload.SessionID)
+			require.Equal(t, payload.ID, payload.Session())
+		})
+	}
+}
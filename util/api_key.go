@@ -0,0 +1,59 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// apiKeyPrefixSize is long enough for prefixes, which are unique, not to collide
+	apiKeyPrefixSize = 16
+	// legacyAPIKeyPrefixSize is the size of the prefixes of the first keys, which still work
+	legacyAPIKeyPrefixSize = 8
+	apiKeySecretSize       = 32
+	apiKeySeparator        = "."
+)
+
+// GenerateAPIKey returns a new random API key and its lookup prefix.
+// The key has the form <prefix>.<secret>; only the prefix is stored in clear.
+func GenerateAPIKey() (prefix string, key string, err error) {
+	prefixBytes := make([]byte, apiKeyPrefixSize/2)
+	if _, err = rand.Read(prefixBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	secretBytes := make([]byte, apiKeySecretSize)
+	if _, err = rand.Read(secretBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = prefix + apiKeySeparator + base64.RawURLEncoding.EncodeToString(secretBytes)
+	return prefix, key, nil
+}
+
+// APIKeyPrefix returns the lookup prefix of an API key
+func APIKeyPrefix(key string) (string, bool) {
+	prefix, secret, ok := strings.Cut(key, apiKeySeparator)
+	if !ok || (len(prefix) != apiKeyPrefixSize && len(prefix) != legacyAPIKeyPrefixSize) || len(secret) == 0 {
+		return "", false
+	}
+	return prefix, true
+}
+
+// HashAPIKey returns the SHA-256 hash of the API key.
+// API keys are long random secrets, so a fast hash is enough to protect them at rest.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKey checks if the key matches the hashed key
+func CheckAPIKey(key string, hashedKey string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hashedKey)) == 1
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey(t *testing.T) {
+	prefix, key, err := GenerateAPIKey()
+	require.NoError(t, err)
+	require.Len(t, prefix, apiKeyPrefixSize)
+	require.NotEmpty(t, key)
+
+	gotPrefix, ok := APIKeyPrefix(key)
+	require.True(t, ok)
+	require.Equal(t, prefix, gotPrefix)
+
+	hashedKey := HashAPIKey(key)
+	require.NotEqual(t, key, hashedKey)
+	require.True(t, CheckAPIKey(key, hashedKey))
+
+	_, otherKey, err := GenerateAPIKey()
+	require.NoError(t, err)
+	require.NotEqual(t, key, otherKey)
+	require.False(t, CheckAPIKey(otherKey, hashedKey))
+}
+
+func TestInvalidAPIKeyPrefix(t *testing.T) {
+	for _, key := range []string{"", "abc", "abcdefgh", "abcdefgh.", "abc.secret", "abcdefghabcd.secret"} {
+		_, ok := APIKeyPrefix(key)
+		require.False(t, ok, key)
+	}
+}
+
+func TestLegacyAPIKeyPrefix(t *testing.T) {
+	prefix, ok := APIKeyPrefix("abcdefgh.secret")
+	require.True(t, ok)
+	require.Equal(t, "abcdefgh", prefix)
+}
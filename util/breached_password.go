@@ -0,0 +1,62 @@
+package util
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const breachedPrefixLength = 5
+
+// BreachedPasswords looks passwords up in a local copy of a breached password corpus
+// such as Pwned Passwords. The corpus is split by the first 5 hex characters of the SHA-1
+// of the passwords, the k-anonymity ranges: <dir>/<PREFIX>.txt holds a "<SUFFIX>:<COUNT>" line
+// per breached password, so a lookup only reads the one small file of its range.
+type BreachedPasswords struct {
+	dir string
+}
+
+// NewBreachedPasswords returns the breached password list stored in dir
+func NewBreachedPasswords(dir string) (*BreachedPasswords, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open breached password list: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password list %s is not a directory", dir)
+	}
+	return &BreachedPasswords{dir: dir}, nil
+}
+
+// Contains reports whether the password appears in the list
+func (list *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+
+	file, err := os.Open(filepath.Join(list.dir, prefix+".txt"))
+	if err != nil {
+		// no file means no breached password in the range
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineSuffix, _, _ := strings.Cut(line, ":")
+		if strings.EqualFold(lineSuffix, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -7,11 +7,57 @@ import (
 )
 
 type Config struct {
-	DBDriver            string        `mapstructure:"DB_DRIVER"`
-	DBSource            string        `mapstructure:"DB_SOURCE"`
-	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
-	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETIC_KEY"`
-	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	DBDriver                     string        `mapstructure:"DB_DRIVER"`
+	DBSource                     string        `mapstructure:"DB_SOURCE"`
+	ServerAddress                string        `mapstructure:"SERVER_ADDRESS"`
+	TokenType                    string        `mapstructure:"TOKEN_TYPE"`
+	TokenSymmetricKey            string        `mapstructure:"TOKEN_SYMMETIC_KEY"`
+	TokenKeyID                   string        `mapstructure:"TOKEN_KEY_ID"`
+	TokenPrivateKey              string        `mapstructure:"TOKEN_PRIVATE_KEY"`
+	TokenPublicKeys              []string      `mapstructure:"TOKEN_PUBLIC_KEYS"`
+	AccessTokenDuration          time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration         time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	TOTPIssuer                   string        `mapstructure:"TOTP_ISSUER"`
+	TOTPChallengeDuration        time.Duration `mapstructure:"TOTP_CHALLENGE_DURATION"`
+	LoginMaxUsernameFailures     int           `mapstructure:"LOGIN_MAX_USERNAME_FAILURES"`
+	LoginMaxIPFailures           int           `mapstructure:"LOGIN_MAX_IP_FAILURES"`
+	LoginBackoffBase             time.Duration `mapstructure:"LOGIN_BACKOFF_BASE"`
+	LoginLockDuration            time.Duration `mapstructure:"LOGIN_LOCK_DURATION"`
+	LoginFailureWindow           time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	EmailSenderType              string        `mapstructure:"EMAIL_SENDER_TYPE"`
+	EmailSenderName              string        `mapstructure:"EMAIL_SENDER_NAME"`
+	EmailSenderAddress           string        `mapstructure:"EMAIL_SENDER_ADDRESS"`
+	EmailSenderPassword          string        `mapstructure:"EMAIL_SENDER_PASSWORD"`
+	SMTPAddress                  string        `mapstructure:"SMTP_ADDRESS"`
+	EmailFileDir                 string        `mapstructure:"EMAIL_FILE_DIR"`
+	VerifyEmailURL               string        `mapstructure:"VERIFY_EMAIL_URL"`
+	VerifyEmailDuration          time.Duration `mapstructure:"VERIFY_EMAIL_DURATION"`
+	PasswordResetURL             string        `mapstructure:"PASSWORD_RESET_URL"`
+	PasswordResetDuration        time.Duration `mapstructure:"PASSWORD_RESET_DURATION"`
+	PasswordResetRateWindow      time.Duration `mapstructure:"PASSWORD_RESET_RATE_WINDOW"`
+	PasswordResetMaxPerEmail     int           `mapstructure:"PASSWORD_RESET_MAX_PER_EMAIL"`
+	PasswordResetMaxPerIP        int           `mapstructure:"PASSWORD_RESET_MAX_PER_IP"`
+	PasswordArgon2Memory         uint32        `mapstructure:"PASSWORD_ARGON2_MEMORY"`
+	PasswordArgon2Iterations     uint32        `mapstructure:"PASSWORD_ARGON2_ITERATIONS"`
+	PasswordArgon2Parallelism    uint8         `mapstructure:"PASSWORD_ARGON2_PARALLELISM"`
+	PasswordMinLength            int           `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMinScore             int           `mapstructure:"PASSWORD_MIN_SCORE"`
+	PasswordBreachedDir          string        `mapstructure:"PASSWORD_BREACHED_DIR"`
+	OIDCIssuerURL                string        `mapstructure:"OIDC_ISSUER_URL"`
+	OIDCClientID                 string        `mapstructure:"OIDC_CLIENT_ID"`
+	OIDCClientSecret             string        `mapstructure:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL              string        `mapstructure:"OIDC_REDIRECT_URL"`
+	OIDCScopes                   []string      `mapstructure:"OIDC_SCOPES"`
+	OIDCAuthRequestDuration      time.Duration `mapstructure:"OIDC_AUTH_REQUEST_DURATION"`
+	IdempotencyKeyDuration       time.Duration `mapstructure:"IDEMPOTENCY_KEY_DURATION"`
+	PaginationCursorKey          string        `mapstructure:"PAGINATION_CURSOR_KEY"`
+	PaginationDefaultPageSize    int           `mapstructure:"PAGINATION_DEFAULT_PAGE_SIZE"`
+	PaginationMaxPageSize        int           `mapstructure:"PAGINATION_MAX_PAGE_SIZE"`
+	FXRateProvider               string        `mapstructure:"FX_RATE_PROVIDER"`
+	FXRatesFile                  string        `mapstructure:"FX_RATES_FILE"`
+	FXQuoteDuration              time.Duration `mapstructure:"FX_QUOTE_DURATION"`
+	ScheduledTransferInterval    time.Duration `mapstructure:"SCHEDULED_TRANSFER_INTERVAL"`
+	ScheduledTransferMaxAttempts int           `mapstructure:"SCHEDULED_TRANSFER_MAX_ATTEMPTS"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -1,20 +1,162 @@
 package util
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword returns the bycrypt bash of the password
+// ErrMismatchedPassword is returned by CheckPassword when the password doesn't match the hash
+var ErrMismatchedPassword = errors.New("password doesn't match the hash")
+
+var errUnsupportedPasswordHash = errors.New("unsupported password hash")
+
+const (
+	argon2idPrefix     = "$argon2id$"
+	argon2SaltLength   = 16
+	argon2KeyLength    = 32
+	passwordHashFormat = "$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s"
+)
+
+// PasswordParams holds the argon2id cost parameters of new password hashes
+type PasswordParams struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultPasswordParams follows the OWASP recommendation for argon2id
+var DefaultPasswordParams = PasswordParams{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+}
+
+// NewPasswordParams returns the parameters set in the config,
+// falling back to the defaults for the ones left out
+func NewPasswordParams(config Config) PasswordParams {
+	params := PasswordParams{
+		Memory:      config.PasswordArgon2Memory,
+		Iterations:  config.PasswordArgon2Iterations,
+		Parallelism: config.PasswordArgon2Parallelism,
+	}
+	if params.Memory == 0 {
+		params.Memory = DefaultPasswordParams.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = DefaultPasswordParams.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultPasswordParams.Parallelism
+	}
+	return params
+}
+
+// HashPassword returns the argon2id hash of the password using the default parameters
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
+	return HashPasswordWithParams(password, DefaultPasswordParams)
+}
+
+// HashPasswordWithParams returns the argon2id hash of the password in the PHC string format
+func HashPasswordWithParams(password string, params PasswordParams) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
-	return string(hashedPassword), nil
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf(
+		passwordHashFormat,
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
+// CheckPassword checks the password against an argon2id or a bcrypt hash.
+// It returns ErrMismatchedPassword if they don't match.
 func CheckPassword(password string, hashedPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if !strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return ErrMismatchedPassword
+		}
+		return err
+	}
+
+	params, salt, key, err := decodeArgon2idHash(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+// PasswordNeedsRehash reports whether the hash was made with another algorithm
+// or weaker parameters than the given ones, and should be replaced on the next successful login.
+// Hashes made with stronger parameters are kept.
+func PasswordNeedsRehash(hashedPassword string, params PasswordParams) bool {
+	if !strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		return true
+	}
+
+	hashParams, _, key, err := decodeArgon2idHash(hashedPassword)
+	if err != nil {
+		return true
+	}
+	return hashParams.Memory < params.Memory ||
+		hashParams.Iterations < params.Iterations ||
+		hashParams.Parallelism < params.Parallelism ||
+		len(key) < argon2KeyLength
+}
+
+func decodeArgon2idHash(hashedPassword string) (params PasswordParams, salt, key []byte, err error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 {
+		err = errUnsupportedPasswordHash
+		return
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		err = errUnsupportedPasswordHash
+		return
+	}
+	if version != argon2.Version {
+		err = errUnsupportedPasswordHash
+		return
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		err = errUnsupportedPasswordHash
+		return
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		err = errUnsupportedPasswordHash
+		return
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		err = errUnsupportedPasswordHash
+		return
+	}
+	return
 }
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Default password policy, used for the settings left out of the config
+const (
+	DefaultPasswordMinLength = 8
+	DefaultPasswordMinScore  = 2
+)
+
+// minUserInputLength keeps very short usernames from ruling out most passwords
+const minUserInputLength = 3
+
+// Errors returned by PasswordPolicy.Check, wrapped with the details of the rule
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooWeak  = errors.New("password is too easy to guess")
+	ErrPasswordPersonal = errors.New("password must not contain the username or email")
+	ErrPasswordBreached = errors.New("password has appeared in a data breach")
+)
+
+// PasswordPolicy holds the rules a new password must follow
+type PasswordPolicy struct {
+	MinLength int
+	// MinScore is the lowest PasswordStrength accepted
+	MinScore int
+	// Breached, if set, rejects passwords known from data breaches
+	Breached *BreachedPasswords
+}
+
+// NewPasswordPolicy returns the policy set in the config,
+// falling back to the defaults for the settings left out
+func NewPasswordPolicy(config Config) (PasswordPolicy, error) {
+	policy := PasswordPolicy{
+		MinLength: config.PasswordMinLength,
+		MinScore:  config.PasswordMinScore,
+	}
+	if policy.MinLength == 0 {
+		policy.MinLength = DefaultPasswordMinLength
+	}
+	if policy.MinScore == 0 {
+		policy.MinScore = DefaultPasswordMinScore
+	}
+
+	if config.PasswordBreachedDir != "" {
+		breached, err := NewBreachedPasswords(config.PasswordBreachedDir)
+		if err != nil {
+			return PasswordPolicy{}, err
+		}
+		policy.Breached = breached
+	}
+
+	return policy, nil
+}
+
+// Check returns an error wrapping one of the ErrPassword errors for the first rule the password breaks.
+// The user inputs, such as the username and the email, must not appear in the password.
+func (policy PasswordPolicy) Check(password string, userInputs ...string) error {
+	if len([]rune(password)) < policy.MinLength {
+		return fmt.Errorf("%w, it must have at least %d characters", ErrPasswordTooShort, policy.MinLength)
+	}
+
+	if err := policy.CheckUserInputs(password, userInputs...); err != nil {
+		return err
+	}
+
+	if PasswordStrength(password) < policy.MinScore {
+		return fmt.Errorf("%w, add more words or characters and avoid common patterns", ErrPasswordTooWeak)
+	}
+
+	if policy.Breached != nil {
+		breached, err := policy.Breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("cannot check breached passwords: %w", err)
+		}
+		if breached {
+			return fmt.Errorf("%w, choose another one", ErrPasswordBreached)
+		}
+	}
+
+	return nil
+}
+
+// CheckUserInputs only checks that none of the user inputs appear in the password.
+// For an email, its local part is looked for too.
+func (policy PasswordPolicy) CheckUserInputs(password string, userInputs ...string) error {
+	password = strings.ToLower(password)
+
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		candidates := []string{input}
+		if local, _, found := strings.Cut(input, "@"); found {
+			candidates = append(candidates, local)
+		}
+
+		for _, candidate := range candidates {
+			if len(candidate) >= minUserInputLength && strings.Contains(password, candidate) {
+				return ErrPasswordPersonal
+			}
+		}
+	}
+
+	return nil
+}
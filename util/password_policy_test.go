@@ -0,0 +1,120 @@
+package util
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeBreachedPasswords stores the passwords as a k-anonymity range list in a temporary directory
+func writeBreachedPasswords(t *testing.T, passwords ...string) string {
+	dir := t.TempDir()
+
+	for _, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+		file, err := os.OpenFile(filepath.Join(dir, hash[:5]+".txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = file.WriteString(hash[5:] + ":42\r\n")
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+	}
+
+	return dir
+}
+
+func TestPasswordStrength(t *testing.T) {
+	testCases := []struct {
+		password string
+		score    int
+	}{
+		{"password", 0},
+		{"Password1", 0},
+		{"p@ssw0rd!", 0},
+		{"aaaaaaaaaa", 0},
+		{"abcdefghij", 0},
+		{"123456789", 0},
+		{"x7k", 1},
+		{"kqzjv", 2},
+		{"kqzjvb", 3},
+		{"mjwqxhfpt", 4},
+		{"Tr0ub4dor&3x", 4},
+		{"correcthorsebatterystaple", 4},
+	}
+
+	for _, tC := range testCases {
+		require.Equal(t, tC.score, PasswordStrength(tC.password), tC.password)
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	dir := writeBreachedPasswords(t, "breached-one", "breached-two")
+
+	list, err := NewBreachedPasswords(dir)
+	require.NoError(t, err)
+
+	for _, password := range []string{"breached-one", "breached-two"} {
+		breached, err := list.Contains(password)
+		require.NoError(t, err)
+		require.True(t, breached)
+	}
+
+	breached, err := list.Contains(RandomString(16))
+	require.NoError(t, err)
+	require.False(t, breached)
+
+	_, err = NewBreachedPasswords(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	dir := writeBreachedPasswords(t, "kqzjvbnmwx")
+
+	policy, err := NewPasswordPolicy(Config{PasswordBreachedDir: dir})
+	require.NoError(t, err)
+	require.Equal(t, DefaultPasswordMinLength, policy.MinLength)
+	require.Equal(t, DefaultPasswordMinScore, policy.MinScore)
+
+	testCases := []struct {
+		desc     string
+		password string
+		err      error
+	}{
+		{"OK", "mjwqxhfptz", nil},
+		{"TooShort", "mjwqxhf", ErrPasswordTooShort},
+		{"TooWeak", "password1", ErrPasswordTooWeak},
+		{"Username", "xx-alice-mjwqx", ErrPasswordPersonal},
+		{"EmailLocalPart", "ALICE.SMITHmjwq", ErrPasswordPersonal},
+		{"Breached", "kqzjvbnmwx", ErrPasswordBreached},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := policy.Check(tC.password, "alice", "alice.smith@example.com")
+			if tC.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tC.err)
+		})
+	}
+}
+
+func TestPasswordPolicyShortUserInputs(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MinScore: 2}
+
+	// a two letter username would otherwise rule out any password containing it
+	require.NoError(t, policy.Check("mjwqxhfptz", "mj"))
+	require.ErrorIs(t, policy.Check("mjwqxhfptz", "mjw"), ErrPasswordPersonal)
+}
+
+func TestNewPasswordPolicyMissingBreachedDir(t *testing.T) {
+	_, err := NewPasswordPolicy(Config{PasswordBreachedDir: filepath.Join(t.TempDir(), "missing")})
+	require.Error(t, err)
+}
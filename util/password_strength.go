@@ -0,0 +1,145 @@
+package util
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords are the passwords and words tried first by any guessing attack, most common first
+var commonPasswords = []string{
+	"password", "123456", "12345678", "qwerty", "123456789", "12345", "1234", "111111",
+	"1234567", "dragon", "123123", "baseball", "abc123", "football", "monkey", "letmein",
+	"696969", "shadow", "master", "666666", "qwertyuiop", "123321", "mustang", "1234567890",
+	"michael", "654321", "superman", "1qaz2wsx", "7777777", "121212", "000000", "qazwsx",
+	"123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter",
+	"buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou",
+	"starwars", "princess", "welcome", "login", "admin", "passw0rd", "hello", "freedom",
+	"whatever", "secret", "charlie", "computer", "bank", "money", "simplebank", "changeme",
+}
+
+var leetSubstitutions = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i",
+)
+
+// PasswordStrength estimates how hard the password is to guess on the zxcvbn scale,
+// from 0 (too guessable) to 4 (very unguessable)
+func PasswordStrength(password string) int {
+	guesses := estimateGuesses(password)
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// estimateGuesses returns roughly how many guesses an attacker trying common passwords first,
+// then every combination of the characters used, needs to find the password
+func estimateGuesses(password string) float64 {
+	if len(password) == 0 {
+		return 1
+	}
+
+	if rank := commonPasswordRank(strings.ToLower(password)); rank > 0 {
+		return float64(rank) * caseVariations(password)
+	}
+
+	// a common word with a few digits or symbols around it is barely better than the word
+	core := strings.TrimFunc(password, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(core) > 0 {
+		word := strings.ToLower(core)
+		for _, candidate := range []string{word, leetSubstitutions.Replace(word)} {
+			if rank := commonPasswordRank(candidate); rank > 0 {
+				affix := strings.Replace(password, core, "", 1)
+				return float64(rank) * caseVariations(core) * bruteforceGuesses(affix)
+			}
+		}
+	}
+
+	return bruteforceGuesses(password)
+}
+
+func commonPasswordRank(password string) int {
+	if len(password) == 0 {
+		return 0
+	}
+	for i, common := range commonPasswords {
+		if password == common {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// caseVariations is the number of ways to capitalize a word an attacker tries
+func caseVariations(word string) float64 {
+	if word == strings.ToLower(word) {
+		return 1
+	}
+	return 2
+}
+
+// bruteforceGuesses counts the combinations of the character classes used,
+// where repeated characters and sequences like "aaa" or "abc" are nearly free
+func bruteforceGuesses(password string) float64 {
+	if len(password) == 0 {
+		return 1
+	}
+
+	cardinality := 0
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if hasLower {
+		cardinality += 26
+	}
+	if hasUpper {
+		cardinality += 26
+	}
+	if hasDigit {
+		cardinality += 10
+	}
+	if hasSymbol {
+		cardinality += 33
+	}
+
+	guesses := 1.0
+	runes := []rune(password)
+	inRun := false
+	var runDelta rune
+	for i, r := range runes {
+		if i > 0 {
+			delta := r - runes[i-1]
+			if delta >= -1 && delta <= 1 && (!inRun || delta == runDelta) {
+				// only whether it's a repeat or a sequence up or down is worth guessing
+				if !inRun {
+					guesses *= 3
+				}
+				inRun, runDelta = true, delta
+				continue
+			}
+		}
+		inRun = false
+		guesses *= float64(cardinality)
+	}
+	return math.Max(guesses, 1)
+}
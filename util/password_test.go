@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -13,16 +14,97 @@ func TestPassword(t *testing.T) {
 	hashPassword1, err := HashPassword(password)
 	require.NoError(t, err)
 	require.NotEmpty(t, hashPassword1)
+	require.True(t, strings.HasPrefix(hashPassword1, "$argon2id$v=19$m=19456,t=2,p=1$"))
 
 	err = CheckPassword(password, hashPassword1)
 	require.NoError(t, err)
 
 	wrongPassword := RandomString(6)
 	err = CheckPassword(wrongPassword, hashPassword1)
-	require.EqualError(t, err, bcrypt.ErrMismatchedHashAndPassword.Error())
+	require.ErrorIs(t, err, ErrMismatchedPassword)
 
 	hashPassword2, err := HashPassword(password)
 	require.NoError(t, err)
 	require.NotEmpty(t, hashPassword2)
 	require.NotEqual(t, hashPassword1, hashPassword2)
 }
+
+func TestPasswordLongerThanBcryptLimit(t *testing.T) {
+	password := RandomString(80)
+
+	hashedPassword, err := HashPassword(password)
+	require.NoError(t, err)
+
+	// bcrypt would ignore everything after the 72nd byte
+	err = CheckPassword(password[:72]+"x", hashedPassword)
+	require.ErrorIs(t, err, ErrMismatchedPassword)
+}
+
+func TestCheckBcryptPassword(t *testing.T) {
+	password := RandomString(6)
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	err = CheckPassword(password, string(hashedPassword))
+	require.NoError(t, err)
+
+	err = CheckPassword(RandomString(6), string(hashedPassword))
+	require.ErrorIs(t, err, ErrMismatchedPassword)
+
+	require.True(t, PasswordNeedsRehash(string(hashedPassword), DefaultPasswordParams))
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	weakParams := PasswordParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1}
+
+	hashedPassword, err := HashPasswordWithParams(RandomString(6), weakParams)
+	require.NoError(t, err)
+
+	require.False(t, PasswordNeedsRehash(hashedPassword, weakParams))
+	require.True(t, PasswordNeedsRehash(hashedPassword, DefaultPasswordParams))
+	require.True(t, PasswordNeedsRehash("$argon2id$v=19$m=invalid", DefaultPasswordParams))
+
+	// a single weaker parameter is enough
+	lessMemory := DefaultPasswordParams
+	lessMemory.Memory /= 2
+	hashedPassword, err = HashPasswordWithParams(RandomString(6), lessMemory)
+	require.NoError(t, err)
+	require.True(t, PasswordNeedsRehash(hashedPassword, DefaultPasswordParams))
+}
+
+func TestPasswordNeedsRehashStrongerParams(t *testing.T) {
+	strongParams := PasswordParams{
+		Memory:      DefaultPasswordParams.Memory * 2,
+		Iterations:  DefaultPasswordParams.Iterations + 1,
+		Parallelism: DefaultPasswordParams.Parallelism + 1,
+	}
+
+	hashedPassword, err := HashPasswordWithParams(RandomString(6), strongParams)
+	require.NoError(t, err)
+
+	require.False(t, PasswordNeedsRehash(hashedPassword, DefaultPasswordParams))
+}
+
+func TestCheckInvalidPasswordHash(t *testing.T) {
+	testCases := []string{
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA",
+		"$argon2id$v=18$m=19456,t=2,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$",
+	}
+
+	for _, hashedPassword := range testCases {
+		err := CheckPassword(RandomString(6), hashedPassword)
+		require.ErrorIs(t, err, errUnsupportedPasswordHash)
+	}
+}
+
+func TestNewPasswordParams(t *testing.T) {
+	require.Equal(t, DefaultPasswordParams, NewPasswordParams(Config{}))
+
+	params := NewPasswordParams(Config{PasswordArgon2Memory: 64 * 1024})
+	require.Equal(t, uint32(64*1024), params.Memory)
+	require.Equal(t, DefaultPasswordParams.Iterations, params.Iterations)
+	require.Equal(t, DefaultPasswordParams.Parallelism, params.Parallelism)
+}
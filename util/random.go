@@ -7,7 +7,10 @@ import (
 	"time"
 )
 
-const alphabet = "abcdefghijklmnopqrstuvwxyz"
+const (
+	alphabet         = "abcdefghijklmnopqrstuvwxyz"
+	passwordAlphabet = "0123456789!#%&*+-=?@^_~"
+)
 
 var r = rand.New(rand.NewSource(time.Now().UnixNano()))
 
@@ -29,6 +32,20 @@ func RandomString(n int) string {
 	return sb.String()
 }
 
+// RandomPassword generates a random password that passes the default password policy.
+// It has no letters, so it never contains a random username or email.
+func RandomPassword() string {
+	var sb strings.Builder
+	k := len(passwordAlphabet)
+
+	for i := 0; i < 12; i++ {
+		c := passwordAlphabet[r.Intn(k)]
+		sb.WriteByte(c)
+	}
+
+	return sb.String()
+}
+
 // RandomOwner generates a random owner name
 func RandomOwner() string {
 	return RandomString(6)
@@ -0,0 +1,8 @@
+package util
+
+// Constants for all user roles
+const (
+	DepositorRole = "depositor"
+	BankerRole    = "banker"
+	AdminRole     = "admin"
+)
@@ -0,0 +1,44 @@
+package util
+
+// Constants for all token scopes
+const (
+	AccountsReadScope   = "accounts:read"
+	AccountsWriteScope  = "accounts:write"
+	TransfersReadScope  = "transfers:read"
+	TransfersWriteScope = "transfers:write"
+	// UsersReadScope and UsersWriteScope cover the user's own profile and credentials:
+	// password, sessions, two-factor authentication and API keys
+	UsersReadScope  = "users:read"
+	UsersWriteScope = "users:write"
+	// AdminScope covers the admin routes, which also require the admin role
+	AdminScope = "admin"
+)
+
+// AllScopes returns every supported scope, granted to tokens that don't request specific ones
+func AllScopes() []string {
+	return []string{
+		AccountsReadScope,
+		AccountsWriteScope,
+		TransfersReadScope,
+		TransfersWriteScope,
+		UsersReadScope,
+		UsersWriteScope,
+		AdminScope,
+	}
+}
+
+// IsSupportedScope returns true if the scope is supported
+func IsSupportedScope(scope string) bool {
+	switch scope {
+	case AccountsReadScope, AccountsWriteScope, TransfersReadScope, TransfersWriteScope,
+		UsersReadScope, UsersWriteScope, AdminScope:
+		return true
+	default:
+		return false
+	}
+}
+
+// TOTPChallengeScope is only carried by the challenge tokens handed out after a correct password
+// for users with two-factor authentication. Such tokens can't be used for anything
+// but the exchange for an access token, and clients can't request the scope.
+const TOTPChallengeScope = "login:totp"
@@ -0,0 +1,26 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const secretCodeSize = 32
+
+// GenerateSecretCode returns a random URL-safe code for one-time links such as email verification
+func GenerateSecretCode() (string, error) {
+	b := make([]byte, secretCodeSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret code: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashSecretCode returns the SHA-256 hash of the secret code, the only form it's stored in
+func HashSecretCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
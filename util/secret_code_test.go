@@ -0,0 +1,22 @@
+package util
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretCode(t *testing.T) {
+	code1, err := GenerateSecretCode()
+	require.NoError(t, err)
+	require.Equal(t, code1, url.QueryEscape(code1))
+
+	code2, err := GenerateSecretCode()
+	require.NoError(t, err)
+	require.NotEqual(t, code1, code2)
+
+	require.Len(t, HashSecretCode(code1), 64)
+	require.Equal(t, HashSecretCode(code1), HashSecretCode(code1))
+	require.NotEqual(t, HashSecretCode(code1), HashSecretCode(code2))
+}
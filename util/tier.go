@@ -0,0 +1,18 @@
+package util
+
+// Constants for all user tiers, which transfer limits are set for
+const (
+	StandardTier = "standard"
+	PremiumTier  = "premium"
+	BusinessTier = "business"
+)
+
+// IsSupportedTier returns true if the tier is supported
+func IsSupportedTier(tier string) bool {
+	switch tier {
+	case StandardTier, PremiumTier, BusinessTier:
+		return true
+	default:
+		return false
+	}
+}
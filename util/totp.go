@@ -0,0 +1,132 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpSecretSize = 20
+	totpDigits     = 6
+	totpPeriod     = 30 * time.Second
+	// totpSkew is how many periods before and after the current one are accepted,
+	// to make up for clock drift between the server and the authenticator
+	totpSkew = 1
+
+	recoveryCodeSize = 10
+	// recoveryCodeAlphabet has 32 characters so each random byte maps to it without bias
+	recoveryCodeAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new random base32 encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPURI returns the otpauth URI that authenticator apps use to enroll the secret
+func TOTPURI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return uri.String()
+}
+
+// TOTPStep returns the time step of t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod.Seconds())
+}
+
+// TOTPCode returns the TOTP code of the secret at the given time step (RFC 6238)
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid totp secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// ValidateTOTP checks the code against the secret at time t.
+// It returns the time step the code belongs to, so callers can refuse to accept it twice.
+func ValidateTOTP(secret string, code string, t time.Time) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns n random single-use recovery codes of the form xxxxx-xxxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+		}
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[int(b[j])%len(recoveryCodeAlphabet)]
+		}
+		codes[i] = string(b[:recoveryCodeSize/2]) + "-" + string(b[recoveryCodeSize/2:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the SHA-256 hash of the normalized recovery code.
+// Like API keys, recovery codes are random enough for a fast hash,
+// which also lets them be looked up by hash.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
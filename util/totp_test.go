@@ -0,0 +1,88 @@
+package util
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA-1 secret of the RFC 6238 test vectors, base32 encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tC := range testCases {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tC.unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, tC.code, code)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := TOTPCode(secret, TOTPStep(now))
+	require.NoError(t, err)
+
+	step, ok := ValidateTOTP(secret, code, now)
+	require.True(t, ok)
+	require.Equal(t, TOTPStep(now), step)
+
+	// still accepted one period later to allow for clock drift
+	step, ok = ValidateTOTP(secret, code, now.Add(totpPeriod))
+	require.True(t, ok)
+	require.Equal(t, TOTPStep(now), step)
+
+	_, ok = ValidateTOTP(secret, code, now.Add(3*totpPeriod))
+	require.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now)
+	require.False(t, ok)
+
+	_, ok = ValidateTOTP("not base32!", code, now)
+	require.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("SimpleBank", "alice", rfc6238Secret))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", uri.Scheme)
+	require.Equal(t, "totp", uri.Host)
+	require.Equal(t, "/SimpleBank:alice", uri.Path)
+	require.Equal(t, rfc6238Secret, uri.Query().Get("secret"))
+	require.Equal(t, "SimpleBank", uri.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		require.Len(t, code, recoveryCodeSize+1)
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+
+	code := codes[0]
+	hashedCode := HashRecoveryCode(code)
+	require.NotEqual(t, code, hashedCode)
+	require.Equal(t, hashedCode, HashRecoveryCode(" "+code[:5]+code[6:]+" "))
+	require.NotEqual(t, hashedCode, HashRecoveryCode(codes[1]))
+}
@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/schedule"
+	"github.com/amrizal94/simplebank/util"
+)
+
+// ScheduledTransferWorker runs the scheduled transfers that are due.
+// Several workers can run at once, each transfer is claimed by only one of them.
+type ScheduledTransferWorker struct {
+	store       db.Store
+	interval    time.Duration
+	maxAttempts int32
+}
+
+// NewScheduledTransferWorker creates a new ScheduledTransferWorker
+func NewScheduledTransferWorker(store db.Store, config util.Config) *ScheduledTransferWorker {
+	return &ScheduledTransferWorker{
+		store:       store,
+		interval:    config.ScheduledTransferInterval,
+		maxAttempts: int32(config.ScheduledTransferMaxAttempts),
+	}
+}
+
+// Start runs the due scheduled transfers every interval until ctx is done
+func (worker *ScheduledTransferWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(worker.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := worker.RunDue(ctx); err != nil {
+			log.Printf("cannot run scheduled transfers: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue runs the scheduled transfers that are due until none is left and returns how many runs it made.
+// A failed run is retried on the next cycle.
+func (worker *ScheduledTransferWorker) RunDue(ctx context.Context) (int, error) {
+	runs := 0
+	for ctx.Err() == nil {
+		result, err := worker.store.RunScheduledTransferTx(ctx, db.RunScheduledTransferTxParams{
+			Next:        nextRun,
+			RetryAt:     time.Now().Add(worker.interval),
+			MaxAttempts: worker.maxAttempts,
+		})
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return runs, err
+		}
+
+		runs++
+		if result.Run.Status == db.ScheduledTransferRunFailed {
+			log.Printf("scheduled transfer %d failed attempt %d: %s", result.Run.ScheduledTransferID, result.Run.Attempt, result.Run.Error)
+		}
+	}
+	return runs, ctx.Err()
+}
+
+// nextRun returns the run of a scheduled transfer after the one due at the given time
+func nextRun(scheduledTransfer db.ScheduledTransfer, after time.Time) (time.Time, error) {
+	if scheduledTransfer.Schedule == "" {
+		return time.Time{}, nil
+	}
+
+	recurrence, err := schedule.Parse(scheduledTransfer.Schedule, scheduledTransfer.StartAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return recurrence.Next(after), nil
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/amrizal94/simplebank/db/mock"
+	db "github.com/amrizal94/simplebank/db/sqlc"
+	"github.com/amrizal94/simplebank/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	config := util.Config{
+		ScheduledTransferInterval:    time.Minute,
+		ScheduledTransferMaxAttempts: 3,
+	}
+
+	store := mockdb.NewMockStore(ctrl)
+	gomock.InOrder(
+		store.EXPECT().
+			RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+			Times(2).
+			DoAndReturn(func(_ interface{}, arg db.RunScheduledTransferTxParams) (db.RunScheduledTransferTxResult, error) {
+				require.Equal(t, int32(3), arg.MaxAttempts)
+				require.WithinDuration(t, time.Now().Add(time.Minute), arg.RetryAt, time.Second)
+				return db.RunScheduledTransferTxResult{Run: db.ScheduledTransferRun{Status: db.ScheduledTransferRunSucceeded}}, nil
+			}),
+		store.EXPECT().
+			RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(db.RunScheduledTransferTxResult{}, sql.ErrNoRows),
+	)
+
+	runs, err := NewScheduledTransferWorker(store, config).RunDue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, runs)
+}
+
+func TestRunDueError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.RunScheduledTransferTxResult{}, sql.ErrConnDone)
+
+	runs, err := NewScheduledTransferWorker(store, util.Config{ScheduledTransferInterval: time.Minute}).RunDue(context.Background())
+	require.ErrorIs(t, err, sql.ErrConnDone)
+	require.Zero(t, runs)
+}
+
+func TestNextRun(t *testing.T) {
+	startAt := time.Date(2023, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+	next, err := nextRun(db.ScheduledTransfer{StartAt: startAt, NextRunAt: startAt}, startAt)
+	require.NoError(t, err)
+	require.True(t, next.IsZero())
+
+	scheduledTransfer := db.ScheduledTransfer{
+		Schedule:  "0 9 * * *",
+		StartAt:   startAt,
+		NextRunAt: startAt.AddDate(0, 0, 3),
+	}
+	next, err = nextRun(scheduledTransfer, scheduledTransfer.NextRunAt)
+	require.NoError(t, err)
+	require.Equal(t, startAt.AddDate(0, 0, 4), next)
+
+	// the run after any time, not only the one the transfer is due for
+	next, err = nextRun(scheduledTransfer, startAt.AddDate(0, 0, 10).Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, startAt.AddDate(0, 0, 11), next)
+
+	_, err = nextRun(db.ScheduledTransfer{Schedule: "invalid", StartAt: startAt, NextRunAt: startAt}, startAt)
+	require.Error(t, err)
+}